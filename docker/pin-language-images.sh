@@ -0,0 +1,23 @@
+#!/bin/sh
+
+# 言語設定ファイルのイメージを，取得したイメージのダイジェストで固定した参照(<イメージ>:<タグ>@sha256:...)に書き換えるスクリプト
+# 使い方: ./docker/pin-language-images.sh [言語設定ファイル(既定: src/common/config/languages.json)]
+# docker と jq が必要．既にダイジェストで固定されているイメージは変更しない．
+set -eu
+
+config="${1:-$(dirname "$0")/../src/common/config/languages.json}"
+
+for image in $(jq -r '.languages[].image' "$config" | grep -v '@sha256:' | sort -u); do
+    docker pull --quiet "$image" > /dev/null
+
+    # イメージのリポジトリのダイジェスト(<リポジトリ>@sha256:...)からダイジェストを取り出し，タグを残したまま固定
+    digest=$(docker image inspect --format '{{index .RepoDigests 0}}' "$image")
+    pinned="${image}@${digest#*@}"
+
+    tmp=$(mktemp)
+    jq --indent 4 --arg image "$image" --arg pinned "$pinned" \
+        '(.languages[] | select(.image == $image) | .image) |= $pinned' "$config" > "$tmp"
+    cat "$tmp" > "$config" # 言語設定ファイルの権限を保つため，置き換えずに上書き
+    rm "$tmp"
+    echo "Pinned $image to $pinned"
+done
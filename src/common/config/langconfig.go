@@ -1,57 +1,219 @@
 package config
 
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
 // LanguageConfigは言語ごとの実行環境設定を保持する構造体である．
 // 各フィールドは特定のプログラミング言語におけるDockerイメージや実行コマンドなどを定義する．
+// コンパイル成果物は提出ごとに共有される /workspace/bin に出力され，全てのテストケースの実行で使い回される．
 type LanguageConfig struct {
-	LanguageID int    // 言語の一意識別子．
-	Image      string // 使用するDockerイメージの名前．
-	Compile    string // ソースコードをコンパイルするためのコマンド．コンパイルが不要な場合は空文字列．
-	Setup      string // 実行環境のセットアップに使用するコマンド．必要な環境変数の設定などを含む．
-	Run        string // コンパイル済みのプログラム，またはスクリプトを実行するためのコマンド．
-}
-
-// SupportedLanguagesはサポートされる各言語の設定をLanguageIDをキーとして保持するマップである．
-// このマップを通じて，言語ごとの実行環境設定にアクセスできる．
-var SupportedLanguages = map[int]LanguageConfig{
-	1: { // Python
-		LanguageID: 1,
-		Image:      "python:3.8-slim",
-		Compile:    "",
-		Run:        "python3 {code}",
-	},
-	2: { // C++
-		LanguageID: 2,
-		Image:      "gcc:latest",
-		Compile:    "g++ {code} -o /workspace/tmp/a.out",
-		Setup:      "export TMPDIR=/workspace/tmp &&",
-		Run:        "/workspace/tmp/a.out",
-	},
-	3: { // Go
-		LanguageID: 3,
-		Image:      "golang:latest",
-		Compile:    "go build -o /workspace/tmp/a.out {code}",
-		Setup:      "export TMPDIR=/workspace/tmp && export GOCACHE=/workspace/tmp/go-cache &&",
-		Run:        "/workspace/tmp/a.out",
-	},
-	4: { // Java
-		LanguageID: 4,
-		Image:      "openjdk:11",
-		Compile:    "javac {code}",
-		Setup:      "export TMPDIR=/workspace/tmp &&",
-		Run:        "java -cp /workspace/code Main",
-	},
-	5: { // Rust
-		LanguageID: 5,
-		Image:      "rust:latest",
-		Compile:    "rustc {code} -o /workspace/tmp/code",
-		Setup:      "export TMPDIR=/workspace/tmp &&",
-		Run:        "/workspace/tmp/code",
-	},
+	LanguageID     int     `json:"id"`                    // 言語の一意識別子．
+	Name           string  `json:"name"`                  // 言語の表示名．
+	Version        string  `json:"version"`               // 言語(コンパイラ，インタプリタ)のバージョンの表示名．
+	Image          string  `json:"image"`                 // 使用するDockerイメージの名前．
+	SourceFile     string  `json:"source_file,omitempty"` // 提出されたソースコードを保存するファイル名．空文字列の場合は "solution.拡張子" となる．
+	Extension      string  `json:"extension"`             // ソースファイルの拡張子．
+	Compile        string  `json:"compile"`               // ソースコードを /workspace/bin にコンパイルするためのコマンド．コンパイルが不要な場合は空文字列．
+	Setup          string  `json:"setup"`                 // 実行環境のセットアップに使用するコマンド．必要な環境変数の設定などを含む．
+	Run            string  `json:"run"`                   // コンパイル済みのプログラム，またはスクリプトを実行するためのコマンド．
+	TimeMultiplier float64 `json:"time_multiplier"`       // 実行時間制限に掛ける言語ごとの既定の倍率．問題ごとの倍率が設定されている場合はそちらが優先される．
+	Enabled        bool    `json:"enabled"`               // 新しい提出を受け付ける場合はtrue．
+}
+
+// SourceFileNameは，提出されたソースコードを保存するファイル名を返す．
+func (c LanguageConfig) SourceFileName() string {
+	if c.SourceFile != "" {
+		return c.SourceFile
+	}
+	return "solution." + c.Extension
+}
+
+// languageFileは，言語設定ファイルの形式である．
+type languageFile struct {
+	Languages []LanguageConfig `json:"languages"`
+}
+
+// defaultLanguagesは，言語設定ファイルが指定されていない場合に用いる既定の言語設定である．
+//
+//go:embed languages.json
+var defaultLanguages []byte
+
+// languageRegistryは，読み込まれた言語設定をLanguageIDをキーとして保持する．
+// 言語設定ファイルの再読み込みと並行してアクセスされるため，ロックにより保護される．
+var languageRegistry = struct {
+	sync.RWMutex
+	languages map[int]LanguageConfig
+}{}
+
+func init() {
+	// 既定の言語設定は埋め込まれたファイルから読み込むため，失敗した場合はビルドの誤りである
+	languages, err := parseLanguages(defaultLanguages)
+	if err != nil {
+		panic(fmt.Sprintf("invalid default language config: %v", err))
+	}
+	languageRegistry.languages = languages
+}
+
+// InitLanguagesは，環境変数 LANGUAGES_CONFIG_PATH で指定された言語設定ファイルを読み込み，変更を監視する関数である．
+// 環境変数が設定されていない場合は，埋め込まれた既定の言語設定を用いる．
+// 起動時の読み込みに失敗した場合はエラーを返し，以降の再読み込みに失敗した場合はログを出力して直前の設定を使い続ける．
+//
+// 戻り値:
+// - error: 言語設定ファイルの読み込みまたは検証に失敗した場合のエラー．
+func InitLanguages() error {
+	path := os.Getenv("LANGUAGES_CONFIG_PATH")
+	if path == "" {
+		return nil
+	}
+	if err := LoadLanguages(path); err != nil {
+		return err
+	}
+	go watchLanguages(path, 10*time.Second)
+	return nil
+}
+
+// LoadLanguagesは，指定されたパスの言語設定ファイルを読み込んで検証し，現在の言語設定を置き換える関数である．
+// 検証に失敗した場合は現在の言語設定を変更しない．
+//
+// パラメータ:
+// - path string: 言語設定ファイル(JSON)のパス．
+//
+// 戻り値:
+// - error: 読み込みまたは検証に失敗した場合のエラー．
+func LoadLanguages(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read language config: %v", err)
+	}
+	languages, err := parseLanguages(data)
+	if err != nil {
+		return fmt.Errorf("invalid language config %s: %v", path, err)
+	}
+
+	languageRegistry.Lock()
+	languageRegistry.languages = languages
+	languageRegistry.Unlock()
+	return nil
+}
+
+// watchLanguagesは，言語設定ファイルの更新日時を一定間隔で確認し，更新されていれば再読み込みする．
+func watchLanguages(path string, interval time.Duration) {
+	var lastModified time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().After(lastModified) {
+			continue
+		}
+		lastModified = info.ModTime()
+
+		if err := LoadLanguages(path); err != nil {
+			log.Printf("Failed to reload language config: %v", err)
+			continue
+		}
+		log.Printf("Reloaded language config: %s", path)
+	}
+}
+
+// parseLanguagesは，言語設定ファイルの内容を解析し，各言語の設定を検証する．
+func parseLanguages(data []byte) (map[int]LanguageConfig, error) {
+	var file languageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Languages) == 0 {
+		return nil, fmt.Errorf("no languages are defined")
+	}
+
+	languages := make(map[int]LanguageConfig, len(file.Languages))
+	for _, language := range file.Languages {
+		if err := validateLanguage(language); err != nil {
+			return nil, err
+		}
+		if _, ok := languages[language.LanguageID]; ok {
+			return nil, fmt.Errorf("duplicate language id: %d", language.LanguageID)
+		}
+		languages[language.LanguageID] = language
+	}
+	return languages, nil
+}
+
+// validateLanguageは，1つの言語の設定が実行に必要な値を満たしているか検証する．
+func validateLanguage(language LanguageConfig) error {
+	switch {
+	case language.LanguageID <= 0:
+		return fmt.Errorf("language id must be positive: %d", language.LanguageID)
+	case strings.TrimSpace(language.Name) == "":
+		return fmt.Errorf("language %d: name is required", language.LanguageID)
+	case strings.TrimSpace(language.Image) == "":
+		return fmt.Errorf("language %d: image is required", language.LanguageID)
+	case strings.TrimSpace(language.Run) == "":
+		return fmt.Errorf("language %d: run command is required", language.LanguageID)
+	case language.Extension == "" && language.SourceFile == "":
+		return fmt.Errorf("language %d: extension or source_file is required", language.LanguageID)
+	case language.SourceFile != "" && filepath.Base(language.SourceFile) != language.SourceFile:
+		return fmt.Errorf("language %d: source_file must be a file name: %s", language.LanguageID, language.SourceFile)
+	case language.TimeMultiplier < 0:
+		return fmt.Errorf("language %d: time_multiplier must not be negative", language.LanguageID)
+	}
+	return nil
 }
 
 // GetLanguageConfigByIDは指定されたLanguageIDに対応するLanguageConfigを返す関数である．
 // 指定されたIDの設定が存在する場合はその設定とtrueを，存在しない場合はfalseを返す．
+// 無効化された言語の設定も返すため，新しい提出を受け付けるかは Enabled で判断する．
 func GetLanguageConfigByID(languageID int) (LanguageConfig, bool) {
-	config, ok := SupportedLanguages[languageID]
+	languageRegistry.RLock()
+	defer languageRegistry.RUnlock()
+	config, ok := languageRegistry.languages[languageID]
 	return config, ok
 }
+
+// GetEnabledLanguagesは，新しい提出を受け付ける言語の設定をLanguageIDの昇順で返す関数である．
+func GetEnabledLanguages() []LanguageConfig {
+	languageRegistry.RLock()
+	defer languageRegistry.RUnlock()
+
+	languages := make([]LanguageConfig, 0, len(languageRegistry.languages))
+	for _, language := range languageRegistry.languages {
+		if language.Enabled {
+			languages = append(languages, language)
+		}
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i].LanguageID < languages[j].LanguageID
+	})
+	return languages
+}
+
+// GetLanguagesは，無効化された言語を含む全ての言語の設定をLanguageIDの昇順で返す関数である．
+// 無効化された言語の既存の解答も再判定できるよう，ジャッジサーバーが判定できる言語の一覧に用いる．
+func GetLanguages() []LanguageConfig {
+	languageRegistry.RLock()
+	defer languageRegistry.RUnlock()
+
+	languages := make([]LanguageConfig, 0, len(languageRegistry.languages))
+	for _, language := range languageRegistry.languages {
+		languages = append(languages, language)
+	}
+	sort.Slice(languages, func(i, j int) bool {
+		return languages[i].LanguageID < languages[j].LanguageID
+	})
+	return languages
+}
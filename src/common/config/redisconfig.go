@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// RedisConfigはRedisに接続するための設定と，Redis Streamsを用いた判定キューの設定を保持する構造体である．
+// 環境変数から設定値を読み込み，未設定または不正な値の場合は既定値を使用する．
+type RedisConfig struct {
+	Addr          string        // Redisサーバーのアドレス．
+	Password      string        // Redisサーバーへの接続に使用するパスワード．
+	MaxDeliveries int64         // 判定リクエストと判定結果を配信する最大回数．超えた場合はデッドレターのストリームに移される．
+	ClaimIdle     time.Duration // 処理中のまま応答がないメッセージを，他のコンシューマーが引き継ぐまでの時間．
+	MaxMessageAge time.Duration // 判定リクエストに署名してから判定を開始するまでの最大時間．超えた判定リクエストは再送として拒否される．
+}
+
+// NewRedisConfigはRedisConfigの新しいインスタンスを生成し，環境変数から設定値を読み込んで返す関数である．
+func NewRedisConfig() *RedisConfig {
+	config := &RedisConfig{
+		Addr:          os.Getenv("REDIS_ADDR"),
+		Password:      os.Getenv("REDIS_PASSWORD"),
+		MaxDeliveries: 3,
+		ClaimIdle:     time.Minute,
+		MaxMessageAge: 24 * time.Hour,
+	}
+	if config.Addr == "" {
+		config.Addr = "redis:6379"
+	}
+	if value, err := strconv.ParseInt(os.Getenv("JUDGE_QUEUE_MAX_DELIVERIES"), 10, 64); err == nil && value > 0 {
+		config.MaxDeliveries = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("JUDGE_QUEUE_CLAIM_IDLE_SECONDS")); err == nil && value > 0 {
+		config.ClaimIdle = time.Duration(value) * time.Second
+	}
+	if value, err := strconv.Atoi(os.Getenv("JUDGE_QUEUE_MAX_MESSAGE_AGE_SECONDS")); err == nil && value > 0 {
+		config.MaxMessageAge = time.Duration(value) * time.Second
+	}
+	return config
+}
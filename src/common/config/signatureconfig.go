@@ -0,0 +1,26 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// SignatureConfigはwebサーバーとジャッジサーバーの間の通信に署名するための設定を保持する構造体である．
+// 環境変数から設定値を読み込み，未設定または不正な値の場合は既定値を使用する．
+type SignatureConfig struct {
+	Secret  string        // HMAC-SHA256の署名に用いる共有鍵．webサーバーと全てのジャッジサーバーで同じ値を設定する．
+	MaxSkew time.Duration // 署名されたリクエストの日時と受信した日時の差の許容範囲．これを超えるリクエストは再送として拒否される．
+}
+
+// NewSignatureConfigはSignatureConfigの新しいインスタンスを生成し，環境変数から設定値を読み込んで返す関数である．
+func NewSignatureConfig() *SignatureConfig {
+	config := &SignatureConfig{
+		Secret:  os.Getenv("JUDGE_SHARED_SECRET"),
+		MaxSkew: 5 * time.Minute,
+	}
+	if value, err := strconv.Atoi(os.Getenv("JUDGE_SIGNATURE_MAX_SKEW_SECONDS")); err == nil && value > 0 {
+		config.MaxSkew = time.Duration(value) * time.Second
+	}
+	return config
+}
@@ -24,6 +24,22 @@ func NewAccessDeniedError(message string) *AccessDeniedError {
 	}
 }
 
+// SignatureError - webサーバーとジャッジサーバーの間のリクエストの署名の検証エラー
+type SignatureError struct {
+	Message string // エラーの詳細メッセージ
+}
+
+func (e *SignatureError) Error() string {
+	return e.Message
+}
+
+// NewSignatureError - 新しい署名の検証エラーを生成
+func NewSignatureError(message string) *SignatureError {
+	return &SignatureError{
+		Message: message,
+	}
+}
+
 // TokenError - JWTトークン関連のエラー
 type TokenError struct {
 	Type    string // エラーのタイプを示す（"InvalidToken", "TokenExpired" など）
@@ -61,6 +61,8 @@ func ErrorToAPIError(err error) *APIError {
 		return NewAPIError(http.StatusBadRequest, e.Error())
 	case *AccessDeniedError:
 		return NewAPIError(http.StatusForbidden, e.Error())
+	case *SignatureError:
+		return NewAPIError(http.StatusUnauthorized, e.Error())
 	case *DataMismatchError:
 		return NewAPIError(http.StatusBadRequest, "Invalid data format")
 	default:
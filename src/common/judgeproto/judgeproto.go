@@ -0,0 +1,76 @@
+package judgeproto
+
+import (
+	"time"
+)
+
+// イベントの種類を表す定数群である．JudgeEventのいずれか1つのイベントのフィールドに対応する．
+const (
+	EventAccepted     = "accepted"      // ジャッジノードのワーカーが判定を開始した．
+	EventCompiled     = "compiled"      // コンパイルが終了した．
+	EventCaseFinished = "case_finished" // 1つのテストケースの判定が終了した．
+	EventDone         = "done"          // 判定が終了した．
+)
+
+// JudgeEventは，ジャッジサーバーがwebサーバーに判定の進捗を通知する1つのイベントである．
+// 各型は同じディレクトリのjudge.protoのスキーマと対応し，proto3のJSON表現（フィールド名はスキーマの名前のまま）でエンコードされる．
+// Accepted，Compiled，CaseFinished，Doneのうち，いずれか1つのみが設定される．
+type JudgeEvent struct {
+	RequestID  string    `json:"request_id"`        // 判定の依頼の一意識別子である．
+	SolutionID int       `json:"solution_id"`       // 判定している解答のIDである．
+	NodeID     string    `json:"node_id,omitempty"` // 判定しているジャッジノードのIDである．
+	Sequence   int       `json:"sequence"`          // 1回の判定の中でのイベントの通し番号（1から始まる）である．
+	Timestamp  time.Time `json:"timestamp"`         // イベントが発生した日時である．
+
+	Accepted     *Accepted     `json:"accepted,omitempty"`
+	Compiled     *Compiled     `json:"compiled,omitempty"`
+	CaseFinished *CaseFinished `json:"case_finished,omitempty"`
+	Done         *Done         `json:"done,omitempty"`
+}
+
+// Acceptedは，ジャッジノードのワーカーが判定を開始したことを表すイベントである．
+type Accepted struct {
+	Attempt int `json:"attempt"` // 判定の試行回数（判定キューでの配信回数，1から始まる）である．
+}
+
+// Compiledは，コンパイルが終了したことを表すイベントである．
+type Compiled struct {
+	Success    bool `json:"success"`     // コンパイルに成功した場合はtrueである．
+	TotalCases int  `json:"total_cases"` // 実行するテストケースの総数である．
+}
+
+// CaseFinishedは，1つのテストケースの判定が終了したことを表すイベントである．
+// テストケースを並列に実行する問題では，Indexの順ではなく判定が終了した順に送信される．
+type CaseFinished struct {
+	Index         int           `json:"index"`          // テストケースの番号（0から始まる，判定結果のテストケースの順）である．
+	Done          int           `json:"done"`           // 判定が終了したテストケースの数（このテストケースを含む）である．
+	TotalCases    int           `json:"total_cases"`    // テストケースの総数である．
+	CaseName      string        `json:"case_name"`      // テストケースの名前である．
+	Verdict       string        `json:"verdict"`        // テストケースの判定結果（"AC", "WA", "TLE" など）である．
+	ExecutionTime time.Duration `json:"execution_time"` // 実行時間（ウォールタイム，ナノ秒）である．
+	CPUTime       time.Duration `json:"cpu_time"`       // CPU時間（ナノ秒）である．
+	PeakMemory    int64         `json:"peak_memory"`    // ピークメモリ使用量（バイト）である．
+}
+
+// Doneは，判定が終了したことを表すイベントである．判定結果の全体は，判定結果のストリームを通じて別に送信される．
+type Done struct {
+	Verdict string  `json:"verdict,omitempty"` // 解答全体の判定結果である（判定に失敗した場合は空）．
+	Score   float64 `json:"score,omitempty"`   // 得点である（サブタスクが設定されている場合）．
+	Error   string  `json:"error,omitempty"`   // 判定に失敗した場合の理由である．
+	Retry   bool    `json:"retry,omitempty"`   // 判定に失敗し，再試行される場合はtrueである．
+}
+
+// Typeは，イベントの種類（EventAccepted など）を返す関数である．いずれのイベントも設定されていない場合は空文字列を返す．
+func (e JudgeEvent) Type() string {
+	switch {
+	case e.Accepted != nil:
+		return EventAccepted
+	case e.Compiled != nil:
+		return EventCompiled
+	case e.CaseFinished != nil:
+		return EventCaseFinished
+	case e.Done != nil:
+		return EventDone
+	}
+	return ""
+}
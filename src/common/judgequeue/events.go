@@ -0,0 +1,75 @@
+package judgequeue
+
+import (
+	"context"
+	"errors"
+	"procon_web_service/src/common/judgeproto"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// 判定の進捗のイベントを受け渡すストリームの設定である．
+// イベントは判定の依頼ごとのストリームに追加され，コンシューマーグループを用いずに，判定を待っている全てのwebサーバーが読み込める．
+const (
+	eventStreamPrefix  = "judge:events:"  // 判定の進捗のイベント（判定の依頼の一意識別子を付加）
+	maxEventStreamSize = 10000            // 判定の依頼ごとに保持するイベントの概数の上限
+	eventStreamTTL     = 30 * time.Minute // 最後のイベントの追加からイベントを保持する時間
+)
+
+// PublishEventは，判定の進捗のイベントを判定の依頼ごとのストリームに追加する関数である．
+// ストリームは最後のイベントの追加から一定時間後に削除される．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - event judgeproto.JudgeEvent: 追加するイベント．
+//
+// 戻り値:
+// - error: 追加に失敗した場合のエラー，またはnil．
+func (q *Queue) PublishEvent(ctx context.Context, event judgeproto.JudgeEvent) error {
+	stream := eventStream(event.RequestID)
+	if err := q.addWithLimit(ctx, stream, maxEventStreamSize, event.RequestID, event); err != nil {
+		return err
+	}
+	return q.client.Expire(ctx, stream, eventStreamTTL).Err()
+}
+
+// ReadEventsは，判定の依頼の進捗のイベントのうち，afterIDより後に追加されたものを取り出す関数である．
+// afterIDに"0"を指定すると最初のイベントから取り出す．新しいイベントがない場合は最大blockの間待機し（blockが負の場合は待機しない），それでもない場合は空のリストを返す．
+// 取り出したイベントはストリームに残るため，次に呼び出す際は最後に取り出したメッセージのIDをafterIDに指定する．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - requestID string: 判定の依頼の一意識別子．
+// - afterID string: 最後に取り出したメッセージのID．
+// - block time.Duration: 新しいイベントを待つ最大時間．
+//
+// 戻り値:
+// - []Message: 取り出したメッセージのリスト．
+// - error: 取り出しに失敗した場合のエラー，またはnil．
+func (q *Queue) ReadEvents(ctx context.Context, requestID, afterID string, block time.Duration) ([]Message, error) {
+	stream := eventStream(requestID)
+	result, err := q.client.XRead(ctx, &redis.XReadArgs{
+		Streams: []string{stream, afterID},
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, s := range result {
+		for _, message := range s.Messages {
+			messages = append(messages, q.newMessage(s.Stream, "", message))
+		}
+	}
+	return messages, nil
+}
+
+// eventStreamは，判定の依頼の進捗のイベントを追加するストリームの名前を返す．
+func eventStream(requestID string) string {
+	return eventStreamPrefix + requestID
+}
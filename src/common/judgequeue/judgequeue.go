@@ -0,0 +1,533 @@
+package judgequeue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/signature"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis Streamsのストリームとコンシューマーグループの名前などの定数群である．
+// 判定リクエストはジャッジノードごとのストリーム，またはいずれのジャッジノードにも割り当てない場合は優先度ごとのストリームに追加される．
+// ジャッジノードは自身のストリーム，優先度の高いストリーム，優先度の低いストリームの順に取り出す．
+const (
+	highPriorityStream = "judge:requests:high"  // 通常の提出の判定リクエスト（優先度が0以上）
+	lowPriorityStream  = "judge:requests:low"   // 再判定などの優先度の低い判定リクエスト（優先度が負）
+	nodeStreamPrefix   = "judge:requests:node:" // 特定のジャッジノードに割り当てた判定リクエスト（ジャッジノードのIDを付加）
+	resultStream       = "judge:results"        // ジャッジサーバーからwebサーバーへの判定結果
+	deadLetterStream   = "judge:dead"           // 規定の回数処理に失敗したメッセージ
+
+	judgeGroup = "judge-servers" // 判定リクエストを取り出すジャッジサーバーのグループ
+	webGroup   = "web-servers"   // 判定結果を取り出すwebサーバーのグループ
+
+	dataField      = "data"       // メッセージの内容（JSON）を格納するフィールド
+	requestIDField = "request_id" // メッセージが対象とする判定の依頼の一意識別子を格納するフィールド
+	signedAtField  = "signed_at"  // メッセージに署名した日時（Unix時間，秒）を格納するフィールド
+	signatureField = "signature"  // 判定の依頼の一意識別子，署名した日時，メッセージの内容の署名を格納するフィールド
+	reasonField    = "reason"     // デッドレターに移した理由を格納するフィールド
+
+	requestEntryPrefix = "judge:request-entry:" // 判定の依頼を現在運んでいる判定リクエストのメッセージ（判定の依頼の一意識別子を付加）
+
+	maxStreamLength = 100000          // 各ストリームに保持するメッセージの概数の上限
+	readBlock       = 2 * time.Second // 新しいメッセージを待つ最大時間
+)
+
+// Queueは，webサーバーとジャッジサーバーの間で判定リクエストと判定結果を受け渡す永続的なキューである．
+// メッセージはRedis Streamsのコンシューマーグループを通じて配信され，Ackされるまで処理中として保持される．
+// 処理中のまま一定時間応答がない（コンシューマーが停止した）メッセージは他のコンシューマーに再配信され，
+// 配信回数が上限を超えたメッセージは呼び出し元がデッドレターのストリームに移す．
+// 全てのメッセージは判定の依頼の一意識別子と署名した日時とともに共有鍵で署名され，署名が正しくないメッセージ（Redisに直接追加されたものなど）はデコードできない．
+// 判定リクエストは追加するたびに，その判定の依頼を運ぶメッセージとして記録され，ジャッジサーバーは記録と異なるメッセージ（再送されたもの）と古いメッセージを拒否する．
+type Queue struct {
+	client        *redis.Client
+	signer        *signature.Signer
+	consumer      string
+	maxDeliveries int64
+	claimIdle     time.Duration
+	maxAge        time.Duration
+
+	mu        sync.Mutex
+	lastClaim map[string]time.Time // ストリームごとに，応答のないメッセージの引き継ぎを最後に確認した時刻
+}
+
+// ErrInvalidSignatureは，メッセージの署名が正しくない場合のエラーである．
+var ErrInvalidSignature = errors.New("invalid message signature")
+
+// ErrStaleMessageは，判定リクエストに署名してから判定を開始するまでの最大時間を超えた場合のエラーである．
+var ErrStaleMessage = errors.New("judge request is too old")
+
+// ErrReplayedMessageは，判定リクエストが判定の依頼を運ぶメッセージとして記録されたものと異なる（再送された）場合のエラーである．
+var ErrReplayedMessage = errors.New("judge request has already been delivered by another message")
+
+// Messageは，キューから取り出した1つのメッセージを表す構造体である．
+type Message struct {
+	ID        string    // メッセージのID．
+	RequestID string    // メッセージが対象とする判定の依頼の一意識別子（署名の対象）．
+	SignedAt  time.Time // メッセージに署名した日時（署名の対象）．
+	stream    string
+	group     string
+	data      string
+	verified  bool // 署名が正しい場合はtrue
+}
+
+// Newは，環境変数の設定に基づいてRedisに接続するキューを生成する関数である．
+// コンシューマー名にはホスト名とプロセスIDを用い，同じホストで複数のプロセスが動作する場合も区別する．
+//
+// パラメータ:
+// - signer *signature.Signer: メッセージの署名と検証に用いるSigner．
+//
+// 戻り値:
+// - *Queue: 生成されたキュー．
+func New(signer *signature.Signer) *Queue {
+	redisConfig := config.NewRedisConfig()
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return &Queue{
+		client: redis.NewClient(&redis.Options{
+			Addr:     redisConfig.Addr,
+			Password: redisConfig.Password,
+		}),
+		signer:        signer,
+		consumer:      fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		maxDeliveries: redisConfig.MaxDeliveries,
+		claimIdle:     redisConfig.ClaimIdle,
+		maxAge:        redisConfig.MaxMessageAge,
+		lastClaim:     make(map[string]time.Time),
+	}
+}
+
+// EnsureGroupsは，全てのストリームとコンシューマーグループを作成する関数である．
+// 既に存在する場合は何もしない．グループは先頭から読み込むよう作成するため，グループの作成前に追加されたメッセージも配信される．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+//
+// 戻り値:
+// - error: 作成に失敗した場合のエラー，またはnil．
+func (q *Queue) EnsureGroups(ctx context.Context) error {
+	groups := map[string]string{
+		highPriorityStream: judgeGroup,
+		lowPriorityStream:  judgeGroup,
+		resultStream:       webGroup,
+	}
+	for stream, group := range groups {
+		if err := q.ensureGroup(ctx, stream, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureGroupは，ストリームとコンシューマーグループを作成する．既に存在する場合は何もしない．
+func (q *Queue) ensureGroup(ctx context.Context, stream, group string) error {
+	err := q.client.XGroupCreateMkStream(ctx, stream, group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s for %s: %v", group, stream, err)
+	}
+	return nil
+}
+
+// MaxDeliveriesは，メッセージを配信する最大回数を返す関数である．
+func (q *Queue) MaxDeliveries() int64 {
+	return q.maxDeliveries
+}
+
+// ClaimIdleは，処理中のまま応答がないメッセージを他のコンシューマーが引き継ぐまでの時間を返す関数である．
+// 処理に時間のかかるメッセージは，この時間より短い間隔でExtendを呼び出す必要がある．
+func (q *Queue) ClaimIdle() time.Duration {
+	return q.claimIdle
+}
+
+// Enqueueは，判定リクエストを優先度に応じたストリームに追加する関数である．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - job models.JudgeJob: 追加する判定リクエスト．
+//
+// 戻り値:
+// - error: 追加に失敗した場合のエラー，またはnil．
+func (q *Queue) Enqueue(ctx context.Context, job models.JudgeJob) error {
+	stream := highPriorityStream
+	if job.Request.Priority < 0 {
+		stream = lowPriorityStream
+	}
+	return q.addJob(ctx, stream, job)
+}
+
+// PublishResultは，判定結果をwebサーバーが取り出すストリームに追加する関数である．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - result models.JudgeJobResult: 追加する判定結果．
+//
+// 戻り値:
+// - error: 追加に失敗した場合のエラー，またはnil．
+func (q *Queue) PublishResult(ctx context.Context, result models.JudgeJobResult) error {
+	return q.add(ctx, resultStream, result.RequestID, result)
+}
+
+// ReadJobsは，ジャッジノードに割り当てられた判定リクエストと，いずれのジャッジノードにも割り当てられていない判定リクエストを最大count件取り出す関数である．
+// ジャッジノード自身のストリーム，優先度の高いストリーム，優先度の低いストリームの順に取り出し，sharedがfalseの場合は自身のストリームからのみ取り出す．
+// 他のジャッジサーバーが処理中のまま応答がない判定リクエストがあれば，それを優先して引き継ぐ．
+// 新しい判定リクエストがない場合は一定時間待機し，それでもない場合は空のリストを返す．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - count int64: 取り出す最大件数．
+// - nodeID string: 判定リクエストを取り出すジャッジノードのID．
+// - shared bool: いずれのジャッジノードにも割り当てられていない判定リクエストも取り出す場合はtrue．
+//
+// 戻り値:
+// - []Message: 取り出したメッセージのリスト．
+// - error: 取り出しに失敗した場合のエラー，またはnil．
+func (q *Queue) ReadJobs(ctx context.Context, count int64, nodeID string, shared bool) ([]Message, error) {
+	streams := []string{nodeStream(nodeID)}
+	if shared {
+		streams = append(streams, highPriorityStream, lowPriorityStream)
+	}
+	return q.read(ctx, judgeGroup, streams, count)
+}
+
+// ReadResultsは，判定結果を最大count件取り出す関数である．
+// 他のwebサーバーが処理中のまま応答がない判定結果があれば，それを優先して引き継ぐ．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - count int64: 取り出す最大件数．
+//
+// 戻り値:
+// - []Message: 取り出したメッセージのリスト．
+// - error: 取り出しに失敗した場合のエラー，またはnil．
+func (q *Queue) ReadResults(ctx context.Context, count int64) ([]Message, error) {
+	return q.read(ctx, webGroup, []string{resultStream}, count)
+}
+
+// Decodeは，メッセージの内容をJSONとしてデコードする関数である．
+//
+// パラメータ:
+// - v interface{}: デコード先の値へのポインタ．
+//
+// 戻り値:
+// - error: 署名が正しくない場合はErrInvalidSignature，デコードに失敗した場合はそのエラー，またはnil．
+func (m Message) Decode(v interface{}) error {
+	if !m.verified {
+		return ErrInvalidSignature
+	}
+	return json.Unmarshal([]byte(m.data), v)
+}
+
+// CheckJobは，取り出した判定リクエストが判定してよいものかを確認する関数である．
+// 署名した日時から判定を開始するまでの最大時間を超えている場合はErrStaleMessageを，
+// 判定の依頼を運ぶメッセージとして記録されたものと異なる（Redisに再送された）場合はErrReplayedMessageを返す．
+// 同じメッセージの再配信（応答のないメッセージの引き継ぎ）は拒否しない．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - message Message: 取り出した判定リクエスト．
+//
+// 戻り値:
+// - error: 判定してはならない場合のエラー，確認に失敗した場合のエラー，またはnil．
+func (q *Queue) CheckJob(ctx context.Context, message Message) error {
+	if !message.verified {
+		return ErrInvalidSignature
+	}
+	if time.Since(message.SignedAt) > q.maxAge {
+		return ErrStaleMessage
+	}
+
+	// 記録がない場合（判定リクエストを追加した直後に記録が失われた場合など）は，最初に取り出したメッセージを記録する
+	key := requestEntryKey(message.RequestID)
+	entry := requestEntry(message.stream, message.ID)
+	claimed, err := q.client.SetNX(ctx, key, entry, q.requestEntryTTL()).Result()
+	if err != nil || claimed {
+		return err
+	}
+	current, err := q.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != entry {
+		return ErrReplayedMessage
+	}
+	return nil
+}
+
+// Ackは，メッセージの処理が完了したことを記録する関数である．Ackしたメッセージは再配信されない．
+// ジャッジノードごとのストリームのメッセージは，ストリームの長さを未処理の判定リクエストの数として扱えるよう削除する．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - message Message: 処理が完了したメッセージ．
+//
+// 戻り値:
+// - error: 記録に失敗した場合のエラー，またはnil．
+func (q *Queue) Ack(ctx context.Context, message Message) error {
+	if err := q.client.XAck(ctx, message.stream, message.group, message.ID).Err(); err != nil {
+		return err
+	}
+	if strings.HasPrefix(message.stream, nodeStreamPrefix) {
+		return q.client.XDel(ctx, message.stream, message.ID).Err()
+	}
+	return nil
+}
+
+// Extendは，処理中のメッセージの応答のない時間をリセットし，他のコンシューマーに引き継がれないようにする関数である．
+// 配信回数は増加しない．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - message Message: 処理中のメッセージ．
+//
+// 戻り値:
+// - error: 操作に失敗した場合のエラー，またはnil．
+func (q *Queue) Extend(ctx context.Context, message Message) error {
+	return q.client.XClaimJustID(ctx, &redis.XClaimArgs{
+		Stream:   message.stream,
+		Group:    message.group,
+		Consumer: q.consumer,
+		Messages: []string{message.ID},
+	}).Err()
+}
+
+// Deliveriesは，メッセージがこれまでに配信された回数（今回の配信を含む）を取得する関数である．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - message Message: 処理中のメッセージ．
+//
+// 戻り値:
+// - int64: 配信された回数．
+// - error: 取得に失敗した場合のエラー，またはnil．
+func (q *Queue) Deliveries(ctx context.Context, message Message) (int64, error) {
+	pending, err := q.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: message.stream,
+		Group:  message.group,
+		Start:  message.ID,
+		End:    message.ID,
+		Count:  1,
+	}).Result()
+	if err != nil {
+		return 0, err
+	}
+	if len(pending) == 0 {
+		return 0, fmt.Errorf("message %s is not pending", message.ID)
+	}
+	return pending[0].RetryCount, nil
+}
+
+// DeadLetterは，処理できなかったメッセージを理由とともにデッドレターのストリームに移し，Ackする関数である．
+// デッドレターのストリームのメッセージは自動では再処理されず，障害の調査に用いる．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - message Message: 処理できなかったメッセージ．
+// - reason string: 処理できなかった理由．
+//
+// 戻り値:
+// - error: 操作に失敗した場合のエラー，またはnil．
+func (q *Queue) DeadLetter(ctx context.Context, message Message, reason string) error {
+	values := map[string]interface{}{
+		dataField:      message.data,
+		requestIDField: message.RequestID,
+		reasonField:    reason,
+		"stream":       message.stream,
+		"id":           message.ID,
+	}
+	if err := q.client.XAdd(ctx, &redis.XAddArgs{Stream: deadLetterStream, MaxLen: maxStreamLength, Approx: true, Values: values}).Err(); err != nil {
+		return err
+	}
+	return q.Ack(ctx, message)
+}
+
+// addJobScriptは，判定リクエストをストリームに追加し，その判定の依頼を運ぶメッセージとして記録するスクリプトである．
+// ジャッジサーバーが追加と記録の間に取り出して再送と誤認しないよう，追加と記録をアトミックに行う．
+var addJobScript = redis.NewScript(`
+local id = redis.call('XADD', KEYS[1], 'MAXLEN', '~', ARGV[1], '*', unpack(ARGV, 3))
+redis.call('SET', KEYS[2], KEYS[1] .. ' ' .. id, 'PX', ARGV[2])
+return id
+`)
+
+// addは，値をJSONにエンコードし，判定の依頼の一意識別子と署名とともにストリームに追加する．
+func (q *Queue) add(ctx context.Context, stream, requestID string, v interface{}) error {
+	return q.addWithLimit(ctx, stream, maxStreamLength, requestID, v)
+}
+
+// addWithLimitは，値をJSONにエンコードし，判定の依頼の一意識別子と署名とともにストリームに追加する．ストリームには概ねmaxLen件までのメッセージを保持する．
+func (q *Queue) addWithLimit(ctx context.Context, stream string, maxLen int64, requestID string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return q.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, MaxLen: maxLen, Approx: true, Values: q.signedValues(requestID, string(data))}).Err()
+}
+
+// addJobは，判定リクエストを署名とともにストリームに追加し，その判定の依頼を運ぶメッセージとして記録する．
+// 以前に同じ判定の依頼を運んでいたメッセージ（他のジャッジノードに割り当て直す前のものなど）は，以降は再送として拒否される．
+func (q *Queue) addJob(ctx context.Context, stream string, job models.JudgeJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return q.addJobData(ctx, stream, job.RequestID, string(data))
+}
+
+// addJobDataは，JSONにエンコードされた判定リクエストを署名とともにストリームに追加し，その判定の依頼を運ぶメッセージとして記録する．
+func (q *Queue) addJobData(ctx context.Context, stream, requestID, data string) error {
+	args := []interface{}{maxStreamLength, q.requestEntryTTL().Milliseconds()}
+	for field, value := range q.signedValues(requestID, data) {
+		args = append(args, field, value)
+	}
+	return addJobScript.Run(ctx, q.client, []string{stream, requestEntryKey(requestID)}, args...).Err()
+}
+
+// signedValuesは，メッセージの内容，判定の依頼の一意識別子，署名した日時とそれらの署名から成るストリームのフィールドを生成する．
+func (q *Queue) signedValues(requestID, data string) map[string]interface{} {
+	signedAt := strconv.FormatInt(time.Now().Unix(), 10)
+	return map[string]interface{}{
+		dataField:      data,
+		requestIDField: requestID,
+		signedAtField:  signedAt,
+		signatureField: q.signer.Sign(canonicalMessage(requestID, signedAt, data)),
+	}
+}
+
+// requestEntryTTLは，判定の依頼を運ぶメッセージの記録を保持する時間を返す．
+// 記録が失われた後の再送は署名した日時が古いため拒否されるよう，判定を開始するまでの最大時間より長く保持する．
+func (q *Queue) requestEntryTTL() time.Duration {
+	return q.maxAge + q.claimIdle
+}
+
+// readは，応答のないメッセージの引き継ぎ，新しいメッセージの取り出し（ストリームの順に優先），新しいメッセージの待機の順にメッセージを取り出す．
+func (q *Queue) read(ctx context.Context, group string, streams []string, count int64) ([]Message, error) {
+	for _, stream := range streams {
+		messages, err := q.claimStale(ctx, group, stream, count)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+	}
+
+	// 優先度の高いストリームから順に，待機せずに取り出す
+	for _, stream := range streams {
+		messages, err := q.readGroup(ctx, group, []string{stream}, count, -1)
+		if err != nil || len(messages) > 0 {
+			return messages, err
+		}
+	}
+
+	// いずれのストリームにもない場合は，新しいメッセージが追加されるまで待機
+	return q.readGroup(ctx, group, streams, count, readBlock)
+}
+
+// readGroupは，コンシューマーグループとして新しいメッセージを取り出す（blockが負の場合は待機しない）．
+func (q *Queue) readGroup(ctx context.Context, group string, streams []string, count int64, block time.Duration) ([]Message, error) {
+	args := make([]string, 0, len(streams)*2)
+	args = append(args, streams...)
+	for range streams {
+		args = append(args, ">")
+	}
+	result, err := q.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: q.consumer,
+		Streams:  args,
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []Message
+	for _, stream := range result {
+		for _, message := range stream.Messages {
+			messages = append(messages, q.newMessage(stream.Stream, group, message))
+		}
+	}
+	return messages, nil
+}
+
+// claimStaleは，処理中のまま応答のない時間がClaimIdleを超えたメッセージを引き継ぐ．
+// 確認はストリームごとにClaimIdleの半分の間隔で行う．引き継ぐと配信回数が1増える．
+func (q *Queue) claimStale(ctx context.Context, group, stream string, count int64) ([]Message, error) {
+	q.mu.Lock()
+	if time.Since(q.lastClaim[stream]) < q.claimIdle/2 {
+		q.mu.Unlock()
+		return nil, nil
+	}
+	q.lastClaim[stream] = time.Now()
+	q.mu.Unlock()
+
+	return q.autoClaim(ctx, group, stream, count)
+}
+
+// autoClaimは，処理中のまま応答のない時間がClaimIdleを超えたメッセージを最大count件引き継ぐ．
+func (q *Queue) autoClaim(ctx context.Context, group, stream string, count int64) ([]Message, error) {
+	claimed, _, err := q.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   stream,
+		Group:    group,
+		Consumer: q.consumer,
+		MinIdle:  q.claimIdle,
+		Start:    "0-0",
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]Message, 0, len(claimed))
+	for _, message := range claimed {
+		messages = append(messages, q.newMessage(stream, group, message))
+	}
+	return messages, nil
+}
+
+// newMessageは，Redisから取得したメッセージの署名を検証し，Messageに変換する．
+func (q *Queue) newMessage(stream, group string, message redis.XMessage) Message {
+	data, _ := message.Values[dataField].(string)
+	requestID, _ := message.Values[requestIDField].(string)
+	signedAt, _ := message.Values[signedAtField].(string)
+	sig, _ := message.Values[signatureField].(string)
+	seconds, err := strconv.ParseInt(signedAt, 10, 64)
+	return Message{
+		ID:        message.ID,
+		RequestID: requestID,
+		SignedAt:  time.Unix(seconds, 0),
+		stream:    stream,
+		group:     group,
+		data:      data,
+		verified:  err == nil && q.signer.Verify(canonicalMessage(requestID, signedAt, data), sig),
+	}
+}
+
+// canonicalMessageは，メッセージの署名の対象となる文字列を生成する．
+// 判定の依頼の一意識別子と署名した日時を含めることで，メッセージの内容だけを他の判定の依頼や古いメッセージとして再送することを防ぐ．
+func canonicalMessage(requestID, signedAt, data string) []byte {
+	return []byte(strings.Join([]string{requestID, signedAt, data}, "\n"))
+}
+
+// requestEntryKeyは，判定の依頼を運ぶメッセージを記録するキーを返す．
+func requestEntryKey(requestID string) string {
+	return requestEntryPrefix + requestID
+}
+
+// requestEntryは，判定の依頼を運ぶメッセージの記録の値（ストリームの名前とメッセージのID）を返す．
+func requestEntry(stream, id string) string {
+	return stream + " " + id
+}
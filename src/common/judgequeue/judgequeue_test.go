@@ -0,0 +1,98 @@
+package judgequeue
+
+import (
+	"context"
+	"errors"
+	"procon_web_service/src/common/signature"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestQueue - Redisに接続せず，メッセージの署名と検証のみを行うキューを生成
+func newTestQueue(t *testing.T, secret string) *Queue {
+	t.Helper()
+	t.Setenv("JUDGE_SHARED_SECRET", secret)
+	signer, err := signature.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Queue{signer: signer, maxAge: time.Hour}
+}
+
+func TestNewMessageVerifiesEnvelope(t *testing.T) {
+	other := newTestQueue(t, "other")
+	q := newTestQueue(t, "secret")
+	tests := []struct {
+		name   string
+		modify func(values map[string]interface{})
+		valid  bool
+	}{
+		{"valid", func(values map[string]interface{}) {}, true},
+		{"request ID", func(values map[string]interface{}) { values[requestIDField] = "other" }, false},
+		{"signed at", func(values map[string]interface{}) { values[signedAtField] = "0" }, false},
+		{"malformed signed at", func(values map[string]interface{}) { values[signedAtField] = "now" }, false},
+		{"data", func(values map[string]interface{}) { values[dataField] = `{"request_id":"other"}` }, false},
+		{"missing request ID", func(values map[string]interface{}) { delete(values, requestIDField) }, false},
+		{"legacy format", func(values map[string]interface{}) {
+			data := values[dataField].(string)
+			for field := range values {
+				delete(values, field)
+			}
+			values[dataField] = data
+			values[signatureField] = q.signer.Sign([]byte(data))
+		}, false},
+		{"other secret", func(values map[string]interface{}) {
+			for field, value := range other.signedValues("req-1", `{"request_id":"req-1"}`) {
+				values[field] = value
+			}
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			values := q.signedValues("req-1", `{"request_id":"req-1"}`)
+			test.modify(values)
+			message := q.newMessage(highPriorityStream, judgeGroup, redis.XMessage{ID: "1-0", Values: values})
+
+			var job struct {
+				RequestID string `json:"request_id"`
+			}
+			err := message.Decode(&job)
+			if test.valid {
+				if err != nil {
+					t.Fatalf("Decode returned error: %v", err)
+				}
+				if message.RequestID != "req-1" || job.RequestID != "req-1" {
+					t.Errorf("request ID = %q / %q, want req-1", message.RequestID, job.RequestID)
+				}
+				if time.Since(message.SignedAt) > time.Minute {
+					t.Errorf("SignedAt = %v, want about now", message.SignedAt)
+				}
+			} else if !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("Decode returned %v, want %v", err, ErrInvalidSignature)
+			}
+		})
+	}
+}
+
+func TestCheckJobRejectsStaleAndUnsigned(t *testing.T) {
+	q := newTestQueue(t, "secret")
+
+	// 古い日時で署名されたメッセージは，Redisの記録を確認する前に拒否する
+	values := q.signedValues("req-1", "{}")
+	signedAt := strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)
+	values[signedAtField] = signedAt
+	values[signatureField] = q.signer.Sign(canonicalMessage("req-1", signedAt, "{}"))
+	stale := q.newMessage(highPriorityStream, judgeGroup, redis.XMessage{ID: "1-0", Values: values})
+	if err := q.CheckJob(context.Background(), stale); !errors.Is(err, ErrStaleMessage) {
+		t.Errorf("CheckJob returned %v, want %v", err, ErrStaleMessage)
+	}
+
+	unsigned := q.newMessage(highPriorityStream, judgeGroup, redis.XMessage{ID: "1-0", Values: map[string]interface{}{dataField: "{}"}})
+	if err := q.CheckJob(context.Background(), unsigned); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("CheckJob returned %v, want %v", err, ErrInvalidSignature)
+	}
+}
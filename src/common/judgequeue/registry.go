@@ -0,0 +1,309 @@
+package judgequeue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"procon_web_service/src/common/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// ジャッジノードの登録に用いるキーなどの定数群である．
+const (
+	nodesKey        = "judge:nodes"       // ジャッジノードのIDと最新のハートビートの内容（JSON）のハッシュ
+	nodeStatesKey   = "judge:node-states" // ジャッジノードのIDと管理者が設定した状態のハッシュ
+	nodeAlivePrefix = "judge:node-alive:" // ハートビートが途絶えていないことを表すキー（ジャッジノードのIDを付加）
+
+	nodeForgetAfter   = 10 * time.Minute // ハートビートが途絶えたジャッジノードを登録から削除するまでの時間
+	reassignBatchSize = 100              // 再割り当てのために一度に取り出す判定リクエストの最大件数
+)
+
+// NodeTTLは，ハートビートが途絶えたジャッジノードを異常とみなすまでの時間である．
+// ジャッジノードはこれより短い間隔でHeartbeatを呼び出す必要がある．
+const NodeTTL = 15 * time.Second
+
+// ErrNodeNotFoundは，指定されたジャッジノードが登録されていない場合のエラーである．
+var ErrNodeNotFound = errors.New("judge node not found")
+
+// Heartbeatは，ジャッジノードを登録し，負荷などの情報を更新する関数である．
+// NodeTTLの間にハートビートがない場合，ジャッジノードは異常とみなされて判定リクエストが割り当てられなくなる．
+// ジャッジノードの状態（State）は管理者が設定するため，この関数では更新しない．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - node models.JudgeNode: ジャッジノードの情報．StateとHealthy，Backlogは無視される．
+//
+// 戻り値:
+// - error: 更新に失敗した場合のエラー，またはnil．
+func (q *Queue) Heartbeat(ctx context.Context, node models.JudgeNode) error {
+	// ハートビートの途絶で登録から削除された後も，割り当てられる判定リクエストを取り出せるよう毎回確認
+	if err := q.ensureGroup(ctx, nodeStream(node.NodeID), judgeGroup); err != nil {
+		return err
+	}
+
+	node.State = ""
+	node.Healthy = false
+	node.Backlog = 0
+	node.LastHeartbeat = time.Now()
+	data, err := json.Marshal(node)
+	if err != nil {
+		return err
+	}
+
+	pipe := q.client.TxPipeline()
+	pipe.HSet(ctx, nodesKey, node.NodeID, string(data))
+	pipe.Set(ctx, nodeAlivePrefix+node.NodeID, 1, NodeTTL)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// Nodesは，登録されている全てのジャッジノードの情報を，IDの昇順で取得する関数である．
+// ハートビートが途絶えたジャッジノードも，登録から削除されるまではHealthyがfalseとして含まれる．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+//
+// 戻り値:
+// - []models.JudgeNode: ジャッジノードの情報のリスト．
+// - error: 取得に失敗した場合のエラー，またはnil．
+func (q *Queue) Nodes(ctx context.Context) ([]models.JudgeNode, error) {
+	infos, err := q.client.HGetAll(ctx, nodesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	states, err := q.client.HGetAll(ctx, nodeStatesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]models.JudgeNode, 0, len(infos))
+	for nodeID, data := range infos {
+		var node models.JudgeNode
+		if err := json.Unmarshal([]byte(data), &node); err != nil {
+			continue
+		}
+		node.NodeID = nodeID
+		node.State = states[nodeID]
+		if node.State == "" {
+			node.State = models.JudgeNodeStateActive
+		}
+
+		alive, err := q.client.Exists(ctx, nodeAlivePrefix+nodeID).Result()
+		if err != nil {
+			return nil, err
+		}
+		node.Healthy = alive > 0
+
+		if node.Backlog, err = q.backlog(ctx, nodeID); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeID < nodes[j].NodeID })
+	return nodes, nil
+}
+
+// NodeStateは，ジャッジノードの状態を取得する関数である．状態が設定されていない場合は active を返す．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - nodeID string: ジャッジノードのID．
+//
+// 戻り値:
+// - string: ジャッジノードの状態．
+// - error: 取得に失敗した場合のエラー，またはnil．
+func (q *Queue) NodeState(ctx context.Context, nodeID string) (string, error) {
+	state, err := q.client.HGet(ctx, nodeStatesKey, nodeID).Result()
+	if errors.Is(err, redis.Nil) {
+		return models.JudgeNodeStateActive, nil
+	}
+	return state, err
+}
+
+// SetNodeStateは，ジャッジノードの状態を変更する関数である．状態はジャッジノードの再起動後も保持される．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - nodeID string: ジャッジノードのID．
+// - state string: 変更後の状態．
+//
+// 戻り値:
+// - error: ジャッジノードが登録されていない場合はErrNodeNotFound，変更に失敗した場合はそのエラー，またはnil．
+func (q *Queue) SetNodeState(ctx context.Context, nodeID, state string) error {
+	exists, err := q.client.HExists(ctx, nodesKey, nodeID).Result()
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrNodeNotFound
+	}
+	return q.client.HSet(ctx, nodeStatesKey, nodeID, state).Err()
+}
+
+// EnqueueToNodeは，判定リクエストを指定されたジャッジノードに割り当てる関数である．
+// 割り当てた判定リクエストは，ジャッジノードが無効化された場合やハートビートが途絶えた場合に他のジャッジノードに再割り当てされる．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - nodeID string: 判定リクエストを割り当てるジャッジノードのID．
+// - job models.JudgeJob: 追加する判定リクエスト．
+//
+// 戻り値:
+// - error: 追加に失敗した場合のエラー，またはnil．
+func (q *Queue) EnqueueToNode(ctx context.Context, nodeID string, job models.JudgeJob) error {
+	return q.addJob(ctx, nodeStream(nodeID), job)
+}
+
+// ReassignNodeJobsは，ジャッジノードに割り当てられ，まだ取り出されていない判定リクエストを優先度ごとのストリームに戻す関数である．
+// staleがtrueの場合は，処理中のまま応答のない時間がClaimIdleを超えた判定リクエストも戻す．
+// 戻した判定リクエストは，いずれかのジャッジノードが取り出して判定する．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - nodeID string: 判定リクエストを戻すジャッジノードのID．
+// - stale bool: 応答のない処理中の判定リクエストも戻す場合はtrue．
+//
+// 戻り値:
+// - int: 戻した判定リクエストの数．
+// - error: 操作に失敗した場合のエラー，またはnil．
+func (q *Queue) ReassignNodeJobs(ctx context.Context, nodeID string, stale bool) (int, error) {
+	stream := nodeStream(nodeID)
+	moved := 0
+	for {
+		messages, err := q.readGroup(ctx, judgeGroup, []string{stream}, reassignBatchSize, -1)
+		if err == nil && stale && len(messages) == 0 {
+			messages, err = q.autoClaim(ctx, judgeGroup, stream, reassignBatchSize)
+		}
+		if isNoGroup(err) {
+			// ストリームが作成される前，または登録から削除された後
+			return moved, nil
+		}
+		if err != nil || len(messages) == 0 {
+			return moved, err
+		}
+
+		for _, message := range messages {
+			if err := q.requeue(ctx, message); err != nil {
+				return moved, err
+			}
+			moved++
+		}
+	}
+}
+
+// RecoverNodesは，ハートビートが途絶えたジャッジノードに割り当てられた判定リクエストを他のジャッジノードに再割り当てする関数である．
+// ハートビートが長時間途絶え，割り当てられた判定リクエストが残っていないジャッジノードは登録から削除する．
+// 自身（selfID）は対象外とする．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - selfID string: 呼び出し元のジャッジノードのID．
+//
+// 戻り値:
+// - error: 操作に失敗した場合のエラー，またはnil．
+func (q *Queue) RecoverNodes(ctx context.Context, selfID string) error {
+	nodes, err := q.Nodes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		if node.Healthy || node.NodeID == selfID {
+			continue
+		}
+		if _, err := q.ReassignNodeJobs(ctx, node.NodeID, true); err != nil {
+			return err
+		}
+		if time.Since(node.LastHeartbeat) < nodeForgetAfter {
+			continue
+		}
+		length, err := q.client.XLen(ctx, nodeStream(node.NodeID)).Result()
+		if err != nil {
+			return err
+		}
+		if length == 0 {
+			pipe := q.client.TxPipeline()
+			pipe.HDel(ctx, nodesKey, node.NodeID)
+			pipe.HDel(ctx, nodeStatesKey, node.NodeID)
+			pipe.Del(ctx, nodeStream(node.NodeID))
+			if _, err := pipe.Exec(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// backlogは，ジャッジノードに割り当てられ，まだ取り出されていない判定リクエストの数を取得する．
+// 処理が完了した判定リクエストはストリームから削除されるため，ストリームの長さから処理中の数を引いた値となる．
+func (q *Queue) backlog(ctx context.Context, nodeID string) (int64, error) {
+	stream := nodeStream(nodeID)
+	length, err := q.client.XLen(ctx, stream).Result()
+	if err != nil || length == 0 {
+		return 0, err
+	}
+	pending, err := q.client.XPending(ctx, stream, judgeGroup).Result()
+	if isNoGroup(err) {
+		return length, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return length - pending.Count, nil
+}
+
+// requeueは，ジャッジノードのストリームのメッセージを優先度ごとのストリームに追加し直してAckする．
+// 追加し直したメッセージが判定の依頼を運ぶメッセージとして記録されるため，元のメッセージは以降は再送として拒否される．
+// 追加した後にAckに失敗した場合は同じ判定リクエストが重複するが，判定結果は判定の依頼の一意識別子により重複して保存されない．
+func (q *Queue) requeue(ctx context.Context, message Message) error {
+	var job models.JudgeJob
+	if err := message.Decode(&job); err != nil {
+		return q.DeadLetter(ctx, message, "malformed judge request: "+err.Error())
+	}
+	stream := highPriorityStream
+	if job.Request.Priority < 0 {
+		stream = lowPriorityStream
+	}
+	if err := q.addJobData(ctx, stream, job.RequestID, message.data); err != nil {
+		return err
+	}
+	return q.Ack(ctx, message)
+}
+
+// nodeStreamは，ジャッジノードに割り当てた判定リクエストのストリームの名前を返す．
+func nodeStream(nodeID string) string {
+	return nodeStreamPrefix + nodeID
+}
+
+// isNoGroupは，ストリームまたはコンシューマーグループが存在しない場合のエラーであればtrueを返す．
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+// SelectNodeは，指定された言語を判定できる，新しい判定リクエストを受け付けられるジャッジノードのうち，負荷が最も小さいものを選択する関数である．
+// 負荷が等しい場合は処理中の判定リクエストが少ないもの，それも等しい場合はIDが小さいものを選択する．
+//
+// パラメータ:
+// - nodes []models.JudgeNode: 選択の候補となるジャッジノードのリスト．
+// - languageID int: 判定する言語のID．
+//
+// 戻り値:
+// - *models.JudgeNode: 選択したジャッジノード．該当するジャッジノードがない場合はnil．
+func SelectNode(nodes []models.JudgeNode, languageID int) *models.JudgeNode {
+	var selected *models.JudgeNode
+	for i := range nodes {
+		node := &nodes[i]
+		if !node.Available() || !node.SupportsLanguage(languageID) {
+			continue
+		}
+		if selected == nil || node.Load() < selected.Load() ||
+			(node.Load() == selected.Load() && (node.Active < selected.Active || (node.Active == selected.Active && node.NodeID < selected.NodeID))) {
+			selected = node
+		}
+	}
+	return selected
+}
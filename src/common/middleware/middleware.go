@@ -1,8 +1,14 @@
 package middleware
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"log"
 	"net/http"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/signature"
+	"procon_web_service/src/common/utils"
 
 	"golang.org/x/time/rate"
 )
@@ -43,3 +49,50 @@ func RateLimiterMiddleware(limiter *rate.Limiter) func(http.Handler) http.Handle
 		})
 	}
 }
+
+// maxSignedBodySizeは，署名を検証するために読み込むリクエストのボディの最大サイズ（バイト）である．
+// 判定リクエスト（提出コードと問題の設定）とカスタム実行（コードと1MBまでの標準入力）を十分に含む大きさとする．
+const maxSignedBodySize = 16 << 20
+
+// SignatureMiddlewareはwebサーバーが署名したリクエストのみを受け付けるミドルウェアである．
+// 署名のヘッダーがないリクエストはボディを読み込まずに拒否し，ボディがmaxSignedBodySizeを超えるリクエストは413 Request Entity Too Largeエラーとして拒否する．
+// リクエストのボディを読み込んで署名を検証し，日時が許容範囲外，nonceが使用済み，署名が一致しないリクエストは401 Unauthorizedエラーとして拒否する．
+// 検証に成功した場合は，読み込んだボディを次のハンドラが再び読めるよう設定して処理を続ける．
+//
+// パラメータ:
+// - signer *signature.Signer: 署名の検証に用いるSigner．
+//
+// 戻り値:
+// - func(http.Handler) http.Handler: 署名の検証機能を備えたミドルウェアを生成する関数．
+func SignatureMiddleware(signer *signature.Signer) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := signature.CheckHeaders(r); err != nil {
+				log.Printf("Rejected request %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				utils.SendErrorResponse(w, err)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxSignedBodySize))
+			var maxBytesError *http.MaxBytesError
+			if errors.As(err, &maxBytesError) {
+				http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				utils.SendErrorResponse(w, commonerrors.NewSignatureError("Failed to read request body"))
+				return
+			}
+			r.Body.Close()
+
+			if err := signer.VerifyRequest(r, body); err != nil {
+				log.Printf("Rejected request %s %s from %s: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+				utils.SendErrorResponse(w, err)
+				return
+			}
+
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
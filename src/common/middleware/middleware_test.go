@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"procon_web_service/src/common/signature"
+	"strings"
+	"testing"
+)
+
+// bodyReader - 読み込まれたバイト数を記録するリクエストのボディ
+type bodyReader struct {
+	io.Reader
+	read int
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func newSignatureHandler(t *testing.T) (http.Handler, *signature.Signer, *bool) {
+	t.Helper()
+	t.Setenv("JUDGE_SHARED_SECRET", "secret")
+	signer, err := signature.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	called := false
+	handler := SignatureMiddleware(signer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	return handler, signer, &called
+}
+
+func TestSignatureMiddleware(t *testing.T) {
+	handler, signer, called := newSignatureHandler(t)
+	req := httptest.NewRequest(http.MethodPost, "http://judge-1:8080/run", strings.NewReader("body"))
+	if err := signer.SignRequest(req, []byte("body")); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || !*called || recorder.Body.String() != "body" {
+		t.Errorf("got status %d, called %v, body %q; want the body passed to the handler", recorder.Code, *called, recorder.Body.String())
+	}
+}
+
+func TestSignatureMiddlewareRejectsUnsignedWithoutReading(t *testing.T) {
+	handler, _, called := newSignatureHandler(t)
+	body := &bodyReader{Reader: strings.NewReader("body")}
+	req := httptest.NewRequest(http.MethodPost, "http://judge-1:8080/run", body)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	// エラーのステータスコードはレスポンスのJSONの status に設定される
+	var response struct {
+		Status int `json:"status"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil || response.Status != http.StatusUnauthorized || *called {
+		t.Errorf("got response %q, called %v; want status 401 without calling the handler", recorder.Body.String(), *called)
+	}
+	if body.read != 0 {
+		t.Errorf("read %d bytes of an unsigned request body", body.read)
+	}
+}
+
+func TestSignatureMiddlewareRejectsLargeBody(t *testing.T) {
+	handler, signer, called := newSignatureHandler(t)
+	large := bytes.Repeat([]byte("a"), maxSignedBodySize+1)
+	req := httptest.NewRequest(http.MethodPost, "http://judge-1:8080/run", bytes.NewReader(large))
+	if err := signer.SignRequest(req, large); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusRequestEntityTooLarge || *called {
+		t.Errorf("got status %d, called %v; want 413 without calling the handler", recorder.Code, *called)
+	}
+}
@@ -0,0 +1,393 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const manifestFileName = "manifest.json" // テストデータの版ごとのマニフェストのファイル名
+
+// GetTestSetSaveNameは，テストデータの版ごとのファイルの保存先のパスを生成する関数である．
+// 指定されたディレクトリ名，問題ID，テストデータの版，ファイルタイプ，ファイル名から成るパスを返す．
+func GetTestSetSaveName(dirName string, problemID, version int, fileType, fileName string) string {
+	return filepath.Join(GetFileSaveName(dirName, problemID, "", ""), "v"+strconv.Itoa(version), fileType, fileName)
+}
+
+// TestSetUploadは，問題のテストデータ（入出力ファイル，チェッカー，インタラクタ）を新しい版としてMinIOにアップロードする処理を表す構造体である．
+//
+// ファイルはAddFileまたはAddFileHeadersで1つずつ，ファイルタイプごとに版のディレクトリ以下に保存され，アップロード中に各ファイルのSHA-256ハッシュ値を計算する．
+// 全てのファイルを追加した後にCommitを呼び出すと，ファイルの一覧とハッシュ値を含むマニフェストを保存する．
+// マニフェストのない版はジャッジサーバーが判定に用いないため，アップロードの途中のテストデータが判定に用いられることはない．
+// アップロードを中止する場合はAbortを呼び出し，既にアップロードされたファイルをクリーンアップする．
+type TestSetUpload struct {
+	manifest          *models.TestSetManifest
+	uploadedFilePaths []string // アップロードされたファイルのパスを追跡
+}
+
+// NewTestSetUploadは，問題のテストデータの新しい版のアップロードを開始する関数である．
+//
+// パラメータ:
+// - problemID int: テストデータが関連する問題のID．
+// - version int: テストデータの版．
+//
+// 戻り値:
+// - *TestSetUpload: ファイルを追加するためのTestSetUploadのポインタ．
+func NewTestSetUpload(problemID, version int) *TestSetUpload {
+	return &TestSetUpload{
+		manifest: &models.TestSetManifest{ProblemID: problemID, Version: version, Files: []models.TestSetFile{}},
+	}
+}
+
+// AddFileは，readerから読み込んだsizeバイトの内容を，指定されたファイルタイプとファイル名のファイルとしてアップロードする．
+// 内容はメモリに保持せずにMinIOに転送されるため，アーカイブから取り出したファイルなども直接アップロードできる．
+//
+// パラメータ:
+// - fileType string: ファイルのタイプ（'in'，'out'，'checker'，'interactor'）．
+// - fileName string: ファイル名．
+// - reader io.Reader: ファイルの内容．
+// - size int64: ファイルのサイズ（バイト）．
+//
+// 戻り値:
+// - error: アップロード中に発生したエラー，またはnil．
+func (u *TestSetUpload) AddFile(fileType, fileName string, reader io.Reader, size int64) error {
+	file := models.TestSetFile{Path: fileType + "/" + fileName, Size: size}
+	if !file.IsValidPath() {
+		return commonerrors.NewFileValidationError(fmt.Sprintf("ファイル名が不正です: %s", file.Path))
+	}
+
+	filePath := GetTestSetSaveName("", u.manifest.ProblemID, u.manifest.Version, fileType, fileName)
+	hash := sha256.New()
+	_, err := minIOClient.PutObject(context.Background(), bucketName, filePath, io.TeeReader(reader, hash), size, minio.PutObjectOptions{
+		ContentType:        "text/plain",
+		ContentEncoding:    "utf-8",
+		ContentDisposition: fmt.Sprintf("attachment; filename=\"%s\"", fileName),
+	})
+	if err != nil {
+		return commonerrors.WrapMinIOError("uploading files to MinIO", err)
+	}
+	u.uploadedFilePaths = append(u.uploadedFilePaths, filePath)
+
+	file.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	u.manifest.Files = append(u.manifest.Files, file)
+	return nil
+}
+
+// AddFileHeadersは，マルチパートフォームデータに含まれるファイルを，指定されたファイルタイプのファイルとしてアップロードする．
+// ファイルが含まれない場合は何もしない．
+//
+// パラメータ:
+// - fileType string: ファイルのタイプ（'in'，'out'，'checker'，'interactor'）．
+// - fileHeaders []*multipart.FileHeader: アップロードするファイルのヘッダ．
+//
+// 戻り値:
+// - error: アップロード中に発生したエラー，またはnil．
+func (u *TestSetUpload) AddFileHeaders(fileType string, fileHeaders []*multipart.FileHeader) error {
+	for _, fileHeader := range fileHeaders {
+		file, err := fileHeader.Open()
+		if err != nil {
+			return commonerrors.WrapMinIOError("uploading files to MinIO", err)
+		}
+		err = u.AddFile(fileType, fileHeader.Filename, file, fileHeader.Size)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Commitは，追加された全てのファイルの一覧とハッシュ値を含むマニフェストを保存し，テストデータの版のアップロードを完了する．
+// マニフェストの保存に失敗した場合，アップロードされたファイルはクリーンアップされ，エラーが返される．
+//
+// 戻り値:
+// - *models.TestSetManifest: 保存されたマニフェスト．
+// - error: マニフェストの保存中に発生したエラー，またはnil．
+func (u *TestSetUpload) Commit() (*models.TestSetManifest, error) {
+	u.manifest.CreatedAt = time.Now()
+	data, err := json.Marshal(u.manifest)
+	if err != nil {
+		u.Abort()
+		return nil, commonerrors.WrapMinIOError("uploading test set manifest to MinIO", err)
+	}
+	_, err = minIOClient.PutObject(context.Background(), bucketName, GetTestSetSaveName("", u.manifest.ProblemID, u.manifest.Version, "", manifestFileName), bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: "application/json",
+	})
+	if err != nil {
+		u.Abort()
+		return nil, commonerrors.WrapMinIOError("uploading test set manifest to MinIO", err)
+	}
+	return u.manifest, nil
+}
+
+// Abortは，テストデータの版のアップロードを中止し，既にアップロードされたファイルを削除する．
+func (u *TestSetUpload) Abort() {
+	cleanupUploadedFiles(u.uploadedFilePaths)
+	u.uploadedFilePaths = nil
+}
+
+// MigrateLegacyTestSetは，テストデータの版を導入する前の形式（problem_<問題ID>/<ファイルタイプ>/<ファイル名>）で保存された問題のテストデータを，
+// 最初の版（models.LegacyTestSetVersion）としてコピーし，マニフェストを保存する．
+// 既に最初の版のマニフェストが存在する場合（以前の移行がデータベースの更新前に中断された場合など）は，コピーせずにそのマニフェストを返す．
+// 移行前の形式のファイルは削除せずに残し，問題のテストデータが更新された際にDeleteOldTestSetsで古い版とともに削除される．
+//
+// パラメータ:
+// - ctx context.Context: 操作のコンテキスト．
+// - problemID int: テストデータが関連する問題のID．
+//
+// 戻り値:
+// - *models.TestSetManifest: 最初の版のマニフェスト．
+// - error: ファイルの一覧の取得，コピー，またはマニフェストの保存中に発生したエラー，またはnil．
+func MigrateLegacyTestSet(ctx context.Context, problemID int) (*models.TestSetManifest, error) {
+	if manifest, err := downloadManifest(ctx, problemID, models.LegacyTestSetVersion); err == nil {
+		return manifest, nil
+	}
+
+	upload := NewTestSetUpload(problemID, models.LegacyTestSetVersion)
+	for _, fileType := range models.TestSetFileTypes {
+		for object := range minIOClient.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+			Prefix:    GetFileSaveName("", problemID, fileType, "") + "/",
+			Recursive: true,
+		}) {
+			if object.Err != nil {
+				upload.Abort()
+				return nil, commonerrors.WrapMinIOError("listing legacy test set in MinIO", object.Err)
+			}
+			if err := copyLegacyFile(ctx, upload, fileType, object); err != nil {
+				upload.Abort()
+				return nil, err
+			}
+		}
+	}
+
+	return upload.Commit()
+}
+
+// copyLegacyFileは，移行前の形式で保存された1つのファイルを読み込み，テストデータの版のファイルとしてアップロードする内部関数である．
+func copyLegacyFile(ctx context.Context, upload *TestSetUpload, fileType string, object minio.ObjectInfo) error {
+	reader, err := minIOClient.GetObject(ctx, bucketName, object.Key, minio.GetObjectOptions{})
+	if err != nil {
+		return commonerrors.WrapMinIOError("reading legacy test set from MinIO", err)
+	}
+	defer reader.Close()
+
+	return upload.AddFile(fileType, filepath.Base(object.Key), reader, object.Size)
+}
+
+// DeleteOldTestSetsは，問題のテストデータのうち，指定された版より古い版をMinIOから削除する．
+// 判定キューで待機している判定リクエストが古い版を参照している場合があるため，呼び出し元は直前の版を残すよう keepFrom を指定する．
+// 版を導入する前の形式で保存されたファイルは版0として扱う（MigrateLegacyTestSetで最初の版に移行済みのため，最初の版が残っていれば削除してよい）．
+//
+// パラメータ:
+// - problemID int: テストデータが関連する問題のID．
+// - keepFrom int: 残す最も古い版．これより古い版が削除される．
+//
+// 戻り値:
+// - error: ファイルの削除中に発生したエラー，またはnil．
+func DeleteOldTestSets(problemID, keepFrom int) error {
+	prefix := GetFileSaveName("", problemID, "", "") + "/"
+	for object := range minIOClient.ListObjects(context.Background(), bucketName, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	}) {
+		if object.Err != nil {
+			return commonerrors.WrapMinIOError("cleaning old test sets in MinIO", object.Err)
+		}
+		versionDir, _, _ := strings.Cut(strings.TrimPrefix(object.Key, prefix), "/")
+		version, err := strconv.Atoi(strings.TrimPrefix(versionDir, "v"))
+		if isLegacyFileType(versionDir) {
+			version, err = 0, nil
+		} else if !strings.HasPrefix(versionDir, "v") {
+			continue
+		}
+		if err != nil || version >= keepFrom {
+			continue
+		}
+		if err := minIOClient.RemoveObject(context.Background(), bucketName, object.Key, minio.RemoveObjectOptions{}); err != nil {
+			return commonerrors.WrapMinIOError("cleaning old test sets in MinIO", err)
+		}
+	}
+	return nil
+}
+
+// isLegacyFileTypeは，問題のディレクトリ直下のディレクトリ名が，版を導入する前の形式のファイルタイプのディレクトリである場合にtrueを返す内部関数である．
+func isLegacyFileType(dirName string) bool {
+	for _, fileType := range models.TestSetFileTypes {
+		if dirName == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// PrepareTestSetは，問題のテストデータの指定された版をローカルの指定されたディレクトリ以下に用意し，保存先のディレクトリを返す．
+//
+// MinIOからマニフェストを取得し，マニフェストに含まれる各ファイルについて，ローカルに保存されているファイルのサイズとSHA-256ハッシュ値がマニフェストと一致するかを確認する．
+// 存在しない，または一致しないファイルは同じディレクトリの一時ファイルにダウンロードし，ハッシュ値がマニフェストと一致することを確認してから置き換える（置き換えはアトミックに行われる）．
+// マニフェストに含まれないファイル（以前のダウンロードの一時ファイルなど）は削除する．
+// 呼び出すたびに全てのファイルのハッシュ値を確認するため，確認済みの版の再利用と，同じ版を同時に用意しないための排他制御は呼び出し元が行う．
+//
+// パラメータ:
+// - ctx context.Context: 操作のコンテキスト．
+// - baseDir string: テストデータを保存するディレクトリ．版ごとに <baseDir>/problem_<問題ID>/v<版> に保存される．
+// - problemID int: テストデータが関連する問題のID．
+// - version int: 用意するテストデータの版．
+//
+// 戻り値:
+// - string: テストデータを保存したディレクトリ（ファイルタイプごとのディレクトリを含む）．
+// - error: マニフェストの取得，ファイルのダウンロード，またはハッシュ値の確認に失敗した場合のエラー，またはnil．
+func PrepareTestSet(ctx context.Context, baseDir string, problemID, version int) (string, error) {
+	if version <= 0 {
+		return "", commonerrors.NewMinIOError("preparing test set", fmt.Sprintf("problem %d has no test set", problemID))
+	}
+	dir := GetTestSetSaveName(baseDir, problemID, version, "", "")
+
+	manifest, err := downloadManifest(ctx, problemID, version)
+	if err != nil {
+		return "", commonerrors.WrapMinIOError("downloading test set manifest from MinIO", err)
+	}
+
+	listed := make(map[string]bool, len(manifest.Files))
+	for _, file := range manifest.Files {
+		if !file.IsValidPath() {
+			return "", commonerrors.NewMinIOError("preparing test set", fmt.Sprintf("invalid file path in manifest: %q", file.Path))
+		}
+		savePath := filepath.Join(dir, filepath.FromSlash(file.Path))
+		listed[savePath] = true
+		if localFileMatches(savePath, file) {
+			continue
+		}
+		objectKey := GetTestSetSaveName("", problemID, version, "", file.Path)
+		if err := downloadVerifiedFile(ctx, objectKey, savePath, file); err != nil {
+			return "", commonerrors.WrapMinIOError("downloading files from MinIO", err)
+		}
+	}
+
+	// 入出力ファイルのディレクトリはテストケースの一覧として読み込まれるため，ファイルがない場合も作成
+	for _, fileType := range models.TestSetFileTypes {
+		if err := os.MkdirAll(filepath.Join(dir, fileType), 0755); err != nil {
+			return "", commonerrors.WrapMinIOError("preparing test set", err)
+		}
+		if err := removeUnlistedFiles(filepath.Join(dir, fileType), listed); err != nil {
+			return "", commonerrors.WrapMinIOError("preparing test set", err)
+		}
+	}
+
+	return dir, nil
+}
+
+// downloadManifestは，テストデータの版のマニフェストをMinIOから取得する内部関数である．
+// マニフェストが存在しない（アップロードが完了していない）場合や，問題IDと版が一致しない場合はエラーを返す．
+func downloadManifest(ctx context.Context, problemID, version int) (*models.TestSetManifest, error) {
+	object, err := minIOClient.GetObject(ctx, bucketName, GetTestSetSaveName("", problemID, version, "", manifestFileName), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	var manifest models.TestSetManifest
+	if err := json.NewDecoder(object).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to read manifest of problem %d version %d: %v", problemID, version, err)
+	}
+	if manifest.ProblemID != problemID || manifest.Version != version {
+		return nil, fmt.Errorf("manifest of problem %d version %d describes problem %d version %d", problemID, version, manifest.ProblemID, manifest.Version)
+	}
+	return &manifest, nil
+}
+
+// localFileMatchesは，ローカルのファイルのサイズとSHA-256ハッシュ値がマニフェストと一致する場合にtrueを返す内部関数である．
+func localFileMatches(filePath string, file models.TestSetFile) bool {
+	fileInfo, err := os.Stat(filePath)
+	if err != nil || !fileInfo.Mode().IsRegular() || fileInfo.Size() != file.Size {
+		return false
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == file.SHA256
+}
+
+// downloadVerifiedFileはMinIOからファイルを同じディレクトリの一時ファイルにダウンロードし，
+// サイズとSHA-256ハッシュ値がマニフェストと一致する場合のみ指定されたパスに置き換える内部関数である．
+// 一時ファイルからの置き換えはリネームで行うため，ダウンロードの途中のファイルが判定に用いられることはない．
+func downloadVerifiedFile(ctx context.Context, objectKey, savePath string, file models.TestSetFile) error {
+	if err := os.MkdirAll(filepath.Dir(savePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for test set: %w", err)
+	}
+
+	object, err := minIOClient.GetObject(ctx, bucketName, objectKey, minio.GetObjectOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get object from MinIO: %v, object key: %s", err, objectKey)
+	}
+	defer object.Close()
+
+	tempFile, err := os.CreateTemp(filepath.Dir(savePath), ".download-*")
+	if err != nil {
+		return fmt.Errorf("failed to create file for saving object: %v, path: %s", err, savePath)
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath) // 置き換えた後は存在しないため何もしない
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tempFile, hash), object)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write object to file: %v, path: %s", err, savePath)
+	}
+
+	if sum := hex.EncodeToString(hash.Sum(nil)); size != file.Size || sum != file.SHA256 {
+		return fmt.Errorf("downloaded file %s does not match manifest (size %d, sha256 %s; expected size %d, sha256 %s)", objectKey, size, sum, file.Size, file.SHA256)
+	}
+	// コンテナ内部のユーザーからも読み込めるよう権限を設定
+	if err := os.Chmod(tempPath, 0644); err != nil {
+		return fmt.Errorf("failed to change file permission: %v, path: %s", err, savePath)
+	}
+	if err := os.Rename(tempPath, savePath); err != nil {
+		return fmt.Errorf("failed to replace file: %v, path: %s", err, savePath)
+	}
+	return nil
+}
+
+// removeUnlistedFilesは，ディレクトリ内のマニフェストに含まれないファイルを削除する内部関数である．
+func removeUnlistedFiles(dir string, listed map[string]bool) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		filePath := filepath.Join(dir, entry.Name())
+		if listed[filePath] {
+			continue
+		}
+		log.Printf("Removing unlisted test set file: %s", filePath)
+		if err := os.RemoveAll(filePath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
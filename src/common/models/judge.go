@@ -0,0 +1,141 @@
+package models
+
+import "time"
+
+// JudgeRequestは，webサーバーからジャッジサーバーへ送信される判定リクエストを表す構造体である．
+// 判定対象の解答と，実行制限などの判定に必要な問題の設定が含まれる．
+type JudgeRequest struct {
+	Solution Solution `json:"solution"`           // 判定対象の解答である．
+	Problem  Problem  `json:"problem"`            // 解答が対象とする問題の設定である．
+	Priority int      `json:"priority,omitempty"` // 判定の優先度である．値が大きいほど先に処理され，同じ優先度の場合は到着順に処理される．
+}
+
+// JudgeJobは，webサーバーが判定キューに追加し，ジャッジサーバーが取り出して判定する判定リクエストを表す構造体である．
+type JudgeJob struct {
+	RequestID string       `json:"request_id"` // 判定の依頼ごとの一意識別子である．判定結果の重複した保存を防ぐために用いる．
+	Request   JudgeRequest `json:"request"`    // 判定リクエストである．
+}
+
+// JudgeJobResultは，ジャッジサーバーが判定結果のキューに追加する判定結果を表す構造体である．
+// 判定に成功した場合はResultが，規定の回数判定に失敗した場合はErrorが設定される．
+type JudgeJobResult struct {
+	RequestID  string        `json:"request_id"`       // 判定の依頼の一意識別子である．
+	SolutionID int           `json:"solution_id"`      // 判定した解答のIDである．
+	Result     *ResultDetail `json:"result,omitempty"` // 判定結果である（判定に成功した場合）．
+	Error      string        `json:"error,omitempty"`  // 判定に失敗した理由である（判定に失敗した場合）．
+}
+
+// QueueStatusは，ジャッジサーバーのキューにおける提出の待機状況を表す構造体である．
+type QueueStatus struct {
+	SolutionID int  `json:"solution_id"` // 解答の一意識別子である．
+	Queued     bool `json:"queued"`      // キューで待機中であればtrueである．判定中または存在しない場合はfalseとなる．判定中の場合はStageが設定される．
+	Position   int  `json:"position"`    // キュー内での順番（1始まり）である．待機中でない場合は0となる．
+	Depth      int  `json:"depth"`       // キューで待機中の提出の総数である．
+
+	Stage       string `json:"stage,omitempty"`        // 判定中の場合の段階（"compiling" または "running"）である．
+	CurrentCase int    `json:"current_case,omitempty"` // 判定が終了したテストケースの数である（"running" の場合）．
+	TotalCases  int    `json:"total_cases,omitempty"`  // テストケースの総数である（"running" の場合）．
+}
+
+// ジャッジサーバーでの判定中の段階を表す定数群である．
+const (
+	JudgeStageCompiling = "compiling" // テストデータの準備とコンパイルを行っている．
+	JudgeStageRunning   = "running"   // テストケースを実行している．
+)
+
+// ジャッジノードの状態を表す定数群である．状態は管理者が変更し，ジャッジノードの再起動後も保持される．
+const (
+	JudgeNodeStateActive   = "active"   // 新しい判定リクエストを受け付ける．
+	JudgeNodeStateDraining = "draining" // 新しい判定リクエストを受け付けず，割り当て済みの判定リクエストのみを処理する．
+	JudgeNodeStateDisabled = "disabled" // 判定リクエストを処理しない．割り当て済みで未処理の判定リクエストは他のジャッジノードに再割り当てされる．
+)
+
+// JudgeNodeは，判定キューに登録されたジャッジノード（ジャッジサーバーの1つのインスタンス）の情報を表す構造体である．
+// ジャッジノードは起動時に自身を登録し，一定間隔のハートビートで負荷などの情報を更新する．
+type JudgeNode struct {
+	NodeID        string    `json:"node_id"`        // ジャッジノードの一意識別子である．
+	URL           string    `json:"url"`            // webサーバーからジャッジノードのHTTP API（キューの状態の取得，カスタム実行）に接続するためのベースURLである．
+	Version       string    `json:"version"`        // ジャッジノードのバージョンである．
+	Languages     []int     `json:"languages"`      // 判定できる言語のIDのリストである．
+	MaxWorkers    int       `json:"max_workers"`    // 同時に判定する提出の最大数である．
+	Active        int       `json:"active"`         // 処理中の判定リクエストの数である（ハートビートの時点）．
+	Backlog       int64     `json:"backlog"`        // ジャッジノードに割り当てられ，まだ取り出されていない判定リクエストの数である．
+	State         string    `json:"state"`          // ジャッジノードの状態（active，draining，disabled）である．
+	Healthy       bool      `json:"healthy"`        // ハートビートが途絶えていない場合はtrueである．
+	LastHeartbeat time.Time `json:"last_heartbeat"` // 最後にハートビートを受信した日時である．
+
+	TestDataCache *TestDataCacheStats `json:"test_data_cache,omitempty"` // テストデータのキャッシュの統計情報である（ハートビートの時点）．
+}
+
+// TestDataCacheStatsは，ジャッジノードのテストデータのキャッシュの使用状況と統計情報を表す構造体である．
+// ヒット数，ミス数，削除数はジャッジノードの起動時からの累計である．
+type TestDataCacheStats struct {
+	BudgetBytes int64 `json:"budget_bytes"` // キャッシュに保持するテストデータの合計サイズの上限（バイト）である．
+	UsedBytes   int64 `json:"used_bytes"`   // キャッシュに保持しているテストデータの合計サイズ（バイト）である．
+	Entries     int   `json:"entries"`      // キャッシュに保持しているテストデータの版の数である．
+	InUse       int   `json:"in_use"`       // 判定中のため削除できないテストデータの版の数である．
+	Hits        int64 `json:"hits"`         // 確認済みのテストデータをそのまま用いた回数である．
+	Misses      int64 `json:"misses"`       // テストデータをダウンロードまたは確認した回数である．
+	Evictions   int64 `json:"evictions"`    // サイズの上限を超えたためにテストデータを削除した回数である．
+}
+
+// Loadは，ジャッジノードの負荷（処理中と割り当て済みの判定リクエストの数を，同時に判定する提出の最大数で割った値）を返す．
+func (n JudgeNode) Load() float64 {
+	workers := n.MaxWorkers
+	if workers <= 0 {
+		workers = 1
+	}
+	return float64(int64(n.Active)+n.Backlog) / float64(workers)
+}
+
+// Availableは，ジャッジノードが新しい判定リクエストを受け付けられる（ハートビートが途絶えておらず，状態が active である）場合にtrueを返す．
+func (n JudgeNode) Available() bool {
+	return n.Healthy && n.State == JudgeNodeStateActive
+}
+
+// SupportsLanguageは，ジャッジノードが指定された言語を判定できる場合にtrueを返す．
+func (n JudgeNode) SupportsLanguage(languageID int) bool {
+	for _, id := range n.Languages {
+		if id == languageID {
+			return true
+		}
+	}
+	return false
+}
+
+// JudgeNodeStateRequestは，ジャッジノードの状態を変更するリクエストを表す構造体である．
+type JudgeNodeStateRequest struct {
+	State string `json:"state"` // 変更後の状態（active，draining，disabled）である．
+}
+
+// CustomRunは，提出を作成せずに任意の入力でコードを実行するカスタム実行の内容を表す構造体である．
+// 実行結果はデータベースに保存されず，提出の統計にも含まれない．
+type CustomRun struct {
+	ProblemID  int    `json:"problem_id,omitempty"` // 実行制限を適用する問題のIDである．0の場合は既定の実行制限が適用される．
+	LanguageID int    `json:"language_id"`          // コードの言語IDである．
+	Code       string `json:"code"`                 // 実行するソースコードである．
+	Stdin      string `json:"stdin"`                // プログラムの標準入力に与える内容である．
+}
+
+// CustomRunRequestは，webサーバーからジャッジサーバーへ送信されるカスタム実行のリクエストを表す構造体である．
+type CustomRunRequest struct {
+	Run     CustomRun `json:"run"`     // 実行するカスタム実行の内容である．
+	Problem Problem   `json:"problem"` // 実行制限を含む問題の設定である．問題が指定されていない場合は空の設定となり，既定の実行制限が適用される．
+}
+
+// CustomRunResultは，カスタム実行の結果を表す構造体である．
+// Resultは，制限内で正常終了した場合は "OK" となり，それ以外の場合は判定結果と同じ値（CE, RE, TLE, MLE, OLE, IE）となる．
+type CustomRunResult struct {
+	Result        string        `json:"result"`                   // 実行結果である．
+	CompileOutput string        `json:"compile_output,omitempty"` // コンパイラの出力である．
+	Stdout        string        `json:"stdout"`                   // プログラムの標準出力（サイズを制限して切り詰めたもの）である．
+	Stderr        string        `json:"stderr"`                   // プログラムの標準エラー出力（サイズを制限して切り詰めたもの）である．
+	ExitCode      int           `json:"exit_code"`                // プログラムの終了コードである．
+	Signal        string        `json:"signal,omitempty"`         // プログラムを終了させたシグナル名である．
+	ExecutionTime time.Duration `json:"execution_time"`           // 実行時間（ウォールタイム，ナノ秒）である．
+	CPUTime       time.Duration `json:"cpu_time"`                 // CPU時間（ナノ秒）である．
+	PeakMemory    int64         `json:"peak_memory"`              // ピークメモリ使用量（バイト）である．
+}
+
+// CustomRunResultOKは，カスタム実行でプログラムが制限内で正常終了したことを表す実行結果である．
+const CustomRunResultOK = "OK"
@@ -1,15 +1,194 @@
 package models
 
-import "time"
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 問題の実行制限に関する既定値および上限値である．
+const (
+	DefaultTimeLimit   = 2000  // 実行時間制限の既定値（ミリ秒）である．
+	DefaultMemoryLimit = 256   // メモリ制限の既定値（MB）である．
+	MaxTimeLimit       = 20000 // 設定可能な実行時間制限の上限（ミリ秒）である．
+	MaxMemoryLimit     = 2048  // 設定可能なメモリ制限の上限（MB）である．
+	MaxTimeMultiplier  = 10.0  // 設定可能な言語ごとの実行時間倍率の上限である．
+)
+
+// 出力の比較モードを表す定数群である．
+// チェッカーが設定されていない問題では，これらのいずれかの方法で提出プログラムの出力と期待される出力を比較する．
+const (
+	CompareModeExact           = "exact"            // バイト単位の完全一致である．
+	CompareModeLines           = "lines"            // 各行末尾の空白と出力末尾の空行を無視した行単位の一致である．
+	CompareModeTokens          = "tokens"           // 空白文字で区切られたトークン単位の一致である．
+	CompareModeFloat           = "float"            // トークン単位で，数値は絶対誤差または相対誤差が許容誤差以下であれば一致とみなす．
+	CompareModeCaseInsensitive = "case_insensitive" // トークン単位で，大文字と小文字を区別しない一致である．
+)
+
+// DefaultFloatEpsilonは，浮動小数点数の比較における許容誤差の既定値である．
+const DefaultFloatEpsilon = 1e-6
+
+// 問題の種類を表す定数群である．
+const (
+	ProblemTypeStandard    = "standard"    // 入力ファイルを標準入力に与え，出力を判定する通常の問題である．
+	ProblemTypeInteractive = "interactive" // 提出プログラムとインタラクタが標準入出力を通じて対話するインタラクティブ問題である．
+)
+
+// テストケースの判定方式を表す定数群である．
+const (
+	JudgeModeAll      = "all"       // 全てのテストケースを並列に実行する．部分点を計算する問題（IOI形式）に用いる．
+	JudgeModeFailFast = "fail_fast" // テストケースを順に実行し，最初に正解とならなかった時点で以降のテストケースを実行しない（ICPC形式）．
+)
+
+// サブタスクの採点方式を表す定数群である．
+const (
+	ScoringAllOrNothing = "all_or_nothing" // 全てのテストケースに正解した場合のみ配点を与える．
+	ScoringMin          = "min"            // テストケースごとの得点率の最小値に配点を掛けた値を得点とする．
+	ScoringSum          = "sum"            // テストケースごとの得点率の平均に配点を掛けた値を得点とする（配点をテストケースに均等に割り振り合計する）．
+)
+
+// Subtaskは，テストケースのグループと配点，採点方式からなるサブタスクを表す構造体である．
+// 1つのテストケースは複数のサブタスクに含まれてもよい．
+type Subtask struct {
+	Name      string   `json:"name"`       // サブタスクの名前である．
+	Points    float64  `json:"points"`     // サブタスクの配点である．
+	Scoring   string   `json:"scoring"`    // 採点方式（"all_or_nothing", "min", "sum"）である．
+	CaseNames []string `json:"case_names"` // サブタスクに含まれるテストケースの名前（入力ファイル名）のリストである．
+}
+
+// テストケースごとの標準エラー出力の公開範囲を表す定数群である．
+// 提出プログラムが入力をそのまま標準エラー出力に書き出すとテストデータが漏洩するため，既定では詳細を公開するテストケースに限る．
+const (
+	StderrVisibilityNone     = "none"     // 全てのテストケースで公開しない．
+	StderrVisibilityRevealed = "revealed" // 詳細を公開するテストケース（RevealedCases）のみ公開する．
+	StderrVisibilityAll      = "all"      // 全てのテストケースで公開する．
+)
+
+// DefaultCheckerLanguageIDは，チェッカーおよびインタラクタの言語が指定されていない場合に用いる言語ID（C++）である．
+// testlib.hを用いたチェッカーおよびインタラクタを想定している．
+const DefaultCheckerLanguageID = 2
 
 // Problemは，コーディング問題の情報を保持する構造体である．
 type Problem struct {
-	ProblemID   int       `json:"problem_id"`   // 問題の一意識別子である．
-	UserID      int       `json:"user_id"`      // 問題を作成したユーザーのIDである．
-	Title       string    `json:"title"`        // 問題のタイトルである．
-	Description string    `json:"description"`  // 問題の説明文である．
-	Difficulty  int       `json:"difficulty"`   // 問題の難易度を表す整数値である．
-	CreatedAt   time.Time `json:"created_at"`   // 問題の作成日時である．
-	UpdatedAt   time.Time `json:"updated_at"`   // 問題の最終更新日時である．
-	CategoryIDs []int     `json:"category_ids"` // 問題に関連付けられたカテゴリIDのリストである．
+	ProblemID            int             `json:"problem_id"`                       // 問題の一意識別子である．
+	UserID               int             `json:"user_id"`                          // 問題を作成したユーザーのIDである．
+	Title                string          `json:"title"`                            // 問題のタイトルである．
+	Description          string          `json:"description"`                      // 問題の説明文である．
+	Difficulty           int             `json:"difficulty"`                       // 問題の難易度を表す整数値である．
+	ProblemType          string          `json:"problem_type"`                     // 問題の種類（"standard" または "interactive"）である．
+	TimeLimit            int             `json:"time_limit"`                       // 実行時間制限（ミリ秒）である．
+	MemoryLimit          int             `json:"memory_limit"`                     // メモリ制限（MB）である．
+	LanguageMultipliers  map[int]float64 `json:"language_multipliers,omitempty"`   // 言語IDをキーとした実行時間制限の倍率である（例: Pythonは3倍）．
+	CheckerLanguageID    int             `json:"checker_language_id,omitempty"`    // チェッカーの言語IDである．0の場合はチェッカーを用いず比較モードに従って判定する．
+	CompareMode          string          `json:"compare_mode"`                     // チェッカーが設定されていない場合の出力の比較モードである．
+	FloatEpsilon         float64         `json:"float_epsilon,omitempty"`          // 比較モードが"float"の場合の許容誤差である．
+	Subtasks             []Subtask       `json:"subtasks,omitempty"`               // 部分点のためのサブタスクのリストである．空の場合は得点を計算しない．
+	InteractorLanguageID int             `json:"interactor_language_id,omitempty"` // インタラクティブ問題のインタラクタの言語IDである．
+	JudgeMode            string          `json:"judge_mode"`                       // テストケースの判定方式（"all" または "fail_fast"）である．
+	SampleCases          []string        `json:"sample_cases,omitempty"`           // 問題の詳細とともに入出力を公開するサンプルケースの名前のリストである．それ以外のテストケースは非公開となる．
+	RevealedCases        []string        `json:"revealed_cases,omitempty"`         // サンプルケースに加えて，提出プログラムの出力と期待される出力を判定結果で公開するテストケースの名前のリストである．
+	StderrVisibility     string          `json:"stderr_visibility"`                // 提出プログラムの標準エラー出力を公開する範囲（"none", "revealed", "all"）である．
+	TestSetVersion       int             `json:"test_set_version"`                 // 判定に用いるテストデータの版である（TestSetManifest.Version）．テストデータの更新のたびに1ずつ増える．
+	CreatedAt            time.Time       `json:"created_at"`                       // 問題の作成日時である．
+	UpdatedAt            time.Time       `json:"updated_at"`                       // 問題の最終更新日時である．
+	CategoryIDs          []int           `json:"category_ids"`                     // 問題に関連付けられたカテゴリIDのリストである．
+	Samples              []SampleCase    `json:"samples,omitempty"`                // サンプルケースの入出力である（問題の詳細の取得時のみ設定される）．
+}
+
+// SampleCaseは，問題の詳細とともに公開されるサンプルケースの入出力を表す構造体である．
+type SampleCase struct {
+	Name      string `json:"name"`                // テストケースの名前（入力ファイル名）である．
+	Input     string `json:"input"`               // 入力ファイルの内容である．
+	Output    string `json:"output"`              // 期待される出力の内容である．
+	Truncated bool   `json:"truncated,omitempty"` // 入力または出力が大きく，内容が途中までしか含まれていない場合はtrueである．
+}
+
+// TimeLimitForは，指定された言語で提出された解答に適用する実行時間制限を返す．
+// 言語ごとの倍率が設定されている場合は，問題の実行時間制限に倍率を掛けた値となる．
+func (p *Problem) TimeLimitFor(languageID int) time.Duration {
+	timeLimit := time.Duration(p.TimeLimit) * time.Millisecond
+	if multiplier, ok := p.LanguageMultipliers[languageID]; ok && multiplier > 0 {
+		timeLimit = time.Duration(float64(timeLimit) * multiplier)
+	}
+	return timeLimit
+}
+
+// HasCheckerは，問題にチェッカー（スペシャルジャッジ）が設定されている場合にtrueを返す．
+func (p *Problem) HasChecker() bool {
+	return p.CheckerLanguageID != 0
+}
+
+// IsInteractiveは，問題がインタラクティブ問題である場合にtrueを返す．
+func (p *Problem) IsInteractive() bool {
+	return p.ProblemType == ProblemTypeInteractive
+}
+
+// FailsFastは，最初に正解とならなかったテストケースで判定を打ち切る場合にtrueを返す．
+func (p *Problem) FailsFast() bool {
+	return p.JudgeMode == JudgeModeFailFast
+}
+
+// OrderCasesは，テストケースの名前（入力ファイル名）を実行する順に並べ替えたリストを返す．
+// サンプルケース，サブタスクに含まれるテストケース（サブタスクの定義順，サブタスク内の記載順），その他のテストケースの順に並べ，
+// サンプルケースとその他のテストケースはそれぞれファイル名の順に並べる．
+func (p *Problem) OrderCases(caseNames []string) []string {
+	sorted := append([]string(nil), caseNames...)
+	sort.Strings(sorted)
+
+	ordered := make([]string, 0, len(sorted))
+	added := make(map[string]bool, len(sorted))
+	add := func(match func(caseName string) bool) {
+		for _, caseName := range sorted {
+			if !added[caseName] && match(caseName) {
+				ordered = append(ordered, caseName)
+				added[caseName] = true
+			}
+		}
+	}
+
+	add(p.IsSample)
+	for _, subtask := range p.Subtasks {
+		for _, name := range subtask.CaseNames {
+			add(func(caseName string) bool { return containsCaseName([]string{name}, caseName) })
+		}
+	}
+	add(func(string) bool { return true })
+	return ordered
+}
+
+// IsSampleは，指定されたテストケースがサンプルケースである場合にtrueを返す．
+func (p *Problem) IsSample(caseName string) bool {
+	return containsCaseName(p.SampleCases, caseName)
+}
+
+// RevealsCaseは，指定されたテストケースの出力と期待される出力を判定結果で公開する場合にtrueを返す．
+// サンプルケースは常に公開される．公開されないテストケースは非公開のテストケースとして，判定結果で名前も伏せられる．
+func (p *Problem) RevealsCase(caseName string) bool {
+	return p.IsSample(caseName) || containsCaseName(p.RevealedCases, caseName)
+}
+
+// containsCaseNameは，テストケースの名前のリストに指定されたテストケースが含まれる場合にtrueを返す．
+// リストのテストケースの名前は入力ファイル名，または拡張子を除いた名前で指定できる．
+func containsCaseName(caseNames []string, caseName string) bool {
+	baseName := strings.TrimSuffix(caseName, filepath.Ext(caseName))
+	for _, name := range caseNames {
+		if name == caseName || name == baseName {
+			return true
+		}
+	}
+	return false
+}
+
+// RevealsStderrは，指定されたテストケースの標準エラー出力を判定結果で公開する場合にtrueを返す．
+// 公開範囲が設定されていない場合は "revealed" として扱う．
+func (p *Problem) RevealsStderr(caseName string) bool {
+	switch p.StderrVisibility {
+	case StderrVisibilityAll:
+		return true
+	case StderrVisibilityNone:
+		return false
+	default:
+		return p.RevealsCase(caseName)
+	}
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// 再判定の状態を表す定数群である．
+const (
+	RejudgeStatusRunning   = "running"   // 対象の解答を再判定している．
+	RejudgeStatusCompleted = "completed" // 全ての対象の解答の再判定が終了した．
+)
+
+// RejudgeRequestは，再判定の対象を絞り込む条件を表す構造体である．
+// 提出日時の範囲は，From以上To未満の日時に提出された解答を対象とする．
+type RejudgeRequest struct {
+	ProblemID int        `json:"problem_id,omitempty"` // 対象とする問題のIDである．0の場合は全ての問題の解答が対象となる（提出日時の範囲による再判定のみ）．
+	From      *time.Time `json:"from,omitempty"`       // 対象とする提出日時の開始（この日時を含む）である．
+	To        *time.Time `json:"to,omitempty"`         // 対象とする提出日時の終了（この日時を含まない）である．
+}
+
+// RejudgeJobは，1回の再判定の要求と，その進捗および結果を表す構造体である．
+// 再判定はバックグラウンドで行われ，各解答の判定結果は以前の判定結果を残したまま新しい試行番号で保存される．
+type RejudgeJob struct {
+	RejudgeID  int             `json:"rejudge_id"`            // 再判定の一意識別子である．
+	UserID     int             `json:"user_id"`               // 再判定を要求したユーザーのIDである．
+	ProblemID  int             `json:"problem_id,omitempty"`  // 対象の問題のIDである（問題を指定した場合）．
+	SolutionID int             `json:"solution_id,omitempty"` // 対象の解答のIDである（1つの解答を指定した場合）．
+	From       *time.Time      `json:"from,omitempty"`        // 対象とする提出日時の開始である（指定した場合）．
+	To         *time.Time      `json:"to,omitempty"`          // 対象とする提出日時の終了である（指定した場合）．
+	Status     string          `json:"status"`                // 再判定の状態（"running" または "completed"）である．
+	Total      int             `json:"total"`                 // 再判定の対象となった解答の数である．
+	Completed  int             `json:"completed"`             // 再判定が終了した（失敗を含む）解答の数である．
+	Failed     int             `json:"failed"`                // 再判定に失敗した解答の数である．
+	Changed    int             `json:"changed"`               // 判定結果または得点が以前の判定結果から変化した解答の数である．
+	Results    []RejudgeResult `json:"results"`               // 再判定が終了した各解答の結果である．
+	StartedAt  time.Time       `json:"started_at"`            // 再判定を開始した日時である．
+	FinishedAt *time.Time      `json:"finished_at,omitempty"` // 再判定が終了した日時である（終了している場合）．
+}
+
+// RejudgeResultは，再判定による1つの解答の判定結果の変化を表す構造体である．
+type RejudgeResult struct {
+	SolutionID      int     `json:"solution_id"`                // 解答の一意識別子である．
+	Attempt         int     `json:"attempt,omitempty"`          // 再判定の結果を保存した試行番号である（再判定に成功した場合）．
+	PreviousVerdict string  `json:"previous_verdict,omitempty"` // 再判定前の最新の判定結果である（以前に判定されていた場合）．
+	Verdict         string  `json:"verdict,omitempty"`          // 再判定の判定結果である（再判定に成功した場合）．
+	PreviousScore   float64 `json:"previous_score"`             // 再判定前の最新の得点である．
+	Score           float64 `json:"score"`                      // 再判定の得点である．
+	Changed         bool    `json:"changed"`                    // 判定結果または得点が以前の判定結果から変化した場合はtrueである．
+	Error           string  `json:"error,omitempty"`            // 再判定に失敗した場合のエラーメッセージである．
+}
@@ -1,25 +1,233 @@
 package models
 
-import "time"
+import (
+	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 判定結果(Verdict)を表す定数群である．
+// 各テストケースの結果および解答全体の結果はこれらのいずれかの値をとる．
+const (
+	VerdictAccepted            = "AC"   // 正解である．
+	VerdictWrongAnswer         = "WA"   // 出力が期待される出力と異なる．
+	VerdictTimeLimitExceeded   = "TLE"  // 実行時間制限を超過した．
+	VerdictMemoryLimitExceeded = "MLE"  // メモリ制限を超過し，強制終了された．
+	VerdictOutputLimitExceeded = "OLE"  // 出力サイズ制限を超過した．
+	VerdictRuntimeError        = "RE"   // 実行時エラー（非ゼロの終了コード，またはシグナルによる終了）である．
+	VerdictCompileError        = "CE"   // コンパイルに失敗した．
+	VerdictInternalError       = "IE"   // ジャッジ側の内部エラーである．
+	VerdictSkipped             = "SKIP" // 判定方式が "fail_fast" の問題で，先に正解とならなかったテストケースがあるため実行しなかった．
+)
+
+// verdictPriorityは，解答全体の判定結果を決定する際の各判定結果の優先度である．
+// 値が大きいほど優先され，複数の判定結果が混在する場合は最も優先度の高いものが解答全体の判定結果となる．
+var verdictPriority = map[string]int{
+	VerdictSkipped:             -1,
+	VerdictAccepted:            0,
+	VerdictWrongAnswer:         1,
+	VerdictOutputLimitExceeded: 2,
+	VerdictTimeLimitExceeded:   3,
+	VerdictMemoryLimitExceeded: 4,
+	VerdictRuntimeError:        5,
+	VerdictInternalError:       6,
+	VerdictCompileError:        7,
+}
 
 // ResultDetailは，解答の評価結果の詳細を表す構造体である．
-// これには，テストケースの総数，正解数，不正解数，実行時間超過数，各テストケースの結果，
-// およびエラーメッセージが含まれる．
+// これには，解答全体の判定結果，テストケースの総数，判定結果ごとのテストケース数，各テストケースの結果，
+// 問題にサブタスクが設定されている場合は得点と各サブタスクの採点結果，およびエラーメッセージが含まれる．
 type ResultDetail struct {
-	TotalCases        int          `json:"total_cases"`         // テストケースの総数である．
-	CorrectCases      int          `json:"correct_cases"`       // 正解したテストケースの数である．
-	IncorrectCases    int          `json:"incorrect_cases"`     // 不正解だったテストケースの数である．
-	TimeLimitExceeded int          `json:"time_limit_exceeded"` // 実行時間超過となったテストケースの数である．
-	CaseResults       []CaseResult `json:"case_results"`        // 各テストケースの詳細結果を含む配列である．
-	ErrorMessage      string       `json:"error,omitempty"`     // 解答の実行中に発生したエラーメッセージである（存在する場合）．
+	Verdict             string          `json:"verdict"`                   // 解答全体の判定結果である．
+	TotalCases          int             `json:"total_cases"`               // テストケースの総数である．
+	CorrectCases        int             `json:"correct_cases"`             // 正解したテストケースの数である．
+	IncorrectCases      int             `json:"incorrect_cases"`           // 不正解だったテストケースの数である．
+	TimeLimitExceeded   int             `json:"time_limit_exceeded"`       // 実行時間超過となったテストケースの数である．
+	MemoryLimitExceeded int             `json:"memory_limit_exceeded"`     // メモリ制限超過となったテストケースの数である．
+	OutputLimitExceeded int             `json:"output_limit_exceeded"`     // 出力サイズ制限超過となったテストケースの数である．
+	RuntimeError        int             `json:"runtime_error"`             // 実行時エラーとなったテストケースの数である．
+	CompileError        int             `json:"compile_error"`             // コンパイルエラーとなったテストケースの数である．
+	InternalError       int             `json:"internal_error"`            // ジャッジ側の内部エラーとなったテストケースの数である．
+	SkippedCases        int             `json:"skipped_cases"`             // 判定を打ち切ったため実行しなかったテストケースの数である．
+	CaseResults         []CaseResult    `json:"case_results"`              // 各テストケースの詳細結果を含む配列である．
+	Score               float64         `json:"score,omitempty"`           // サブタスクの得点の合計である（問題にサブタスクが設定されている場合）．
+	MaxScore            float64         `json:"max_score,omitempty"`       // サブタスクの配点の合計である（問題にサブタスクが設定されている場合）．
+	SubtaskResults      []SubtaskResult `json:"subtask_results,omitempty"` // 各サブタスクの採点結果を含む配列である．
+	CompileOutput       string          `json:"compile_output,omitempty"`  // コンパイラの出力（警告を含む，サイズを制限して切り詰めたもの）である．
+	ErrorMessage        string          `json:"error,omitempty"`           // 解答の実行中に発生したエラーメッセージである（存在する場合）．
+	TestSetVersion      int             `json:"test_set_version"`          // 判定に用いたテストデータの版である．
+	Attempt             int             `json:"attempt,omitempty"`         // 判定の試行番号である．最初の判定は1となり，再判定のたびに1ずつ増える（データベースに保存された判定結果のみ）．
+	JudgedAt            time.Time       `json:"judged_at"`                 // 判定結果がデータベースに保存された日時である．
+	RequestID           string          `json:"-"`                         // 判定の依頼の一意識別子である．同じ依頼の判定結果を重複して保存しないために用いる．
+}
+
+// AddCaseResultは，テストケースの結果をResultDetailに追加し，判定結果に応じたカウンタと解答全体の判定結果を更新する．
+func (r *ResultDetail) AddCaseResult(caseResult CaseResult) {
+	switch caseResult.Result {
+	case VerdictAccepted:
+		r.CorrectCases++
+	case VerdictWrongAnswer:
+		r.IncorrectCases++
+	case VerdictTimeLimitExceeded:
+		r.TimeLimitExceeded++
+	case VerdictMemoryLimitExceeded:
+		r.MemoryLimitExceeded++
+	case VerdictOutputLimitExceeded:
+		r.OutputLimitExceeded++
+	case VerdictRuntimeError:
+		r.RuntimeError++
+	case VerdictCompileError:
+		r.CompileError++
+	case VerdictSkipped:
+		r.SkippedCases++
+	default:
+		r.InternalError++
+	}
+
+	if r.Verdict == "" || verdictPriority[caseResult.Result] > verdictPriority[r.Verdict] {
+		r.Verdict = caseResult.Result
+	}
+	r.CaseResults = append(r.CaseResults, caseResult)
+}
+
+// ScoreSubtasksは，テストケースの結果をもとに各サブタスクの得点と合計得点を計算する．
+// テストケースの得点率は，正解の場合は1，チェッカーが部分点を報告した場合はその値（0以上1以下に丸める），それ以外は0である．
+// サブタスクが設定されていない場合は何もしない．
+func (r *ResultDetail) ScoreSubtasks(subtasks []Subtask) {
+	if len(subtasks) == 0 {
+		return
+	}
+
+	caseResults := make(map[string]CaseResult, len(r.CaseResults))
+	for _, caseResult := range r.CaseResults {
+		caseResults[caseResult.CaseName] = caseResult
+		caseResults[strings.TrimSuffix(caseResult.CaseName, filepath.Ext(caseResult.CaseName))] = caseResult
+	}
+
+	r.Score = 0
+	r.MaxScore = 0
+	r.SubtaskResults = make([]SubtaskResult, 0, len(subtasks))
+	for _, subtask := range subtasks {
+		subtaskResult := SubtaskResult{
+			Name:     subtask.Name,
+			Scoring:  subtask.Scoring,
+			MaxScore: subtask.Points,
+		}
+
+		ratios := make([]float64, 0, len(subtask.CaseNames))
+		for _, caseName := range subtask.CaseNames {
+			caseResult, ok := caseResults[caseName]
+			if !ok {
+				// 結果の存在しないテストケースは不正解として扱う
+				caseResult = CaseResult{Result: VerdictInternalError}
+			}
+			if subtaskResult.Verdict == "" || verdictPriority[caseResult.Result] > verdictPriority[subtaskResult.Verdict] {
+				subtaskResult.Verdict = caseResult.Result
+			}
+			ratios = append(ratios, caseResult.scoreRatio())
+		}
+		if subtaskResult.Verdict == "" {
+			subtaskResult.Verdict = VerdictAccepted
+		}
+
+		subtaskResult.Score = subtask.Points * aggregateScoreRatios(subtask.Scoring, ratios)
+		r.Score += subtaskResult.Score
+		r.MaxScore += subtaskResult.MaxScore
+		r.SubtaskResults = append(r.SubtaskResults, subtaskResult)
+	}
+}
+
+// HideCasesは，問題の設定で公開されていないテストケース（非公開のテストケース）の名前とチェッカーのメッセージを伏せる．
+// 非公開のテストケースの名前は，元の名前の順に "hidden_1", "hidden_2", ... と置き換えられ，提出ごとに同じテストケースには同じ名前が付く．
+// 判定結果はデータベースには元の名前で保存されており，この関数はクライアントへの応答の直前に用いる．
+func (r *ResultDetail) HideCases(problem *Problem) {
+	hiddenNames := make([]string, 0, len(r.CaseResults))
+	for _, caseResult := range r.CaseResults {
+		if !problem.RevealsCase(caseResult.CaseName) {
+			hiddenNames = append(hiddenNames, caseResult.CaseName)
+		}
+	}
+	sort.Strings(hiddenNames)
+
+	aliases := make(map[string]string, len(hiddenNames))
+	for i, name := range hiddenNames {
+		aliases[name] = "hidden_" + strconv.Itoa(i+1)
+	}
+	for i := range r.CaseResults {
+		caseResult := &r.CaseResults[i]
+		if alias, ok := aliases[caseResult.CaseName]; ok {
+			caseResult.CaseName = alias
+			caseResult.CheckerMessage = ""
+		}
+	}
+}
+
+// aggregateScoreRatiosは，採点方式に従ってテストケースごとの得点率をサブタスク全体の得点率にまとめる．
+func aggregateScoreRatios(scoring string, ratios []float64) float64 {
+	if len(ratios) == 0 {
+		return 0
+	}
+
+	switch scoring {
+	case ScoringMin:
+		ratio := ratios[0]
+		for _, r := range ratios[1:] {
+			ratio = math.Min(ratio, r)
+		}
+		return ratio
+	case ScoringSum:
+		sum := 0.0
+		for _, r := range ratios {
+			sum += r
+		}
+		return sum / float64(len(ratios))
+	default: // ScoringAllOrNothing
+		for _, r := range ratios {
+			if r < 1 {
+				return 0
+			}
+		}
+		return 1
+	}
+}
+
+// SubtaskResultは，1つのサブタスクの採点結果を表す構造体である．
+type SubtaskResult struct {
+	Name     string  `json:"name"`      // サブタスクの名前である．
+	Scoring  string  `json:"scoring"`   // サブタスクの採点方式である．
+	Verdict  string  `json:"verdict"`   // サブタスクに含まれるテストケースの判定結果のうち最も優先度の高いものである．
+	Score    float64 `json:"score"`     // サブタスクの得点である．
+	MaxScore float64 `json:"max_score"` // サブタスクの配点である．
 }
 
 // CaseResultは，個々のテストケースの実行結果を表す構造体である．
-// テストケースの名前，結果（正解，不正解，実行時間超過），および実行時間が含まれる．
+// テストケースの名前，判定結果，実行時間，CPU時間，ピークメモリ使用量，および実行時エラーの場合は終了コードとシグナルが含まれる．
+// 問題にチェッカーが設定されている場合は，チェッカーのメッセージと部分点も含まれる．
+// 問題の設定で公開されているテストケースでは，サイズを制限して切り詰めた標準エラー出力，提出プログラムの出力，期待される出力も含まれる．
 type CaseResult struct {
-	CaseName      string        `json:"case_name"`      // テストケースの名前である．
-	Result        string        `json:"result"`         // テストケースの結果（"correct", "incorrect", "time_limit_exceeded"）である．
-	ExecutionTime time.Duration `json:"execution_time"` // テストケースの実行時間（ミリ秒）である．
+	CaseName       string        `json:"case_name"`                 // テストケースの名前である．
+	Result         string        `json:"result"`                    // テストケースの判定結果（"AC", "WA", "TLE", "MLE", "OLE", "RE", "CE", "IE"）である．
+	ExecutionTime  time.Duration `json:"execution_time"`            // テストケースの実行時間（ウォールタイム，ナノ秒）である．
+	CPUTime        time.Duration `json:"cpu_time"`                  // テストケースの実行に要したCPU時間（ナノ秒）である．
+	PeakMemory     int64         `json:"peak_memory"`               // テストケースの実行中のピークメモリ使用量（バイト）である．
+	ExitCode       int           `json:"exit_code"`                 // 提出プログラムの終了コードである．
+	Signal         string        `json:"signal,omitempty"`          // 提出プログラムがシグナルにより終了した場合のシグナル名（"SIGSEGV" など）である．
+	CheckerMessage string        `json:"checker_message,omitempty"` // チェッカーが出力したメッセージである（チェッカーが設定されている場合）．
+	Score          float64       `json:"score,omitempty"`           // チェッカーが部分点を報告した場合の得点である．
+	Stderr         string        `json:"stderr,omitempty"`          // 提出プログラムの標準エラー出力である（公開されている場合）．
+	Stdout         string        `json:"stdout,omitempty"`          // 提出プログラムの出力である（公開されている場合）．
+	ExpectedOutput string        `json:"expected_output,omitempty"` // 期待される出力である（公開されている場合）．
+}
+
+// scoreRatioは，テストケースの得点率（0以上1以下）を返す．
+func (c *CaseResult) scoreRatio() float64 {
+	if c.Result == VerdictAccepted {
+		return 1
+	}
+	return math.Max(0, math.Min(1, c.Score))
 }
 
 // Solutionは，ユーザーが提出した解答の情報を保持する構造体である．
@@ -31,3 +239,66 @@ type Solution struct {
 	Code        string    `json:"code"`         // 解答のソースコードである．
 	SubmittedAt time.Time `json:"submitted_at"` // 解答の提出日時である．
 }
+
+// 解答の判定状況を表す定数群である．
+// 判定状況は Pending → Queued → Compiling → Running → Judged / SystemError の順に遷移し，再判定では Judged / SystemError から Queued に戻る．
+// 判定を依頼するたびに判定状況は Queued から始まり，以前の依頼に対する判定状況の更新は反映されない．
+const (
+	SubmissionStatusPending     = "Pending"     // 提出されたが，まだ判定を依頼していない．
+	SubmissionStatusQueued      = "Queued"      // ジャッジサーバーのキューで判定を待っている．
+	SubmissionStatusCompiling   = "Compiling"   // テストデータの準備とコンパイルを行っている．
+	SubmissionStatusRunning     = "Running"     // テストケースを実行している．
+	SubmissionStatusJudged      = "Judged"      // 判定が終了し，判定結果が保存された．
+	SubmissionStatusSystemError = "SystemError" // ジャッジサーバーとの通信の失敗などにより，判定結果を得られなかった．
+)
+
+// submissionStatusTransitionsは，各判定状況から遷移できる判定状況である．
+// 同じ判定状況への遷移（待機順や実行したテストケース数の更新）は常に許可される．
+// コンパイルエラーや通信の失敗では，途中の判定状況を経ずに Judged や SystemError へ遷移する．
+var submissionStatusTransitions = map[string][]string{
+	SubmissionStatusPending:     {SubmissionStatusQueued, SubmissionStatusSystemError},
+	SubmissionStatusQueued:      {SubmissionStatusCompiling, SubmissionStatusRunning, SubmissionStatusJudged, SubmissionStatusSystemError},
+	SubmissionStatusCompiling:   {SubmissionStatusRunning, SubmissionStatusJudged, SubmissionStatusSystemError},
+	SubmissionStatusRunning:     {SubmissionStatusJudged, SubmissionStatusSystemError},
+	SubmissionStatusJudged:      {SubmissionStatusQueued},
+	SubmissionStatusSystemError: {SubmissionStatusQueued},
+}
+
+// SubmissionStatusSourcesは，判定状況 to へ遷移できる判定状況（to自身を含む）を返す．
+func SubmissionStatusSources(to string) []string {
+	var sources []string
+	for from := range submissionStatusTransitions {
+		if CanTransitionSubmissionStatus(from, to) {
+			sources = append(sources, from)
+		}
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// CanTransitionSubmissionStatusは，判定状況 from から to へ遷移できる場合にtrueを返す．
+func CanTransitionSubmissionStatus(from, to string) bool {
+	if from == to {
+		return true
+	}
+	for _, next := range submissionStatusTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// SubmissionStatusは，解答の判定状況を表す構造体である．
+// 判定状況はデータベースに保存されるため，WebSocket接続が切断された場合もREST APIで取得して判定の終了を確認できる．
+type SubmissionStatus struct {
+	SolutionID    int           `json:"solution_id"`              // 解答の一意識別子である．
+	Status        string        `json:"status"`                   // 判定状況（Pending, Queued, Compiling, Running, Judged, SystemError）である．
+	CurrentCase   int           `json:"current_case"`             // 判定が終了したテストケースの数である（Running の場合）．
+	TotalCases    int           `json:"total_cases"`              // テストケースの総数である（Running 以降）．
+	QueuePosition int           `json:"queue_position,omitempty"` // ジャッジサーバーのキューでの順番（1始まり）である（Queued の場合）．
+	Message       string        `json:"message,omitempty"`        // 判定に失敗した理由である（SystemError の場合）．
+	UpdatedAt     time.Time     `json:"updated_at"`               // 判定状況が更新された日時である．
+	RequestID     string        `json:"-"`                        // 判定状況が対象とする判定の依頼の一意識別子である．
+	Result        *ResultDetail `json:"result,omitempty"`         // 最新の判定結果である（Judged の場合）．
+}
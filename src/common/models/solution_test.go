@@ -0,0 +1,89 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAggregateScoreRatios(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoring string
+		ratios  []float64
+		want    float64
+	}{
+		{"all or nothing all accepted", ScoringAllOrNothing, []float64{1, 1, 1}, 1},
+		{"all or nothing partial", ScoringAllOrNothing, []float64{1, 0.99, 1}, 0},
+		{"default scoring is all or nothing", "", []float64{1, 0.5}, 0},
+		{"min", ScoringMin, []float64{1, 0.25, 0.5}, 0.25},
+		{"min single", ScoringMin, []float64{0.75}, 0.75},
+		{"sum", ScoringSum, []float64{1, 0, 0.5, 0.5}, 0.5},
+		{"no cases", ScoringSum, nil, 0},
+		{"no cases all or nothing", ScoringAllOrNothing, []float64{}, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := aggregateScoreRatios(test.scoring, test.ratios); math.Abs(got-test.want) > 1e-9 {
+				t.Errorf("aggregateScoreRatios(%q, %v) = %v, want %v", test.scoring, test.ratios, got, test.want)
+			}
+		})
+	}
+}
+
+func TestScoreSubtasks(t *testing.T) {
+	caseResults := []CaseResult{
+		{CaseName: "01.in", Result: VerdictAccepted},
+		{CaseName: "02.in", Result: VerdictAccepted},
+		{CaseName: "03.in", Result: VerdictWrongAnswer, Score: 0.5},
+		{CaseName: "04.in", Result: VerdictTimeLimitExceeded},
+		{CaseName: "05.in", Result: VerdictWrongAnswer, Score: 1.5},
+		{CaseName: "06.in", Result: VerdictWrongAnswer, Score: -1},
+	}
+	subtasks := []Subtask{
+		{Name: "small", Points: 30, Scoring: ScoringAllOrNothing, CaseNames: []string{"01.in", "02"}},
+		{Name: "partial", Points: 40, Scoring: ScoringMin, CaseNames: []string{"01", "03.in"}},
+		{Name: "large", Points: 30, Scoring: ScoringSum, CaseNames: []string{"02", "03", "04"}},
+		{Name: "clamped", Points: 10, Scoring: ScoringSum, CaseNames: []string{"05", "06"}},
+		{Name: "missing", Points: 10, Scoring: ScoringAllOrNothing, CaseNames: []string{"01", "99"}},
+		{Name: "empty", Points: 5, Scoring: ScoringAllOrNothing},
+	}
+	want := []SubtaskResult{
+		{Name: "small", Scoring: ScoringAllOrNothing, Verdict: VerdictAccepted, Score: 30, MaxScore: 30},
+		{Name: "partial", Scoring: ScoringMin, Verdict: VerdictWrongAnswer, Score: 20, MaxScore: 40},
+		{Name: "large", Scoring: ScoringSum, Verdict: VerdictTimeLimitExceeded, Score: 15, MaxScore: 30},
+		{Name: "clamped", Scoring: ScoringSum, Verdict: VerdictWrongAnswer, Score: 5, MaxScore: 10},
+		{Name: "missing", Scoring: ScoringAllOrNothing, Verdict: VerdictInternalError, Score: 0, MaxScore: 10},
+		{Name: "empty", Scoring: ScoringAllOrNothing, Verdict: VerdictAccepted, Score: 0, MaxScore: 5},
+	}
+
+	result := ResultDetail{CaseResults: caseResults}
+	result.ScoreSubtasks(subtasks)
+
+	if len(result.SubtaskResults) != len(want) {
+		t.Fatalf("got %d subtask results, want %d", len(result.SubtaskResults), len(want))
+	}
+	for i, got := range result.SubtaskResults {
+		if got.Name != want[i].Name || got.Scoring != want[i].Scoring || got.Verdict != want[i].Verdict ||
+			math.Abs(got.Score-want[i].Score) > 1e-9 || got.MaxScore != want[i].MaxScore {
+			t.Errorf("subtask %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if math.Abs(result.Score-70) > 1e-9 || result.MaxScore != 125 {
+		t.Errorf("score = %v / %v, want 70 / 125", result.Score, result.MaxScore)
+	}
+
+	// 再計算しても結果が累積しない
+	result.ScoreSubtasks(subtasks)
+	if math.Abs(result.Score-70) > 1e-9 || result.MaxScore != 125 || len(result.SubtaskResults) != len(want) {
+		t.Errorf("rescored = %v / %v with %d subtasks, want 70 / 125 with %d", result.Score, result.MaxScore, len(result.SubtaskResults), len(want))
+	}
+}
+
+func TestScoreSubtasksWithoutSubtasks(t *testing.T) {
+	result := ResultDetail{CaseResults: []CaseResult{{CaseName: "01.in", Result: VerdictAccepted}}}
+	result.ScoreSubtasks(nil)
+	if result.Score != 0 || result.MaxScore != 0 || result.SubtaskResults != nil {
+		t.Errorf("ScoreSubtasks(nil) changed the result: %+v", result)
+	}
+}
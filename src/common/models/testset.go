@@ -0,0 +1,54 @@
+package models
+
+import (
+	"path"
+	"strings"
+	"time"
+)
+
+// テストデータのファイルのタイプ（MinIOとジャッジサーバーでの保存先のディレクトリ名）を表す定数群である．
+const (
+	TestSetFileInput      = "in"         // 入力ファイル
+	TestSetFileOutput     = "out"        // 期待される出力ファイル
+	TestSetFileChecker    = "checker"    // チェッカーのソースファイル
+	TestSetFileInteractor = "interactor" // インタラクタのソースファイル
+)
+
+// LegacyTestSetVersionは，テストデータの版を導入する前に作成された問題（版が0の問題）のテストデータを移行する版である．
+// webサーバーは起動時に移行前の形式のテストデータをこの版にコピーし，ジャッジサーバーは版が0の判定リクエストをこの版で判定する．
+const LegacyTestSetVersion = 1
+
+// TestSetFileTypesは，テストデータに含まれるファイルのタイプのリストである．
+var TestSetFileTypes = []string{TestSetFileInput, TestSetFileOutput, TestSetFileChecker, TestSetFileInteractor}
+
+// TestSetManifestは，問題のテストデータ（入出力ファイル，チェッカー，インタラクタ）の1つの版に含まれるファイルの一覧を表す構造体である．
+// テストデータは問題の作成時と更新時に新しい版としてアップロードされ，マニフェストは全てのファイルのアップロードが完了した後に保存される．
+// ジャッジサーバーはマニフェストのハッシュ値と一致するファイルのみを判定に用いる．
+type TestSetManifest struct {
+	ProblemID int           `json:"problem_id"` // 問題のIDである．
+	Version   int           `json:"version"`    // テストデータの版である．最初の版は1となり，更新のたびに1ずつ増える．
+	Files     []TestSetFile `json:"files"`      // テストデータに含まれるファイルのリストである．
+	CreatedAt time.Time     `json:"created_at"` // テストデータがアップロードされた日時である．
+}
+
+// TestSetFileは，テストデータに含まれる1つのファイルを表す構造体である．
+type TestSetFile struct {
+	Path   string `json:"path"`   // ファイルのタイプとファイル名から成るパス（例: "in/case01.txt"）である．
+	Size   int64  `json:"size"`   // ファイルのサイズ（バイト）である．
+	SHA256 string `json:"sha256"` // ファイルの内容のSHA-256ハッシュ値（16進表記）である．
+}
+
+// IsValidPathは，ファイルのパスが「既知のファイルのタイプ/ファイル名」の形式であり，テストデータの外を指していない場合にtrueを返す．
+// 改ざんされたマニフェストにより，ジャッジサーバーがテストデータの保存先の外に書き込むことを防ぐために用いる．
+func (f *TestSetFile) IsValidPath() bool {
+	fileType, fileName, ok := strings.Cut(f.Path, "/")
+	if !ok || fileName == "" || fileName == "." || fileName == ".." || strings.ContainsAny(fileName, `/\`) || path.Clean(f.Path) != f.Path {
+		return false
+	}
+	for _, known := range TestSetFileTypes {
+		if fileType == known {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package signature
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"procon_web_service/src/common/config"
+	commonerrors "procon_web_service/src/common/errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 署名されたリクエストのヘッダーの名前である．
+const (
+	TimestampHeader = "X-Judge-Timestamp" // リクエストに署名した日時（Unix時間，秒）．
+	NonceHeader     = "X-Judge-Nonce"     // リクエストごとに一意な乱数（16進表記）．同じ値のリクエストは再送として拒否される．
+	SignatureHeader = "X-Judge-Signature" // リクエストのHMAC-SHA256署名（16進表記）．
+)
+
+// ErrMissingSecretは，署名に用いる共有鍵が設定されていない場合のエラーである．
+var ErrMissingSecret = errors.New("JUDGE_SHARED_SECRET is not set; the web server and judge servers require a shared secret to sign their communication")
+
+// Signerは，webサーバーとジャッジサーバーの間のリクエストと判定キューのメッセージに署名し，検証する構造体である．
+// 署名は共有鍵によるHMAC-SHA256で，HTTPリクエストの署名はメソッド，ホスト，パスとクエリ，日時，nonce，ボディのハッシュを対象とする．
+// 検証では，日時が許容範囲内であることと，nonceが許容範囲の時間内に使用されていないことを確認して再送を拒否する．
+type Signer struct {
+	secret  []byte
+	maxSkew time.Duration
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time // 使用済みのnonceと，再送として拒否する期限
+	lastPrune time.Time            // 期限を過ぎたnonceを最後に削除した日時
+}
+
+// Newは，環境変数の設定に基づいてSignerを生成する関数である．
+//
+// 戻り値:
+// - *Signer: 生成されたSigner．
+// - error: 共有鍵が設定されていない場合はErrMissingSecret，またはnil．
+func New() (*Signer, error) {
+	signatureConfig := config.NewSignatureConfig()
+	if signatureConfig.Secret == "" {
+		return nil, ErrMissingSecret
+	}
+	return &Signer{
+		secret:  []byte(signatureConfig.Secret),
+		maxSkew: signatureConfig.MaxSkew,
+		nonces:  make(map[string]time.Time),
+	}, nil
+}
+
+// Signは，データのHMAC-SHA256署名を16進表記で返す関数である．
+//
+// パラメータ:
+// - data []byte: 署名するデータ．
+//
+// 戻り値:
+// - string: 署名．
+func (s *Signer) Sign(data []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verifyは，データの署名が正しい場合にtrueを返す関数である．比較は定数時間で行う．
+//
+// パラメータ:
+// - data []byte: 署名されたデータ．
+// - signature string: 検証する署名．
+//
+// 戻り値:
+// - bool: 署名が正しい場合はtrue．
+func (s *Signer) Verify(data []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// SignRequestは，HTTPリクエストに日時，nonce，署名のヘッダーを設定する関数である．
+// リクエストのURL（ホストを含む）とメソッドを設定した後に呼び出す必要がある．
+//
+// パラメータ:
+// - req *http.Request: 署名するリクエスト．
+// - body []byte: リクエストのボディ（ボディがない場合はnil）．
+//
+// 戻り値:
+// - error: nonceの生成に失敗した場合のエラー，またはnil．
+func (s *Signer) SignRequest(req *http.Request, body []byte) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, hex.EncodeToString(nonce))
+	req.Header.Set(SignatureHeader, s.Sign(canonicalRequest(req.Method, req.URL.Host, req.URL.RequestURI(), timestamp, req.Header.Get(NonceHeader), body)))
+	return nil
+}
+
+// CheckHeadersは，リクエストに日時，nonce，署名のヘッダーが全て設定されていることを確認する関数である．
+// ボディを読み込む前に署名のないリクエストを拒否するために用いる．
+//
+// パラメータ:
+// - r *http.Request: 確認するリクエスト．
+//
+// 戻り値:
+// - error: いずれかのヘッダーがない場合のSignatureError，またはnil．
+func CheckHeaders(r *http.Request) error {
+	if r.Header.Get(TimestampHeader) == "" || r.Header.Get(NonceHeader) == "" || r.Header.Get(SignatureHeader) == "" {
+		return commonerrors.NewSignatureError("Missing request signature: requests to the judge server must be signed by the web server")
+	}
+	return nil
+}
+
+// VerifyRequestは，受信したHTTPリクエストの署名を検証する関数である．
+// 署名のヘッダーがない場合，日時が許容範囲外の場合，nonceが既に使用された場合，署名が一致しない場合はそれぞれ異なるメッセージのエラーを返す．
+//
+// パラメータ:
+// - r *http.Request: 検証するリクエスト．
+// - body []byte: リクエストのボディ．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のSignatureError，またはnil．
+func (s *Signer) VerifyRequest(r *http.Request, body []byte) error {
+	if err := CheckHeaders(r); err != nil {
+		return err
+	}
+	timestamp := r.Header.Get(TimestampHeader)
+	nonce := r.Header.Get(NonceHeader)
+	signature := r.Header.Get(SignatureHeader)
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return commonerrors.NewSignatureError("Invalid request timestamp")
+	}
+	signedAt := time.Unix(seconds, 0)
+	if skew := time.Since(signedAt); skew > s.maxSkew || skew < -s.maxSkew {
+		return commonerrors.NewSignatureError("Request timestamp is outside the allowed window")
+	}
+
+	if !s.Verify(canonicalRequest(r.Method, r.Host, r.URL.RequestURI(), timestamp, nonce, body), signature) {
+		return commonerrors.NewSignatureError("Invalid request signature")
+	}
+
+	// 署名が正しいリクエストのnonceのみを記録し，許容範囲の時間内の再送を拒否
+	if !s.useNonce(nonce, signedAt.Add(s.maxSkew)) {
+		return commonerrors.NewSignatureError("Request nonce has already been used")
+	}
+	return nil
+}
+
+// useNonceは，nonceを使用済みとして記録する．既に使用されている場合はfalseを返す．
+// 期限を過ぎたnonceは日時の検証で拒否されるため，1秒に1回まとめて記録から削除する．
+func (s *Signer) useNonce(nonce string, expiresAt time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiry, ok := s.nonces[nonce]; ok && now.Before(expiry) {
+		return false
+	}
+	if now.Sub(s.lastPrune) >= time.Second {
+		s.lastPrune = now
+		for n, expiry := range s.nonces {
+			if now.After(expiry) {
+				delete(s.nonces, n)
+			}
+		}
+	}
+	s.nonces[nonce] = expiresAt
+	return true
+}
+
+// canonicalRequestは，HTTPリクエストの署名の対象となる文字列を生成する．
+// ホストを含めることで，あるジャッジサーバーへのリクエストを他のジャッジサーバーに再送することを防ぐ．
+func canonicalRequest(method, host, requestURI, timestamp, nonce string, body []byte) []byte {
+	bodyHash := sha256.Sum256(body)
+	return []byte(strings.Join([]string{method, host, requestURI, timestamp, nonce, hex.EncodeToString(bodyHash[:])}, "\n"))
+}
@@ -0,0 +1,197 @@
+package signature
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	commonerrors "procon_web_service/src/common/errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testURL = "http://judge-1:8080/judge/run?debug=1"
+
+func newTestSigner(secret string) *Signer {
+	return &Signer{secret: []byte(secret), maxSkew: time.Minute, nonces: make(map[string]time.Time)}
+}
+
+// newSignedRequest - 署名したリクエストを，ジャッジサーバーが受信したリクエストとして生成
+func newSignedRequest(t *testing.T, signer *Signer, body string) *http.Request {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, testURL, strings.NewReader(body))
+	if err := signer.SignRequest(req, []byte(body)); err != nil {
+		t.Fatal(err)
+	}
+	return req
+}
+
+// signAt - 指定した日時とnonceでリクエストに署名
+func signAt(signer *Signer, req *http.Request, body string, signedAt time.Time, nonce string) {
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, signer.Sign(canonicalRequest(req.Method, req.URL.Host, req.URL.RequestURI(), timestamp, nonce, []byte(body))))
+}
+
+func assertSignatureError(t *testing.T, err error, message string) {
+	t.Helper()
+	var signatureError *commonerrors.SignatureError
+	if !errors.As(err, &signatureError) {
+		t.Fatalf("VerifyRequest returned %v, want SignatureError", err)
+	}
+	if !strings.Contains(signatureError.Message, message) {
+		t.Errorf("VerifyRequest returned %q, want message containing %q", signatureError.Message, message)
+	}
+}
+
+func TestNewRequiresSecret(t *testing.T) {
+	t.Setenv("JUDGE_SHARED_SECRET", "")
+	if _, err := New(); !errors.Is(err, ErrMissingSecret) {
+		t.Errorf("New returned %v, want %v", err, ErrMissingSecret)
+	}
+
+	t.Setenv("JUDGE_SHARED_SECRET", "secret")
+	t.Setenv("JUDGE_SIGNATURE_MAX_SKEW_SECONDS", "30")
+	signer, err := New()
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+	if signer.maxSkew != 30*time.Second {
+		t.Errorf("maxSkew = %v, want 30s", signer.maxSkew)
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := newTestSigner("secret")
+	sig := signer.Sign([]byte("data"))
+	if !signer.Verify([]byte("data"), sig) {
+		t.Error("Verify rejected a valid signature")
+	}
+	if signer.Verify([]byte("other"), sig) {
+		t.Error("Verify accepted a signature of different data")
+	}
+	if newTestSigner("other").Verify([]byte("data"), sig) {
+		t.Error("Verify accepted a signature made with a different secret")
+	}
+	if signer.Verify([]byte("data"), "not hex") {
+		t.Error("Verify accepted a malformed signature")
+	}
+}
+
+func TestVerifyRequest(t *testing.T) {
+	signer := newTestSigner("secret")
+	req := newSignedRequest(t, signer, `{"code":"x"}`)
+	if err := signer.VerifyRequest(req, []byte(`{"code":"x"}`)); err != nil {
+		t.Errorf("VerifyRequest rejected a valid request: %v", err)
+	}
+}
+
+func TestVerifyRequestTampered(t *testing.T) {
+	body := `{"code":"x"}`
+	tests := []struct {
+		name   string
+		modify func(req *http.Request) []byte
+	}{
+		{"body", func(req *http.Request) []byte { return []byte(`{"code":"y"}`) }},
+		{"method", func(req *http.Request) []byte { req.Method = http.MethodPut; return []byte(body) }},
+		{"host", func(req *http.Request) []byte { req.Host = "judge-2:8080"; return []byte(body) }},
+		{"query", func(req *http.Request) []byte { req.URL.RawQuery = "debug=0"; return []byte(body) }},
+		{"secret", func(req *http.Request) []byte {
+			signAt(newTestSigner("other"), req, body, time.Now(), "0123")
+			return []byte(body)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer := newTestSigner("secret")
+			req := newSignedRequest(t, signer, body)
+			assertSignatureError(t, signer.VerifyRequest(req, test.modify(req)), "Invalid request signature")
+		})
+	}
+}
+
+func TestVerifyRequestMissingHeaders(t *testing.T) {
+	for _, header := range []string{TimestampHeader, NonceHeader, SignatureHeader} {
+		t.Run(header, func(t *testing.T) {
+			signer := newTestSigner("secret")
+			req := newSignedRequest(t, signer, "")
+			req.Header.Del(header)
+			assertSignatureError(t, signer.VerifyRequest(req, nil), "Missing request signature")
+		})
+	}
+}
+
+func TestVerifyRequestSkew(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset time.Duration
+		valid  bool
+	}{
+		{"now", 0, true},
+		{"past within window", -50 * time.Second, true},
+		{"future within window", 50 * time.Second, true},
+		{"past outside window", -2 * time.Minute, false},
+		{"future outside window", 2 * time.Minute, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signer := newTestSigner("secret")
+			req := httptest.NewRequest(http.MethodGet, testURL, nil)
+			signAt(signer, req, "", time.Now().Add(test.offset), "0123")
+			err := signer.VerifyRequest(req, nil)
+			if test.valid && err != nil {
+				t.Errorf("VerifyRequest rejected a request signed %v from now: %v", test.offset, err)
+			}
+			if !test.valid {
+				assertSignatureError(t, err, "outside the allowed window")
+			}
+		})
+	}
+
+	signer := newTestSigner("secret")
+	req := newSignedRequest(t, signer, "")
+	req.Header.Set(TimestampHeader, "yesterday")
+	assertSignatureError(t, signer.VerifyRequest(req, nil), "Invalid request timestamp")
+}
+
+func TestVerifyRequestNonceReuse(t *testing.T) {
+	signer := newTestSigner("secret")
+	req := newSignedRequest(t, signer, "body")
+	if err := signer.VerifyRequest(req, []byte("body")); err != nil {
+		t.Fatalf("VerifyRequest rejected the first request: %v", err)
+	}
+	assertSignatureError(t, signer.VerifyRequest(req, []byte("body")), "nonce has already been used")
+
+	// 同じnonceでも署名し直したリクエストは再送として拒否する
+	replayed := httptest.NewRequest(http.MethodPost, testURL, nil)
+	signAt(signer, replayed, "other", time.Now(), req.Header.Get(NonceHeader))
+	assertSignatureError(t, signer.VerifyRequest(replayed, []byte("other")), "nonce has already been used")
+
+	// 署名が正しくないリクエストのnonceは記録しない
+	forged := httptest.NewRequest(http.MethodPost, testURL, nil)
+	signAt(newTestSigner("other"), forged, "", time.Now(), "fresh")
+	assertSignatureError(t, signer.VerifyRequest(forged, nil), "Invalid request signature")
+	valid := httptest.NewRequest(http.MethodPost, testURL, nil)
+	signAt(signer, valid, "", time.Now(), "fresh")
+	if err := signer.VerifyRequest(valid, nil); err != nil {
+		t.Errorf("VerifyRequest rejected a nonce used only by a forged request: %v", err)
+	}
+}
+
+func TestUseNonceExpiry(t *testing.T) {
+	signer := newTestSigner("secret")
+	if !signer.useNonce("a", time.Now().Add(-time.Second)) {
+		t.Fatal("useNonce rejected a new nonce")
+	}
+	// 期限を過ぎたnonceは記録から削除され，再び使用できる
+	if !signer.useNonce("a", time.Now().Add(time.Minute)) {
+		t.Error("useNonce rejected an expired nonce")
+	}
+	if signer.useNonce("a", time.Now().Add(time.Minute)) {
+		t.Error("useNonce accepted a nonce that has not expired")
+	}
+}
@@ -0,0 +1,226 @@
+package compare
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"procon_web_service/src/common/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	readBufferSize = 64 << 10 // ファイル読み込み時のバッファサイズ(バイト)
+	maxTokenSize   = 64 << 20 // 1つのトークンまたは行の最大サイズ(バイト)
+)
+
+// Comparator - 提出プログラムの出力と期待される出力を比較する比較器
+type Comparator interface {
+	// Compare - 出力が期待される出力と一致するとみなせる場合はtrueを返す
+	Compare(actual, expected io.Reader) (bool, error)
+}
+
+// New - 問題に設定された比較モードに対応する比較器を生成
+// 比較モードが空文字列の場合は完全一致で比較する．
+func New(mode string, epsilon float64) (Comparator, error) {
+	switch mode {
+	case "", models.CompareModeExact:
+		return exactComparator{}, nil
+	case models.CompareModeLines:
+		return linesComparator{}, nil
+	case models.CompareModeTokens:
+		return tokensComparator{equal: func(a, b string) bool { return a == b }}, nil
+	case models.CompareModeCaseInsensitive:
+		return tokensComparator{equal: strings.EqualFold}, nil
+	case models.CompareModeFloat:
+		if epsilon <= 0 {
+			epsilon = models.DefaultFloatEpsilon
+		}
+		return tokensComparator{equal: floatEqual(epsilon)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compare mode: %s", mode)
+	}
+}
+
+// CompareFiles - 比較器を用いて2つのファイルを比較
+func CompareFiles(comparator Comparator, actualPath, expectedPath string) (bool, error) {
+	actual, err := os.Open(actualPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open output file: %v", err)
+	}
+	defer actual.Close()
+
+	expected, err := os.Open(expectedPath)
+	if err != nil {
+		return false, fmt.Errorf("failed to open expected output file: %v", err)
+	}
+	defer expected.Close()
+
+	return comparator.Compare(actual, expected)
+}
+
+// exactComparator - バイト単位で完全一致を判定する比較器
+type exactComparator struct{}
+
+func (exactComparator) Compare(actual, expected io.Reader) (bool, error) {
+	actualReader := bufio.NewReaderSize(actual, readBufferSize)
+	expectedReader := bufio.NewReaderSize(expected, readBufferSize)
+	actualBuf := make([]byte, readBufferSize)
+	expectedBuf := make([]byte, readBufferSize)
+
+	for {
+		n, actualErr := io.ReadFull(actualReader, actualBuf)
+		m, expectedErr := io.ReadFull(expectedReader, expectedBuf)
+		if !bytes.Equal(actualBuf[:n], expectedBuf[:m]) {
+			return false, nil
+		}
+
+		actualEOF := actualErr == io.EOF || actualErr == io.ErrUnexpectedEOF
+		expectedEOF := expectedErr == io.EOF || expectedErr == io.ErrUnexpectedEOF
+		if actualErr != nil && !actualEOF {
+			return false, actualErr
+		}
+		if expectedErr != nil && !expectedEOF {
+			return false, expectedErr
+		}
+		if actualEOF || expectedEOF {
+			return actualEOF == expectedEOF, nil
+		}
+	}
+}
+
+// linesComparator - 各行の末尾の空白と，出力末尾の空行を無視して行単位で比較する比較器
+type linesComparator struct{}
+
+func (linesComparator) Compare(actual, expected io.Reader) (bool, error) {
+	actualLines := newLineReader(actual)
+	expectedLines := newLineReader(expected)
+
+	for {
+		actualLine, actualOK := actualLines.next()
+		expectedLine, expectedOK := expectedLines.next()
+		if !actualOK || !expectedOK {
+			if err := firstError(actualLines.err(), expectedLines.err()); err != nil {
+				return false, err
+			}
+			// 一方が終了した場合は，他方の残りの行が全て空行であれば一致とみなす
+			if actualOK && actualLine != "" {
+				return false, nil
+			}
+			if expectedOK && expectedLine != "" {
+				return false, nil
+			}
+			if !actualOK && !expectedOK {
+				return true, nil
+			}
+			if actualOK {
+				return actualLines.restIsEmpty()
+			}
+			return expectedLines.restIsEmpty()
+		}
+		if actualLine != expectedLine {
+			return false, nil
+		}
+	}
+}
+
+// tokensComparator - 空白文字で区切られたトークン単位で比較する比較器
+type tokensComparator struct {
+	equal func(actual, expected string) bool // 2つのトークンが一致するとみなせる場合はtrue
+}
+
+func (c tokensComparator) Compare(actual, expected io.Reader) (bool, error) {
+	actualTokens := newTokenScanner(actual)
+	expectedTokens := newTokenScanner(expected)
+
+	for {
+		actualOK := actualTokens.Scan()
+		expectedOK := expectedTokens.Scan()
+		if !actualOK || !expectedOK {
+			if err := firstError(actualTokens.Err(), expectedTokens.Err()); err != nil {
+				return false, err
+			}
+			return actualOK == expectedOK, nil
+		}
+		if !c.equal(actualTokens.Text(), expectedTokens.Text()) {
+			return false, nil
+		}
+	}
+}
+
+// floatEqual - 絶対誤差または相対誤差が epsilon 以下であれば一致とみなす比較関数を生成
+// 数値として解釈できないトークンは完全一致で比較する．
+func floatEqual(epsilon float64) func(actual, expected string) bool {
+	return func(actual, expected string) bool {
+		if actual == expected {
+			return true
+		}
+		a, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return false
+		}
+		e, err := strconv.ParseFloat(expected, 64)
+		if err != nil {
+			return false
+		}
+		if math.IsNaN(a) || math.IsNaN(e) || math.IsInf(a, 0) || math.IsInf(e, 0) {
+			return false
+		}
+		diff := math.Abs(a - e)
+		return diff <= epsilon || diff <= epsilon*math.Abs(e)
+	}
+}
+
+// lineReader - 末尾の空白文字を取り除いた行を順に読み出すリーダー
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, readBufferSize), maxTokenSize)
+	return &lineReader{scanner: scanner}
+}
+
+func (r *lineReader) next() (string, bool) {
+	if !r.scanner.Scan() {
+		return "", false
+	}
+	return strings.TrimRight(r.scanner.Text(), " \t\r"), true
+}
+
+func (r *lineReader) err() error {
+	return r.scanner.Err()
+}
+
+// restIsEmpty - 残りの行が全て空行であればtrue
+func (r *lineReader) restIsEmpty() (bool, error) {
+	for {
+		line, ok := r.next()
+		if !ok {
+			return true, r.err()
+		}
+		if line != "" {
+			return false, nil
+		}
+	}
+}
+
+func newTokenScanner(r io.Reader) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, readBufferSize), maxTokenSize)
+	scanner.Split(bufio.ScanWords)
+	return scanner
+}
+
+func firstError(errs ...error) error {
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package compare
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"procon_web_service/src/common/models"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	long := strings.Repeat("a", readBufferSize+10)
+
+	tests := []struct {
+		name     string
+		mode     string
+		epsilon  float64
+		actual   string
+		expected string
+		want     bool
+	}{
+		// 完全一致
+		{"exact same", models.CompareModeExact, 0, "1 2\n3\n", "1 2\n3\n", true},
+		{"exact default mode", "", 0, "1 2\n", "1 2\n", true},
+		{"exact missing trailing newline", models.CompareModeExact, 0, "1 2", "1 2\n", false},
+		{"exact extra trailing newline", models.CompareModeExact, 0, "1 2\n\n", "1 2\n", false},
+		{"exact trailing space", models.CompareModeExact, 0, "1 2 \n", "1 2\n", false},
+		{"exact empty", models.CompareModeExact, 0, "", "", true},
+		{"exact longer than buffer", models.CompareModeExact, 0, long, long, true},
+		{"exact differs after buffer", models.CompareModeExact, 0, long + "x", long + "y", false},
+		{"exact prefix longer than buffer", models.CompareModeExact, 0, long, long + "\n", false},
+
+		// 行単位
+		{"lines missing trailing newline", models.CompareModeLines, 0, "1 2", "1 2\n", true},
+		{"lines trailing spaces and CR", models.CompareModeLines, 0, "1 2 \t\r\n3\r\n", "1 2\n3\n", true},
+		{"lines trailing blank lines in actual", models.CompareModeLines, 0, "1\n\n\n", "1\n", true},
+		{"lines trailing blank lines in expected", models.CompareModeLines, 0, "1\n", "1\n\n \n", true},
+		{"lines blank line in the middle", models.CompareModeLines, 0, "1\n\n2\n", "1\n2\n", false},
+		{"lines extra line", models.CompareModeLines, 0, "1\n2\n", "1\n", false},
+		{"lines leading space", models.CompareModeLines, 0, " 1\n", "1\n", false},
+
+		// トークン単位
+		{"tokens different whitespace", models.CompareModeTokens, 0, "1  2\n\n3", "1 2\n3\n", true},
+		{"tokens missing token", models.CompareModeTokens, 0, "1 2", "1 2 3", false},
+		{"tokens case sensitive", models.CompareModeTokens, 0, "Yes", "YES", false},
+		{"case insensitive", models.CompareModeCaseInsensitive, 0, "Yes\n", "YES\n", true},
+		{"case insensitive different token", models.CompareModeCaseInsensitive, 0, "Yes", "No", false},
+
+		// 浮動小数点数
+		{"float default epsilon", models.CompareModeFloat, 0, "1.0000005", "1", true},
+		{"float default epsilon exceeded", models.CompareModeFloat, 0, "1.00001", "1", false},
+		{"float absolute error", models.CompareModeFloat, 1e-3, "0.0009", "0", true},
+		{"float absolute error exceeded", models.CompareModeFloat, 1e-3, "0.0011", "0", false},
+		{"float relative error", models.CompareModeFloat, 1e-6, "1000000001", "1e9", true},
+		{"float relative error exceeded", models.CompareModeFloat, 1e-6, "1000002000", "1e9", false},
+		{"float non-numeric token", models.CompareModeFloat, 1e-6, "abc", "abc", true},
+		{"float non-numeric mismatch", models.CompareModeFloat, 1e-6, "abc", "1", false},
+		{"float NaN", models.CompareModeFloat, 1e-6, "NaN", "1", false},
+		{"float NaN to NaN with different spelling", models.CompareModeFloat, 1e-6, "nan", "NaN", false},
+		{"float same NaN token", models.CompareModeFloat, 1e-6, "NaN", "NaN", true},
+		{"float infinity", models.CompareModeFloat, 1e-6, "Inf", "1e308", false},
+		{"float infinity to infinity with different spelling", models.CompareModeFloat, 1e-6, "+Inf", "Inf", false},
+		{"float large value is not infinity", models.CompareModeFloat, 1e-6, "1e308", "1e308", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			comparator, err := New(test.mode, test.epsilon)
+			if err != nil {
+				t.Fatalf("New(%q) returned error: %v", test.mode, err)
+			}
+			got, err := comparator.Compare(strings.NewReader(test.actual), strings.NewReader(test.expected))
+			if err != nil {
+				t.Fatalf("Compare returned error: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("Compare(%q, %q) = %v, want %v", test.actual, test.expected, got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewUnsupportedMode(t *testing.T) {
+	if _, err := New("unknown", 0); err == nil {
+		t.Error("New returned no error for an unsupported compare mode")
+	}
+}
+
+func TestCompareOversizedToken(t *testing.T) {
+	for _, mode := range []string{models.CompareModeLines, models.CompareModeTokens} {
+		t.Run(mode, func(t *testing.T) {
+			comparator, err := New(mode, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := io.LimitReader(repeatReader('a'), maxTokenSize+1)
+			_, err = comparator.Compare(actual, strings.NewReader("a\n"))
+			if !errors.Is(err, bufio.ErrTooLong) {
+				t.Errorf("Compare returned %v, want %v", err, bufio.ErrTooLong)
+			}
+		})
+	}
+}
+
+// repeatReader - 同じバイトを無限に読み出すリーダー
+type repeatReader byte
+
+func (r repeatReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
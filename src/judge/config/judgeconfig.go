@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// JudgeConfigは，ジャッジサーバーの並列実行に関する設定を保持する構造体である．
+// 環境変数から設定値を読み込み，未設定または不正な値の場合は既定値を使用する．
+//
+// フィールド:
+// - MaxWorkers int: 同時に判定する提出の最大数．これを超える提出はキューで待機する．
+// - MaxContainers int: 全ての提出を通して同時に起動するコンテナの最大数．
+// - Sandbox string: コンパイルと実行に用いるサンドボックスのランタイム("docker" または "local")．
+// - WarmPoolSize int: 言語ごとに起動済みの状態で待機させるサンドボックスの数．0の場合は実行ごとにコンテナを起動する．
+// - RequirePinnedImages bool: trueの場合，ダイジェストで固定されていない言語のイメージを使用しない(Dockerのランタイムのみ)．既定値はtrue．
+// - NodeID string: 判定キューに登録するジャッジノードの一意識別子．既定値はホスト名．
+// - NodeURL string: webサーバーからジャッジノードのHTTP APIに接続するためのベースURL．既定値は "http://<NodeID>:8080"．
+// - NodeVersion string: 判定キューに登録するジャッジノードのバージョン．
+// - NodeLanguages []int: ジャッジノードが判定する言語のIDのリスト．空の場合は有効な全ての言語を判定する．
+// - TestDataCacheBytes int64: ローカルに保持するテストデータの合計サイズの上限(バイト)．超えた場合は最後に用いられた日時が古いものから削除する．
+// - TestDataCacheDir string: ジャッジノードごとのテストデータのキャッシュのディレクトリ(<TestDataCacheDir>/<NodeID>)を作成するディレクトリ．サンドボックスのコンテナにマウントするため，ホストと同じパスで共有されている必要がある．
+type JudgeConfig struct {
+	MaxWorkers          int
+	MaxContainers       int
+	Sandbox             string
+	WarmPoolSize        int
+	RequirePinnedImages bool
+	NodeID              string
+	NodeURL             string
+	NodeVersion         string
+	NodeLanguages       []int
+	TestDataCacheBytes  int64
+	TestDataCacheDir    string
+}
+
+// NewJudgeConfigは，環境変数からジャッジサーバーの設定を読み込み，JudgeConfigインスタンスを生成する関数である．
+// 戻り値として，初期化されたJudgeConfigのポインタを返す．
+func NewJudgeConfig() *JudgeConfig {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "judge-server"
+	}
+	nodeID := getEnvString("JUDGE_NODE_ID", hostname)
+
+	return &JudgeConfig{
+		MaxWorkers:          getEnvInt("JUDGE_MAX_WORKERS", 2),
+		MaxContainers:       getEnvInt("JUDGE_MAX_CONTAINERS", runtime.NumCPU()),
+		Sandbox:             getEnvString("JUDGE_SANDBOX", "docker"),
+		WarmPoolSize:        getEnvCount("JUDGE_WARM_POOL_SIZE", 2),
+		RequirePinnedImages: getEnvBool("JUDGE_REQUIRE_PINNED_IMAGES", true),
+		NodeID:              nodeID,
+		NodeURL:             getEnvString("JUDGE_NODE_URL", "http://"+nodeID+":8080"),
+		NodeVersion:         getEnvString("JUDGE_NODE_VERSION", "dev"),
+		NodeLanguages:       getEnvIntList("JUDGE_NODE_LANGUAGES"),
+		TestDataCacheBytes:  int64(getEnvInt("JUDGE_TEST_DATA_CACHE_MB", 2048)) << 20,
+		TestDataCacheDir:    getEnvString("JUDGE_TEST_DATA_CACHE_DIR", "/tmp/judge-cache"),
+	}
+}
+
+// getEnvIntは，環境変数を正の整数として読み込む．未設定または不正な値の場合は既定値を返す．
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value <= 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvCountは，環境変数を0以上の整数として読み込む．未設定または不正な値の場合は既定値を返す．
+func getEnvCount(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvBoolは，環境変数を真偽値として読み込む．未設定または不正な値の場合は既定値を返す．
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
+
+// getEnvStringは，環境変数を文字列として読み込む．未設定の場合は既定値を返す．
+func getEnvString(key string, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvIntListは，環境変数をカンマ区切りの整数のリストとして読み込む．未設定の場合や不正な値は無視する．
+func getEnvIntList(key string) []int {
+	var values []int
+	for _, field := range strings.Split(os.Getenv(key), ",") {
+		if value, err := strconv.Atoi(strings.TrimSpace(field)); err == nil {
+			values = append(values, value)
+		}
+	}
+	return values
+}
@@ -0,0 +1,299 @@
+package datacache
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"procon_web_service/src/common/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// lockFileName - キャッシュのディレクトリを使用中のジャッジノードが排他ロック(flock)を保持するファイルの名前
+const lockFileName = ".lock"
+
+// PrepareFunc - 問題のテストデータの指定された版を baseDir 以下(<baseDir>/problem_<問題ID>/v<版>)に用意し，保存先のディレクトリを返す関数
+// 既に用意されている場合はマニフェストとの一致を確認し，一致しないファイルのみを取得し直す(minio.PrepareTestSet)．
+type PrepareFunc func(ctx context.Context, baseDir string, problemID, version int) (string, error)
+
+// Cache - ローカルに用意したテストデータを版ごとに保持するキャッシュ
+// 合計サイズが上限を超えた場合は，判定に用いられていない版を最後に用いられた日時が古いものから削除する．
+// 同じ版を同時に用意する判定は，先に開始した判定のダウンロードと確認の完了を待つ．
+// ロックとLRUはプロセス内で管理するため，ホストの/tmpを共有する複数のジャッジノードはそれぞれ自身のディレクトリ(<baseDir>/<ノードID>)を用いる．
+type Cache struct {
+	root     string      // テストデータを保存するディレクトリ
+	budget   int64       // 保持するテストデータの合計サイズの上限(バイト)
+	prepare  PrepareFunc // テストデータを用意する関数
+	lockFile *os.File    // root を使用中であることを示す排他ロックを保持するファイル(プロセスの終了まで閉じない)
+
+	mu        sync.Mutex
+	entries   map[string]*entry // テストデータの保存先をキーとしたエントリ
+	lru       *list.List        // 最後に用いられた日時が新しい順のエントリ
+	used      int64             // 保持しているテストデータの合計サイズ(バイト)
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+// entry - キャッシュに保持しているテストデータの1つの版
+type entry struct {
+	problemID int
+	version   int
+	dir       string
+	element   *list.Element
+
+	prepare  sync.Mutex // テストデータの用意を排他制御するロック
+	verified bool       // マニフェストとの一致を確認済みの場合はtrue(prepare で保護)
+
+	// 以下は Cache.mu で保護
+	size int64 // ディレクトリの合計サイズ(コンパイル済みの補助プログラムを含む)
+	refs int   // テストデータを用いている判定の数(0より大きい間は削除しない)
+}
+
+// NewCache - ジャッジノードのキャッシュのディレクトリ(<baseDir>/<nodeID>)と合計サイズの上限を指定してキャッシュを生成し，以前の起動時に保存されたテストデータを登録
+// ディレクトリには排他ロックを取得し，同じノードIDのジャッジノードが既に起動している場合はエラーを返す．
+// 終了したジャッジノード(ロックが保持されていない他のノードのディレクトリ)のテストデータは削除する．
+// 以前の起動時のテストデータは最初に用いられる際にマニフェストとの一致を確認する．上限を超えている場合は古いものから削除する．
+// テストデータは prepare で用意する．
+func NewCache(baseDir, nodeID string, budget int64, prepare PrepareFunc) (*Cache, error) {
+	if nodeID == "" || nodeID == "." || nodeID == ".." || filepath.Base(nodeID) != nodeID {
+		return nil, fmt.Errorf("invalid judge node ID for test data cache directory: %q", nodeID)
+	}
+	root := filepath.Join(baseDir, nodeID)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create test data cache directory: %w", err)
+	}
+	lockFile, locked, err := tryLock(root, true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock test data cache directory %s: %w", root, err)
+	}
+	if !locked {
+		return nil, fmt.Errorf("test data cache directory %s is in use by another judge node with ID %q", root, nodeID)
+	}
+
+	removeOrphans(baseDir, nodeID)
+
+	c := &Cache{root: root, budget: budget, prepare: prepare, lockFile: lockFile, entries: make(map[string]*entry), lru: list.New()}
+	c.loadExisting()
+	return c, nil
+}
+
+// Acquire - 問題のテストデータの指定された版を用意し，保存先のディレクトリを返す
+// 判定が終了したら，返された関数を呼び出してテストデータの使用を終了する．呼び出すまでテストデータは削除されない．
+func (c *Cache) Acquire(ctx context.Context, problemID, version int) (string, func(), error) {
+	dir := filepath.Join(c.root, "problem_"+strconv.Itoa(problemID), "v"+strconv.Itoa(version))
+
+	c.mu.Lock()
+	e, ok := c.entries[dir]
+	if !ok {
+		e = &entry{problemID: problemID, version: version, dir: dir}
+		e.element = c.lru.PushFront(e)
+		c.entries[dir] = e
+	}
+	e.refs++
+	c.lru.MoveToFront(e.element)
+	c.mu.Unlock()
+
+	e.prepare.Lock()
+	defer e.prepare.Unlock()
+
+	if e.verified {
+		c.mu.Lock()
+		c.hits++
+		c.mu.Unlock()
+		return dir, func() { c.release(e) }, nil
+	}
+
+	c.mu.Lock()
+	c.misses++
+	c.mu.Unlock()
+
+	if _, err := c.prepare(ctx, c.root, problemID, version); err != nil {
+		c.release(e)
+		return "", nil, err
+	}
+	e.verified = true
+
+	c.mu.Lock()
+	c.resize(e)
+	c.evict()
+	c.mu.Unlock()
+	return dir, func() { c.release(e) }, nil
+}
+
+// Stats - キャッシュの使用状況と統計情報を取得
+func (c *Cache) Stats() models.TestDataCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := models.TestDataCacheStats{
+		BudgetBytes: c.budget,
+		UsedBytes:   c.used,
+		Entries:     len(c.entries),
+		Hits:        c.hits,
+		Misses:      c.misses,
+		Evictions:   c.evictions,
+	}
+	for _, e := range c.entries {
+		if e.refs > 0 {
+			stats.InUse++
+		}
+	}
+	return stats
+}
+
+// release - テストデータの使用を終了し，判定中に追加されたファイル(コンパイル済みの補助プログラムなど)を含めたサイズで上限を確認
+func (c *Cache) release(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e.refs--
+	c.resize(e)
+	c.evict()
+}
+
+// resize - エントリのディレクトリの合計サイズを計算し直す(c.mu を保持した状態で呼び出す)
+func (c *Cache) resize(e *entry) {
+	size := dirSize(e.dir)
+	c.used += size - e.size
+	e.size = size
+}
+
+// evict - 合計サイズが上限以下になるまで，用いられていないエントリを最後に用いられた日時が古いものから削除(c.mu を保持した状態で呼び出す)
+// 削除中に同じ版が用意されないよう，ディレクトリの削除もロックを保持したまま行う．
+func (c *Cache) evict() {
+	for element := c.lru.Back(); element != nil && c.used > c.budget; {
+		e := element.Value.(*entry)
+		element = element.Prev()
+		if e.refs > 0 {
+			continue
+		}
+
+		if err := os.RemoveAll(e.dir); err != nil {
+			log.Printf("Failed to evict test data of problem %d version %d: %v", e.problemID, e.version, err)
+			continue
+		}
+		os.Remove(filepath.Dir(e.dir)) // 問題の最後の版を削除した場合は問題のディレクトリも削除(他の版が残っている場合は何もしない)
+
+		c.lru.Remove(e.element)
+		delete(c.entries, e.dir)
+		c.used -= e.size
+		c.evictions++
+		log.Printf("Evicted test data of problem %d version %d (%d bytes)", e.problemID, e.version, e.size)
+	}
+}
+
+// loadExisting - 以前の起動時に保存されたテストデータを，最後に更新された日時が古いものほど先に削除されるよう登録
+// 版のディレクトリ(v<版>)以外はキャッシュに登録せず，削除もしない．
+func (c *Cache) loadExisting() {
+	problemDirs, err := filepath.Glob(filepath.Join(c.root, "problem_*"))
+	if err != nil {
+		return
+	}
+
+	var existing []*entry
+	modTimes := make(map[*entry]time.Time)
+	for _, problemDir := range problemDirs {
+		problemID, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(problemDir), "problem_"))
+		if err != nil {
+			continue
+		}
+		children, err := os.ReadDir(problemDir)
+		if err != nil {
+			continue
+		}
+		for _, child := range children {
+			version, err := strconv.Atoi(strings.TrimPrefix(child.Name(), "v"))
+			if !child.IsDir() || !strings.HasPrefix(child.Name(), "v") || err != nil || version <= 0 {
+				continue
+			}
+			info, err := child.Info()
+			if err != nil {
+				continue
+			}
+			e := &entry{problemID: problemID, version: version, dir: filepath.Join(problemDir, child.Name()), size: dirSize(filepath.Join(problemDir, child.Name()))}
+			existing = append(existing, e)
+			modTimes[e] = info.ModTime()
+		}
+	}
+
+	sort.Slice(existing, func(i, j int) bool { return modTimes[existing[i]].After(modTimes[existing[j]]) })
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, e := range existing {
+		e.element = c.lru.PushBack(e)
+		c.entries[e.dir] = e
+		c.used += e.size
+	}
+	c.evict()
+	log.Printf("Loaded %d cached test data (%d bytes, budget %d bytes)", len(c.entries), c.used, c.budget)
+}
+
+// removeOrphans - baseDir 以下の他のジャッジノードのディレクトリのうち，排他ロックが保持されていないもの(終了したジャッジノードのもの)を削除
+// ロックのファイルがないディレクトリは作成中の可能性があるため削除しない．
+func removeOrphans(baseDir, nodeID string) {
+	nodeDirs, err := os.ReadDir(baseDir)
+	if err != nil {
+		return
+	}
+	for _, nodeDir := range nodeDirs {
+		if !nodeDir.IsDir() || nodeDir.Name() == nodeID {
+			continue
+		}
+		dir := filepath.Join(baseDir, nodeDir.Name())
+		lockFile, locked, err := tryLock(dir, false)
+		if err != nil || !locked {
+			continue
+		}
+		log.Printf("Removing test data cache of stopped judge node: %s", dir)
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Failed to remove test data cache of stopped judge node %s: %v", dir, err)
+		}
+		lockFile.Close()
+	}
+}
+
+// tryLock - ディレクトリのロックのファイルに排他ロック(flock)の取得を試みる(他のプロセスが保持している場合は待たずに locked を false とする)
+// ロックはファイルを閉じるか，プロセスが終了すると解放される．ロックのファイルがない場合，create が true であれば作成し，false であればエラーを返す．
+func tryLock(dir string, create bool) (*os.File, bool, error) {
+	flags := os.O_RDWR
+	if create {
+		flags |= os.O_CREATE
+	}
+	lockFile, err := os.OpenFile(filepath.Join(dir, lockFileName), flags, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := syscall.Flock(int(lockFile.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		lockFile.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return lockFile, true, nil
+}
+
+// dirSize - ディレクトリに含まれるファイルの合計サイズ(バイト)を取得(取得できないファイルは無視)
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
@@ -0,0 +1,210 @@
+package datacache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testFileSize = 100 // fakePrepare が用意するテストデータのサイズ(バイト)
+
+// fakePrepare - MinIOの代わりに，testFileSize バイトのファイル1つから成るテストデータを用意する PrepareFunc
+type fakePrepare struct {
+	calls int
+	err   error
+}
+
+func (f *fakePrepare) prepare(ctx context.Context, baseDir string, problemID, version int) (string, error) {
+	f.calls++
+	if f.err != nil {
+		return "", f.err
+	}
+	dir := versionDir(baseDir, problemID, version)
+	if err := os.MkdirAll(filepath.Join(dir, "in"), 0755); err != nil {
+		return "", err
+	}
+	return dir, os.WriteFile(filepath.Join(dir, "in", "01.txt"), make([]byte, testFileSize), 0644)
+}
+
+func versionDir(root string, problemID, version int) string {
+	return filepath.Join(root, "problem_"+strconv.Itoa(problemID), "v"+strconv.Itoa(version))
+}
+
+func newTestCache(t *testing.T, baseDir string, budget int64, prepare *fakePrepare) *Cache {
+	t.Helper()
+	cache, err := NewCache(baseDir, "node-1", budget, prepare.prepare)
+	if err != nil {
+		t.Fatalf("NewCache returned error: %v", err)
+	}
+	t.Cleanup(func() { cache.lockFile.Close() })
+	return cache
+}
+
+// acquire - テストデータを用意し，保存先が期待されるディレクトリであることを確認
+func acquire(t *testing.T, cache *Cache, problemID int) func() {
+	t.Helper()
+	dir, release, err := cache.Acquire(context.Background(), problemID, 1)
+	if err != nil {
+		t.Fatalf("Acquire(%d) returned error: %v", problemID, err)
+	}
+	if want := versionDir(cache.root, problemID, 1); dir != want {
+		t.Errorf("Acquire(%d) = %s, want %s", problemID, dir, want)
+	}
+	return release
+}
+
+func assertCached(t *testing.T, cache *Cache, cached map[int]bool) {
+	t.Helper()
+	for problemID, want := range cached {
+		_, err := os.Stat(versionDir(cache.root, problemID, 1))
+		if got := err == nil; got != want {
+			t.Errorf("test data of problem %d exists = %v, want %v", problemID, got, want)
+		}
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	prepare := &fakePrepare{}
+	cache := newTestCache(t, t.TempDir(), 2*testFileSize+testFileSize/2, prepare)
+
+	acquire(t, cache, 1)()
+	acquire(t, cache, 2)()
+	acquire(t, cache, 3)()
+	assertCached(t, cache, map[int]bool{1: false, 2: true, 3: true})
+
+	// 最後に用いられた問題2は残り，問題3が削除される
+	acquire(t, cache, 2)()
+	acquire(t, cache, 4)()
+	assertCached(t, cache, map[int]bool{2: true, 3: false, 4: true})
+
+	stats := cache.Stats()
+	if stats.Hits != 1 || stats.Misses != 4 || stats.Evictions != 2 || stats.Entries != 2 || stats.UsedBytes != 2*testFileSize || stats.InUse != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+	if prepare.calls != 4 {
+		t.Errorf("prepare was called %d times, want 4", prepare.calls)
+	}
+}
+
+func TestCacheKeepsTestDataInUse(t *testing.T) {
+	cache := newTestCache(t, t.TempDir(), testFileSize, &fakePrepare{})
+
+	release1 := acquire(t, cache, 1)
+	release2 := acquire(t, cache, 2)
+	assertCached(t, cache, map[int]bool{1: true, 2: true})
+	if stats := cache.Stats(); stats.InUse != 2 || stats.UsedBytes != 2*testFileSize {
+		t.Errorf("Stats() = %+v, want 2 entries in use over budget", stats)
+	}
+
+	// 使用を終了した時点で上限を超えていれば，使用中でないものから削除する
+	release2()
+	assertCached(t, cache, map[int]bool{1: true, 2: false})
+	release1()
+	assertCached(t, cache, map[int]bool{1: true})
+	if stats := cache.Stats(); stats.InUse != 0 || stats.Entries != 1 {
+		t.Errorf("Stats() = %+v, want 1 entry not in use", stats)
+	}
+}
+
+func TestCachePrepareError(t *testing.T) {
+	prepare := &fakePrepare{err: errors.New("download failed")}
+	cache := newTestCache(t, t.TempDir(), testFileSize, prepare)
+
+	if _, _, err := cache.Acquire(context.Background(), 1, 1); !errors.Is(err, prepare.err) {
+		t.Fatalf("Acquire returned %v, want %v", err, prepare.err)
+	}
+	// 失敗した版は確認済みとせず，次の判定で改めて用意する
+	prepare.err = nil
+	acquire(t, cache, 1)()
+	if prepare.calls != 2 {
+		t.Errorf("prepare was called %d times, want 2", prepare.calls)
+	}
+	if stats := cache.Stats(); stats.InUse != 0 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestCacheLoadsExisting(t *testing.T) {
+	baseDir := t.TempDir()
+	root := filepath.Join(baseDir, "node-1")
+	prepare := &fakePrepare{}
+	now := time.Now()
+	for i, problemID := range []int{1, 2, 3} {
+		if _, err := prepare.prepare(context.Background(), root, problemID, 1); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(time.Duration(i-3) * time.Hour)
+		if err := os.Chtimes(versionDir(root, problemID, 1), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	legacyDir := filepath.Join(root, "problem_1", "in")
+	if err := os.MkdirAll(legacyDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 上限を超えた分は，最後に更新された日時が古いものから削除する
+	cache := newTestCache(t, baseDir, 2*testFileSize, prepare)
+	assertCached(t, cache, map[int]bool{1: false, 2: true, 3: true})
+	if _, err := os.Stat(legacyDir); err != nil {
+		t.Errorf("directory that is not a version was removed: %v", err)
+	}
+
+	// 以前の起動時のテストデータは最初に用いられる際に改めて確認する
+	prepare.calls = 0
+	acquire(t, cache, 3)()
+	acquire(t, cache, 3)()
+	if prepare.calls != 1 {
+		t.Errorf("prepare was called %d times, want 1", prepare.calls)
+	}
+}
+
+func TestNewCacheLocksNodeDirectory(t *testing.T) {
+	baseDir := t.TempDir()
+	newTestCache(t, baseDir, testFileSize, &fakePrepare{})
+
+	if _, err := NewCache(baseDir, "node-1", testFileSize, (&fakePrepare{}).prepare); err == nil {
+		t.Error("NewCache returned no error for a directory in use")
+	}
+	for _, nodeID := range []string{"", ".", "..", "a/b"} {
+		if _, err := NewCache(baseDir, nodeID, testFileSize, (&fakePrepare{}).prepare); err == nil {
+			t.Errorf("NewCache returned no error for node ID %q", nodeID)
+		}
+	}
+}
+
+func TestNewCacheRemovesOrphans(t *testing.T) {
+	baseDir := t.TempDir()
+
+	// 終了したジャッジノードのディレクトリ(ロックが保持されていない)
+	stopped, err := NewCache(baseDir, "node-2", testFileSize, (&fakePrepare{}).prepare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped.lockFile.Close()
+
+	// 稼働中のジャッジノードのディレクトリ(ロックが保持されている)
+	running, err := NewCache(baseDir, "node-3", testFileSize, (&fakePrepare{}).prepare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer running.lockFile.Close()
+
+	// ロックのファイルがないディレクトリ(作成中の可能性がある)
+	creating := filepath.Join(baseDir, "node-4")
+	if err := os.Mkdir(creating, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	newTestCache(t, baseDir, testFileSize, &fakePrepare{})
+	for dir, want := range map[string]bool{stopped.root: false, running.root: true, creating: true} {
+		_, err := os.Stat(dir)
+		if got := err == nil; got != want {
+			t.Errorf("%s exists = %v, want %v", dir, got, want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/utils"
+	"procon_web_service/src/judge/queue"
+	judgeutils "procon_web_service/src/judge/utils"
+)
+
+// customRunID - カスタム実行をスケジューラに追加する際のID
+// カスタム実行は解答を持たないため，解答IDと重ならない値を用いる(キューでの順番は取得できない)．
+const customRunID = 0
+
+// CustomRunHandler - 提出を作成せずに任意の入力でコードを実行し，出力と実行結果を返す
+// 判定と同じワーカーで実行されるため，ワーカーが空いていない場合は判定と同様にキューで待機する．
+func CustomRunHandler(scheduler *queue.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request models.CustomRunRequest
+
+		if err := utils.DecodeRequestBody(r, &request); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		var result *models.CustomRunResult
+		var runErr error
+		err := scheduler.Submit(ctx, customRunID, 0, func(ctx context.Context) {
+			result, runErr = judgeutils.RunCustomInContainer(ctx, request.Run, request.Problem)
+		})
+
+		switch {
+		case err != nil || ctx.Err() != nil:
+			utils.SendErrorResponse(w, errors.New("request timed out"))
+		case runErr != nil:
+			utils.SendErrorResponse(w, runErr)
+		default:
+			utils.SendJSONResponse(w, http.StatusOK, *result)
+		}
+	}
+}
@@ -1,46 +1,45 @@
 package handlers
 
 import (
+	"context"
 	"errors"
 	"net/http"
 	"procon_web_service/src/common/models"
 	"procon_web_service/src/common/utils"
+	"procon_web_service/src/judge/queue"
 	judgeutils "procon_web_service/src/judge/utils"
 )
 
 // JudgeHandler - リクエストに添付された伝播contextを利用して非同期通信をコントロール
-func JudgeHandler(w http.ResponseWriter, r *http.Request) {
-	var solution models.Solution
+// 判定はスケジューラのワーカーで実行され，ワーカーが空いていない場合はキューで待機する．
+// 判定結果はまとめて1つのレスポンスで返す(進捗のイベントは判定キューを通じた判定でのみ通知される)．
+func JudgeHandler(scheduler *queue.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var request models.JudgeRequest
 
-	if err := utils.DecodeRequestBody(r, &solution); err != nil {
-		utils.SendErrorResponse(w, err)
-		return
-	}
-
-	// http.Requestのコンテキストを取得
-	ctx := r.Context()
-
-	resultChan := make(chan *models.ResultDetail)
-	errChan := make(chan error)
-	go func() {
-		resultDetail, err := judgeutils.BuildAndRunInContainer(ctx, solution)
-		if err != nil {
-			errChan <- err
+		if err := utils.DecodeRequestBody(r, &request); err != nil {
+			utils.SendErrorResponse(w, err)
 			return
 		}
-		resultChan <- resultDetail
-	}()
 
-	select {
-	case resultDetail := <-resultChan:
-		if resultDetail.ErrorMessage != "" {
+		// http.Requestのコンテキストを取得
+		ctx := r.Context()
+
+		var resultDetail *models.ResultDetail
+		var judgeErr error
+		err := scheduler.Submit(ctx, request.Solution.SolutionID, request.Priority, func(ctx context.Context) {
+			resultDetail, judgeErr = judgeutils.BuildAndRunInContainer(ctx, request.Solution, request.Problem, nil)
+		})
+
+		switch {
+		case err != nil || ctx.Err() != nil:
+			utils.SendErrorResponse(w, errors.New("request timed out"))
+		case judgeErr != nil:
+			utils.SendErrorResponse(w, judgeErr)
+		case resultDetail.ErrorMessage != "":
 			utils.SendErrorResponse(w, errors.New(resultDetail.ErrorMessage))
-			return
+		default:
+			utils.SendJSONResponse(w, http.StatusOK, *resultDetail)
 		}
-		utils.SendJSONResponse(w, http.StatusOK, *resultDetail)
-	case err := <-errChan:
-		utils.SendErrorResponse(w, err)
-	case <-ctx.Done():
-		utils.SendErrorResponse(w, errors.New("request timed out"))
 	}
 }
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/utils"
+	"procon_web_service/src/judge/queue"
+	judgeutils "procon_web_service/src/judge/utils"
+)
+
+// QueueStatusHandler - キュー全体の状態(待機数，実行数，ワーカー数)を返す
+func QueueStatusHandler(scheduler *queue.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJSONResponse(w, http.StatusOK, scheduler.Status())
+	}
+}
+
+// QueuePositionHandler - 指定された解答IDの提出のキュー内での順番を返す
+// 待機中でない(判定中，または存在しない)場合は queued を false として返し，判定中の場合は判定の段階と進捗を含める．
+func QueuePositionHandler(scheduler *queue.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		solutionID, err := utils.GetIntVarFromRequest(r, "solution_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		position, queued := scheduler.Position(solutionID)
+		status := models.QueueStatus{
+			SolutionID: solutionID,
+			Queued:     queued,
+			Position:   position,
+			Depth:      scheduler.Status().Depth,
+		}
+		if stage, done, total, ok := judgeutils.GetProgress(solutionID); ok && !queued {
+			status.Stage = stage
+			status.CurrentCase = done
+			status.TotalCases = total
+		}
+		utils.SendJSONResponse(w, http.StatusOK, status)
+	}
+}
@@ -1,27 +1,74 @@
 package main
 
 import (
+	"context"
 	"log"
 	"net/http"
-	"procon_web_service/src/common/middleware"
+	commonconfig "procon_web_service/src/common/config"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/minio"
+	"procon_web_service/src/common/signature"
+	"procon_web_service/src/judge/config"
+	"procon_web_service/src/judge/datacache"
+	"procon_web_service/src/judge/queue"
 	"procon_web_service/src/judge/routes"
+	"procon_web_service/src/judge/sandbox"
 	"procon_web_service/src/judge/utils"
+	"procon_web_service/src/judge/worker"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
 func main() {
+	// 言語設定ファイルの読み込みと検証(以降は変更を監視して再読み込み)
+	if err := commonconfig.InitLanguages(); err != nil {
+		log.Fatal(err)
+	}
+
+	judgeConfig := config.NewJudgeConfig()
+
 	// クリーンアップスケジューラの開始
-	utils.StartCleanupScheduler(30*time.Minute, 2*time.Hour) // 30分ごとに実行 && 2時間以上前のファイルを削除
+	utils.StartCleanupScheduler(30*time.Minute, 2*time.Hour, judgeConfig.TestDataCacheDir) // 30分ごとに実行 && 2時間以上前のファイルを削除
+
+	// webサーバーとの通信の署名の検証に用いる共有鍵の読み込み
+	signer, err := signature.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// コンパイルと実行に用いるサンドボックスのランタイムを設定
+	runtime, err := sandbox.New(judgeConfig.Sandbox)
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetJudgeConfig(judgeConfig)
+	utils.SetSandboxRuntime(runtime)
+
+	// ローカルに用意するテストデータのキャッシュをジャッジノードごとのディレクトリに設定(以前の起動時に保存されたテストデータを引き継ぐ)
+	testDataCache, err := datacache.NewCache(judgeConfig.TestDataCacheDir, judgeConfig.NodeID, judgeConfig.TestDataCacheBytes, minio.PrepareTestSet)
+	if err != nil {
+		log.Fatal(err)
+	}
+	utils.SetTestDataCache(testDataCache)
+
+	// 言語のイメージを事前に取得してダイジェストで固定し，提出プログラムを実行するサンドボックスを待機させる
+	if err := utils.PrepareLanguageImages(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	// 判定を行うワーカーの起動(ワーカー数を超える提出はキューで待機)
+	scheduler := queue.NewScheduler(judgeConfig.MaxWorkers)
+
+	// ジャッジノードとして判定キューに登録し，判定リクエストを取り出して判定(ワーカー数の2倍までを取り出してスケジューラのキューで待機させる)
+	go worker.Run(context.Background(), judgequeue.New(signer), scheduler, judgeConfig)
 
 	limiter := rate.NewLimiter(5, 5) // 1秒あたり5リクエストまで許可し、バーストサイズも5に設定
-	router := routes.SetupRoutes()
 
-	// レート制限ミドルウェアの適用
-	limitedRouter := middleware.RateLimiterMiddleware(limiter)(router)
+	// レート制限ミドルウェアは判定リクエストのルートに適用
+	router := routes.SetupRoutes(scheduler, limiter, signer)
 
-	if err := http.ListenAndServe(":8080", limitedRouter); err != nil {
+	if err := http.ListenAndServe(":8080", router); err != nil {
 		log.Fatal(err)
 	}
 }
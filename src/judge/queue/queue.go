@@ -0,0 +1,179 @@
+package queue
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+)
+
+// Scheduler - 提出の判定を一定数のワーカーで処理するスケジューラ
+// ワーカー数を超える提出は優先度付きのキューで待機し，同じ優先度の提出は到着順(FIFO)に処理される．
+type Scheduler struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending jobHeap // 待機中の提出
+	seq     uint64  // 到着順を表す通し番号
+	workers int     // ワーカー数
+	running int     // 実行中の提出数
+}
+
+// job - キューで待機する1つの提出
+type job struct {
+	id       int                       // 提出を識別するID(解答ID)
+	priority int                       // 優先度(値が大きいほど先に処理)
+	seq      uint64                    // 到着順
+	index    int                       // ヒープ内の位置(待機中でない場合は -1)
+	ctx      context.Context           // 提出元のコンテキスト
+	run      func(ctx context.Context) // 判定処理
+	done     chan struct{}             // 判定処理の完了時にクローズ
+}
+
+// Status - キュー全体の状態
+type Status struct {
+	Depth   int `json:"depth"`   // 待機中の提出数
+	Running int `json:"running"` // 実行中の提出数
+	Workers int `json:"workers"` // ワーカー数
+}
+
+// NewScheduler - 指定された数のワーカーを起動したスケジューラを生成
+func NewScheduler(workers int) *Scheduler {
+	if workers <= 0 {
+		workers = 1
+	}
+	s := &Scheduler{workers: workers}
+	s.cond = sync.NewCond(&s.mu)
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	return s
+}
+
+// Submit - 提出をキューに追加し，判定処理が完了するまで待機
+// 待機中にコンテキストがキャンセルされた場合はキューから取り除き，コンテキストのエラーを返す．
+// 実行開始後のキャンセルは判定処理に渡したコンテキストを通じて処理側で扱う．
+func (s *Scheduler) Submit(ctx context.Context, id, priority int, run func(ctx context.Context)) error {
+	j := &job{
+		id:       id,
+		priority: priority,
+		ctx:      ctx,
+		run:      run,
+		done:     make(chan struct{}),
+	}
+
+	s.mu.Lock()
+	s.seq++
+	j.seq = s.seq
+	heap.Push(&s.pending, j)
+	s.mu.Unlock()
+	s.cond.Signal()
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// 待機中であればキューから取り除く
+	s.mu.Lock()
+	if j.index >= 0 {
+		heap.Remove(&s.pending, j.index)
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+	s.mu.Unlock()
+
+	// 既に実行中の場合は判定処理の終了を待つ
+	<-j.done
+	return nil
+}
+
+// Position - 指定されたIDの提出のキュー内での順番(1始まり)を取得
+// 待機中でない(実行中，または存在しない)場合は false を返す．
+func (s *Scheduler) Position(id int) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var target *job
+	for _, j := range s.pending {
+		if j.id == id && (target == nil || j.less(target)) {
+			target = j
+		}
+	}
+	if target == nil {
+		return 0, false
+	}
+
+	position := 1
+	for _, j := range s.pending {
+		if j.less(target) {
+			position++
+		}
+	}
+	return position, true
+}
+
+// Status - キュー全体の状態を取得
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return Status{
+		Depth:   len(s.pending),
+		Running: s.running,
+		Workers: s.workers,
+	}
+}
+
+// worker - キューから提出を取り出して順に処理
+func (s *Scheduler) worker() {
+	for {
+		s.mu.Lock()
+		for len(s.pending) == 0 {
+			s.cond.Wait()
+		}
+		j := heap.Pop(&s.pending).(*job)
+		s.running++
+		s.mu.Unlock()
+
+		j.run(j.ctx)
+
+		s.mu.Lock()
+		s.running--
+		s.mu.Unlock()
+		close(j.done)
+	}
+}
+
+// less - 優先度が高い，または同じ優先度で先に到着した提出であればtrue
+func (j *job) less(other *job) bool {
+	if j.priority != other.priority {
+		return j.priority > other.priority
+	}
+	return j.seq < other.seq
+}
+
+// jobHeap - 優先度と到着順に基づくcontainer/heap用のスライス
+type jobHeap []*job
+
+func (h jobHeap) Len() int           { return len(h) }
+func (h jobHeap) Less(i, k int) bool { return h[i].less(h[k]) }
+func (h jobHeap) Swap(i, k int) {
+	h[i], h[k] = h[k], h[i]
+	h[i].index = i
+	h[k].index = k
+}
+
+func (h *jobHeap) Push(x interface{}) {
+	j := x.(*job)
+	j.index = len(*h)
+	*h = append(*h, j)
+}
+
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	j := old[n-1]
+	old[n-1] = nil
+	j.index = -1
+	*h = old[:n-1]
+	return j
+}
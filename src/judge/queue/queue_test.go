@@ -0,0 +1,178 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitFor - 条件が満たされるまで待機し，一定時間内に満たされない場合はテストを失敗させる
+func waitFor(t *testing.T, message string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", message)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// block - 解放されるまで全てのワーカーを占有する提出を追加し，占有を解放する関数を返す
+func block(t *testing.T, s *Scheduler) func() {
+	t.Helper()
+	release := make(chan struct{})
+	for i := 0; i < s.workers; i++ {
+		go s.Submit(context.Background(), -1-i, 0, func(ctx context.Context) { <-release })
+	}
+	waitFor(t, "workers to be occupied", func() bool { return s.Status().Running == s.workers })
+	var once sync.Once
+	return func() { once.Do(func() { close(release) }) }
+}
+
+func TestSchedulerPriorityAndFIFO(t *testing.T) {
+	s := NewScheduler(1)
+	release := block(t, s)
+	defer release()
+
+	submissions := []struct {
+		id       int
+		priority int
+	}{
+		{1, 0}, {2, 1}, {3, 0}, {4, 2}, {5, 1}, {6, 0},
+	}
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+	for i, submission := range submissions {
+		wg.Add(1)
+		go func(id, priority int) {
+			defer wg.Done()
+			s.Submit(context.Background(), id, priority, func(ctx context.Context) {
+				mu.Lock()
+				order = append(order, id)
+				mu.Unlock()
+			})
+		}(submission.id, submission.priority)
+		// 到着順を確定させるため，キューに追加されてから次の提出を追加する
+		waitFor(t, "submission to be queued", func() bool { return s.Status().Depth == i+1 })
+	}
+
+	// 優先度が高い提出ほど前に，同じ優先度の提出は到着順に並ぶ
+	for id, want := range map[int]int{4: 1, 2: 2, 5: 3, 1: 4, 3: 5, 6: 6} {
+		if position, ok := s.Position(id); !ok || position != want {
+			t.Errorf("Position(%d) = %d, %v; want %d", id, position, ok, want)
+		}
+	}
+
+	release()
+	wg.Wait()
+	if want := []int{4, 2, 5, 1, 3, 6}; !reflect.DeepEqual(order, want) {
+		t.Errorf("processed in order %v, want %v", order, want)
+	}
+	if _, ok := s.Position(1); ok {
+		t.Error("Position reported a processed submission as waiting")
+	}
+}
+
+func TestSchedulerCancelWhileWaiting(t *testing.T) {
+	s := NewScheduler(1)
+	release := block(t, s)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ran := make(chan struct{}, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Submit(ctx, 1, 0, func(ctx context.Context) { ran <- struct{}{} })
+	}()
+	waitFor(t, "submission to be queued", func() bool { return s.Status().Depth == 1 })
+
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Errorf("Submit returned %v, want %v", err, context.Canceled)
+	}
+	if status := s.Status(); status.Depth != 0 {
+		t.Errorf("depth = %d after cancelling, want 0", status.Depth)
+	}
+
+	// キャンセルされた提出は，ワーカーが空いても処理されない
+	release()
+	if err := s.Submit(context.Background(), 2, 0, func(ctx context.Context) {}); err != nil {
+		t.Fatalf("Submit returned %v", err)
+	}
+	select {
+	case <-ran:
+		t.Error("a cancelled submission was processed")
+	default:
+	}
+}
+
+func TestSchedulerCancelWhileRunning(t *testing.T) {
+	s := NewScheduler(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		result <- s.Submit(ctx, 1, 0, func(ctx context.Context) {
+			close(started)
+			<-ctx.Done()
+		})
+	}()
+	<-started
+
+	// 実行開始後のキャンセルは判定処理に伝わり，判定処理の終了を待って完了とする
+	cancel()
+	if err := <-result; err != nil {
+		t.Errorf("Submit returned %v for a submission cancelled while running, want nil", err)
+	}
+}
+
+func TestSchedulerWorkerLimit(t *testing.T) {
+	const workers = 2
+	s := NewScheduler(workers)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for id := 1; id <= 5; id++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			s.Submit(context.Background(), id, 0, func(ctx context.Context) {
+				mu.Lock()
+				running++
+				if running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+				<-release
+				mu.Lock()
+				running--
+				mu.Unlock()
+			})
+		}(id)
+	}
+
+	// ワーカー数を超える提出は待機する
+	waitFor(t, "workers to be occupied", func() bool {
+		status := s.Status()
+		return status.Running == workers && status.Depth == 3
+	})
+	if status := s.Status(); status.Workers != workers {
+		t.Errorf("workers = %d, want %d", status.Workers, workers)
+	}
+
+	close(release)
+	wg.Wait()
+	if maxRunning != workers {
+		t.Errorf("at most %d submissions ran at once, want %d", maxRunning, workers)
+	}
+	if status := s.Status(); status.Running != 0 || status.Depth != 0 {
+		t.Errorf("status = %+v after all submissions finished, want empty", status)
+	}
+}
@@ -3,16 +3,28 @@ package routes
 import (
 	"net/http"
 	"procon_web_service/src/common/middleware"
+	"procon_web_service/src/common/signature"
 	"procon_web_service/src/judge/handlers"
+	"procon_web_service/src/judge/queue"
 
 	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
 )
 
-func SetupRoutes() *mux.Router {
+func SetupRoutes(scheduler *queue.Scheduler, limiter *rate.Limiter, signer *signature.Signer) *mux.Router {
 	router := mux.NewRouter()
 	router.Use(middleware.LoggingMiddleware)
+	// 全てのルートでwebサーバーが署名したリクエストのみを受け付ける(署名のないリクエストがレート制限の枠を消費しないよう先に検証)
+	router.Use(middleware.SignatureMiddleware(signer))
 
-	router.HandleFunc("/judge", handlers.JudgeHandler).Methods(http.MethodPost)
+	// 判定リクエストとカスタム実行にのみレート制限を適用(キューの状態確認はwebサーバーから定期的に呼び出されるため対象外)
+	router.Handle("/judge", middleware.RateLimiterMiddleware(limiter)(handlers.JudgeHandler(scheduler))).Methods(http.MethodPost)
+	// カスタム実行(提出を作成せずに任意の入力で実行)
+	router.Handle("/run", middleware.RateLimiterMiddleware(limiter)(handlers.CustomRunHandler(scheduler))).Methods(http.MethodPost)
+
+	// キューの状態確認
+	router.HandleFunc("/queue", handlers.QueueStatusHandler(scheduler)).Methods(http.MethodGet)
+	router.HandleFunc("/queue/{solution_id}", handlers.QueuePositionHandler(scheduler)).Methods(http.MethodGet)
 
 	return router
 }
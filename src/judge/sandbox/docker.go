@@ -0,0 +1,673 @@
+package sandbox
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDockerSocket = "/var/run/docker.sock" // Dockerデーモンの既定のUNIXソケット
+	statsDir            = "/sandbox_stats"       // 計測結果を書き出すコンテナ内部のディレクトリ
+	statsFileName       = "stats"
+	commandFileName     = "command" // 待機中のコンテナに実行させるコマンドを書き出すファイル名
+)
+
+// cgroupStatFuncsは，コンテナ内部でcgroupの統計情報を読み取るシェル関数の定義である．
+// cpu_ns はコンテナ全体のCPU使用時間(ナノ秒)を，peak_bytes はコンテナのピークメモリ使用量(バイト)を，
+// oom_kills はメモリ制限の超過によりOOM Killerが強制終了したプロセスの数を出力する(cgroup v2 および v1 に対応)．
+const cgroupStatFuncs = `cpu_ns() { ` +
+	`if [ -r /sys/fs/cgroup/cpu.stat ]; then while read key value; do if [ "$key" = usage_usec ]; then echo $((value*1000)); return; fi; done < /sys/fs/cgroup/cpu.stat; ` +
+	`elif [ -r /sys/fs/cgroup/cpuacct/cpuacct.usage ]; then cat /sys/fs/cgroup/cpuacct/cpuacct.usage; return; fi; echo 0; }; ` +
+	`peak_bytes() { ` +
+	`for file in /sys/fs/cgroup/memory.peak /sys/fs/cgroup/memory/memory.max_usage_in_bytes; do if [ -r $file ]; then cat $file; return; fi; done; echo 0; }; ` +
+	`oom_kills() { ` +
+	`for file in /sys/fs/cgroup/memory.events /sys/fs/cgroup/memory/memory.oom_control; do if [ -r $file ]; then while read key value; do if [ "$key" = oom_kill ]; then echo $value; return; fi; done < $file; fi; done; echo 0; }`
+
+// measureScriptは，コンテナの主プロセスとして実行され，引数で渡されたコマンドを制限時間付きで実行して計測結果をファイルに書き出すスクリプトである．
+// 引数は「制限時間 計測結果の出力先 コマンド...」の順で受け取り，コマンドは "$@" としてそのまま実行されるため引用符の処理は不要である．
+// 計測結果は「終了コード 実行時間 CPU時間 ピークメモリ使用量 OOM Killerによる強制終了の数」の形式で出力される．
+// コマンドはスクリプトの子プロセスとして実行されるため，コマンドだけが強制終了された場合もコンテナの情報では検出できないことがあり，強制終了の数は cgroup の統計情報の増分から求める．
+const measureScript = cgroupStatFuncs + `; ` +
+	`limit=$1; stats=$2; shift 2; ` +
+	`cpu_start=$(cpu_ns); oom_start=$(oom_kills); start=$(date +%s%N); ` +
+	`if [ "$limit" = 0 ]; then "$@"; else timeout -k 1 "$limit" "$@"; fi; ` +
+	`status=$?; end=$(date +%s%N); cpu_end=$(cpu_ns); ` +
+	`echo "$status $((end-start)) $((cpu_end-cpu_start)) $(peak_bytes) $(($(oom_kills)-oom_start))" > "$stats"; ` +
+	`exit $status`
+
+// DockerRuntime - Docker Engine APIを用いてコマンドをコンテナ内で実行するランタイム
+// コマンドは実行ごとに異なるコンテナで実行され，実行後にコンテナは削除される．
+// Warm により待機中のコンテナが用意されている構成では，起動済みのコンテナを用いることで起動時間を短縮する．
+type DockerRuntime struct {
+	socket string
+	client *http.Client
+
+	poolsMu      sync.Mutex
+	pools        map[string]*warmPool // 構成ごとの待機中のコンテナのプール
+	cleanupStale sync.Once            // 前回の起動時に残された待機中のコンテナの削除
+}
+
+// NewDockerRuntime - Dockerデーモンに接続するランタイムを生成
+// host には DOCKER_HOST と同じ形式(unix:///var/run/docker.sock)を指定し，空文字列の場合は既定のソケットを用いる．
+func NewDockerRuntime(host string) (*DockerRuntime, error) {
+	socket := defaultDockerSocket
+	if host != "" {
+		if !strings.HasPrefix(host, "unix://") {
+			return nil, fmt.Errorf("unsupported docker host: %s", host)
+		}
+		socket = strings.TrimPrefix(host, "unix://")
+	}
+
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", socket)
+	}
+	return &DockerRuntime{
+		socket: socket,
+		client: &http.Client{Transport: &http.Transport{DialContext: dial}},
+		pools:  make(map[string]*warmPool),
+	}, nil
+}
+
+// Create - コンテナの設定を保持するサンドボックスを生成し，イメージが存在しない場合は取得
+func (r *DockerRuntime) Create(ctx context.Context, config Config) (Sandbox, error) {
+	if err := r.ensureImage(ctx, config.Image); err != nil {
+		return nil, err
+	}
+
+	filesDir, err := createFilesDir()
+	if err != nil {
+		return nil, err
+	}
+	return &dockerSandbox{runtime: r, config: config, filesDir: filesDir}, nil
+}
+
+// dockerSandbox - Dockerコンテナによるサンドボックス
+type dockerSandbox struct {
+	runtime  *DockerRuntime
+	config   Config
+	filesDir string // CopyIn で複製したファイルを保持するホスト側のディレクトリ
+}
+
+func (s *dockerSandbox) CopyIn(ctx context.Context, hostPath, name string) error {
+	return copyFile(s.filesDir, hostPath, name)
+}
+
+func (s *dockerSandbox) Destroy(ctx context.Context) error {
+	return os.RemoveAll(s.filesDir)
+}
+
+// Run - 待機中のコンテナ，またはなければ新しいコンテナでコマンドを実行し，標準入出力を接続して終了まで待機
+func (s *dockerSandbox) Run(ctx context.Context, options RunOptions) (RunResult, error) {
+	if len(options.Command) == 0 {
+		return RunResult{}, fmt.Errorf("command is empty")
+	}
+
+	c := s.runtime.acquireWarmContainer(ctx, s.config, s.filesDir)
+	if c == nil {
+		var err error
+		if c, err = s.createContainer(ctx, options); err != nil {
+			return RunResult{}, err
+		}
+	}
+	defer c.remove(s.runtime)
+
+	// 出力を取りこぼさないようコマンドの開始前に標準入出力を接続
+	conn, reader, err := s.runtime.attachContainer(ctx, c.id, c.stdin)
+	if err != nil {
+		return RunResult{}, err
+	}
+	defer conn.Close()
+
+	var killOnce sync.Once
+	kill := func() {
+		killOnce.Do(func() { s.runtime.killContainer(c.id) })
+	}
+
+	stdout := newLimitedWriter(options.Stdout, options.OutputLimit, kill)
+	stderr := options.Stderr
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	outputDone := make(chan error, 1)
+	go func() {
+		outputDone <- demuxStream(reader, &discardOnErrorWriter{w: stdout}, &discardOnErrorWriter{w: stderr})
+	}()
+
+	if err := c.start(ctx, s.runtime, measureCommand(options)); err != nil {
+		return RunResult{}, err
+	}
+
+	if c.stdin {
+		go func() {
+			if options.Stdin != nil {
+				io.Copy(conn, options.Stdin)
+			}
+			if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+		}()
+	}
+
+	// 制限時間内に終了しない場合はコンテナごと強制終了(通常はコンテナ内部の timeout により終了する)
+	killedByHost := false
+	waitCtx := ctx
+	if options.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, options.TimeLimit+killGracePeriod)
+		defer cancel()
+	}
+	if err := s.runtime.waitContainer(waitCtx, c.id); err != nil {
+		if ctx.Err() != nil {
+			kill()
+			return RunResult{}, ctx.Err()
+		}
+		if waitCtx.Err() == nil {
+			return RunResult{}, err
+		}
+		killedByHost = true
+		kill()
+		if err := s.runtime.waitContainer(ctx, c.id); err != nil {
+			return RunResult{}, err
+		}
+	}
+	if err := <-outputDone; err != nil {
+		return RunResult{}, fmt.Errorf("failed to read container output: %v", err)
+	}
+
+	inspect, err := s.runtime.inspectContainer(ctx, c.id)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	result := RunResult{
+		ExitCode:            inspect.State.ExitCode,
+		OOMKilled:           inspect.State.OOMKilled,
+		OutputLimitExceeded: outputExceeded(stdout),
+		WallTime:            inspect.State.FinishedAt.Sub(inspect.State.StartedAt),
+	}
+	if c.warm {
+		// 待機中のコンテナは事前に起動しているため，コマンドを開始した時刻から計測
+		result.WallTime = inspect.State.FinishedAt.Sub(c.startedAt)
+	}
+	// 計測結果が書き出されていない場合(強制終了など)はコンテナの情報から得た値を用いる
+	if stats, err := readStats(filepath.Join(c.statsDir, statsFileName)); err == nil {
+		result.ExitCode = stats.exitCode
+		result.WallTime = stats.wallTime
+		result.CPUTime = stats.cpuTime
+		result.PeakMemory = stats.peakMemory
+		result.OOMKilled = result.OOMKilled || stats.oomKills > 0
+	}
+	result.Signal = signalFromExitCode(result.ExitCode)
+	result.TimedOut = killedByHost || isTimedOut(result.ExitCode, result.WallTime, options.TimeLimit)
+
+	return result, nil
+}
+
+// createContainer - コマンドを実行する新しいコンテナを作成(起動は start で行う)
+func (s *dockerSandbox) createContainer(ctx context.Context, options RunOptions) (*runContainer, error) {
+	// 計測結果の出力先はコンテナごとに用意し，コンテナ内部のユーザーから書き込めるよう権限を設定
+	hostStatsDir, err := ioutil.TempDir("/tmp", "sandbox_stats_")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stats directory: %v", err)
+	}
+	if err := os.Chmod(hostStatsDir, 0777); err != nil {
+		os.RemoveAll(hostStatsDir)
+		return nil, fmt.Errorf("failed to change stats directory permission: %v", err)
+	}
+
+	binds := make([]string, 0, len(s.config.Mounts)+2)
+	for _, mount := range s.config.Mounts {
+		binds = append(binds, bindSpec(mount.Source, mount.Target, mount.ReadOnly))
+	}
+	binds = append(binds,
+		bindSpec(s.filesDir, FilesDir, true),
+		bindSpec(hostStatsDir, statsDir, false),
+	)
+
+	stdin := options.Stdin != nil
+	id, err := s.runtime.createContainer(ctx, containerRequest(s.config, binds, measureCommand(options), stdin))
+	if err != nil {
+		os.RemoveAll(hostStatsDir)
+		return nil, err
+	}
+	return &runContainer{id: id, dir: hostStatsDir, statsDir: hostStatsDir, stdin: stdin}, nil
+}
+
+// runContainer - 1回の実行に用いるコンテナ
+type runContainer struct {
+	id        string
+	dir       string    // コンテナに関連するホスト側のディレクトリ(実行後に削除)
+	statsDir  string    // 計測結果が書き出されるホスト側のディレクトリ
+	stdin     bool      // 標準入力を接続する場合はtrue
+	warm      bool      // 待機中のコンテナを用いる場合はtrue
+	startedAt time.Time // コマンドを開始した時刻(待機中のコンテナの場合のみ)
+	dirLock   *os.File  // dir を使用中であることを示す共有ロックを保持するファイル(待機中のコンテナの場合のみ)
+}
+
+// start - コマンドを開始
+// 新しいコンテナは起動することで，待機中のコンテナはコマンドを書き出すことで開始する．
+func (c *runContainer) start(ctx context.Context, r *DockerRuntime, command []string) error {
+	if !c.warm {
+		return r.do(ctx, http.MethodPost, "/containers/"+c.id+"/start", nil, nil)
+	}
+
+	// 書き込み途中のファイルを読み取らないよう，一時ファイルに書き出してから名前を変更
+	script := "exec " + shellQuote(command)
+	tempPath := filepath.Join(c.statsDir, commandFileName+".tmp")
+	if err := ioutil.WriteFile(tempPath, []byte(script), 0644); err != nil {
+		return fmt.Errorf("failed to write sandbox command: %v", err)
+	}
+	c.startedAt = time.Now()
+	if err := os.Rename(tempPath, filepath.Join(c.statsDir, commandFileName)); err != nil {
+		return fmt.Errorf("failed to write sandbox command: %v", err)
+	}
+	return nil
+}
+
+// remove - コンテナと関連するホスト側のディレクトリを削除
+// 待機中のコンテナも再利用せずに削除することで，前の実行のプロセスや一時ファイルが次の実行に残らないようにする．
+func (c *runContainer) remove(r *DockerRuntime) {
+	r.removeContainer(c.id)
+	os.RemoveAll(c.dir)
+	if c.dirLock != nil {
+		c.dirLock.Close()
+	}
+}
+
+// measureCommand - 計測スクリプトを通してコマンドを実行する引数を構築
+func measureCommand(options RunOptions) []string {
+	limit := "0"
+	if options.TimeLimit > 0 {
+		limit = fmt.Sprintf("%.3fs", options.TimeLimit.Seconds())
+	}
+	return append([]string{"/bin/sh", "-c", measureScript, "sh", limit, statsDir + "/" + statsFileName}, options.Command...)
+}
+
+// shellQuote - 引数をシェルがそのまま解釈するよう単一引用符で囲んで連結
+func shellQuote(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.Replace(arg, "'", `'\''`, -1) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// bindSpec - Binds に指定するマウントの設定を構築
+func bindSpec(source, target string, readOnly bool) string {
+	bind := source + ":" + target
+	if readOnly {
+		bind += ":ro"
+	}
+	return bind
+}
+
+// containerRequest - コンテナ作成APIに渡す設定を構築
+func containerRequest(config Config, binds []string, cmd []string, stdin bool) containerCreateRequest {
+	request := containerCreateRequest{
+		Image:           config.Image,
+		Cmd:             cmd,
+		Env:             config.Env,
+		AttachStdin:     stdin,
+		AttachStdout:    true,
+		AttachStderr:    true,
+		OpenStdin:       stdin,
+		StdinOnce:       stdin,
+		NetworkDisabled: true,
+		HostConfig: hostConfig{
+			Binds:          binds,
+			Memory:         config.MemoryLimit,
+			MemorySwap:     config.MemoryLimit, // スワップを無効化
+			NanoCpus:       int64(config.CPUs * 1e9),
+			NetworkMode:    "none",          // コンテナのネットワークアクセスを遮断
+			ReadonlyRootfs: true,            // コンテナのファイルシステムを読み取り専用に設定
+			CapDrop:        []string{"ALL"}, // セキュリティのためコンテナ内部Linuxカーネル機能を削除
+		},
+	}
+	if config.ScratchDir != "" {
+		// 読み書き可能でサイズ制限付きの一時ファイルシステムとしてマウント
+		request.HostConfig.Tmpfs = map[string]string{
+			config.ScratchDir: fmt.Sprintf("rw,size=%d", config.ScratchSize),
+		}
+	}
+	return request
+}
+
+// containerCreateRequest - コンテナ作成APIのリクエスト
+type containerCreateRequest struct {
+	Image           string
+	Cmd             []string
+	Env             []string `json:",omitempty"`
+	AttachStdin     bool
+	AttachStdout    bool
+	AttachStderr    bool
+	OpenStdin       bool
+	StdinOnce       bool
+	NetworkDisabled bool
+	Labels          map[string]string `json:",omitempty"`
+	HostConfig      hostConfig
+}
+
+type hostConfig struct {
+	Binds          []string
+	Memory         int64 `json:",omitempty"`
+	MemorySwap     int64 `json:",omitempty"`
+	NanoCpus       int64 `json:",omitempty"`
+	NetworkMode    string
+	ReadonlyRootfs bool
+	CapDrop        []string
+	Tmpfs          map[string]string `json:",omitempty"`
+}
+
+// containerInspect - コンテナ情報取得APIのレスポンスのうち判定に用いる部分
+type containerInspect struct {
+	State struct {
+		ExitCode   int
+		OOMKilled  bool
+		StartedAt  time.Time
+		FinishedAt time.Time
+	}
+}
+
+// dockerError - Docker Engine APIがエラーを返した場合のエラー
+type dockerError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerError) Error() string {
+	return fmt.Sprintf("docker API error (status %d): %s", e.StatusCode, e.Message)
+}
+
+// do - Docker Engine APIにリクエストを送信し，レスポンスをJSONとして解析
+func (r *DockerRuntime) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode docker API request: %v", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect docker daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return readDockerError(resp)
+	}
+	if out == nil {
+		_, err = io.Copy(ioutil.Discard, resp.Body)
+		return err
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode docker API response: %v", err)
+	}
+	return nil
+}
+
+// readDockerError - エラーレスポンスのメッセージを取得
+func readDockerError(resp *http.Response) error {
+	var message struct {
+		Message string `json:"message"`
+	}
+	data, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err := json.Unmarshal(data, &message); err != nil || message.Message == "" {
+		message.Message = strings.TrimSpace(string(data))
+	}
+	return &dockerError{StatusCode: resp.StatusCode, Message: message.Message}
+}
+
+// ensureImage - イメージがローカルに存在しない場合は取得
+func (r *DockerRuntime) ensureImage(ctx context.Context, image string) error {
+	err := r.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil)
+	if dockerErr, ok := err.(*dockerError); !ok || dockerErr.StatusCode != http.StatusNotFound {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://docker/images/create?fromImage="+url.QueryEscape(image), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect docker daemon: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return readDockerError(resp)
+	}
+
+	// 取得の進捗はJSONのストリームとして返され，失敗した場合は error フィールドが含まれる
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var message struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&message); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to pull image %s: %v", image, err)
+		}
+		if message.Error != "" {
+			return fmt.Errorf("failed to pull image %s: %s", image, message.Error)
+		}
+	}
+}
+
+// PrepareImage - イメージを取得し，ダイジェストで固定した参照を返す
+// 参照にダイジェストが含まれる場合は，取得したイメージのダイジェストと一致することを検証してそのまま返す．
+// レジストリのダイジェストを持たないイメージ(ローカルでビルドしたイメージなど)はイメージIDで固定する．
+func (r *DockerRuntime) PrepareImage(ctx context.Context, image string) (string, error) {
+	if err := r.ensureImage(ctx, image); err != nil {
+		return "", err
+	}
+
+	var inspect struct {
+		ID          string `json:"Id"`
+		RepoDigests []string
+	}
+	if err := r.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, &inspect); err != nil {
+		return "", err
+	}
+
+	repository, digest := splitImageReference(image)
+	for _, repoDigest := range inspect.RepoDigests {
+		index := strings.LastIndex(repoDigest, "@")
+		if index < 0 {
+			continue
+		}
+		if digest != "" && repoDigest[index+1:] == digest {
+			return image, nil
+		}
+		if digest == "" && repoDigest[:index] == repository {
+			return repoDigest, nil
+		}
+	}
+	if digest != "" {
+		return "", fmt.Errorf("image %s does not match digest %s", image, digest)
+	}
+	return inspect.ID, nil
+}
+
+// splitImageReference - イメージの参照をリポジトリ名(タグを除く)とダイジェストに分割
+func splitImageReference(image string) (repository, digest string) {
+	if index := strings.Index(image, "@"); index >= 0 {
+		image, digest = image[:index], image[index+1:]
+	}
+	// レジストリのポート番号と区別するため，最後の "/" より後ろにある ":" のみをタグの区切りとみなす
+	if index := strings.LastIndex(image, ":"); index > strings.LastIndex(image, "/") {
+		image = image[:index]
+	}
+	return image, digest
+}
+
+// createContainer - コンテナを作成し，コンテナIDを取得
+func (r *DockerRuntime) createContainer(ctx context.Context, request containerCreateRequest) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := r.do(ctx, http.MethodPost, "/containers/create", request, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// attachContainer - コンテナの標準入出力に接続
+// Docker Engine APIは接続をアップグレードしてストリームとして扱うため，HTTPクライアントを用いずにソケットへ直接リクエストを送信する．
+func (r *DockerRuntime) attachContainer(ctx context.Context, id string, stdin bool) (net.Conn, *bufio.Reader, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", r.socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect docker daemon: %v", err)
+	}
+
+	query := "stream=1&stdout=1&stderr=1"
+	if stdin {
+		query += "&stdin=1"
+	}
+	req, err := http.NewRequest(http.MethodPost, "http://docker/containers/"+id+"/attach?"+query, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to attach container: %v", err)
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("failed to attach container: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		defer conn.Close()
+		return nil, nil, readDockerError(resp)
+	}
+	return conn, reader, nil
+}
+
+// waitContainer - コンテナが終了するまで待機
+func (r *DockerRuntime) waitContainer(ctx context.Context, id string) error {
+	var waited struct {
+		StatusCode int
+	}
+	return r.do(ctx, http.MethodPost, "/containers/"+id+"/wait", nil, &waited)
+}
+
+// inspectContainer - 終了したコンテナの状態を取得
+func (r *DockerRuntime) inspectContainer(ctx context.Context, id string) (containerInspect, error) {
+	var inspect containerInspect
+	err := r.do(ctx, http.MethodGet, "/containers/"+id+"/json", nil, &inspect)
+	return inspect, err
+}
+
+// killContainer - コンテナを強制終了(呼び出し元のコンテキストがキャンセルされていても実行する)
+func (r *DockerRuntime) killContainer(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), killGracePeriod)
+	defer cancel()
+	r.do(ctx, http.MethodPost, "/containers/"+id+"/kill", nil, nil)
+}
+
+// removeContainer - コンテナを強制的に削除(呼び出し元のコンテキストがキャンセルされていても実行する)
+func (r *DockerRuntime) removeContainer(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), killGracePeriod)
+	defer cancel()
+	r.do(ctx, http.MethodDelete, "/containers/"+id+"?force=1&v=1", nil, nil)
+}
+
+// demuxStream - 標準出力と標準エラー出力が多重化されたストリームを分離
+// 各フレームは8バイトのヘッダ(ストリームの種類1バイト，予約3バイト，ビッグエンディアンのサイズ4バイト)とデータから構成される．
+func demuxStream(reader io.Reader, stdout, stderr io.Writer) error {
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		var w io.Writer
+		switch header[0] {
+		case 1:
+			w = stdout
+		case 2:
+			w = stderr
+		default:
+			w = ioutil.Discard
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		if _, err := io.CopyN(w, reader, size); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// runStats - コンテナ内部で計測された実行結果
+type runStats struct {
+	exitCode   int
+	wallTime   time.Duration
+	cpuTime    time.Duration
+	peakMemory int64
+	oomKills   int64
+}
+
+// readStats - 計測スクリプトが書き出した「終了コード 実行時間 CPU時間 ピークメモリ使用量 OOM Killerによる強制終了の数」を解析
+func readStats(path string) (runStats, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return runStats{}, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 5 {
+		return runStats{}, fmt.Errorf("invalid stats: %s", data)
+	}
+
+	values := make([]int64, len(fields))
+	for i, field := range fields {
+		if values[i], err = strconv.ParseInt(field, 10, 64); err != nil {
+			return runStats{}, fmt.Errorf("invalid stats: %s", data)
+		}
+	}
+	return runStats{
+		exitCode:   int(values[0]),
+		wallTime:   time.Duration(values[1]),
+		cpuTime:    time.Duration(values[2]),
+		peakMemory: values[3],
+		oomKills:   values[4],
+	}, nil
+}
@@ -0,0 +1,87 @@
+package sandbox
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// frame - Docker の多重化されたストリームの1フレームを生成
+func frame(stream byte, data string) []byte {
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func TestDemuxStream(t *testing.T) {
+	var input []byte
+	input = append(input, frame(1, "out1 ")...)
+	input = append(input, frame(2, "err")...)
+	input = append(input, frame(0, "stdin")...) // 標準出力と標準エラー出力以外は破棄する
+	input = append(input, frame(1, "out2")...)
+
+	var stdout, stderr bytes.Buffer
+	if err := demuxStream(bytes.NewReader(input), &stdout, &stderr); err != nil {
+		t.Fatalf("demuxStream returned error: %v", err)
+	}
+	if stdout.String() != "out1 out2" || stderr.String() != "err" {
+		t.Errorf("stdout = %q, stderr = %q; want %q, %q", stdout.String(), stderr.String(), "out1 out2", "err")
+	}
+}
+
+func TestDemuxStreamTruncated(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"empty", nil, ""},
+		{"truncated header", append(frame(1, "out"), 1, 0, 0), "out"},
+		{"truncated data", frame(1, "output")[:11], "out"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if err := demuxStream(bytes.NewReader(test.input), &stdout, &bytes.Buffer{}); err != nil {
+				t.Fatalf("demuxStream returned error: %v", err)
+			}
+			if stdout.String() != test.want {
+				t.Errorf("stdout = %q, want %q", stdout.String(), test.want)
+			}
+		})
+	}
+}
+
+func TestReadStats(t *testing.T) {
+	dir := t.TempDir()
+	write := func(data string) string {
+		path := filepath.Join(dir, statsFileName)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	stats, err := readStats(write("137 1500000000 1200000000 268435456 1\n"))
+	if err != nil {
+		t.Fatalf("readStats returned error: %v", err)
+	}
+	want := runStats{exitCode: 137, wallTime: 1500 * time.Millisecond, cpuTime: 1200 * time.Millisecond, peakMemory: 256 << 20, oomKills: 1}
+	if stats != want {
+		t.Errorf("readStats = %+v, want %+v", stats, want)
+	}
+
+	for _, data := range []string{"", "0 1 2 3", "0 1 2 3 4 5", "0 1 2 x 4"} {
+		if _, err := readStats(write(data)); err == nil {
+			t.Errorf("readStats(%q) returned no error", data)
+		}
+	}
+	if _, err := readStats(filepath.Join(dir, "missing")); err == nil {
+		t.Error("readStats returned no error for a missing file")
+	}
+}
@@ -0,0 +1,198 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// limitScriptは，引数で渡されたrlimit(仮想メモリ(KB)，CPU時間(秒))を設定した上でコマンドを実行するスクリプトである．
+// 値が "unlimited" の場合は制限しない．コマンドは "$@" としてそのまま実行されるため引用符の処理は不要である．
+const limitScript = `ulimit -v "$1" && ulimit -t "$2" && shift 2 && exec "$@"`
+
+// LocalRuntime - rlimitを設定したローカルプロセスとしてコマンドを実行するランタイム
+// ファイルシステムやネットワークの隔離は行わないため，Dockerを利用できないテスト環境や信頼できるコードのみを扱う軽量な環境での利用を想定する．
+// サンドボックス内部のパスはコマンドと環境変数の中でホスト側のパスに置き換えて実行する．
+type LocalRuntime struct{}
+
+// NewLocalRuntime - ローカルプロセスとして実行するランタイムを生成
+func NewLocalRuntime() (*LocalRuntime, error) {
+	return &LocalRuntime{}, nil
+}
+
+// Create - CopyIn で複製したファイルを保持するディレクトリを作成し，サンドボックスを生成
+func (r *LocalRuntime) Create(ctx context.Context, config Config) (Sandbox, error) {
+	filesDir, err := createFilesDir()
+	if err != nil {
+		return nil, err
+	}
+	return &localSandbox{config: config, filesDir: filesDir}, nil
+}
+
+// PrepareImage - イメージを用いないため，指定された参照をそのまま返す
+func (r *LocalRuntime) PrepareImage(ctx context.Context, image string) (string, error) {
+	return image, nil
+}
+
+// Warm - プロセスの起動は十分に速いため何もしない
+func (r *LocalRuntime) Warm(ctx context.Context, config Config, size int) error {
+	return nil
+}
+
+// localSandbox - ローカルプロセスによるサンドボックス
+type localSandbox struct {
+	config   Config
+	filesDir string // CopyIn で複製したファイルを保持するホスト側のディレクトリ
+}
+
+func (s *localSandbox) CopyIn(ctx context.Context, hostPath, name string) error {
+	return copyFile(s.filesDir, hostPath, name)
+}
+
+func (s *localSandbox) Destroy(ctx context.Context) error {
+	return os.RemoveAll(s.filesDir)
+}
+
+// Run - プロセスグループを分けてコマンドを実行し，制限時間を超過した場合はプロセスグループごと強制終了
+func (s *localSandbox) Run(ctx context.Context, options RunOptions) (RunResult, error) {
+	if len(options.Command) == 0 {
+		return RunResult{}, fmt.Errorf("command is empty")
+	}
+
+	// 書き込み可能な一時ディレクトリは実行ごとに作成
+	scratchDir, err := ioutil.TempDir("/tmp", "sandbox_scratch_")
+	if err != nil {
+		return RunResult{}, fmt.Errorf("failed to create scratch directory: %v", err)
+	}
+	defer os.RemoveAll(scratchDir)
+
+	replacer := s.pathReplacer(scratchDir)
+	args := make([]string, 0, len(options.Command)+4)
+	args = append(args, "/bin/sh", "-c", limitScript, "sh", s.memoryLimitKB(), cpuLimitSeconds(options.TimeLimit))
+	for _, arg := range options.Command {
+		args = append(args, replacer.Replace(arg))
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = scratchDir
+	cmd.Env = os.Environ()
+	for _, env := range s.config.Env {
+		cmd.Env = append(cmd.Env, replacer.Replace(env))
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var killOnce sync.Once
+	kill := func() {
+		killOnce.Do(func() {
+			if cmd.Process != nil {
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+			}
+		})
+	}
+
+	stdout := newLimitedWriter(options.Stdout, options.OutputLimit, kill)
+	cmd.Stdin = options.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = options.Stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return RunResult{}, fmt.Errorf("failed to start process: %v", err)
+	}
+
+	var timedOut atomic.Bool
+	var timer *time.Timer
+	if options.TimeLimit > 0 {
+		timer = time.AfterFunc(options.TimeLimit, func() {
+			timedOut.Store(true)
+			kill()
+		})
+	}
+	stopWatch := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			kill()
+		case <-stopWatch:
+		}
+	}()
+
+	waitErr := cmd.Wait()
+	wallTime := time.Since(start)
+	close(stopWatch)
+	if timer != nil {
+		timer.Stop()
+	}
+	if ctx.Err() != nil {
+		return RunResult{}, ctx.Err()
+	}
+	if _, ok := waitErr.(*exec.ExitError); waitErr != nil && !ok {
+		return RunResult{}, fmt.Errorf("failed to wait process: %v", waitErr)
+	}
+
+	result := RunResult{
+		WallTime:            wallTime,
+		OutputLimitExceeded: outputExceeded(stdout),
+	}
+	if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		result.Signal = int(status.Signal())
+		result.ExitCode = signalExitBase + result.Signal
+	} else {
+		result.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	if usage, ok := cmd.ProcessState.SysUsage().(*syscall.Rusage); ok {
+		result.CPUTime = time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+		result.PeakMemory = usage.Maxrss * 1024 // Linux では KB 単位
+	}
+	// rlimit による制限ではメモリ不足は確保の失敗として現れるため，ピークメモリ使用量が制限に達した場合に超過とみなす
+	result.OOMKilled = s.config.MemoryLimit > 0 && result.PeakMemory >= s.config.MemoryLimit
+	result.TimedOut = timedOut.Load() || isTimedOut(result.ExitCode, result.WallTime, options.TimeLimit) ||
+		result.Signal == int(syscall.SIGXCPU)
+
+	return result, nil
+}
+
+// pathReplacer - サンドボックス内部のパスをホスト側のパスに置き換える Replacer を生成
+// 前方一致するパスが複数ある場合は最も長いものを優先する．
+func (s *localSandbox) pathReplacer(scratchDir string) *strings.Replacer {
+	mounts := append([]Mount{{Source: s.filesDir, Target: FilesDir}}, s.config.Mounts...)
+	if s.config.ScratchDir != "" {
+		mounts = append(mounts, Mount{Source: scratchDir, Target: s.config.ScratchDir})
+	}
+	sort.SliceStable(mounts, func(i, j int) bool {
+		return len(mounts[i].Target) > len(mounts[j].Target)
+	})
+
+	pairs := make([]string, 0, len(mounts)*2)
+	for _, mount := range mounts {
+		pairs = append(pairs, mount.Target, mount.Source)
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// memoryLimitKB - ulimit -v に指定する仮想メモリの制限(KB)
+func (s *localSandbox) memoryLimitKB() string {
+	if s.config.MemoryLimit <= 0 {
+		return "unlimited"
+	}
+	return strconv.FormatInt(s.config.MemoryLimit/1024, 10)
+}
+
+// cpuLimitSeconds - ulimit -t に指定するCPU時間の制限(秒)
+// 実行時間制限による強制終了を補助するため，制限時間に1秒の猶予を加える．
+func cpuLimitSeconds(timeLimit time.Duration) string {
+	if timeLimit <= 0 {
+		return "unlimited"
+	}
+	return strconv.Itoa(int(math.Ceil(timeLimit.Seconds())) + 1)
+}
@@ -0,0 +1,108 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// runLocal - local ランタイムのサンドボックスでシェルスクリプトを実行
+func runLocal(t *testing.T, config Config, options RunOptions, script string) RunResult {
+	t.Helper()
+	runtime, err := NewLocalRuntime()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sb, err := runtime.Create(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Destroy(context.Background())
+
+	options.Command = []string{"/bin/sh", "-c", script}
+	result, err := sb.Run(context.Background(), options)
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	return result
+}
+
+func TestLocalRunExitCode(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	result := runLocal(t, Config{}, RunOptions{Stdin: strings.NewReader("input"), Stdout: &stdout, Stderr: &stderr}, "cat; echo err >&2; exit 3")
+	if result.ExitCode != 3 || result.Signal != 0 || result.TimedOut || result.OOMKilled {
+		t.Errorf("got %+v, want exit code 3", result)
+	}
+	if stdout.String() != "input" || stderr.String() != "err\n" {
+		t.Errorf("stdout = %q, stderr = %q", stdout.String(), stderr.String())
+	}
+
+	// シグナルによる終了は実際の待機状態から取得し，exit(200) などと区別する
+	if result := runLocal(t, Config{}, RunOptions{}, "exit 200"); result.ExitCode != 200 || result.Signal != 0 {
+		t.Errorf("got %+v, want exit code 200 without a signal", result)
+	}
+	if result := runLocal(t, Config{}, RunOptions{}, "kill -SEGV $$"); result.Signal != int(syscall.SIGSEGV) || result.ExitCode != signalExitBase+int(syscall.SIGSEGV) {
+		t.Errorf("got %+v, want SIGSEGV", result)
+	}
+}
+
+func TestLocalRunTimeLimit(t *testing.T) {
+	result := runLocal(t, Config{}, RunOptions{TimeLimit: 200 * time.Millisecond}, "sleep 5")
+	if !result.TimedOut || result.WallTime >= 5*time.Second {
+		t.Errorf("got %+v, want the process killed at the time limit", result)
+	}
+
+	if result := runLocal(t, Config{}, RunOptions{TimeLimit: 5 * time.Second}, "exit 0"); result.TimedOut {
+		t.Errorf("got %+v, want no timeout", result)
+	}
+}
+
+func TestLocalRunMemoryLimit(t *testing.T) {
+	// 制限を超えるメモリの確保は失敗し，異常終了する
+	const script = `x=$(head -c 268435456 /dev/zero | tr '\0' a); echo ${#x}`
+	var stdout bytes.Buffer
+	result := runLocal(t, Config{MemoryLimit: 32 << 20}, RunOptions{Stdout: &stdout}, script)
+	if result.ExitCode == 0 || strings.TrimSpace(stdout.String()) == "268435456" {
+		t.Errorf("got %+v, stdout %q; want the allocation to fail", result, stdout.String())
+	}
+}
+
+func TestLocalRunOutputLimit(t *testing.T) {
+	var stdout bytes.Buffer
+	result := runLocal(t, Config{}, RunOptions{Stdout: &stdout, OutputLimit: 1024, TimeLimit: 5 * time.Second}, "yes")
+	if !result.OutputLimitExceeded || stdout.Len() != 1024 || result.TimedOut {
+		t.Errorf("got %+v with %d bytes of output, want the process killed at the output limit", result, stdout.Len())
+	}
+}
+
+func TestLocalRunReplacesPaths(t *testing.T) {
+	dir := t.TempDir()
+	hostPath := filepath.Join(dir, "input.txt")
+	if err := os.WriteFile(hostPath, []byte("copied"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runtime, _ := NewLocalRuntime()
+	sb, err := runtime.Create(context.Background(), Config{ScratchDir: "/tmp/work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sb.Destroy(context.Background())
+	if err := sb.CopyIn(context.Background(), hostPath, "data/input.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	result, err := sb.Run(context.Background(), RunOptions{
+		Command: []string{"/bin/sh", "-c", "cat " + FilesDir + "/data/input.txt > /tmp/work/out && cat /tmp/work/out"},
+		Stdout:  &stdout,
+	})
+	if err != nil || result.ExitCode != 0 || stdout.String() != "copied" {
+		t.Errorf("got %+v, %v, stdout %q; want the copied file", result, err, stdout.String())
+	}
+}
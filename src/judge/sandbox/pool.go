@@ -0,0 +1,332 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	poolOwnerLabel = "procon.sandbox.pool"     // 待機中のコンテナを作成したジャッジサーバーのホスト名
+	poolDirLabel   = "procon.sandbox.pool.dir" // 待機中のコンテナに関連するホスト側のディレクトリ
+	poolDirPrefix  = "sandbox_pool_"
+)
+
+// waitScriptは，待機中のコンテナの主プロセスとして実行され，実行するコマンドが書き出されるまで待機するスクリプトである．
+// コンテナは起動直後に一時停止されるため，プールで待機している間はCPUを消費しない．
+const waitScript = `while [ ! -e ` + statsDir + `/` + commandFileName + ` ]; do sleep 0.01; done; ` +
+	`exec /bin/sh ` + statsDir + `/` + commandFileName
+
+// warmPool - 同じ構成のコンテナを起動して一時停止した状態で保持するプール
+// マウント先ごとにホスト側の空のディレクトリをマウントしておき，実行時にマウント元の内容をリンクして配置する．
+// コンテナは1回の実行ごとに削除され，プールには新しいコンテナが補充される．
+type warmPool struct {
+	runtime    *DockerRuntime
+	config     Config
+	size       int
+	containers chan *runContainer
+
+	mu      sync.Mutex
+	pending int // 作成中のコンテナ数
+}
+
+// Warm - 設定と同じ構成のコンテナを size 個起動して一時停止した状態で待機させる
+// マウントはいずれも読み取り専用である必要がある．メモリ制限とCPU制限は実行時のサンドボックスの設定に変更される．
+func (r *DockerRuntime) Warm(ctx context.Context, config Config, size int) error {
+	if size <= 0 {
+		return nil
+	}
+	for _, mount := range config.Mounts {
+		if !mount.ReadOnly {
+			return fmt.Errorf("warm sandbox supports only read-only mounts: %s", mount.Target)
+		}
+	}
+	if err := r.ensureImage(ctx, config.Image); err != nil {
+		return err
+	}
+	r.cleanupStale.Do(func() { r.removeStalePoolContainers(ctx) })
+
+	key := poolKey(config)
+	r.poolsMu.Lock()
+	pool, ok := r.pools[key]
+	if !ok {
+		pool = &warmPool{runtime: r, config: config, size: size, containers: make(chan *runContainer, size)}
+		r.pools[key] = pool
+	}
+	r.poolsMu.Unlock()
+	if ok {
+		return nil
+	}
+
+	for i := 0; i < size; i++ {
+		if err := pool.fill(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// acquireWarmContainer - 構成が一致する待機中のコンテナを取り出し，実行できる状態に準備
+// 待機中のコンテナがない場合や準備に失敗した場合はnilを返し，呼び出し元は新しいコンテナを作成する．
+func (r *DockerRuntime) acquireWarmContainer(ctx context.Context, config Config, filesDir string) *runContainer {
+	r.poolsMu.Lock()
+	pool := r.pools[poolKey(config)]
+	r.poolsMu.Unlock()
+	if pool == nil {
+		return nil
+	}
+
+	var c *runContainer
+	select {
+	case c = <-pool.containers:
+	default:
+	}
+	// 取り出したコンテナの代わりとなるコンテナ(待機中のコンテナがない場合も含む)を補充
+	go func() {
+		if err := pool.fill(context.Background()); err != nil {
+			log.Printf("Failed to refill warm sandbox pool: %v", err)
+		}
+	}()
+	if c == nil {
+		return nil
+	}
+
+	if err := r.prepareWarmContainer(ctx, c, config, filesDir); err != nil {
+		log.Printf("Failed to prepare warm sandbox, falling back to a new container: %v", err)
+		c.remove(r)
+		return nil
+	}
+	return c
+}
+
+// prepareWarmContainer - 一時停止を解除し，リソース制限の変更とマウント元の内容の配置を行う
+func (r *DockerRuntime) prepareWarmContainer(ctx context.Context, c *runContainer, config Config, filesDir string) error {
+	if err := r.do(ctx, http.MethodPost, "/containers/"+c.id+"/unpause", nil, nil); err != nil {
+		return err
+	}
+
+	update := map[string]int64{
+		"Memory":     config.MemoryLimit,
+		"MemorySwap": config.MemoryLimit,
+		"NanoCpus":   int64(config.CPUs * 1e9),
+	}
+	if err := r.do(ctx, http.MethodPost, "/containers/"+c.id+"/update", update, nil); err != nil {
+		return err
+	}
+
+	for i, mount := range config.Mounts {
+		if err := linkTree(mount.Source, filepath.Join(c.dir, "mounts", strconv.Itoa(i))); err != nil {
+			return err
+		}
+	}
+	return linkTree(filesDir, filepath.Join(c.dir, "files"))
+}
+
+// fill - プールのコンテナ数が上限に満たない場合に1つ作成して追加
+func (p *warmPool) fill(ctx context.Context) error {
+	p.mu.Lock()
+	if len(p.containers)+p.pending >= p.size {
+		p.mu.Unlock()
+		return nil
+	}
+	p.pending++
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.pending--
+		p.mu.Unlock()
+	}()
+
+	c, err := p.runtime.startWarmContainer(ctx, p.config)
+	if err != nil {
+		return err
+	}
+	select {
+	case p.containers <- c:
+	default:
+		c.remove(p.runtime)
+	}
+	return nil
+}
+
+// startWarmContainer - 構成に基づいてコンテナを作成・起動し，一時停止した状態にする
+// マウント先にはそれぞれ空のディレクトリを，FilesDir には CopyIn で複製したファイルを配置するディレクトリをマウントする．
+func (r *DockerRuntime) startWarmContainer(ctx context.Context, config Config) (*runContainer, error) {
+	dir, err := ioutil.TempDir("/tmp", poolDirPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create warm sandbox directory: %v", err)
+	}
+	c := &runContainer{dir: dir, statsDir: filepath.Join(dir, "stats"), stdin: true, warm: true}
+
+	binds := make([]string, 0, len(config.Mounts)+2)
+	filesDir := filepath.Join(dir, "files")
+	dirs := map[string]os.FileMode{dir: 0755, filesDir: 0755, c.statsDir: 0777}
+	for i, mount := range config.Mounts {
+		mountDir := filepath.Join(dir, "mounts", strconv.Itoa(i))
+		dirs[mountDir] = 0755
+		binds = append(binds, bindSpec(mountDir, mount.Target, true))
+	}
+	binds = append(binds,
+		bindSpec(filesDir, FilesDir, true),
+		bindSpec(c.statsDir, statsDir, false),
+	)
+	for path, mode := range dirs {
+		// コンテナ内部のユーザーから読み書きできるよう，umask の影響を避けて作成後に権限を設定
+		if err := os.MkdirAll(path, 0755); err == nil {
+			err = os.Chmod(path, mode)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("failed to create warm sandbox directory: %v", err)
+		}
+	}
+
+	// 待機中は判定をまたいでディレクトリを保持するため，/tmpのクリーンアップで削除されないよう共有ロックを取得
+	if c.dirLock, err = os.Open(dir); err == nil {
+		err = syscall.Flock(int(c.dirLock.Fd()), syscall.LOCK_SH)
+	}
+	if err != nil {
+		if c.dirLock != nil {
+			c.dirLock.Close()
+		}
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("failed to lock warm sandbox directory: %v", err)
+	}
+
+	request := containerRequest(config, binds, []string{"/bin/sh", "-c", waitScript}, true)
+	request.Labels = map[string]string{poolOwnerLabel: poolOwner(), poolDirLabel: dir}
+	if c.id, err = r.createContainer(ctx, request); err != nil {
+		os.RemoveAll(dir)
+		c.dirLock.Close()
+		return nil, err
+	}
+	for _, action := range []string{"start", "pause"} {
+		if err := r.do(ctx, http.MethodPost, "/containers/"+c.id+"/"+action, nil, nil); err != nil {
+			c.remove(r)
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// removeStalePoolContainers - 前回の起動時に作成され，終了時に削除されずに残った待機中のコンテナを削除
+func (r *DockerRuntime) removeStalePoolContainers(ctx context.Context) {
+	filters, _ := json.Marshal(map[string][]string{"label": {poolOwnerLabel + "=" + poolOwner()}})
+	var containers []struct {
+		ID     string `json:"Id"`
+		Labels map[string]string
+	}
+	if err := r.do(ctx, http.MethodGet, "/containers/json?all=1&filters="+url.QueryEscape(string(filters)), nil, &containers); err != nil {
+		log.Printf("Failed to list stale warm sandboxes: %v", err)
+		return
+	}
+	for _, container := range containers {
+		r.removeContainer(container.ID)
+		if dir := container.Labels[poolDirLabel]; strings.HasPrefix(filepath.Base(dir), poolDirPrefix) {
+			os.RemoveAll(dir)
+		}
+	}
+}
+
+// WorkspaceInUse - ホスト側のディレクトリが起動中のジャッジサーバーの待機中のコンテナに使用されている場合はtrue
+// 待機中のコンテナのディレクトリは共有ロック(flock)が保持されるため，排他ロックを取得できるかで判定する．/tmpを共有する他のジャッジサーバーのものも判定できる．
+func WorkspaceInUse(dir string) bool {
+	file, err := os.Open(dir)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		return errors.Is(err, syscall.EWOULDBLOCK)
+	}
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return false
+}
+
+// poolOwner - 待機中のコンテナを作成したジャッジサーバーを識別する名前(ホスト名)
+// 複数のジャッジサーバーが同じDockerデーモンを共有する場合に，他のサーバーのコンテナを削除しないために用いる．
+func poolOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return hostname
+}
+
+// poolKey - 待機中のコンテナを共有できる構成を識別するキー
+// メモリ制限とCPU制限は実行時に変更できるため含めない．
+func poolKey(config Config) string {
+	targets := make([]string, len(config.Mounts))
+	for i, mount := range config.Mounts {
+		targets[i] = bindSpec("", mount.Target, mount.ReadOnly)
+	}
+	key, _ := json.Marshal([]interface{}{config.Image, config.ScratchDir, config.ScratchSize, config.Env, targets})
+	return string(key)
+}
+
+// linkTree - ディレクトリの内容をハードリンクで別のディレクトリに配置(リンクできない場合は複製)
+func linkTree(source, target string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(target, rel)
+
+		switch {
+		case info.IsDir():
+			if err := os.MkdirAll(dst, 0755); err != nil {
+				return err
+			}
+			return os.Chmod(dst, info.Mode().Perm())
+		case rel == ".":
+			return fmt.Errorf("warm sandbox supports only directory mounts: %s", source)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, dst)
+		case info.Mode().IsRegular():
+			if err := os.Link(path, dst); err == nil {
+				return nil
+			}
+			return copyFileMode(path, dst, info.Mode().Perm())
+		}
+		return nil
+	})
+}
+
+// copyFileMode - 権限を保ったままファイルを複製
+func copyFileMode(source, target string, mode os.FileMode) error {
+	src, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
@@ -0,0 +1,33 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestWorkspaceInUse(t *testing.T) {
+	dir := t.TempDir()
+	if WorkspaceInUse(dir) {
+		t.Error("WorkspaceInUse returned true for an unlocked directory")
+	}
+	if WorkspaceInUse(filepath.Join(dir, "missing")) {
+		t.Error("WorkspaceInUse returned true for a missing directory")
+	}
+
+	lock, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := syscall.Flock(int(lock.Fd()), syscall.LOCK_SH); err != nil {
+		t.Fatal(err)
+	}
+	if !WorkspaceInUse(dir) {
+		t.Error("WorkspaceInUse returned false for a directory locked by a warm sandbox")
+	}
+	lock.Close()
+	if WorkspaceInUse(dir) {
+		t.Error("WorkspaceInUse returned true after the lock was released")
+	}
+}
@@ -0,0 +1,215 @@
+package sandbox
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	RuntimeDocker = "docker" // Docker Engine APIを用いてコンテナ内で実行するランタイム
+	RuntimeLocal  = "local"  // rlimitを設定したローカルプロセスとして実行するランタイム(テストや軽量な環境向け)
+
+	// FilesDir - CopyIn でサンドボックスに複製したファイルが配置されるサンドボックス内部のディレクトリ(読み取り専用)
+	FilesDir = "/sandbox"
+)
+
+// Runtime - サンドボックスを生成する実行環境
+type Runtime interface {
+	// Create - 設定に基づいてサンドボックスを生成
+	Create(ctx context.Context, config Config) (Sandbox, error)
+	// PrepareImage - イメージを事前に取得し，内容が変わらないようダイジェストで固定した参照を取得
+	PrepareImage(ctx context.Context, image string) (string, error)
+	// Warm - 設定と同じ構成のサンドボックスを起動済みの状態で size 個まで待機させ，以降の実行の起動時間を短縮
+	// 設定の Mounts はマウント先のみを用い，マウント元は実行時のサンドボックスの設定に置き換えられる．
+	Warm(ctx context.Context, config Config, size int) error
+}
+
+// Sandbox - リソース制限のもとでコマンドを隔離して実行する環境
+// 1つのサンドボックスで複数のコマンドを並行して実行でき，各コマンドは互いに独立した環境で実行される．
+type Sandbox interface {
+	// CopyIn - ホスト側のファイルをサンドボックス内部の FilesDir/name に複製
+	CopyIn(ctx context.Context, hostPath, name string) error
+	// Run - コマンドを実行し，終了コードと計測結果を取得
+	Run(ctx context.Context, options RunOptions) (RunResult, error)
+	// Destroy - サンドボックスを破棄し，関連するリソースを削除
+	Destroy(ctx context.Context) error
+}
+
+// Mount - ホスト側のディレクトリをサンドボックス内部にマウントする設定
+type Mount struct {
+	Source   string // ホスト側のパス
+	Target   string // サンドボックス内部のパス
+	ReadOnly bool   // 読み取り専用でマウントする場合はtrue
+}
+
+// Config - サンドボックスの設定
+type Config struct {
+	Image       string  // 使用するDockerイメージ(local ランタイムでは無視される)
+	Mounts      []Mount // マウントするディレクトリ
+	ScratchDir  string  // 実行ごとに用意される書き込み可能な一時ディレクトリ(Dockerではtmpfs)
+	ScratchSize int64   // 一時ディレクトリのサイズ制限(バイト)
+	MemoryLimit int64   // メモリ制限(バイト)
+	CPUs        float64 // CPU制限(コア数)
+	Env         []string
+}
+
+// RunOptions - コマンドの実行設定
+type RunOptions struct {
+	Command     []string      // 実行するコマンドと引数
+	Stdin       io.Reader     // 標準入力(nilの場合は空)
+	Stdout      io.Writer     // 標準出力の書き込み先(nilの場合は破棄)
+	Stderr      io.Writer     // 標準エラー出力の書き込み先(nilの場合は破棄)
+	TimeLimit   time.Duration // 実行時間制限(0の場合は制限なし)
+	OutputLimit int64         // 標準出力のサイズ制限(バイト，0の場合は制限なし)
+}
+
+// RunResult - コマンドの実行結果
+type RunResult struct {
+	ExitCode            int           // 終了コード(シグナルにより終了した場合は 128 + シグナル番号)
+	Signal              int           // 終了の原因となったシグナル番号(シグナルによる終了でない場合，または終了コードから判別できない場合は0)
+	TimedOut            bool          // 実行時間制限を超過して強制終了された場合はtrue
+	OOMKilled           bool          // メモリ制限を超過して強制終了された場合はtrue(ランタイムがcgroupのOOM Killerによる強制終了などを検出した場合のみ)
+	OutputLimitExceeded bool          // 標準出力のサイズ制限を超過した場合はtrue
+	WallTime            time.Duration // 実行時間(ウォールタイム)
+	CPUTime             time.Duration // CPU時間(取得できない場合は0)
+	PeakMemory          int64         // ピークメモリ使用量(バイト，取得できない場合は0)
+}
+
+const (
+	timeoutExitStatus = 124 // timeoutコマンドが制限時間超過時に返す終了コード
+	signalExitBase    = 128 // シグナル終了時の終了コードの基準値(128 + シグナル番号)
+	maxSignal         = 31  // 終了コードからシグナルとみなす最大のシグナル番号(標準シグナルの最大値)
+	killGracePeriod   = 5 * time.Second
+)
+
+// New - 名前に対応するランタイムを生成
+// 名前が空文字列の場合は Docker ランタイムを用いる．
+func New(name string) (Runtime, error) {
+	switch name {
+	case "", RuntimeDocker:
+		return NewDockerRuntime(os.Getenv("DOCKER_HOST"))
+	case RuntimeLocal:
+		return NewLocalRuntime()
+	default:
+		return nil, fmt.Errorf("unsupported sandbox runtime: %s", name)
+	}
+}
+
+// createFilesDir - CopyIn で複製したファイルを保持するホスト側のディレクトリを作成
+func createFilesDir() (string, error) {
+	dir, err := ioutil.TempDir("/tmp", "sandbox_")
+	if err != nil {
+		return "", fmt.Errorf("failed to create sandbox directory: %v", err)
+	}
+	// サンドボックス内部のユーザーから読み取れるよう権限を設定
+	if err := os.Chmod(dir, 0755); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to change sandbox directory permission: %v", err)
+	}
+	return dir, nil
+}
+
+// copyFile - ホスト側のファイルを FilesDir に対応するディレクトリへ複製
+func copyFile(filesDir, hostPath, name string) error {
+	target := filepath.Join(filesDir, filepath.Clean("/"+name))
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create sandbox directory: %v", err)
+	}
+
+	src, err := os.Open(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %v", err)
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create sandbox file: %v", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to copy file into sandbox: %v", err)
+	}
+	return dst.Close()
+}
+
+// isTimedOut - 終了コードと実行時間から実行時間制限の超過による強制終了かを判定
+// timeout は制限時間超過時に 124 を返し，-k による強制終了の場合は SIGKILL(137)となる．
+func isTimedOut(exitCode int, wallTime, timeLimit time.Duration) bool {
+	if timeLimit <= 0 {
+		return false
+	}
+	return (exitCode == timeoutExitStatus || exitCode == signalExitBase+9) && wallTime >= timeLimit
+}
+
+// signalFromExitCode - シェルが返す終了コードから終了の原因となったシグナル番号を取得(シグナルによる終了とみなせない場合は0)
+// シェルはシグナルによる終了を 128 + シグナル番号 として返すが，exit(200) のような通常の終了と区別できないため，
+// 標準シグナル(1〜31)に対応する 129〜159 のみをシグナルによる終了とみなす．
+func signalFromExitCode(exitCode int) int {
+	if exitCode > signalExitBase && exitCode <= signalExitBase+maxSignal {
+		return exitCode - signalExitBase
+	}
+	return 0
+}
+
+// limitedWriter - 書き込みサイズを制限し，超過した場合は以降の書き込みを破棄する
+type limitedWriter struct {
+	w         io.Writer
+	remaining int64
+	exceeded  bool
+	onExceed  func() // 制限を超過した時点で1度だけ呼び出される
+}
+
+func newLimitedWriter(w io.Writer, limit int64, onExceed func()) io.Writer {
+	if w == nil {
+		w = ioutil.Discard
+	}
+	if limit <= 0 {
+		return w
+	}
+	return &limitedWriter{w: w, remaining: limit, onExceed: onExceed}
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if l.exceeded {
+		return len(p), nil
+	}
+	if int64(len(p)) > l.remaining {
+		l.w.Write(p[:l.remaining])
+		l.remaining = 0
+		l.exceeded = true
+		if l.onExceed != nil {
+			l.onExceed()
+		}
+		return len(p), nil
+	}
+	l.remaining -= int64(len(p))
+	return l.w.Write(p)
+}
+
+// discardOnErrorWriter - 書き込み先がエラーを返した場合は以降の書き込みを破棄する
+// インタラクタとの対話で相手のプロセスが先に終了した場合など，書き込み先が閉じられても出力の読み取りを継続するために用いる．
+type discardOnErrorWriter struct {
+	w      io.Writer
+	failed bool
+}
+
+func (d *discardOnErrorWriter) Write(p []byte) (int, error) {
+	if !d.failed {
+		if _, err := d.w.Write(p); err != nil {
+			d.failed = true
+		}
+	}
+	return len(p), nil
+}
+
+// outputExceeded - 書き込み先がサイズ制限を超過したかを取得
+func outputExceeded(w io.Writer) bool {
+	l, ok := w.(*limitedWriter)
+	return ok && l.exceeded
+}
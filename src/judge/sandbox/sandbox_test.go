@@ -0,0 +1,97 @@
+package sandbox
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestIsTimedOut(t *testing.T) {
+	tests := []struct {
+		name      string
+		exitCode  int
+		wallTime  time.Duration
+		timeLimit time.Duration
+		want      bool
+	}{
+		{"timeout exit status", timeoutExitStatus, 2 * time.Second, time.Second, true},
+		{"killed after grace period", signalExitBase + 9, 2 * time.Second, time.Second, true},
+		{"killed within limit", signalExitBase + 9, 500 * time.Millisecond, time.Second, false},
+		{"exit 124 within limit", timeoutExitStatus, 500 * time.Millisecond, time.Second, false},
+		{"normal exit after limit", 0, 2 * time.Second, time.Second, false},
+		{"no limit", timeoutExitStatus, 2 * time.Second, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTimedOut(test.exitCode, test.wallTime, test.timeLimit); got != test.want {
+				t.Errorf("isTimedOut(%d, %v, %v) = %v, want %v", test.exitCode, test.wallTime, test.timeLimit, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSignalFromExitCode(t *testing.T) {
+	tests := []struct {
+		exitCode int
+		want     int
+	}{
+		{0, 0},
+		{1, 0},
+		{128, 0},
+		{129, 1},
+		{137, 9},
+		{159, 31},
+		{160, 0},
+		{200, 0}, // exit(200) はシグナルによる終了ではない
+		{255, 0},
+	}
+
+	for _, test := range tests {
+		if got := signalFromExitCode(test.exitCode); got != test.want {
+			t.Errorf("signalFromExitCode(%d) = %d, want %d", test.exitCode, got, test.want)
+		}
+	}
+}
+
+func TestLimitedWriter(t *testing.T) {
+	var buf bytes.Buffer
+	calls := 0
+	w := newLimitedWriter(&buf, 5, func() { calls++ })
+
+	// 制限ちょうどまでは超過とみなさない
+	if n, err := w.Write([]byte("abcde")); n != 5 || err != nil {
+		t.Fatalf("Write returned %d, %v", n, err)
+	}
+	if outputExceeded(w) || calls != 0 {
+		t.Fatalf("exceeded = %v, calls = %d after writing exactly the limit", outputExceeded(w), calls)
+	}
+
+	// 超過した書き込みも書き込んだものとして扱い，以降の書き込みは破棄する
+	for _, data := range []string{"fg", "hij"} {
+		if n, err := w.Write([]byte(data)); n != len(data) || err != nil {
+			t.Fatalf("Write(%q) returned %d, %v", data, n, err)
+		}
+	}
+	if buf.String() != "abcde" || !outputExceeded(w) || calls != 1 {
+		t.Errorf("got %q, exceeded = %v, calls = %d; want %q, true, 1", buf.String(), outputExceeded(w), calls, "abcde")
+	}
+}
+
+func TestLimitedWriterPartialWrite(t *testing.T) {
+	var buf bytes.Buffer
+	w := newLimitedWriter(&buf, 3, nil)
+	w.Write([]byte("abcdef"))
+	if buf.String() != "abc" || !outputExceeded(w) {
+		t.Errorf("got %q, exceeded = %v; want %q, true", buf.String(), outputExceeded(w), "abc")
+	}
+}
+
+func TestLimitedWriterWithoutLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := newLimitedWriter(&buf, 0, func() { t.Error("onExceed called without a limit") })
+	w.Write(bytes.Repeat([]byte("a"), 1<<16))
+	if buf.Len() != 1<<16 || outputExceeded(w) {
+		t.Errorf("wrote %d bytes, exceeded = %v; want all bytes without exceeding", buf.Len(), outputExceeded(w))
+	}
+}
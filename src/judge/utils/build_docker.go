@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os/exec"
+	"os"
 	"path/filepath"
 	"procon_web_service/src/common/config"
-	"procon_web_service/src/common/minio"
 	"procon_web_service/src/common/models"
-	"regexp"
+	"procon_web_service/src/judge/compare"
+	judgeconfig "procon_web_service/src/judge/config"
+	"procon_web_service/src/judge/datacache"
+	"procon_web_service/src/judge/sandbox"
 	"strconv"
 	"sync"
 	"time"
@@ -19,184 +22,511 @@ import (
 )
 
 var (
-	// リソース制限の設定
-	memoryLimit = "512m" // メモリ制限
-	cpuLimit    = "1.0"  // CPU制限
-	timeout     = 1      //実行時間制限
-
-	// コンテナ内部のセキュリティオプション指定
-	securityOpts = []string{
-		"--net=none",                      // コンテナのネットワークアクセスを遮断
-		"--read-only",                     // コンテナのファイルシステムを読み取り専用に設定
-		"--tmpfs /workspace:rw,size=512m", // 読み書き可能で512MBのサイズ制限付きの一時ファイルシステムとしてマウント
-		"--cap-drop=ALL",                  // セキュリティのためコンテナ内部Linuxカーネル機能を削除
-	}
+	// リソース制限の設定(実行時間制限とメモリ制限は問題ごとに設定される)
+	cpuLimit    = 1.0       // CPU制限(コア数)
+	outputLimit = 64 << 20  // 出力サイズ制限(バイト)
+	scratchSize = 512 << 20 // コンテナ内部の一時ファイルシステム(/workspace)のサイズ制限(バイト)
+
+	// ジャッジサーバーの設定(SetJudgeConfig で設定される)
+	judgeConfig *judgeconfig.JudgeConfig
+
+	// 全ての提出を通して同時に起動するコンテナ数を制限するセマフォ(SetJudgeConfig で設定される)
+	containerSlots chan struct{}
+	multiSlotMu    sync.Mutex // 複数の枠を同時に確保する処理を直列化するロック
+
+	// コンパイルと実行に用いるサンドボックスのランタイム(SetSandboxRuntime で設定される)
+	sandboxRuntime sandbox.Runtime
+
+	// ローカルに用意したテストデータのキャッシュ(SetTestDataCache で設定される)
+	testDataCache *datacache.Cache
 )
 
+const (
+	compileTimeLimit     = 30 * time.Second // コンパイルの制限時間
+	compileMemoryLimit   = 1024 << 20       // コンパイル時のメモリ制限(バイト)
+	maxCompileOutputSize = 64 << 10         // 保存するコンパイラ出力の最大サイズ(バイト)
+	maxCaseSnippetSize   = 4 << 10          // 保存するテストケースごとの標準エラー出力，出力，期待される出力の最大サイズ(バイト)
+	scratchDir           = "/workspace"     // コンテナ内部の書き込み可能な一時ファイルシステム
+)
+
+// signalNamesは，実行時エラーの表示に用いるシグナル番号とシグナル名の対応表である．
+var signalNames = map[int]string{
+	1:  "SIGHUP",
+	2:  "SIGINT",
+	3:  "SIGQUIT",
+	4:  "SIGILL",
+	5:  "SIGTRAP",
+	6:  "SIGABRT",
+	7:  "SIGBUS",
+	8:  "SIGFPE",
+	9:  "SIGKILL",
+	11: "SIGSEGV",
+	13: "SIGPIPE",
+	14: "SIGALRM",
+	15: "SIGTERM",
+	24: "SIGXCPU",
+	25: "SIGXFSZ",
+}
+
+// ResourceLimits - 1つの解答の実行に適用するリソース制限
+type ResourceLimits struct {
+	TimeLimit   time.Duration // 実行時間制限(言語ごとの倍率を適用済み)
+	MemoryLimit int           // メモリ制限(MB)
+}
+
+// NewResourceLimits - 問題の設定と提出言語から実行に適用するリソース制限を生成
+func NewResourceLimits(problem models.Problem, languageID int) ResourceLimits {
+	if problem.TimeLimit <= 0 {
+		problem.TimeLimit = models.DefaultTimeLimit
+	}
+	if problem.MemoryLimit <= 0 {
+		problem.MemoryLimit = models.DefaultMemoryLimit
+	}
+	// 問題ごとの倍率が設定されていない場合は言語ごとの既定の倍率を適用
+	timeLimit := problem.TimeLimitFor(languageID)
+	if _, ok := problem.LanguageMultipliers[languageID]; !ok {
+		if langConfig, ok := config.GetLanguageConfigByID(languageID); ok && langConfig.TimeMultiplier > 0 {
+			timeLimit = time.Duration(float64(timeLimit) * langConfig.TimeMultiplier)
+		}
+	}
+
+	return ResourceLimits{
+		TimeLimit:   timeLimit,
+		MemoryLimit: problem.MemoryLimit,
+	}
+}
+
+// CompileResult - コンパイルフェーズの結果
+type CompileResult struct {
+	Success bool   // コンパイルに成功した場合はtrue
+	Output  string // コンパイラの出力(標準出力と標準エラー出力)
+}
+
 type ExecutionResult struct {
-	Success       bool
-	ExecutionTime int64  // 実行時間（ナノ秒）
-	OutputDiff    bool   // 出力が期待される出力と異なる場合はtrue
-	ErrorMessage  string // 実行エラーのメッセージ（エラーが発生した場合）
+	Success        bool
+	ExecutionTime  int64  // 実行時間(ウォールタイム)（ナノ秒）
+	CPUTime        int64  // CPU時間（ナノ秒）
+	PeakMemory     int64  // ピークメモリ使用量（バイト）
+	ExitStatus     int    // 提出プログラムの終了コード
+	Signal         int    // 提出プログラムを終了させたシグナル番号（シグナルによる終了でない場合は0）
+	TimedOut       bool   // 実行時間制限の超過により強制終了された場合はtrue
+	MemoryExceeded bool   // メモリ制限の超過により強制終了された場合はtrue
+	OutputExceeded bool   // 出力サイズ制限を超過した場合はtrue
+	OutputDiff     bool   // 出力が期待される出力と異なる場合はtrue
+	OutputSize     int64  // 提出プログラムの出力サイズ（バイト）
+	Stderr         string // 提出プログラムの標準エラー出力（サイズを制限して切り詰めたもの）
+	ErrorMessage   string // 実行エラーのメッセージ（エラーが発生した場合）
+}
+
+// SetJudgeConfig - ジャッジサーバーの設定(同時に起動するコンテナ数，待機させるサンドボックスの数，イメージの固定の要否)を設定
+// コンパイルと実行を行う前に呼び出す．
+func SetJudgeConfig(newConfig *judgeconfig.JudgeConfig) {
+	judgeConfig = newConfig
+	containerSlots = make(chan struct{}, newConfig.MaxContainers)
+}
+
+// SetSandboxRuntime - コンパイルと実行に用いるサンドボックスのランタイムを設定
+func SetSandboxRuntime(runtime sandbox.Runtime) {
+	sandboxRuntime = runtime
 }
 
-// BuildAndRunInContainer - 提出されたコードをDockerコンテナ内で平行処理によりテスト && 結果を取得
-func BuildAndRunInContainer(ctx context.Context, solution models.Solution) (*models.ResultDetail, error) {
+// SetTestDataCache - 判定に用いるテストデータのキャッシュを設定
+func SetTestDataCache(cache *datacache.Cache) {
+	testDataCache = cache
+}
+
+// TestDataCacheStats - テストデータのキャッシュの使用状況と統計情報を取得(キャッシュが設定されていない場合はnil)
+func TestDataCacheStats() *models.TestDataCacheStats {
+	if testDataCache == nil {
+		return nil
+	}
+	stats := testDataCache.Stats()
+	return &stats
+}
+
+// BuildAndRunInContainer - 提出されたコードを問題ごとの実行制限のもとDockerコンテナ内で平行処理によりテスト && 結果を取得
+// 判定方式が "fail_fast" の問題ではテストケースを順に実行し，最初に正解とならなかった時点で判定を打ち切る．
+// events が nil でない場合は，コンパイルの終了と各テストケースの判定の終了をイベントとして送信する．
+func BuildAndRunInContainer(ctx context.Context, solution models.Solution, problem models.Problem, events EventSink) (*models.ResultDetail, error) {
 	_, ok := config.GetLanguageConfigByID(solution.LanguageID)
 	if !ok {
 		return nil, fmt.Errorf("unsupported language ID: %d", solution.LanguageID)
 	}
 
-	// ソースコードを一時ファイルに保存
-	codeFilePath, langConfig, cleanup, err := SaveCodeToFile(solution.LanguageID, solution.Code)
+	// 問題の設定から実行制限を決定
+	limits := NewResourceLimits(problem, solution.LanguageID)
+
+	// 判定の進捗をキューの状態とともに取得できるよう記録し，イベントとして送信
+	progress, untrack := trackProgress(solution.SolutionID, events)
+	defer untrack()
+
+	// 提出ごとの作業ディレクトリを作成しソースコードを保存
+	ws, langConfig, cleanup, err := CreateWorkspace(solution.LanguageID, solution.Code)
+	if cleanup != nil {
+		defer cleanup()
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer cleanup()
 
-	// 関連する入出力ファイルをダウンロードしローカルに保存
-	if err := minio.DownloadIOFiles(ctx, solution.ProblemID); err != nil {
+	// 判定に用いる版のテストデータを，マニフェストのハッシュ値と一致することを確認してローカルに用意(判定の終了まで削除されない)
+	// 版を導入する前に作成された問題(版が0)は，webサーバーが起動時に移行した最初の版で判定する
+	testSetVersion := problem.TestSetVersion
+	if testSetVersion == 0 {
+		testSetVersion = models.LegacyTestSetVersion
+	}
+	testSetDir, releaseTestSet, err := testDataCache.Acquire(ctx, solution.ProblemID, testSetVersion)
+	if err != nil {
 		return nil, err
 	}
+	defer releaseTestSet()
 
-	ioFiles, err := getTestCases(solution.ProblemID)
+	testCases, err := getTestCases(testSetDir, problem)
 	if err != nil {
 		return nil, err
 	}
 
 	var results models.ResultDetail
-	results.TotalCases = len(ioFiles)
+	results.TotalCases = len(testCases)
+	results.TestSetVersion = testSetVersion
+
+	// コンパイルは提出ごとに1度だけ行い，成果物を全てのテストケースで共有
+	compileResult, err := compileInContainer(ctx, langConfig, ws)
+	if err != nil {
+		return nil, err
+	}
+	// コンパイラの出力は成功した場合も警告を確認できるよう保存
+	results.CompileOutput = compileResult.Output
+	progress.compiled(compileResult.Success, len(testCases))
+	if !compileResult.Success {
+		// コンパイルに失敗した場合は全てのテストケースをコンパイルエラーとする
+		for _, testCase := range testCases {
+			results.AddCaseResult(models.CaseResult{
+				CaseName: testCase.name(),
+				Result:   models.VerdictCompileError,
+			})
+		}
+		results.Verdict = models.VerdictCompileError
+		results.ScoreSubtasks(problem.Subtasks)
+		return &results, nil
+	}
+
+	// テストケースの実行方法を問題の種類と判定方法に基づいて準備
+	runner, err := newCaseRunner(ctx, langConfig, ws, problem, testSetDir, limits)
+	if err != nil {
+		return nil, err
+	}
+	defer runner.close()
+	runner.progress = progress
+	progress.startRunning(len(testCases))
+
+	// 判定方式に従ってテストケースを実行(結果はいずれもテストケースの順に並ぶ)
+	var caseResults []models.CaseResult
+	if problem.FailsFast() {
+		caseResults, err = runner.runInOrder(ctx, testCases)
+	} else {
+		caseResults, err = runner.runAll(ctx, testCases)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, caseResult := range caseResults {
+		results.AddCaseResult(caseResult)
+	}
+	if results.Verdict == "" {
+		results.Verdict = models.VerdictAccepted
+	}
+	// サブタスクが設定されている場合は得点を計算
+	results.ScoreSubtasks(problem.Subtasks)
+	return &results, nil
+}
+
+// testCase - 1つのテストケースの入力ファイルと期待される出力ファイルのパス
+type testCase struct {
+	inputPath  string
+	outputPath string
+}
+
+// name - テストケースの名前(入力ファイル名)
+func (tc testCase) name() string {
+	return filepath.Base(tc.inputPath)
+}
+
+// caseRunner - 1つの提出の各テストケースを実行し判定する
+// 通常の問題はチェッカーまたは比較モードで出力を判定し，インタラクティブ問題はインタラクタと対話させて判定する．
+type caseRunner struct {
+	langConfig config.LanguageConfig
+	ws         *Workspace
+	problem    models.Problem // 判定結果で公開する詳細の設定を含む問題の設定
+	testSetDir string         // 判定に用いるテストデータの保存先
+	limits     ResourceLimits
+	sandbox    sandbox.Sandbox    // 提出プログラムを実行するサンドボックス
+	checker    *Checker           // チェッカー(設定されている場合)
+	comparator compare.Comparator // 出力の比較器(チェッカーが設定されていない場合)
+	interactor *Interactor        // インタラクタ(インタラクティブ問題の場合)
+	progress   *judgeProgress     // 判定の進捗(記録しない場合はnil)
+}
+
+// newCaseRunner - 提出プログラムのサンドボックスを生成し，問題の種類と判定方法に応じてチェッカー，比較器，インタラクタを準備
+func newCaseRunner(ctx context.Context, langConfig config.LanguageConfig, ws *Workspace, problem models.Problem, testSetDir string, limits ResourceLimits) (*caseRunner, error) {
+	image, err := languageImage(ctx, langConfig)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := sandboxRuntime.Create(ctx, sandboxConfig(image, solutionMounts(ws.CodeDir(), ws.BinDir), int64(limits.MemoryLimit)<<20))
+	if err != nil {
+		return nil, err
+	}
 
-	errChan := make(chan error, 1)                            // エラーを受け取るチャネル
-	resultsChan := make(chan models.CaseResult, len(ioFiles)) // 結果を受け取るチャネル
+	runner := &caseRunner{
+		langConfig: langConfig,
+		ws:         ws,
+		problem:    problem,
+		testSetDir: testSetDir,
+		limits:     limits,
+		sandbox:    sb,
+	}
 
-	var wg sync.WaitGroup
-	// 並列処理によってテストケースを実行
-	// ATTENTION: 同時に多数のDockerコンテナを起動することになるためシステムリソースに与える影響を考慮し適切な並列度を設定する必要あり
-	for inputFilePath, outputFilePath := range ioFiles {
+	switch {
+	case problem.IsInteractive():
+		runner.interactor, err = PrepareInteractor(ctx, testSetDir, problem.InteractorLanguageID)
+	case problem.HasChecker():
+		runner.checker, err = PrepareChecker(ctx, testSetDir, problem.CheckerLanguageID)
+	default:
+		runner.comparator, err = compare.New(problem.CompareMode, problem.FloatEpsilon)
+	}
+	if err != nil {
+		runner.close()
+		return nil, err
+	}
+
+	return runner, nil
+}
+
+// close - 提出プログラムと補助プログラムのサンドボックスを破棄
+func (r *caseRunner) close() {
+	r.sandbox.Destroy(context.Background())
+	if r.checker != nil {
+		r.checker.close()
+	}
+	if r.interactor != nil {
+		r.interactor.close()
+	}
+}
 
+// runAll - 全てのテストケースを並列に実行し，テストケースの順に判定結果を取得
+// 同時に起動するコンテナ数は containerSlots により制限される．いずれかのテストケースでエラーが発生した場合は残りの実行を中断する．
+func (r *caseRunner) runAll(ctx context.Context, testCases []testCase) ([]models.CaseResult, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	caseResults := make([]models.CaseResult, len(testCases))
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i, tc := range testCases {
 		wg.Add(1)
-		go func(inputFilePath, outputFilePath string) {
+		go func(i int, tc testCase) {
 			defer wg.Done()
 
-			// 一時的な書き込みファイルを作成
-			tempFilePath, cleanup, err := CreateTempFile()
+			caseResult, err := r.run(runCtx, tc.inputPath, tc.outputPath)
 			if err != nil {
-				select {
-				case errChan <- err:
-				case <-ctx.Done():
-				}
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
 				return
 			}
-			defer cleanup()
+			caseResults[i] = caseResult
+			r.progress.caseDone(i, caseResult)
+		}(i, tc)
+	}
+	wg.Wait()
 
-			dockerCommand := buildDockerRunCommandWithTimeout(langConfig, codeFilePath, inputFilePath, tempFilePath, outputFilePath, solution.ProblemID, timeout)
-			executionResult, err := executeDockerCommand(ctx, dockerCommand)
-			if err != nil {
-				select {
-				case errChan <- err:
-				case <-ctx.Done():
-				}
-				return
-			}
+	if ctx.Err() != nil {
+		// コンテキストがキャンセルされた場合，処理を中断
+		return nil, ctx.Err()
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return caseResults, nil
+}
 
-			caseResult := models.CaseResult{
-				CaseName: filepath.Base(inputFilePath),
-				Result: func() string {
-					if executionResult.ErrorMessage != "" {
-						results.IncorrectCases++
-						return "INTERNAL ERROR"
-					}
-					if executionResult.OutputDiff {
-						results.IncorrectCases++
-						return "FAILED"
-					}
-					if executionResult.ExecutionTime/1_000_000 > 2000 {
-						results.TimeLimitExceeded++
-						return "TIME LIMITED EXCEEDED"
-					}
-					results.CorrectCases++
-					return "PASSED"
-				}(),
-				ExecutionTime: time.Duration(executionResult.ExecutionTime),
+// runInOrder - テストケースを順に1つずつ実行し，最初に正解とならなかった時点で以降のテストケースを実行せずに打ち切る
+// 実行しなかったテストケースの判定結果は SKIP となる．
+func (r *caseRunner) runInOrder(ctx context.Context, testCases []testCase) ([]models.CaseResult, error) {
+	caseResults := make([]models.CaseResult, 0, len(testCases))
+	for i, tc := range testCases {
+		caseResult, err := r.run(ctx, tc.inputPath, tc.outputPath)
+		if err != nil {
+			return nil, err
+		}
+		caseResults = append(caseResults, caseResult)
+		r.progress.caseDone(i, caseResult)
+
+		if caseResult.Result != models.VerdictAccepted {
+			for _, skipped := range testCases[i+1:] {
+				caseResults = append(caseResults, models.CaseResult{
+					CaseName: skipped.name(),
+					Result:   models.VerdictSkipped,
+				})
 			}
+			break
+		}
+	}
+	return caseResults, nil
+}
 
-			select {
-			case resultsChan <- caseResult:
-			case <-ctx.Done():
-			}
-		}(inputFilePath, outputFilePath)
+// run - 1つのテストケースを実行し判定結果を取得
+func (r *caseRunner) run(ctx context.Context, inputFilePath, outputFilePath string) (models.CaseResult, error) {
+	if r.interactor != nil {
+		caseResult, err := r.interactor.RunCase(ctx, r.sandbox, runCommand(r.langConfig, r.ws), inputFilePath, outputFilePath, r.limits)
+		if err != nil {
+			return models.CaseResult{}, err
+		}
+		// 提出プログラムの出力はインタラクタとの対話であるため公開しない
+		r.revealDetails(&caseResult, "", "")
+		return caseResult, nil
+	}
 
+	// 一時的な書き込みファイルを作成
+	tempFilePath, cleanup, err := CreateTempFile()
+	if err != nil {
+		return models.CaseResult{}, err
 	}
+	defer cleanup()
 
-	// ゴルーチンがすべて終了するのを待つ
-	go func() {
-		wg.Wait()
-		close(errChan)
-		close(resultsChan)
-	}()
+	executionResult, err := r.execute(ctx, inputFilePath, tempFilePath)
+	if err != nil {
+		return models.CaseResult{}, err
+	}
 
-	// 結果とエラーの処理
-	for {
-		select {
-		case err := <-errChan:
-			if err != nil {
-				return nil, err
-			}
-		case result, ok := <-resultsChan:
-			if !ok {
-				// チャネルが閉じられたとき，全てのケースが処理されたと判断
-				return &results, nil
-			}
-			results.CaseResults = append(results.CaseResults, result)
-		case <-ctx.Done():
-			// コンテキストがキャンセルされた場合，処理を中断
-			return nil, ctx.Err()
+	// 正常終了した場合のみ出力を期待される出力と比較
+	if r.comparator != nil && executionResult.Success && executionResult.ExitStatus == 0 && !executionResult.OutputExceeded {
+		matched, err := compare.CompareFiles(r.comparator, tempFilePath, outputFilePath)
+		if err != nil {
+			executionResult.ErrorMessage = err.Error()
 		}
+		executionResult.OutputDiff = !matched
 	}
+
+	caseResult := judgeExecutionResult(executionResult, r.limits)
+	caseResult.CaseName = filepath.Base(inputFilePath)
+	caseResult.Stderr = executionResult.Stderr
+
+	// 制限内で正常終了した場合のみチェッカーで出力を判定
+	if r.checker != nil && caseResult.Result == models.VerdictAccepted {
+		checkerResult, err := r.checker.Run(ctx, inputFilePath, tempFilePath, outputFilePath)
+		if err != nil {
+			return models.CaseResult{}, err
+		}
+		caseResult.Result = checkerResult.Verdict
+		caseResult.CheckerMessage = checkerResult.Message
+		caseResult.Score = checkerResult.Score
+	}
+
+	r.revealDetails(&caseResult, tempFilePath, outputFilePath)
+	return caseResult, nil
 }
 
-// buildDockerRunCommandWithTimeout - 提出された言語設定に基づいて適切なDockerコマンドを構築
-func buildDockerRunCommandWithTimeout(langConfig config.LanguageConfig, codeFilePath, inputFilePath, tempFilePath, outputFilePath string, problemID int, timeout int) string {
-	// コードファイルのマウント設定
-	codeFileVolume := fmt.Sprintf("-v %s:/workspace/code", filepath.Dir(codeFilePath))
+// revealDetails - 問題の設定に従い，公開しない標準エラー出力を取り除き，公開するテストケースには出力と期待される出力を付加
+// stdoutPath が空文字列の場合は出力と期待される出力を付加しない．
+func (r *caseRunner) revealDetails(caseResult *models.CaseResult, stdoutPath, expectedPath string) {
+	if !r.problem.RevealsStderr(caseResult.CaseName) {
+		caseResult.Stderr = ""
+	}
+	if stdoutPath == "" || !r.problem.RevealsCase(caseResult.CaseName) {
+		return
+	}
+	caseResult.Stdout = readFileSnippet(stdoutPath, maxCaseSnippetSize)
+	caseResult.ExpectedOutput = readFileSnippet(expectedPath, maxCaseSnippetSize)
+}
 
-	// 入出力ファイルのマウント設定
-	ioVolumeMapping := fmt.Sprintf("-v %s:/workspace/io", minio.GetFileSaveName("/tmp", problemID, "", ""))
+// execute - 入力ファイルを標準入力に与えて提出プログラムを実行し，標準出力を一時ファイルに保存
+func (r *caseRunner) execute(ctx context.Context, inputFilePath, tempFilePath string) (ExecutionResult, error) {
+	input, err := os.Open(inputFilePath)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to open input file: %v", err)
+	}
+	defer input.Close()
 
-	// 一時ファイルの保存先を /workspace/tmp に設定
-	tempFileVolume := fmt.Sprintf("-v %s:/workspace/tmp", filepath.Dir(tempFilePath))
+	output, err := os.OpenFile(tempFilePath, os.O_WRONLY|os.O_TRUNC, 0666)
+	if err != nil {
+		return ExecutionResult{}, fmt.Errorf("failed to open output file: %v", err)
+	}
+	defer output.Close()
+
+	stderr := newBoundedBuffer(maxCaseSnippetSize)
+	runResult, err := runInSandbox(ctx, r.sandbox, sandbox.RunOptions{
+		Command:     runCommand(r.langConfig, r.ws),
+		Stdin:       input,
+		Stdout:      output,
+		Stderr:      stderr,
+		TimeLimit:   r.limits.TimeLimit,
+		OutputLimit: int64(outputLimit),
+	})
+	if ctx.Err() != nil {
+		return ExecutionResult{}, ctx.Err()
+	}
+	executionResult := newExecutionResult(runResult, err)
+	executionResult.Stderr = stderr.String()
 
-	// コンパイルコマンドの準備
-	compileCmd := ""
-	if langConfig.Compile != "" {
-		compileCmd = strings.Replace(langConfig.Compile, "{code}", "/workspace/code/"+filepath.Base(codeFilePath), -1) + " && "
+	// 出力サイズはホスト側に保存した一時ファイルから取得
+	if fileInfo, err := output.Stat(); err == nil {
+		executionResult.OutputSize = fileInfo.Size()
 	}
 
-	// 実行コマンドの準備
-	runCmd := strings.Replace(langConfig.Run, "{code}", "/workspace/code/"+filepath.Base(codeFilePath), -1)
+	return executionResult, nil
+}
 
-	// 入力ファイルから一時ファイルへのリダイレクトを含む実行コマンド
-	executionCmd := fmt.Sprintf("%s < /workspace/io/in/%s > /workspace/tmp/%s", runCmd, filepath.Base(inputFilePath), filepath.Base(tempFilePath))
+// sandboxConfig - 言語のイメージ(languageImage で固定した参照)に基づいてサンドボックスの設定を生成
+func sandboxConfig(image string, mounts []sandbox.Mount, memoryLimit int64) sandbox.Config {
+	return sandbox.Config{
+		Image:       image,
+		Mounts:      mounts,
+		ScratchDir:  scratchDir,
+		ScratchSize: int64(scratchSize),
+		MemoryLimit: memoryLimit,
+		CPUs:        cpuLimit,
+	}
+}
 
-	// タイムアウトと実行時間計測を含む実行コマンド(コンパイル時の一時ファイルの作成場所を変更)
-	commandWithTimeout := fmt.Sprintf("%s %s timeout --preserve-status %ds /bin/sh -c 'start=\\$(date +%%s%%N); %s; end=\\$(date +%%s%%N); echo Execution time: \\$((end-start)) nanoseconds'", langConfig.Setup, compileCmd, timeout, executionCmd)
+// solutionMounts - 提出プログラムの実行時のマウント(ソースコードとコンパイル成果物はいずれも読み取り専用)
+func solutionMounts(codeDir, binDir string) []sandbox.Mount {
+	return []sandbox.Mount{
+		{Source: codeDir, Target: "/workspace/code", ReadOnly: true},
+		{Source: binDir, Target: "/workspace/bin", ReadOnly: true},
+	}
+}
 
-	// 結果比較スクリプトの追加（一時ファイルと期待される出力ファイルを比較）
-	compareScript := fmt.Sprintf(" && diff -q /workspace/tmp/%s /workspace/io/out/%s", filepath.Base(tempFilePath), filepath.Base(outputFilePath))
+// shellCommand - 実行環境のセットアップを行った上でコマンドを実行するシェルの引数を構築
+// コマンドはコンテナ内部のシェルで解釈されるため，言語設定のコマンドをそのまま指定できる．
+// args はシェルの位置パラメータ("$@")としてコマンドに渡される．
+func shellCommand(langConfig config.LanguageConfig, command string, args ...string) []string {
+	// 一時ファイルはコンテナ内部のtmpfsに作成し，セットアップの末尾の && は取り除いてステップとして扱う
+	steps := []string{"mkdir -p " + scratchDir + "/tmp"}
+	if setup := strings.TrimSuffix(strings.TrimSpace(langConfig.Setup), "&&"); setup != "" {
+		steps = append(steps, setup)
+	}
+	steps = append(steps, command)
 
-	// Dockerコマンドの組み立て
-	dockerCommand := fmt.Sprintf("docker run --rm %s %s %s %s --memory %s --cpus %s %s /bin/sh -c \"%s%s\"",
-		strings.Join(securityOpts, " "), codeFileVolume, ioVolumeMapping, tempFileVolume, memoryLimit, cpuLimit, langConfig.Image, commandWithTimeout, compareScript)
+	return append([]string{"/bin/sh", "-c", strings.Join(steps, "; "), "sh"}, args...)
+}
 
-	return dockerCommand
+// runCommand - 提出プログラムを実行するコマンドを構築
+func runCommand(langConfig config.LanguageConfig, ws *Workspace) []string {
+	runCmd := strings.Replace(langConfig.Run, "{code}", "/workspace/code/"+filepath.Base(ws.CodeFilePath), -1)
+	return shellCommand(langConfig, "exec "+runCmd)
 }
 
-// getTestCases - 指定された問題IDに基づき入出力ファイルのパスのペアを返す
-func getTestCases(problemID int) (map[string]string, error) {
-	testCases := make(map[string]string)
-	inDir := minio.GetFileSaveName("/tmp", problemID, "in", "")
-	outDir := minio.GetFileSaveName("/tmp", problemID, "out", "")
+// getTestCases - 用意されたテストデータの入出力ファイルのパスの組を問題の設定で定められた実行順に返す
+func getTestCases(testSetDir string, problem models.Problem) ([]testCase, error) {
+	inDir := filepath.Join(testSetDir, models.TestSetFileInput)
+	outDir := filepath.Join(testSetDir, models.TestSetFileOutput)
 
 	// 入力ファイルのリストを取得
 	inputFiles, err := ioutil.ReadDir(inDir)
@@ -210,59 +540,214 @@ func getTestCases(problemID int) (map[string]string, error) {
 	}
 
 	// 入力ファイルと出力ファイルをマッピング
+	caseNames := make([]string, 0, len(inputFiles))
 	for _, inFile := range inputFiles {
 		if !inFile.IsDir() { // ディレクトリではないことを確認
-			inFilePath := filepath.Join(inDir, inFile.Name())
-			outFilePath := filepath.Join(outDir, inFile.Name())
 			// 出力ファイルが存在するか確認
-			if _, err := ioutil.ReadFile(outFilePath); err == nil {
-				testCases[inFilePath] = outFilePath
-			} else {
+			if _, err := os.Stat(filepath.Join(outDir, inFile.Name())); err != nil {
 				return nil, fmt.Errorf("output file does not exist for input file %s", inFile.Name())
 			}
+			caseNames = append(caseNames, inFile.Name())
 		}
 	}
 
+	testCases := make([]testCase, 0, len(caseNames))
+	for _, caseName := range problem.OrderCases(caseNames) {
+		testCases = append(testCases, testCase{
+			inputPath:  filepath.Join(inDir, caseName),
+			outputPath: filepath.Join(outDir, caseName),
+		})
+	}
 	return testCases, nil
 }
 
-// executeDockerCommand - Dockerコマンドを実行 && 結果を解析
-func executeDockerCommand(ctx context.Context, dockerCmd string) (ExecutionResult, error) {
-	// コマンドを実行
-	cmd := exec.CommandContext(ctx, "sh", "-c", dockerCmd)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &out
-	err := cmd.Run()
+// compileInContainer - 提出されたコードをDockerコンテナ内でコンパイル
+// コンパイル成果物は作業ディレクトリの bin に出力され，以降の実行フェーズで読み取り専用としてマウントされる．
+// コンパイルが不要な言語の場合は何もせずに成功を返す．
+func compileInContainer(ctx context.Context, langConfig config.LanguageConfig, ws *Workspace) (CompileResult, error) {
+	if langConfig.Compile == "" {
+		return CompileResult{Success: true}, nil
+	}
+
+	image, err := languageImage(ctx, langConfig)
+	if err != nil {
+		return CompileResult{}, err
+	}
+	// ソースコードは読み取り専用，成果物の出力先は書き込み可能としてマウント
+	sb, err := sandboxRuntime.Create(ctx, sandboxConfig(image, []sandbox.Mount{
+		{Source: ws.CodeDir(), Target: "/workspace/code", ReadOnly: true},
+		{Source: ws.BinDir, Target: "/workspace/bin"},
+	}, compileMemoryLimit))
+	if err != nil {
+		return CompileResult{}, err
+	}
+	defer sb.Destroy(context.Background())
+
+	// コンパイラの出力は標準出力と標準エラー出力をまとめてサイズを制限して取得
+	compileCmd := strings.Replace(langConfig.Compile, "{code}", "/workspace/code/"+filepath.Base(ws.CodeFilePath), -1)
+	output := newBoundedBuffer(maxCompileOutputSize)
+	result, err := runInSandbox(ctx, sb, sandbox.RunOptions{
+		Command:   shellCommand(langConfig, compileCmd+" 2>&1"),
+		Stdout:    output,
+		TimeLimit: compileTimeLimit,
+	})
+	if err != nil {
+		return CompileResult{}, fmt.Errorf("failed to run compile container: %v", err)
+	}
+
+	compileOutput := output.String()
+	if result.TimedOut {
+		compileOutput += "\ncompilation timed out"
+	}
+
+	return CompileResult{Success: result.ExitCode == 0, Output: compileOutput}, nil
+}
+
+// acquireContainerSlots - 同時に起動するコンテナの枠を指定された数だけ確保し，解放する関数を返す
+// 複数の枠を確保する場合は，確保途中の枠を互いに奪い合って停止しないよう1つずつ順番に確保する．
+func acquireContainerSlots(ctx context.Context, n int) (func(), error) {
+	if n > cap(containerSlots) {
+		n = cap(containerSlots)
+	}
+	if n > 1 {
+		multiSlotMu.Lock()
+		defer multiSlotMu.Unlock()
+	}
+
+	acquired := 0
+	release := func() {
+		for i := 0; i < acquired; i++ {
+			<-containerSlots
+		}
+	}
+	for acquired < n {
+		select {
+		case containerSlots <- struct{}{}:
+			acquired++
+		case <-ctx.Done():
+			release()
+			return nil, ctx.Err()
+		}
+	}
+	return release, nil
+}
 
-	result := ExecutionResult{}
+// runInSandbox - コンテナの起動枠を確保した上でサンドボックス内でコマンドを実行
+func runInSandbox(ctx context.Context, sb sandbox.Sandbox, options sandbox.RunOptions) (sandbox.RunResult, error) {
+	release, err := acquireContainerSlots(ctx, 1)
+	if err != nil {
+		return sandbox.RunResult{}, err
+	}
+	defer release()
 
-	// コマンドの出力を解析
-	output := out.String()
+	return sb.Run(ctx, options)
+}
 
-	// 実行時間を抽出
-	timeRegex := regexp.MustCompile(`Execution time: (\d+) nanoseconds`)
-	matches := timeRegex.FindStringSubmatch(output)
-	if len(matches) > 1 {
-		// matches[1]に実行時間が含まれる
-		result.ExecutionTime, _ = strconv.ParseInt(matches[1], 10, 64)
+// newExecutionResult - サンドボックスの実行結果を判定に用いる実行結果に変換
+// err はコンテナの実行自体に失敗した場合のエラーである．
+func newExecutionResult(runResult sandbox.RunResult, err error) ExecutionResult {
+	if err != nil {
+		return ExecutionResult{Success: false, ErrorMessage: err.Error()}
 	}
 
-	// diffコマンドの結果を確認（出力が異なる場合はdiffコマンドから何らかの出力がある）
-	diffRegex := regexp.MustCompile(`Files /workspace/tmp/.+ and /workspace/io/out/.+ differ`)
-	if diffRegex.MatchString(output) {
-		result.OutputDiff = true
-	} else {
-		result.OutputDiff = false
+	return ExecutionResult{
+		Success:        true,
+		ExecutionTime:  int64(runResult.WallTime),
+		CPUTime:        int64(runResult.CPUTime),
+		PeakMemory:     runResult.PeakMemory,
+		ExitStatus:     runResult.ExitCode,
+		Signal:         runResult.Signal,
+		TimedOut:       runResult.TimedOut,
+		MemoryExceeded: runResult.OOMKilled,
+		OutputExceeded: runResult.OutputLimitExceeded,
+	}
+}
+
+// boundedBuffer - 指定されたサイズまで書き込みを保持し，超過分は破棄するバッファ
+// 超過した場合は String で切り詰めたことを示す文字列を付加する．
+// 保持した内容はデータベースに保存されるため，String では不正なUTF-8のバイト列を置換文字に置き換える．
+type boundedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func newBoundedBuffer(limit int) *boundedBuffer {
+	return &boundedBuffer{limit: limit}
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
 	}
+	return b.buf.Write(p)
+}
 
-	// エラーメッセージの設定
+func (b *boundedBuffer) String() string {
+	s := strings.ToValidUTF8(b.buf.String(), "\uFFFD")
+	if b.truncated {
+		return s + "\n... (truncated)"
+	}
+	return s
+}
+
+// readFileSnippet - ファイルの先頭から指定されたサイズまでを読み取る(読み取れない場合は空文字列)
+func readFileSnippet(path string, limit int) string {
+	file, err := os.Open(path)
 	if err != nil {
-		result.Success = false
-		result.ErrorMessage = err.Error()
-	} else {
-		result.Success = true
+		return ""
 	}
+	defer file.Close()
 
-	return result, nil
+	buf := newBoundedBuffer(limit)
+	io.CopyN(buf, file, int64(limit)+1)
+	return buf.String()
+}
+
+// judgeExecutionResult - 実行結果から判定結果を決定
+// 判定の優先順位は IE > TLE > OLE > MLE > RE > WA > AC である(CE はコンパイルフェーズで判定される)．
+// MLE はサンドボックスのランタイムがメモリ制限の超過(cgroupのOOM Killerによる強制終了など)を検出した場合のみ判定し，
+// それ以外の理由でSIGKILLを受けて終了した場合は RE (SIGKILL) とする．
+func judgeExecutionResult(executionResult ExecutionResult, limits ResourceLimits) models.CaseResult {
+	caseResult := models.CaseResult{
+		ExecutionTime: time.Duration(executionResult.ExecutionTime),
+		CPUTime:       time.Duration(executionResult.CPUTime),
+		PeakMemory:    executionResult.PeakMemory,
+		ExitCode:      executionResult.ExitStatus,
+	}
+
+	if executionResult.Signal != 0 {
+		caseResult.Signal = signalName(executionResult.Signal)
+	}
+
+	switch {
+	case executionResult.ErrorMessage != "":
+		caseResult.Result = models.VerdictInternalError
+	case executionResult.TimedOut: // 制限時間の超過により timeout またはホストから強制終了された場合
+		caseResult.Result = models.VerdictTimeLimitExceeded
+	case executionResult.OutputExceeded || executionResult.Signal == 25 || executionResult.OutputSize > int64(outputLimit): // SIGXFSZ
+		caseResult.Result = models.VerdictOutputLimitExceeded
+	case executionResult.MemoryExceeded:
+		caseResult.Result = models.VerdictMemoryLimitExceeded
+	case executionResult.ExitStatus != 0:
+		caseResult.Result = models.VerdictRuntimeError
+	case executionResult.OutputDiff:
+		caseResult.Result = models.VerdictWrongAnswer
+	case time.Duration(executionResult.ExecutionTime) > limits.TimeLimit:
+		caseResult.Result = models.VerdictTimeLimitExceeded
+	default:
+		caseResult.Result = models.VerdictAccepted
+	}
+
+	return caseResult
+}
+
+// signalName - シグナル番号に対応するシグナル名を取得
+func signalName(signal int) string {
+	if name, ok := signalNames[signal]; ok {
+		return name
+	}
+	return "SIG" + strconv.Itoa(signal)
 }
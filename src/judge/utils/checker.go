@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/sandbox"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	checkerTimeLimit      = 10 * time.Second // 1つのテストケースに対するチェッカーの制限時間
+	maxCheckerMessageSize = 4 << 10          // 保存するチェッカーのメッセージの最大サイズ(バイト)
+
+	// testlib.h 互換のチェッカーの終了コード
+	checkerExitOK     = 0 // 正解
+	checkerExitWA     = 1 // 不正解
+	checkerExitPE     = 2 // 出力形式の誤り(WAとして扱う)
+	checkerExitFail   = 3 // チェッカー自身の失敗(IEとして扱う)
+	checkerExitPoints = 7 // 部分点
+)
+
+// Checker - 問題ごとに設定されたコンパイル済みのチェッカー(スペシャルジャッジ)
+type Checker struct {
+	*problemProgram
+}
+
+// CheckerResult - 1つのテストケースに対するチェッカーの判定結果
+type CheckerResult struct {
+	Verdict string  // 判定結果(AC, WA, IE のいずれか)
+	Message string  // チェッカーが出力したメッセージ
+	Score   float64 // チェッカーが報告した部分点(部分点でない場合は0)
+}
+
+// PrepareChecker - 用意されたテストデータに含まれるチェッカーを必要に応じてコンパイル
+// コンパイル済みのチェッカーはテストデータの版ごとにキャッシュされ，再利用される．
+func PrepareChecker(ctx context.Context, testSetDir string, checkerLanguageID int) (*Checker, error) {
+	program, err := prepareProblemProgram(ctx, testSetDir, checkerLanguageID, models.TestSetFileChecker)
+	if err != nil {
+		return nil, err
+	}
+	return &Checker{program}, nil
+}
+
+// Run - 提出プログラムの出力をチェッカーで判定
+// チェッカーは testlib.h と同じく「入力ファイル 提出プログラムの出力 期待される出力」の順で引数を受け取る．
+// 提出プログラムの出力はテストケースごとにチェッカーのサンドボックスへ複製して渡す．
+func (c *Checker) Run(ctx context.Context, inputFilePath, tempFilePath, outputFilePath string) (CheckerResult, error) {
+	outputName := filepath.Join("output", filepath.Base(filepath.Dir(tempFilePath)))
+	if err := c.sandbox.CopyIn(ctx, tempFilePath, outputName); err != nil {
+		return CheckerResult{}, err
+	}
+
+	// チェッカーの出力(標準出力と標準エラー出力)はサイズを制限して取得
+	message := newBoundedBuffer(maxCheckerMessageSize)
+	result, err := runInSandbox(ctx, c.sandbox, sandbox.RunOptions{
+		Command: c.command(
+			"/workspace/io/in/"+filepath.Base(inputFilePath),
+			filepath.Join(sandbox.FilesDir, outputName),
+			"/workspace/io/out/"+filepath.Base(outputFilePath),
+		),
+		Stdout:    message,
+		Stderr:    message,
+		TimeLimit: checkerTimeLimit,
+	})
+	if ctx.Err() != nil {
+		return CheckerResult{}, ctx.Err()
+	}
+	if err != nil {
+		return CheckerResult{}, fmt.Errorf("failed to run checker container: %v", err)
+	}
+	if result.TimedOut {
+		return CheckerResult{Verdict: models.VerdictInternalError, Message: "checker exceeded the time limit"}, nil
+	}
+
+	return judgeCheckerResult(result.ExitCode, strings.TrimSpace(message.String())), nil
+}
+
+// judgeCheckerResult - チェッカー(またはインタラクタ)の終了コードとメッセージから判定結果を決定
+// 部分点は testlib.h の quitp と同じく，メッセージの先頭に "points" に続けて出力された値を得点とする．
+func judgeCheckerResult(status int, message string) CheckerResult {
+	result := CheckerResult{Message: message}
+
+	switch status {
+	case checkerExitOK:
+		result.Verdict = models.VerdictAccepted
+	case checkerExitWA, checkerExitPE:
+		result.Verdict = models.VerdictWrongAnswer
+	case checkerExitPoints:
+		result.Verdict = models.VerdictWrongAnswer
+		result.Score = parseCheckerScore(message)
+	case checkerExitFail:
+		result.Verdict = models.VerdictInternalError
+	default:
+		// 制限時間の超過などチェッカーが異常終了した場合
+		result.Verdict = models.VerdictInternalError
+		if result.Message == "" {
+			result.Message = fmt.Sprintf("checker exited with status %d", status)
+		}
+	}
+
+	return result
+}
+
+// parseCheckerScore - チェッカーのメッセージから部分点を取得
+func parseCheckerScore(message string) float64 {
+	fields := strings.Fields(message)
+	if len(fields) > 0 && fields[0] == "points" {
+		fields = fields[1:]
+	}
+	if len(fields) == 0 {
+		return 0
+	}
+	score, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0
+	}
+	return score
+}
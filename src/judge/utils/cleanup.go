@@ -5,21 +5,51 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"procon_web_service/src/judge/sandbox"
+	"strings"
 	"time"
 )
 
-// StartCleanupScheduler - 定期的に/tmpディレクトリをクリーンアップするスケジューラを開始
-func StartCleanupScheduler(interval time.Duration, maxAge time.Duration) {
+// staleWorkspacePrefixes - 判定ごとに作成され，判定の終了時に削除される作業ディレクトリの名前の接頭辞
+// 判定中にジャッジサーバーが停止した場合に残ったものを，ディレクトリごと削除する．
+// サンドボックスのランタイムが作成するディレクトリも含む．
+var staleWorkspacePrefixes = []string{"submission_", "judge_", "sandbox_", "sandbox_stats_", "sandbox_scratch_", "sandbox_pool_"}
+
+// legacyTestDataPrefix - テストデータのキャッシュを導入する前に/tmp直下に保存されていたテストデータ(problem_<問題ID>)の名前の接頭辞
+const legacyTestDataPrefix = "problem_"
+
+// StartCleanupScheduler - 以前の形式のテストデータを削除し，定期的に/tmpディレクトリをクリーンアップするスケジューラを開始
+// テストデータのキャッシュのディレクトリ(cacheDir)はキャッシュがサイズの上限に基づいて削除するため，対象としない．
+func StartCleanupScheduler(interval time.Duration, maxAge time.Duration, cacheDir string) {
+	removeLegacyTestData("/tmp")
 	ticker := time.NewTicker(interval)
 	go func() {
 		for range ticker.C {
-			cleanupTmpFiles("/tmp", maxAge)
+			cleanupTmpFiles("/tmp", maxAge, cacheDir)
 		}
 	}()
 }
 
-// cleanupTmpFiles - 指定されたディレクトリ内の古いファイルを削除
-func cleanupTmpFiles(directory string, maxAge time.Duration) {
+// removeLegacyTestData - 指定されたディレクトリ直下に残った以前の形式のテストデータを削除
+// テストデータはキャッシュのディレクトリに用意されるため，以前の形式のテストデータが判定に用いられることはない．
+func removeLegacyTestData(directory string) {
+	dirs, err := filepath.Glob(filepath.Join(directory, legacyTestDataPrefix+"*"))
+	if err != nil {
+		log.Printf("Failed to list legacy test data in %s: %v", directory, err)
+		return
+	}
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("Failed to delete legacy test data %s: %v", dir, err)
+			continue
+		}
+		log.Printf("Deleted legacy test data: %s", filepath.Base(dir))
+	}
+}
+
+// cleanupTmpFiles - 指定されたディレクトリ内の古いファイルと，判定の作業ディレクトリの残骸を削除
+// テストデータのキャッシュのディレクトリ(cacheDir)と，起動中のジャッジサーバーが使用している待機中のサンドボックスのディレクトリは削除しない．
+func cleanupTmpFiles(directory string, maxAge time.Duration, cacheDir string) {
 	files, err := ioutil.ReadDir(directory)
 	if err != nil {
 		log.Printf("Failed to list directory %s: %v", directory, err)
@@ -27,10 +57,34 @@ func cleanupTmpFiles(directory string, maxAge time.Duration) {
 	}
 
 	now := time.Now()
+	cacheDir = filepath.Clean(cacheDir)
 	for _, file := range files {
-		if now.Sub(file.ModTime()) > maxAge {
-			os.Remove(filepath.Join(directory, file.Name()))
+		path := filepath.Join(directory, file.Name())
+		if now.Sub(file.ModTime()) <= maxAge || path == cacheDir {
+			continue
+		}
+		if file.IsDir() && isStaleWorkspace(file.Name()) {
+			// 待機中のサンドボックスは判定をまたいで保持されるため，古くても使用中の場合は削除しない
+			if sandbox.WorkspaceInUse(path) {
+				continue
+			}
+			if err := os.RemoveAll(path); err == nil {
+				log.Printf("Deleted stale workspace: %s", file.Name())
+			}
+			continue
+		}
+		if err := os.Remove(path); err == nil {
 			log.Printf("Deleted old file: %s", file.Name())
 		}
 	}
 }
+
+// isStaleWorkspace - 判定ごとに作成される作業ディレクトリの名前であればtrue
+func isStaleWorkspace(name string) bool {
+	for _, prefix := range staleWorkspacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/sandbox"
+	"strings"
+)
+
+const maxCustomRunOutputSize = 64 << 10 // カスタム実行で返す標準出力と標準エラー出力の最大サイズ(バイト)
+
+// RunCustomInContainer - 提出を作成せずに，指定された入力を標準入力に与えてコードを実行し，出力と実行結果を取得
+// 提出の判定と同じサンドボックスと実行制限(問題が指定されていない場合は既定の実行制限)を用いるが，出力の判定は行わない．
+func RunCustomInContainer(ctx context.Context, run models.CustomRun, problem models.Problem) (*models.CustomRunResult, error) {
+	if _, ok := config.GetLanguageConfigByID(run.LanguageID); !ok {
+		return nil, fmt.Errorf("unsupported language ID: %d", run.LanguageID)
+	}
+
+	limits := NewResourceLimits(problem, run.LanguageID)
+
+	ws, langConfig, cleanup, err := CreateWorkspace(run.LanguageID, run.Code)
+	if cleanup != nil {
+		defer cleanup()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	compileResult, err := compileInContainer(ctx, langConfig, ws)
+	if err != nil {
+		return nil, err
+	}
+	result := &models.CustomRunResult{CompileOutput: compileResult.Output}
+	if !compileResult.Success {
+		result.Result = models.VerdictCompileError
+		return result, nil
+	}
+
+	image, err := languageImage(ctx, langConfig)
+	if err != nil {
+		return nil, err
+	}
+	sb, err := sandboxRuntime.Create(ctx, sandboxConfig(image, solutionMounts(ws.CodeDir(), ws.BinDir), int64(limits.MemoryLimit)<<20))
+	if err != nil {
+		return nil, err
+	}
+	defer sb.Destroy(context.Background())
+
+	stdout := newBoundedBuffer(maxCustomRunOutputSize)
+	stderr := newBoundedBuffer(maxCustomRunOutputSize)
+	runResult, err := runInSandbox(ctx, sb, sandbox.RunOptions{
+		Command:     runCommand(langConfig, ws),
+		Stdin:       strings.NewReader(run.Stdin),
+		Stdout:      stdout,
+		Stderr:      stderr,
+		TimeLimit:   limits.TimeLimit,
+		OutputLimit: int64(outputLimit),
+	})
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	// 出力の判定は行わないため，制限内で正常終了した場合は OK とする
+	caseResult := judgeExecutionResult(newExecutionResult(runResult, err), limits)
+	result.Result = caseResult.Result
+	if result.Result == models.VerdictAccepted {
+		result.Result = models.CustomRunResultOK
+	}
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.ExitCode = caseResult.ExitCode
+	result.Signal = caseResult.Signal
+	result.ExecutionTime = caseResult.ExecutionTime
+	result.CPUTime = caseResult.CPUTime
+	result.PeakMemory = caseResult.PeakMemory
+
+	return result, nil
+}
@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/sandbox"
+	"strings"
+	"sync"
+)
+
+// pinnedImage - 言語設定のイメージをダイジェストで固定した参照
+type pinnedImage struct {
+	mu  sync.Mutex
+	ref string // 固定した参照(未取得の場合は空文字列)
+}
+
+// pinnedImages - 言語設定のイメージ名ごとの固定した参照
+// 判定中にイメージの内容が変わらないよう，1度固定した参照はジャッジサーバーの終了まで使い続ける．
+// 言語設定の再読み込みで追加されたイメージは，初めて使用される時に取得して固定する．
+var pinnedImages sync.Map
+
+// PrepareLanguageImages - 有効な全ての言語のイメージを取得してダイジェストで固定し，提出プログラムを実行するサンドボックスを待機させる
+// 起動時に呼び出すことで，最初の提出の判定でイメージの取得を待たず，ダイジェストの不一致を起動時に検出する．
+func PrepareLanguageImages(ctx context.Context) error {
+	for _, langConfig := range config.GetEnabledLanguages() {
+		ref, err := languageImage(ctx, langConfig)
+		if err != nil {
+			return fmt.Errorf("failed to prepare image for %s: %v", langConfig.Name, err)
+		}
+		log.Printf("Language %s (ID %d) uses image %s", langConfig.Name, langConfig.LanguageID, ref)
+	}
+	return nil
+}
+
+// languageImage - 言語のイメージをダイジェストで固定した参照を取得
+// 初めて使用するイメージの場合は取得して固定し，提出プログラムを実行するサンドボックスをバックグラウンドで待機させる．
+func languageImage(ctx context.Context, langConfig config.LanguageConfig) (string, error) {
+	value, _ := pinnedImages.LoadOrStore(langConfig.Image, &pinnedImage{})
+	pinned := value.(*pinnedImage)
+	pinned.mu.Lock()
+	defer pinned.mu.Unlock()
+	if pinned.ref != "" {
+		return pinned.ref, nil
+	}
+
+	// ローカルプロセスのランタイムはイメージを用いないため確認しない
+	if judgeConfig.Sandbox == sandbox.RuntimeDocker && !strings.Contains(langConfig.Image, "@sha256:") {
+		// ダイジェストで固定されていないイメージは拒否する(拒否しない設定の場合は警告を出力して使用する)
+		if judgeConfig.RequirePinnedImages {
+			return "", fmt.Errorf("image %s is not pinned by digest (pin it with docker/pin-language-images.sh or set JUDGE_REQUIRE_PINNED_IMAGES=false)", langConfig.Image)
+		}
+		// 起動した時期によってジャッジノードごとに異なるイメージで判定する可能性があるため，警告を出力
+		log.Printf("WARNING: image %s for %s is not pinned by digest; judge nodes started at different times may judge with different images. Pin it with docker/pin-language-images.sh", langConfig.Image, langConfig.Name)
+	}
+	ref, err := sandboxRuntime.PrepareImage(ctx, langConfig.Image)
+	if err != nil {
+		return "", err
+	}
+	pinned.ref = ref
+
+	// 提出プログラムの実行と同じ構成で待機させる(メモリ制限は実行時に問題ごとの値に変更される)
+	warmConfig := sandboxConfig(ref, solutionMounts("", ""), int64(models.DefaultMemoryLimit)<<20)
+	go func() {
+		if err := sandboxRuntime.Warm(context.Background(), warmConfig, judgeConfig.WarmPoolSize); err != nil {
+			// 待機中のサンドボックスがなくても実行ごとに起動できるため，ログを出力して続行
+			log.Printf("Failed to warm sandboxes for image %s: %v", ref, err)
+		}
+	}()
+
+	return ref, nil
+}
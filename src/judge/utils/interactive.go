@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/sandbox"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	interactorExtraTime = 10 * time.Second // インタラクタの制限時間として提出プログラムの制限時間に加える猶予
+)
+
+// Interactor - インタラクティブ問題に設定されたコンパイル済みのインタラクタ
+type Interactor struct {
+	*problemProgram
+}
+
+// PrepareInteractor - 用意されたテストデータに含まれるインタラクタを必要に応じてコンパイル
+func PrepareInteractor(ctx context.Context, testSetDir string, interactorLanguageID int) (*Interactor, error) {
+	program, err := prepareProblemProgram(ctx, testSetDir, interactorLanguageID, models.TestSetFileInteractor)
+	if err != nil {
+		return nil, err
+	}
+	return &Interactor{program}, nil
+}
+
+// RunCase - 提出プログラムとインタラクタをそれぞれのサンドボックスで起動し，標準入出力を相互に接続して1つのテストケースを判定
+// インタラクタは testlib.h と同じく「入力ファイル 出力ファイル 期待される出力」の順で引数を受け取り，終了コードで判定結果を返す．
+// 提出プログラムの実行時間制限とメモリ制限は通常の問題と同様に適用され，インタラクタにも制限時間が適用される．
+func (it *Interactor) RunCase(ctx context.Context, contestant sandbox.Sandbox, contestantCommand []string, inputFilePath, outputFilePath string, limits ResourceLimits) (models.CaseResult, error) {
+	// 提出プログラムとインタラクタの2つのコンテナの枠を確保
+	release, err := acquireContainerSlots(ctx, 2)
+	if err != nil {
+		return models.CaseResult{}, err
+	}
+	defer release()
+
+	// インタラクタ -> 提出プログラム，提出プログラム -> インタラクタ の2本のパイプを作成
+	toContestantReader, toContestantWriter, err := os.Pipe()
+	if err != nil {
+		return models.CaseResult{}, fmt.Errorf("failed to create pipe: %v", err)
+	}
+	defer toContestantReader.Close()
+	toInteractorReader, toInteractorWriter, err := os.Pipe()
+	if err != nil {
+		toContestantWriter.Close()
+		return models.CaseResult{}, fmt.Errorf("failed to create pipe: %v", err)
+	}
+	defer toInteractorReader.Close()
+
+	var (
+		wg                                 sync.WaitGroup
+		contestantResult, interactorResult sandbox.RunResult
+		contestantErr, interactorErr       error
+		message                            = newBoundedBuffer(maxCheckerMessageSize)
+		stderr                             = newBoundedBuffer(maxCaseSnippetSize)
+	)
+	wg.Add(2)
+
+	// 一方が終了した際に他方がEOFを受け取れるよう，終了したプログラムの出力側のパイプを閉じる
+	go func() {
+		defer wg.Done()
+		defer toInteractorWriter.Close()
+		contestantResult, contestantErr = contestant.Run(ctx, sandbox.RunOptions{
+			Command:   contestantCommand,
+			Stdin:     toContestantReader,
+			Stdout:    toInteractorWriter,
+			Stderr:    stderr,
+			TimeLimit: limits.TimeLimit,
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		defer toContestantWriter.Close()
+		// インタラクタの標準入出力は対話に用いるため，メッセージは標準エラー出力から取得
+		interactorResult, interactorErr = it.sandbox.Run(ctx, sandbox.RunOptions{
+			Command: it.command(
+				"/workspace/io/in/"+filepath.Base(inputFilePath),
+				scratchDir+"/tmp/interactor.out",
+				"/workspace/io/out/"+filepath.Base(outputFilePath),
+			),
+			Stdin:     toInteractorReader,
+			Stdout:    toContestantWriter,
+			Stderr:    message,
+			TimeLimit: limits.TimeLimit + interactorExtraTime,
+		})
+	}()
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return models.CaseResult{}, ctx.Err()
+	}
+	if interactorErr != nil {
+		return models.CaseResult{}, fmt.Errorf("failed to run interactor container: %v", interactorErr)
+	}
+
+	caseResult := judgeExecutionResult(newExecutionResult(contestantResult, contestantErr), limits)
+	caseResult.CaseName = filepath.Base(inputFilePath)
+	caseResult.Stderr = stderr.String()
+
+	verdict := CheckerResult{Verdict: models.VerdictInternalError, Message: "interactor exceeded the time limit"}
+	if !interactorResult.TimedOut {
+		verdict = judgeCheckerResult(interactorResult.ExitCode, strings.TrimSpace(message.String()))
+	}
+	caseResult.CheckerMessage = verdict.Message
+
+	switch {
+	case caseResult.Result == models.VerdictAccepted:
+		// 提出プログラムが制限内で正常終了した場合はインタラクタの判定に従う
+		caseResult.Result = verdict.Verdict
+		caseResult.Score = verdict.Score
+	case caseResult.Result == models.VerdictRuntimeError && verdict.Verdict == models.VerdictWrongAnswer:
+		// インタラクタが先に不正解と判定して終了したことで，提出プログラムが異常終了した場合は不正解とする
+		caseResult.Result = models.VerdictWrongAnswer
+	}
+
+	return caseResult, nil
+}
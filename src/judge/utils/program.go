@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/judge/sandbox"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	programStampFile   = ".compiled" // 補助プログラムのコンパイル完了を示すファイル名(内容はコンパイル時の言語ID)
+	programMemoryLimit = 1024 << 20  // 補助プログラム実行時のメモリ制限(バイト)
+)
+
+// programLocks - テストデータの保存先・ファイルタイプごとの補助プログラムのコンパイルを排他制御するロック
+// 同じ問題に対する複数の提出が同時に補助プログラムをコンパイルしないようにする．
+var programLocks sync.Map
+
+// problemProgram - 問題ごとに設定されたコンパイル済みの補助プログラム(チェッカー，インタラクタ)
+type problemProgram struct {
+	langConfig config.LanguageConfig // 補助プログラムの言語設定
+	ws         *Workspace            // 補助プログラムのソースコードとコンパイル成果物の保存先
+	sandbox    sandbox.Sandbox       // 補助プログラムを実行するサンドボックス
+}
+
+// prepareProblemProgram - 用意されたテストデータに含まれる補助プログラムを必要に応じてコンパイルした上で実行用のサンドボックスを生成
+// コンパイル済みの補助プログラムはテストデータの版ごとにキャッシュされ，再利用される．
+func prepareProblemProgram(ctx context.Context, testSetDir string, languageID int, fileType string) (*problemProgram, error) {
+	program, err := compileProblemProgram(ctx, testSetDir, languageID, fileType)
+	if err != nil {
+		return nil, err
+	}
+
+	image, err := languageImage(ctx, program.langConfig)
+	if err != nil {
+		return nil, err
+	}
+	// ソースコード，コンパイル成果物，入出力ファイルはいずれも読み取り専用でマウント
+	program.sandbox, err = sandboxRuntime.Create(ctx, sandboxConfig(image, []sandbox.Mount{
+		{Source: program.ws.CodeDir(), Target: "/workspace/code", ReadOnly: true},
+		{Source: program.ws.BinDir, Target: "/workspace/bin", ReadOnly: true},
+		{Source: testSetDir, Target: "/workspace/io", ReadOnly: true},
+	}, programMemoryLimit))
+	if err != nil {
+		return nil, err
+	}
+
+	return program, nil
+}
+
+// compileProblemProgram - テストデータに含まれる補助プログラムを，コンパイル済みでなければコンパイル
+func compileProblemProgram(ctx context.Context, testSetDir string, languageID int, fileType string) (*problemProgram, error) {
+	langConfig, ok := config.GetLanguageConfigByID(languageID)
+	if !ok {
+		return nil, fmt.Errorf("unsupported %s language ID: %d", fileType, languageID)
+	}
+
+	sourcePath, err := findProgramSource(filepath.Join(testSetDir, fileType))
+	if err != nil {
+		return nil, err
+	}
+
+	program := &problemProgram{
+		langConfig: langConfig,
+		ws: &Workspace{
+			Dir:          testSetDir,
+			CodeFilePath: sourcePath,
+			BinDir:       filepath.Join(testSetDir, fileType+"_bin"),
+		},
+	}
+
+	lock, _ := programLocks.LoadOrStore(filepath.Join(testSetDir, fileType), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if programIsCompiled(program.ws, languageID) {
+		return program, nil
+	}
+
+	// 古いコンパイル成果物を削除し，コンテナ内部のユーザーから書き込めるよう権限を設定
+	if err := os.RemoveAll(program.ws.BinDir); err != nil {
+		return nil, fmt.Errorf("failed to clean %s directory: %v", fileType, err)
+	}
+	if err := os.Mkdir(program.ws.BinDir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to create %s directory: %v", fileType, err)
+	}
+	if err := os.Chmod(program.ws.BinDir, 0777); err != nil {
+		return nil, fmt.Errorf("failed to change %s directory permission: %v", fileType, err)
+	}
+
+	compileResult, err := compileInContainer(ctx, langConfig, program.ws)
+	if err != nil {
+		return nil, err
+	}
+	if !compileResult.Success {
+		return nil, fmt.Errorf("failed to compile %s: %s", fileType, compileResult.Output)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(program.ws.BinDir, programStampFile), []byte(strconv.Itoa(languageID)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to mark %s as compiled: %v", fileType, err)
+	}
+
+	return program, nil
+}
+
+// command - 補助プログラムに引数を渡して実行するコマンドを構築
+func (p *problemProgram) command(args ...string) []string {
+	runCmd := strings.Replace(p.langConfig.Run, "{code}", "/workspace/code/"+filepath.Base(p.ws.CodeFilePath), -1)
+	return shellCommand(p.langConfig, "exec "+runCmd+` "$@"`, args...)
+}
+
+// close - 補助プログラムのサンドボックスを破棄
+func (p *problemProgram) close() {
+	p.sandbox.Destroy(context.Background())
+}
+
+// findProgramSource - 補助プログラムのディレクトリからソースファイル(ヘッダファイル以外)を検索
+// 問題の更新により複数のソースファイルが存在する場合は最も新しいものを用いる．
+func findProgramSource(programDir string) (string, error) {
+	files, err := ioutil.ReadDir(programDir)
+	if err != nil {
+		return "", fmt.Errorf("failed to read program directory: %v", err)
+	}
+
+	var source os.FileInfo
+	for _, file := range files {
+		if file.IsDir() || isHeaderFile(file.Name()) {
+			continue
+		}
+		if source == nil || file.ModTime().After(source.ModTime()) {
+			source = file
+		}
+	}
+	if source == nil {
+		return "", fmt.Errorf("program source file does not exist in %s", programDir)
+	}
+
+	return filepath.Join(programDir, source.Name()), nil
+}
+
+// programIsCompiled - 同じ言語でコンパイルされた補助プログラムがソースコードより新しい場合はtrue
+func programIsCompiled(ws *Workspace, languageID int) bool {
+	stampPath := filepath.Join(ws.BinDir, programStampFile)
+	stamp, err := os.Stat(stampPath)
+	if err != nil {
+		return false
+	}
+	if content, err := ioutil.ReadFile(stampPath); err != nil || string(content) != strconv.Itoa(languageID) {
+		return false
+	}
+
+	files, err := ioutil.ReadDir(ws.CodeDir())
+	if err != nil {
+		return false
+	}
+	for _, file := range files {
+		if file.ModTime().After(stamp.ModTime()) {
+			return false
+		}
+	}
+	return true
+}
+
+// isHeaderFile - 補助プログラムと共にアップロードされるヘッダファイル(testlib.h など)であればtrue
+func isHeaderFile(fileName string) bool {
+	switch filepath.Ext(fileName) {
+	case ".h", ".hpp":
+		return true
+	default:
+		return false
+	}
+}
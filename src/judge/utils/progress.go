@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"procon_web_service/src/common/judgeproto"
+	"procon_web_service/src/common/models"
+	"sync"
+	"sync/atomic"
+)
+
+// EventSink - 判定の進捗のイベント(Compiled，CaseFinished)の送信先
+// イベントの種類ごとのフィールドのみが設定されたイベントを受け取る．テストケースを並列に実行する場合は複数のゴルーチンから呼び出される．
+type EventSink func(event judgeproto.JudgeEvent)
+
+// judgeProgresses - 判定中の提出の進捗(解答IDごと)
+// 同じ解答が並行して判定される場合(再判定など)は，後から開始した判定の進捗を保持する．
+var judgeProgresses sync.Map
+
+// judgeProgress - 1つの提出の判定の進捗
+type judgeProgress struct {
+	stage  atomic.Value // 判定の段階(models.JudgeStageCompiling または models.JudgeStageRunning)
+	done   atomic.Int64 // 判定が終了したテストケースの数
+	total  atomic.Int64 // テストケースの総数
+	events EventSink    // 進捗のイベントの送信先(送信しない場合はnil)
+}
+
+// trackProgress - 解答の判定の進捗の記録を開始
+// 判定の終了時に返された関数を呼び出し，記録を破棄する．
+func trackProgress(solutionID int, events EventSink) (*judgeProgress, func()) {
+	progress := &judgeProgress{events: events}
+	progress.stage.Store(models.JudgeStageCompiling)
+	judgeProgresses.Store(solutionID, progress)
+	return progress, func() { judgeProgresses.CompareAndDelete(solutionID, progress) }
+}
+
+// compiled - コンパイルが終了したことを通知
+func (p *judgeProgress) compiled(success bool, total int) {
+	if p == nil {
+		return
+	}
+	p.emit(judgeproto.JudgeEvent{Compiled: &judgeproto.Compiled{Success: success, TotalCases: total}})
+}
+
+// startRunning - テストケースの実行を開始したことを記録
+func (p *judgeProgress) startRunning(total int) {
+	if p == nil {
+		return
+	}
+	p.total.Store(int64(total))
+	p.stage.Store(models.JudgeStageRunning)
+}
+
+// caseDone - 1つのテストケースの判定が終了したことを記録し，判定結果を通知
+func (p *judgeProgress) caseDone(index int, caseResult models.CaseResult) {
+	if p == nil {
+		return
+	}
+	done := p.done.Add(1)
+	p.emit(judgeproto.JudgeEvent{CaseFinished: &judgeproto.CaseFinished{
+		Index:         index,
+		Done:          int(done),
+		TotalCases:    int(p.total.Load()),
+		CaseName:      caseResult.CaseName,
+		Verdict:       caseResult.Result,
+		ExecutionTime: caseResult.ExecutionTime,
+		CPUTime:       caseResult.CPUTime,
+		PeakMemory:    caseResult.PeakMemory,
+	}})
+}
+
+// emit - 送信先が設定されている場合にイベントを送信
+func (p *judgeProgress) emit(event judgeproto.JudgeEvent) {
+	if p.events != nil {
+		p.events(event)
+	}
+}
+
+// GetProgress - 判定中の提出の段階と，判定が終了したテストケースの数，テストケースの総数を取得
+// 判定中でない場合は false を返す．
+func GetProgress(solutionID int) (stage string, done, total int, ok bool) {
+	value, ok := judgeProgresses.Load(solutionID)
+	if !ok {
+		return "", 0, 0, false
+	}
+	progress := value.(*judgeProgress)
+	return progress.stage.Load().(string), int(progress.done.Load()), int(progress.total.Load()), true
+}
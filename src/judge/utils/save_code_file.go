@@ -9,35 +9,72 @@ import (
 	"strings"
 )
 
-// SaveCodeToFile - 提出されたソースコードを一時ファイルに保存
-func SaveCodeToFile(languageID int, code string) (codeFilePath string, langConfig config.LanguageConfig, cleanupFunc func() error, err error) {
+// Workspace - 1つの提出に対して全てのテストケースで共有される作業ディレクトリ
+type Workspace struct {
+	Dir          string // 作業ディレクトリのルート
+	CodeFilePath string // 提出されたソースコードのパス(Dir/code/solution.ext)
+	BinDir       string // コンパイル成果物の保存先(Dir/bin)
+}
+
+// CodeDir - ソースコードを保存しているディレクトリを取得
+func (ws *Workspace) CodeDir() string {
+	return filepath.Dir(ws.CodeFilePath)
+}
+
+// CreateWorkspace - 提出ごとの作業ディレクトリを作成し，提出されたソースコードを保存
+func CreateWorkspace(languageID int, code string) (ws *Workspace, langConfig config.LanguageConfig, cleanupFunc func() error, err error) {
 	langConfig, ok := config.GetLanguageConfigByID(languageID)
 	if !ok {
-		return "", langConfig, nil, fmt.Errorf("unsupported language ID: %d", languageID)
+		return nil, langConfig, nil, fmt.Errorf("unsupported language ID: %d", languageID)
 	}
 
-	codeDir, err := ioutil.TempDir("/tmp", "codeDir_")
+	workspaceDir, err := ioutil.TempDir("/tmp", "submission_")
 	if err != nil {
-		return "", langConfig, nil, fmt.Errorf("failed to create temporary directory for code: %v", err)
+		return nil, langConfig, nil, fmt.Errorf("failed to create workspace directory: %v", err)
 	}
 
 	cleanupFunc = func() error {
-		return os.RemoveAll(codeDir)
+		return os.RemoveAll(workspaceDir)
+	}
+
+	ws = &Workspace{
+		Dir:    workspaceDir,
+		BinDir: filepath.Join(workspaceDir, "bin"),
+	}
+
+	// コンパイル成果物はコンテナ内部のユーザーから書き込めるよう権限を設定
+	for _, dir := range []string{filepath.Join(workspaceDir, "code"), ws.BinDir} {
+		if err := os.Mkdir(dir, 0777); err != nil {
+			return nil, langConfig, cleanupFunc, fmt.Errorf("failed to create workspace directory: %v", err)
+		}
+		if err := os.Chmod(dir, 0777); err != nil {
+			return nil, langConfig, cleanupFunc, fmt.Errorf("failed to change workspace directory permission: %v", err)
+		}
 	}
 
-	fileExt := getFileExtension(langConfig.LanguageID)
-	codeFile, err := os.Create(filepath.Join(codeDir, fmt.Sprintf("solution.%s", fileExt)))
+	codeFilePath, err := saveCodeToFile(filepath.Join(workspaceDir, "code"), langConfig, code)
 	if err != nil {
-		return "", langConfig, cleanupFunc, fmt.Errorf("failed to create code file: %v", err)
+		return nil, langConfig, cleanupFunc, err
+	}
+	ws.CodeFilePath = codeFilePath
+
+	return ws, langConfig, cleanupFunc, nil
+}
+
+// saveCodeToFile - 提出されたソースコードを指定されたディレクトリに保存
+func saveCodeToFile(codeDir string, langConfig config.LanguageConfig, code string) (string, error) {
+	codeFile, err := os.Create(filepath.Join(codeDir, langConfig.SourceFileName()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create code file: %v", err)
 	}
 	defer codeFile.Close()
 
 	// コードをファイルに書き込み(\n　は改行として認識)
 	if _, err := codeFile.WriteString(strings.Replace(code, "\\n", "\n", -1)); err != nil {
-		return "", langConfig, cleanupFunc, fmt.Errorf("failed to write code to file: %v", err)
+		return "", fmt.Errorf("failed to write code to file: %v", err)
 	}
 
-	return codeFile.Name(), langConfig, cleanupFunc, nil
+	return codeFile.Name(), nil
 }
 
 // CreateTempFile - 一時的なディレクトリ内部に一時的なファイルを作成
@@ -59,21 +96,3 @@ func CreateTempFile() (tempFilePath string, cleanupFunc func() error, err error)
 
 	return tempFilePath, cleanupFunc, nil
 }
-
-// getFileExtension - 言語IDに基づいてファイル拡張子を計算
-func getFileExtension(languageID int) string {
-	switch languageID {
-	case 1: // Python
-		return "py"
-	case 2: // C++
-		return "cpp"
-	case 3: // Go
-		return "go"
-	case 4: // Java
-		return "java"
-	case 5: // Rust
-		return "rs"
-	default:
-		return "txt"
-	}
-}
@@ -0,0 +1,61 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"procon_web_service/src/common/judgeproto"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/models"
+	"sync"
+	"time"
+)
+
+// eventPublisher - 1回の判定の進捗のイベントに判定の依頼の識別子と通し番号を付けて，判定キューを通じてwebサーバーに通知
+// テストケースを並列に実行する場合は複数のゴルーチンから呼び出されるため，通し番号の順にイベントが追加されるよう直列化する．
+type eventPublisher struct {
+	ctx        context.Context
+	jobs       *judgequeue.Queue
+	requestID  string
+	solutionID int
+	nodeID     string
+
+	mu       sync.Mutex
+	sequence int
+}
+
+// newEventPublisher - 判定リクエストの進捗のイベントを通知するeventPublisherを生成
+func newEventPublisher(ctx context.Context, jobs *judgequeue.Queue, nodeID string, job models.JudgeJob) *eventPublisher {
+	return &eventPublisher{ctx: ctx, jobs: jobs, requestID: job.RequestID, solutionID: job.Request.Solution.SolutionID, nodeID: nodeID}
+}
+
+// publish - イベントを判定キューに追加
+// 進捗のイベントは判定結果とは別に通知されるため，追加に失敗しても判定は続行し，ログに記録するのみとする．
+func (p *eventPublisher) publish(event judgeproto.JudgeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.sequence++
+	event.RequestID = p.requestID
+	event.SolutionID = p.solutionID
+	event.NodeID = p.nodeID
+	event.Sequence = p.sequence
+	event.Timestamp = time.Now()
+	if err := p.jobs.PublishEvent(p.ctx, event); err != nil {
+		log.Printf("Failed to publish %s event of solution %d: %v", event.Type(), p.solutionID, err)
+	}
+}
+
+// accepted - 判定を開始したことを通知
+func (p *eventPublisher) accepted(attempt int64) {
+	p.publish(judgeproto.JudgeEvent{Accepted: &judgeproto.Accepted{Attempt: int(attempt)}})
+}
+
+// done - 判定が終了したことを通知(resultDetail が nil の場合は判定に失敗したことを通知)
+func (p *eventPublisher) done(resultDetail *models.ResultDetail, reason string, retry bool) {
+	done := &judgeproto.Done{Error: reason, Retry: retry}
+	if resultDetail != nil {
+		done.Verdict = resultDetail.Verdict
+		done.Score = resultDetail.Score
+	}
+	p.publish(judgeproto.JudgeEvent{Done: done})
+}
@@ -0,0 +1,115 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/utils"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeatInterval - 判定キューにハートビートを送信する間隔
+const heartbeatInterval = 5 * time.Second
+
+// node - 判定キューに登録したこのジャッジノードの情報と状態
+type node struct {
+	jobs      *judgequeue.Queue
+	info      models.JudgeNode // ジャッジノードの情報(ID，URL，バージョン，同時に判定する提出の最大数)
+	languages []int            // 判定する言語のIDのリスト(空の場合は言語設定の全ての言語)
+	state     atomic.Value     // 管理者が設定した状態(models.JudgeNodeStateActive など)
+	active    atomic.Int64     // 処理中の判定リクエストの数
+}
+
+// newNode - ジャッジノードの情報を生成
+func newNode(jobs *judgequeue.Queue, info models.JudgeNode, languages []int) *node {
+	n := &node{jobs: jobs, info: info, languages: languages}
+	n.state.Store(models.JudgeNodeStateActive)
+	return n
+}
+
+// run - ctxがキャンセルされるまで，一定間隔でハートビートを送信し，ハートビートが途絶えた他のジャッジノードの判定リクエストを再割り当て
+func (n *node) run(ctx context.Context) {
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	lastRecover := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		n.heartbeat(ctx)
+		if time.Since(lastRecover) >= n.jobs.ClaimIdle() {
+			lastRecover = time.Now()
+			if err := n.jobs.RecoverNodes(ctx, n.info.NodeID); err != nil {
+				log.Printf("Failed to recover judge requests of unhealthy nodes: %v", err)
+			}
+		}
+	}
+}
+
+// heartbeat - 管理者が設定した状態を取得し，負荷などの情報を判定キューに送信
+// 無効化されている場合は，割り当てられた未処理の判定リクエストを他のジャッジノードに再割り当てする．
+func (n *node) heartbeat(ctx context.Context) {
+	state, err := n.jobs.NodeState(ctx, n.info.NodeID)
+	if err != nil {
+		log.Printf("Failed to get state of judge node %s: %v", n.info.NodeID, err)
+	} else if state != n.currentState() {
+		log.Printf("Judge node %s is now %s", n.info.NodeID, state)
+		n.state.Store(state)
+	}
+
+	info := n.info
+	info.Languages = n.supportedLanguages()
+	info.Active = int(n.active.Load())
+	info.TestDataCache = utils.TestDataCacheStats()
+	if err := n.jobs.Heartbeat(ctx, info); err != nil {
+		log.Printf("Failed to send heartbeat of judge node %s: %v", n.info.NodeID, err)
+	}
+
+	if n.currentState() == models.JudgeNodeStateDisabled {
+		if moved, err := n.jobs.ReassignNodeJobs(ctx, n.info.NodeID, false); err != nil {
+			log.Printf("Failed to reassign judge requests of disabled node %s: %v", n.info.NodeID, err)
+		} else if moved > 0 {
+			log.Printf("Reassigned %d judge requests of disabled node %s", moved, n.info.NodeID)
+		}
+	}
+}
+
+// currentState - 管理者が設定した現在の状態を取得
+func (n *node) currentState() string {
+	return n.state.Load().(string)
+}
+
+// supportedLanguages - 判定できる言語のIDのリストを取得(言語設定の全ての言語のうち，設定された言語)
+// 無効化された言語も既存の解答の再判定のために含める．言語設定の再読み込みに追従するため，ハートビートのたびに取得する．
+func (n *node) supportedLanguages() []int {
+	var languages []int
+	for _, langConfig := range config.GetLanguages() {
+		if len(n.languages) == 0 || containsInt(n.languages, langConfig.LanguageID) {
+			languages = append(languages, langConfig.LanguageID)
+		}
+	}
+	sort.Ints(languages)
+	return languages
+}
+
+// supports - 指定された言語を判定できる場合にtrueを返す
+func (n *node) supports(languageID int) bool {
+	return containsInt(n.supportedLanguages(), languageID)
+}
+
+// containsInt - スライスに値が含まれている場合にtrueを返す
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
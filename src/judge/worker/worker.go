@@ -0,0 +1,257 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/judge/config"
+	"procon_web_service/src/judge/queue"
+	judgeutils "procon_web_service/src/judge/utils"
+	"time"
+)
+
+// judgeTimeout - 1つの判定リクエストの判定にかける最大時間
+const judgeTimeout = 5 * time.Minute
+
+// retryInterval - 判定キューの操作に失敗した場合に再試行するまでの時間
+const retryInterval = 5 * time.Second
+
+// Run - 判定キューにジャッジノードを登録し，判定リクエストを取り出してスケジューラのワーカーで判定し，判定結果を判定キューに追加
+// このジャッジノードに割り当てられた判定リクエストを優先し，次にいずれのジャッジノードにも割り当てられていない判定リクエストを取り出す．
+// 同時に取り出す判定リクエストはワーカー数の2倍までとし，それを超える判定リクエストは他のジャッジノードが取り出せるよう判定キューに残す．
+// 管理者によりdrainingにされた場合は割り当て済みの判定リクエストのみを，disabledにされた場合は判定リクエストを取り出さない．
+// 判定に失敗した判定リクエストはAckせずに残し，一定時間後に再配信させる．配信回数が上限に達した場合はエラーを判定結果として通知し，デッドレターに移す．
+// 判定の進捗(判定の開始，コンパイルの終了，各テストケースの判定の終了，判定の終了)は，判定結果とは別にイベントとして判定キューを通じて通知する．
+// ctxがキャンセルされるまで戻らない．
+func Run(ctx context.Context, jobs *judgequeue.Queue, scheduler *queue.Scheduler, judgeConfig *config.JudgeConfig) {
+	// Redisが起動するまでコンシューマーグループの作成を再試行
+	for {
+		err := jobs.EnsureGroups(ctx)
+		if err == nil {
+			break
+		}
+		log.Printf("Failed to prepare judge queue: %v", err)
+		if !sleep(ctx, retryInterval) {
+			return
+		}
+	}
+
+	// ジャッジノードを登録してからハートビートを開始
+	n := newNode(jobs, models.JudgeNode{
+		NodeID:     judgeConfig.NodeID,
+		URL:        judgeConfig.NodeURL,
+		Version:    judgeConfig.NodeVersion,
+		MaxWorkers: judgeConfig.MaxWorkers,
+	}, judgeConfig.NodeLanguages)
+	n.heartbeat(ctx)
+	go n.run(ctx)
+	log.Printf("Judge node %s registered (%s)", judgeConfig.NodeID, judgeConfig.NodeURL)
+
+	capacity := judgeConfig.MaxWorkers * 2
+	slots := make(chan struct{}, capacity)
+	for {
+		// 判定リクエストを処理する空きができるまで待機
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
+
+		state := n.currentState()
+		if state == models.JudgeNodeStateDisabled {
+			<-slots
+			if !sleep(ctx, heartbeatInterval) {
+				return
+			}
+			continue
+		}
+
+		messages, err := jobs.ReadJobs(ctx, 1, n.info.NodeID, state == models.JudgeNodeStateActive)
+		if err != nil || len(messages) == 0 {
+			<-slots
+			if err != nil {
+				log.Printf("Failed to read judge queue: %v", err)
+				if !sleep(ctx, retryInterval) {
+					return
+				}
+			}
+			continue
+		}
+
+		n.active.Add(1)
+		go func(message judgequeue.Message) {
+			defer func() {
+				n.active.Add(-1)
+				<-slots
+			}()
+			handle(ctx, n, scheduler, message)
+		}(messages[0])
+	}
+}
+
+// handle - 1つの判定リクエストを判定し，判定の進捗のイベントと判定結果を判定キューに追加してAck
+func handle(ctx context.Context, n *node, scheduler *queue.Scheduler, message judgequeue.Message) {
+	jobs := n.jobs
+	var job models.JudgeJob
+	if err := message.Decode(&job); err != nil {
+		if err := jobs.DeadLetter(ctx, message, fmt.Sprintf("malformed judge request: %v", err)); err != nil {
+			log.Printf("Failed to dead-letter judge request %s: %v", message.ID, err)
+		}
+		return
+	}
+
+	events := newEventPublisher(ctx, jobs, n.info.NodeID, job)
+
+	// 古い判定リクエストや再送された判定リクエストは判定しない
+	err := jobs.CheckJob(ctx, message)
+	if err == nil && job.RequestID != message.RequestID {
+		err = judgequeue.ErrInvalidSignature
+	}
+	if errors.Is(err, judgequeue.ErrStaleMessage) {
+		// 正しい判定の依頼が待機中に期限を過ぎた場合は，提出が判定待ちのまま残らないよう失敗を通知する
+		log.Printf("Rejected judge request %s (request %s): %v", message.ID, message.RequestID, err)
+		fail(ctx, jobs, message, job, events, "Judge request expired before judging started")
+		return
+	}
+	if errors.Is(err, judgequeue.ErrReplayedMessage) || errors.Is(err, judgequeue.ErrInvalidSignature) {
+		// 再送された判定の依頼は元のメッセージが判定結果を通知し，署名の正しくない判定の依頼は一意識別子を信頼できないため，判定結果を通知しない
+		log.Printf("Rejected judge request %s (request %s): %v", message.ID, message.RequestID, err)
+		if err := jobs.DeadLetter(ctx, message, fmt.Sprintf("rejected judge request: %v", err)); err != nil {
+			log.Printf("Failed to dead-letter judge request %s: %v", message.ID, err)
+		}
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to check judge request %s: %v", message.ID, err)
+		return
+	}
+
+	deliveries, err := jobs.Deliveries(ctx, message)
+	if err != nil {
+		log.Printf("Failed to get delivery count of judge request %s: %v", message.ID, err)
+		return
+	}
+	if deliveries > jobs.MaxDeliveries() {
+		fail(ctx, jobs, message, job, events, "Judging failed repeatedly")
+		return
+	}
+
+	// 判定できない言語の場合は，判定できるジャッジノードに割り当て直す
+	if languageID := job.Request.Solution.LanguageID; !n.supports(languageID) {
+		forward(ctx, jobs, message, job, events, languageID)
+		return
+	}
+
+	// 判定中は他のジャッジサーバーに引き継がれないよう定期的に通知
+	keepAliveCtx, stopKeepAlive := context.WithCancel(ctx)
+	defer stopKeepAlive()
+	go keepAlive(keepAliveCtx, jobs, message)
+
+	judgeCtx, cancel := context.WithTimeout(ctx, judgeTimeout)
+	defer cancel()
+
+	var resultDetail *models.ResultDetail
+	var judgeErr error
+	err = scheduler.Submit(judgeCtx, job.Request.Solution.SolutionID, job.Request.Priority, func(ctx context.Context) {
+		events.accepted(deliveries)
+		resultDetail, judgeErr = judgeutils.BuildAndRunInContainer(ctx, job.Request.Solution, job.Request.Problem, events.publish)
+	})
+	switch {
+	case err != nil || judgeCtx.Err() != nil:
+		judgeErr = errors.New("judging timed out")
+	case judgeErr == nil && resultDetail.ErrorMessage != "":
+		judgeErr = errors.New(resultDetail.ErrorMessage)
+	}
+	stopKeepAlive()
+
+	// 終了中の場合は，他のジャッジサーバーが引き継げるよう判定キューに残す
+	if ctx.Err() != nil {
+		return
+	}
+
+	if judgeErr != nil {
+		if deliveries < jobs.MaxDeliveries() {
+			log.Printf("Judging of solution %d failed (attempt %d/%d), will be retried: %v", job.Request.Solution.SolutionID, deliveries, jobs.MaxDeliveries(), judgeErr)
+			events.done(nil, judgeErr.Error(), true)
+			return
+		}
+		fail(ctx, jobs, message, job, events, judgeErr.Error())
+		return
+	}
+
+	// 判定の終了のイベントは，webサーバーが判定結果より先に受け取れるよう先に通知
+	events.done(resultDetail, "", false)
+	result := models.JudgeJobResult{RequestID: job.RequestID, SolutionID: job.Request.Solution.SolutionID, Result: resultDetail}
+	if err := jobs.PublishResult(ctx, result); err != nil {
+		// 判定結果を通知できない場合はAckせず，再判定させる
+		log.Printf("Failed to publish result of solution %d: %v", job.Request.Solution.SolutionID, err)
+		return
+	}
+	if err := jobs.Ack(ctx, message); err != nil {
+		log.Printf("Failed to ack judge request %s: %v", message.ID, err)
+	}
+}
+
+// forward - 判定リクエストを，指定された言語を判定できる負荷の最も小さいジャッジノードに割り当て直してAck
+// 判定できるジャッジノードがない場合は，判定に失敗したことを判定結果として通知する．
+func forward(ctx context.Context, jobs *judgequeue.Queue, message judgequeue.Message, job models.JudgeJob, events *eventPublisher, languageID int) {
+	nodes, err := jobs.Nodes(ctx)
+	if err != nil {
+		log.Printf("Failed to get judge nodes: %v", err)
+		return
+	}
+	target := judgequeue.SelectNode(nodes, languageID)
+	if target == nil {
+		fail(ctx, jobs, message, job, events, fmt.Sprintf("No judge node supports language %d", languageID))
+		return
+	}
+	if err := jobs.EnqueueToNode(ctx, target.NodeID, job); err != nil {
+		log.Printf("Failed to forward judge request %s to node %s: %v", message.ID, target.NodeID, err)
+		return
+	}
+	if err := jobs.Ack(ctx, message); err != nil {
+		log.Printf("Failed to ack judge request %s: %v", message.ID, err)
+	}
+}
+
+// fail - 判定に失敗したことを判定結果とイベントとして通知し，判定リクエストをデッドレターに移す
+func fail(ctx context.Context, jobs *judgequeue.Queue, message judgequeue.Message, job models.JudgeJob, events *eventPublisher, reason string) {
+	events.done(nil, reason, false)
+	result := models.JudgeJobResult{RequestID: job.RequestID, SolutionID: job.Request.Solution.SolutionID, Error: reason}
+	if err := jobs.PublishResult(ctx, result); err != nil {
+		log.Printf("Failed to publish failure of solution %d: %v", job.Request.Solution.SolutionID, err)
+		return
+	}
+	if err := jobs.DeadLetter(ctx, message, reason); err != nil {
+		log.Printf("Failed to dead-letter judge request %s: %v", message.ID, err)
+	}
+}
+
+// keepAlive - ctxがキャンセルされるまで，判定リクエストを処理中であることを定期的に通知
+func keepAlive(ctx context.Context, jobs *judgequeue.Queue, message judgequeue.Message) {
+	ticker := time.NewTicker(jobs.ClaimIdle() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := jobs.Extend(ctx, message); err != nil && ctx.Err() == nil {
+				log.Printf("Failed to extend judge request %s: %v", message.ID, err)
+			}
+		}
+	}
+}
+
+// sleep - 指定された時間待機．待機中にctxがキャンセルされた場合はfalseを返す
+func sleep(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
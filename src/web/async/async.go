@@ -10,75 +10,99 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"procon_web_service/src/common/judgeproto"
 	"procon_web_service/src/common/models"
-	"procon_web_service/src/web/config"
 	"procon_web_service/src/web/database"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
-var (
-	judgeURL = config.NewAPIEndpointsConfig().JudgeServerURL
-)
+var queuePollInterval = 2 * time.Second // ジャッジサーバーのキューの状態を確認する間隔
 
 // JudgeSolutionAsyncは，WebSocketを使用して解答の非同期判定を行い，結果をクライアントに通知する関数である．
-// この関数は，提出された解答をジャッジサーバーへ送信し，判定結果を取得した後，その結果をWebSocketを介してクライアントに送信する．
+// この関数は，データベースに保存された解答を問題の実行制限とともにジャッジサーバーへ送信し，判定結果を取得した後，その結果をWebSocketを介してクライアントに送信する．
 // 判定プロセス中に発生したエラーは，WebSocketを通じてクライアントにエラーメッセージとして送信される．
-// 解答の判定結果は，ジャッジサーバーからのレスポンスとして受け取り，models.ResultDetail構造体にデシリアライズされる．
-// 最後に，判定結果をデータベースに保存し，WebSocketを使用してクライアントに判定結果を送信する．
+// 判定結果を待つ間は，ジャッジサーバーのキューでの待機順を定期的に取得し，順番が変わるたびにクライアントに通知する．
+// 判定が開始された後は，ジャッジサーバーが通知する判定の進捗のイベント（判定の開始，コンパイルの終了，各テストケースの判定の終了，判定の終了）をリアルタイムにクライアントに転送する．
+// 判定は永続的な判定キューを通じて依頼され，判定状況（Queued，Compiling，Running n/N，Judged / SystemError）はデータベースに保存されるため，
+// WebSocket接続が切断されても，webサーバーやジャッジサーバーが再起動しても判定は続行され，結果はREST APIで取得できる．
+// 最後に，データベースに保存された判定結果を取得し，非公開のテストケースの名前と内容を伏せた判定結果をWebSocketを使用してクライアントに送信する．
 // この関数は，WebSocket通信を介してユーザーにリアルタイムのフィードバックを提供するための非同期処理の一部として機能する．
 //
 // パラメータ:
-// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．WebSocket接続の切断で判定が中断されないよう，接続とは独立したコンテキストを渡す．
 // - db *sql.DB: データベース接続へのポインタ．
-// - solution models.Solution: 判定する解答．
+// - solution models.Solution: 判定する解答．解答IDのみを用い，判定するコードなどはデータベースから取得する．
 // - conn *websocket.Conn: クライアントとのWebSocket接続．
-//
-// 注意:
-// - この関数は，ジャッジサーバーへのリクエスト送信，レスポンスの処理，結果のクライアントへの送信を行う．
-// - 判定結果のデータベースへの保存は，本番環境でのみ実行されるべきであり，開発やテスト環境では異なる扱いが必要になる場合がある．
 func JudgeSolutionAsync(ctx context.Context, db *sql.DB, solution models.Solution, conn *websocket.Conn) {
-	solutionBytes, err := json.Marshal(solution)
+	// 判定する解答と，実行制限などの判定に必要な問題の設定を取得
+	stored, err := database.SelectSolutionBySolutionID(db, solution.SolutionID)
 	if err != nil {
-		SendError(conn, "Failed to marshal solution: "+err.Error())
+		SendError(conn, "Failed to get solution: "+err.Error())
 		return
 	}
-
-	// ジャッジサーバーへのリクエストを準備
-	respBytes, err := sendRequestToJudgeServer(ctx, judgeURL, solutionBytes)
+	problem, err := database.SelectProblemByProblemID(db, stored.ProblemID)
 	if err != nil {
-		errMsg := "Failed to send request to judge server: " + err.Error()
-		// タイムアウトによるエラーメッセージの調整
-		if errors.Is(err, context.Canceled) {
-			errMsg = "Judge server request timed out"
-		}
-		SendError(conn, errMsg)
+		newStatusTracker(db, stored.SolutionID, "").update(models.SubmissionStatus{Status: models.SubmissionStatusSystemError, Message: "Failed to get problem: " + err.Error()})
+		SendError(conn, "Failed to get problem: "+err.Error())
 		return
 	}
 
-	// ジャッジサーバーからのレスポンスを取得
-	var response struct {
-		Status  int                 `json:"status"`
-		Result  models.ResultDetail `json:"result"`
-		Message string              `json:"message"`
-	}
-	if err := json.Unmarshal(respBytes, &response); err != nil {
-		SendError(conn, "Failed to unmarshal judge server response: "+err.Error())
+	// 判定結果を待つ間は，キューでの待機状況と判定の進捗をクライアントに通知
+	resultDetail, err := judgeSolution(ctx, db, *stored, problem, 0, func(status models.QueueStatus) {
+		sendQueueStatus(conn, status)
+	}, func(event judgeproto.JudgeEvent) {
+		sendJudgeEvent(conn, event, problem)
+	})
+	if err != nil {
+		SendError(conn, err.Error())
 		return
 	}
 
-	resultDetail := response.Result
-
-	if err := database.CreateResultDetail(db, solution.SolutionID, &resultDetail); err != nil {
-		SendError(conn, "Failed to save result detail: "+err.Error())
+	// 非公開のテストケースの名前と内容を伏せてから，WebSocketを通じて結果をクライアントに送信
+	// データベースには元のテストケースの名前で保存されている．
+	resultDetail.HideCases(problem)
+	message, err := json.Marshal(map[string]interface{}{
+		"status":  http.StatusOK,
+		"result":  resultDetail,
+		"message": nil,
+	})
+	if err != nil {
+		SendError(conn, "Failed to marshal result details: "+err.Error())
 		return
 	}
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		// 接続が切断されていても判定結果は保存されているため，ログに記録するのみとする
+		log.Printf("Failed to send result details of solution %d: %v", stored.SolutionID, err)
+	}
+}
 
-	// WebSocketを通じて結果をクライアントに送信
-	if err := conn.WriteMessage(websocket.TextMessage, respBytes); err != nil {
-		SendError(conn, "Failed to send result details: "+err.Error())
-		return
+// judgeSolutionは，解答の判定を判定キューに依頼し，判定結果がデータベースに保存されるまで待機する関数である．
+// 判定結果の保存と判定状況の Judged / SystemError への更新は，判定キューから判定結果を取り出すStartResultConsumerが行う．
+// そのため，ctxが終了して待機を打ち切った場合も判定は続行され，判定結果はREST APIで取得できる．
+// 判定が開始されるまではジャッジサーバーのキューの状態を定期的に取得して判定状況を更新し，キューでの待機順が変わるたびにonQueueを呼び出す．
+// 判定が開始された後は，ジャッジサーバーが通知する判定の進捗のイベントから判定状況を更新し，イベントごとにonEventを呼び出す．
+// onQueueとonEventがnilの場合は呼び出さない．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - db *sql.DB: データベース接続へのポインタ．
+// - solution models.Solution: 判定する解答．
+// - problem *models.Problem: 解答の対象の問題の設定．
+// - priority int: 判定キューとジャッジサーバーのキューでの優先度．負の場合は優先度の低いストリームに追加される．
+// - onQueue func(models.QueueStatus): キューでの待機順が変わるたびに呼び出される関数．
+// - onEvent func(judgeproto.JudgeEvent): 判定の進捗のイベントを受け取るたびに呼び出される関数．
+//
+// 戻り値:
+// - *models.ResultDetail: 保存された判定結果（試行番号と判定日時を含む）．
+// - error: 判定の依頼または判定に失敗した場合，あるいは待機を打ち切った場合のエラー，またはnil．
+func judgeSolution(ctx context.Context, db *sql.DB, solution models.Solution, problem *models.Problem, priority int, onQueue func(models.QueueStatus), onEvent func(judgeproto.JudgeEvent)) (*models.ResultDetail, error) {
+	requestID, err := enqueueJudge(ctx, db, solution, problem, priority)
+	if err != nil {
+		return nil, err
 	}
+	return waitForJudgement(ctx, db, solution.SolutionID, requestID, onQueue, onEvent)
 }
 
 // SendErrorは，WebSocketを使用しているクライアントに対してエラーメッセージを送信し，その後コネクションを適切にクローズする関数である．
@@ -107,6 +131,140 @@ func SendError(conn *websocket.Conn, errMsg string) {
 	}
 }
 
+// sendQueueStatusは，キューでの待機状況をWebSocketを介してクライアントに送信する関数である．
+// メッセージは判定結果と同じ形式で，HTTPステータスコード202(Accepted)と待機順を表すメッセージ(例: "waiting in queue (#12)")を含む．
+//
+// パラメータ:
+// - conn *websocket.Conn: メッセージを送信するWebSocketコネクション．
+// - status models.QueueStatus: 送信するキューでの待機状況．
+func sendQueueStatus(conn *websocket.Conn, status models.QueueStatus) {
+	message, err := json.Marshal(map[string]interface{}{
+		"status":  http.StatusAccepted,
+		"result":  status,
+		"message": fmt.Sprintf("waiting in queue (#%d)", status.Position),
+	})
+	if err != nil {
+		log.Printf("Failed to marshal queue status: %v", err)
+		return
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		log.Printf("Failed to send queue status: %v", err)
+	}
+}
+
+// sendJudgeEventは，ジャッジサーバーが通知した判定の進捗のイベントをWebSocketを介してクライアントに送信する関数である．
+// メッセージは判定結果と同じ形式で，HTTPステータスコード202(Accepted)とイベントを表すメッセージ(例: "case 3/10 finished: AC")を含む．
+// 非公開のテストケースの名前は伏せて送信する．
+//
+// パラメータ:
+// - conn *websocket.Conn: メッセージを送信するWebSocketコネクション．
+// - event judgeproto.JudgeEvent: 送信する判定の進捗のイベント．
+// - problem *models.Problem: 解答の対象の問題の設定．テストケースを公開するかの判断に用いる．
+func sendJudgeEvent(conn *websocket.Conn, event judgeproto.JudgeEvent, problem *models.Problem) {
+	var text string
+	switch {
+	case event.Accepted != nil:
+		text = fmt.Sprintf("judging started (attempt %d)", event.Accepted.Attempt)
+	case event.Compiled != nil && event.Compiled.Success:
+		text = "compiled"
+	case event.Compiled != nil:
+		text = "compile error"
+	case event.CaseFinished != nil:
+		caseFinished := *event.CaseFinished
+		if !problem.RevealsCase(caseFinished.CaseName) {
+			caseFinished.CaseName = ""
+		}
+		event.CaseFinished = &caseFinished
+		text = fmt.Sprintf("case %d/%d finished: %s", caseFinished.Done, caseFinished.TotalCases, caseFinished.Verdict)
+	case event.Done != nil && event.Done.Retry:
+		text = "judging failed, will be retried: " + event.Done.Error
+	case event.Done != nil && event.Done.Error != "":
+		text = "judging failed: " + event.Done.Error
+	case event.Done != nil:
+		text = "judging finished: " + event.Done.Verdict
+	default:
+		return
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"status":  http.StatusAccepted,
+		"result":  event,
+		"message": text,
+	})
+	if err != nil {
+		log.Printf("Failed to marshal judge event: %v", err)
+		return
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		log.Printf("Failed to send judge event: %v", err)
+	}
+}
+
+// fetchQueueStatusは，ジャッジノードから指定された解答のキューでの待機状況を取得する関数である．
+// 解答を処理しているジャッジノードは判定キューから取り出されるまで定まらないため，正常な全てのジャッジノードに問い合わせ，
+// 待機中または判定中と応答したジャッジノードの待機状況を返す．いずれのジャッジノードも処理していない場合は空の待機状況を返す．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - solutionID int: 待機状況を取得する解答のID．
+//
+// 戻り値:
+// - models.QueueStatus: キューでの待機状況．
+// - error: 取得に失敗した場合のエラー，またはnil．
+func fetchQueueStatus(ctx context.Context, solutionID int) (models.QueueStatus, error) {
+	nodes, err := jobs.Nodes(ctx)
+	if err != nil {
+		return models.QueueStatus{SolutionID: solutionID}, err
+	}
+
+	var lastErr error
+	for _, node := range nodes {
+		if !node.Healthy {
+			continue
+		}
+		status, err := fetchNodeQueueStatus(ctx, node.URL, solutionID)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if status.Queued || status.Stage != "" {
+			return status, nil
+		}
+	}
+	return models.QueueStatus{SolutionID: solutionID}, lastErr
+}
+
+// fetchNodeQueueStatusは，1つのジャッジノードから指定された解答のキューでの待機状況を取得する．
+func fetchNodeQueueStatus(ctx context.Context, nodeURL string, solutionID int) (models.QueueStatus, error) {
+	var response struct {
+		Status int                `json:"status"`
+		Result models.QueueStatus `json:"result"`
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/queue/%d", nodeURL, solutionID), nil)
+	if err != nil {
+		return response.Result, err
+	}
+	if err := signer.SignRequest(req, nil); err != nil {
+		return response.Result, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return response.Result, err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return response.Result, err
+	}
+	if response.Status != http.StatusOK {
+		return response.Result, fmt.Errorf("judge server returned status %d", response.Status)
+	}
+
+	return response.Result, nil
+}
+
 func sendRequestToJudgeServer(ctx context.Context, url string, data []byte) ([]byte, error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(data))
 	if err != nil {
@@ -114,6 +272,9 @@ func sendRequestToJudgeServer(ctx context.Context, url string, data []byte) ([]b
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if err := signer.SignRequest(req, data); err != nil {
+		return nil, err
+	}
 
 	client := &http.Client{}
 	resp, err := client.Do(req)
@@ -0,0 +1,99 @@
+package async
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/web/database"
+
+	"github.com/gorilla/websocket"
+)
+
+// RunCustomは，カスタム実行をジャッジサーバーに送信し，実行結果を取得する関数である．
+// 問題IDが指定されている場合は，その問題の実行制限（実行時間制限，メモリ制限，言語ごとの倍率）を適用し，指定されていない場合は既定の実行制限を適用する．
+// カスタム実行は解答としてデータベースに保存されず，提出の統計にも影響しない．
+// カスタム実行は，コードの言語を実行できる負荷が最も小さい正常なジャッジノードで行われる．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - db *sql.DB: データベース接続へのポインタ．
+// - run models.CustomRun: 実行するカスタム実行の内容．
+//
+// 戻り値:
+// - *models.CustomRunResult: カスタム実行の結果．
+// - error: 問題の取得，またはジャッジサーバーでの実行に失敗した場合のエラー．成功時はnil．
+func RunCustom(ctx context.Context, db *sql.DB, run models.CustomRun) (*models.CustomRunResult, error) {
+	request := models.CustomRunRequest{Run: run}
+	if run.ProblemID != 0 {
+		problem, err := database.SelectProblemByProblemID(db, run.ProblemID)
+		if err != nil {
+			return nil, err
+		}
+		request.Problem = *problem
+	}
+
+	requestBytes, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	// カスタム実行は判定キューを経由せず，言語を実行できる負荷が最も小さいジャッジノードに直接送信
+	node, err := selectJudgeNode(ctx, run.LanguageID)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, errors.New("No judge node is available")
+	}
+	respBytes, err := sendRequestToJudgeServer(ctx, node.URL+"/run", requestBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Status  int                    `json:"status"`
+		Result  models.CustomRunResult `json:"result"`
+		Message string                 `json:"message"`
+	}
+	if err := json.Unmarshal(respBytes, &response); err != nil {
+		return nil, err
+	}
+	if response.Status != http.StatusOK {
+		return nil, errors.New(response.Message)
+	}
+
+	return &response.Result, nil
+}
+
+// RunCustomAsyncは，WebSocketを介して受け取ったカスタム実行をジャッジサーバーで実行し，結果をクライアントに通知する関数である．
+// 結果のメッセージは判定結果と区別できるよう，`type` に "custom_run" を含む．
+// 実行に失敗した場合は，判定と同様にエラーメッセージを送信してコネクションをクローズする．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - db *sql.DB: データベース接続へのポインタ．
+// - run models.CustomRun: 実行するカスタム実行の内容．
+// - conn *websocket.Conn: クライアントとのWebSocket接続．
+func RunCustomAsync(ctx context.Context, db *sql.DB, run models.CustomRun, conn *websocket.Conn) {
+	result, err := RunCustom(ctx, db, run)
+	if err != nil {
+		SendError(conn, "Failed to run code: "+err.Error())
+		return
+	}
+
+	message, err := json.Marshal(map[string]interface{}{
+		"type":    "custom_run",
+		"status":  http.StatusOK,
+		"result":  result,
+		"message": nil,
+	})
+	if err != nil {
+		SendError(conn, "Failed to marshal custom run result: "+err.Error())
+		return
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+		SendError(conn, "Failed to send custom run result: "+err.Error())
+	}
+}
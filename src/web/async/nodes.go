@@ -0,0 +1,78 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/models"
+)
+
+// ListJudgeNodesは，判定キューに登録されている全てのジャッジノードの情報（負荷，状態，ハートビートの有無を含む）を取得する関数である．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+//
+// 戻り値:
+// - []models.JudgeNode: ジャッジノードの情報のリスト（IDの昇順）．
+// - error: 取得に失敗した場合のエラー，またはnil．
+func ListJudgeNodes(ctx context.Context) ([]models.JudgeNode, error) {
+	nodes, err := jobs.Nodes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get judge nodes: %w", err)
+	}
+	return nodes, nil
+}
+
+// SetJudgeNodeStateは，ジャッジノードの状態を変更し，変更後のジャッジノードの情報を返す関数である．
+// draining にしたジャッジノードには新しい判定リクエストが割り当てられず，割り当て済みの判定リクエストの処理が終わると待機状態になる．
+// disabled にしたジャッジノードは判定リクエストを処理せず，割り当て済みで未処理の判定リクエストは他のジャッジノードに再割り当てされる．
+// 状態の変更は，ジャッジノードの次のハートビート（5秒以内）で反映される．
+//
+// パラメータ:
+// - ctx context.Context: 操作の実行に使用されるコンテキスト．
+// - nodeID string: 状態を変更するジャッジノードのID．
+// - state string: 変更後の状態．
+//
+// 戻り値:
+// - *models.JudgeNode: 変更後のジャッジノードの情報．
+// - error: ジャッジノードが見つからない場合はNotFoundError，変更に失敗した場合はそのエラー，またはnil．
+func SetJudgeNodeState(ctx context.Context, nodeID, state string) (*models.JudgeNode, error) {
+	if err := jobs.SetNodeState(ctx, nodeID, state); err != nil {
+		if errors.Is(err, judgequeue.ErrNodeNotFound) {
+			return nil, commonerrors.NewNotFoundError("JudgeNode", "NodeID", nodeID)
+		}
+		return nil, fmt.Errorf("Failed to update judge node: %w", err)
+	}
+
+	nodes, err := ListJudgeNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range nodes {
+		if node.NodeID == nodeID {
+			return &node, nil
+		}
+	}
+	return nil, commonerrors.NewNotFoundError("JudgeNode", "NodeID", nodeID)
+}
+
+// selectJudgeNodeは，指定された言語を判定できる，負荷が最も小さい正常なジャッジノードを選択する．
+// 新しい判定リクエストを受け付けられるジャッジノードがない場合はnilを返し，
+// 受け付けられるジャッジノードはあるがいずれも言語を判定できない場合はエラーを返す．
+func selectJudgeNode(ctx context.Context, languageID int) (*models.JudgeNode, error) {
+	nodes, err := ListJudgeNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if node := judgequeue.SelectNode(nodes, languageID); node != nil {
+		return node, nil
+	}
+	for _, node := range nodes {
+		if node.Available() {
+			return nil, fmt.Errorf("No judge node supports language %d", languageID)
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,251 @@
+package async
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"procon_web_service/src/common/judgeproto"
+	"procon_web_service/src/common/judgequeue"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/signature"
+	"procon_web_service/src/web/database"
+	"time"
+)
+
+var (
+	signer *signature.Signer // ジャッジサーバーへのリクエストと判定キューのメッセージの署名に用いるSigner
+	jobs   *judgequeue.Queue // ジャッジサーバーとの間で判定リクエストと判定結果を受け渡す判定キュー
+
+	queueRetryInterval = 5 * time.Second // 判定キューの操作に失敗した場合に再試行するまでの時間
+	resultBatchSize    = int64(10)       // 判定キューから一度に取り出す判定結果の最大件数
+)
+
+// Configureは，ジャッジサーバーへのリクエストと判定キューのメッセージの署名に用いるSignerを設定し，判定キューに接続する関数である．
+// 判定の依頼やカスタム実行を行う他の関数より先に，起動時に1度だけ呼び出す必要がある．
+//
+// パラメータ:
+// - s *signature.Signer: 署名に用いるSigner．
+func Configure(s *signature.Signer) {
+	signer = s
+	jobs = judgequeue.New(s)
+}
+
+// StartResultConsumerは，判定キューから判定結果を取り出してデータベースに保存するゴルーチンを開始する関数である．
+// 判定結果を保存した解答の判定状況は Judged に，ジャッジサーバーが判定に失敗した解答の判定状況は SystemError に更新される．
+// 保存に失敗した判定結果はAckせずに残し，一定時間後に再配信させる．配信回数が上限に達した場合はデッドレターに移し，判定状況を SystemError とする．
+// 複数のwebサーバーが起動している場合，各判定結果はいずれか1つのwebサーバーが保存する．
+//
+// パラメータ:
+// - ctx context.Context: 判定結果の取り出しを終了するためのコンテキスト．
+// - db *sql.DB: データベース接続へのポインタ．
+func StartResultConsumer(ctx context.Context, db *sql.DB) {
+	go func() {
+		// Redisが起動するまでコンシューマーグループの作成を再試行
+		for {
+			err := jobs.EnsureGroups(ctx)
+			if err == nil {
+				break
+			}
+			log.Printf("Failed to prepare judge queue: %v", err)
+			if !sleepContext(ctx, queueRetryInterval) {
+				return
+			}
+		}
+
+		for ctx.Err() == nil {
+			messages, err := jobs.ReadResults(ctx, resultBatchSize)
+			if err != nil {
+				log.Printf("Failed to read judge results: %v", err)
+				sleepContext(ctx, queueRetryInterval)
+				continue
+			}
+			for _, message := range messages {
+				consumeResult(ctx, db, message)
+			}
+		}
+	}()
+}
+
+// consumeResultは，1つの判定結果をデータベースに保存し，判定状況を更新してAckする．
+func consumeResult(ctx context.Context, db *sql.DB, message judgequeue.Message) {
+	var result models.JudgeJobResult
+	if err := message.Decode(&result); err != nil {
+		if err := jobs.DeadLetter(ctx, message, fmt.Sprintf("malformed judge result: %v", err)); err != nil {
+			log.Printf("Failed to dead-letter judge result %s: %v", message.ID, err)
+		}
+		return
+	}
+
+	deliveries, err := jobs.Deliveries(ctx, message)
+	if err != nil {
+		log.Printf("Failed to get delivery count of judge result %s: %v", message.ID, err)
+		return
+	}
+	if deliveries > jobs.MaxDeliveries() {
+		newStatusTracker(db, result.SolutionID, result.RequestID).update(models.SubmissionStatus{Status: models.SubmissionStatusSystemError, Message: "Failed to save result detail"})
+		if err := jobs.DeadLetter(ctx, message, "failed to save result repeatedly"); err != nil {
+			log.Printf("Failed to dead-letter judge result %s: %v", message.ID, err)
+		}
+		return
+	}
+
+	status := models.SubmissionStatus{SolutionID: result.SolutionID, RequestID: result.RequestID, Status: models.SubmissionStatusSystemError, Message: result.Error}
+	if result.Error == "" {
+		if result.Result == nil {
+			status.Message = "Judge server returned no result"
+		} else {
+			// 再配信された判定結果は，判定の依頼の一意識別子により重複して保存されない
+			result.Result.RequestID = result.RequestID
+			if err := database.CreateResultDetail(db, result.SolutionID, result.Result); err != nil {
+				log.Printf("Failed to save result detail of solution %d: %v", result.SolutionID, err)
+				return
+			}
+			status = models.SubmissionStatus{SolutionID: result.SolutionID, RequestID: result.RequestID, Status: models.SubmissionStatusJudged,
+				CurrentCase: result.Result.TotalCases, TotalCases: result.Result.TotalCases}
+		}
+	}
+
+	if err := database.TransitionSubmissionStatus(db, status); err != nil {
+		log.Printf("Failed to update status of solution %d: %v", result.SolutionID, err)
+		return
+	}
+	if err := jobs.Ack(ctx, message); err != nil {
+		log.Printf("Failed to ack judge result %s: %v", message.ID, err)
+	}
+}
+
+// enqueueJudgeは，新しい判定の依頼として判定状況を Queued とし，判定リクエストを判定キューに追加する．
+// 判定リクエストは，解答の言語を判定できる負荷が最も小さい正常なジャッジノードに割り当てる．
+// 新しい判定リクエストを受け付けられるジャッジノードがない場合は，いずれかのジャッジノードが受け付けられるようになるまで判定キューで待機させる．
+// 判定キューへの追加に失敗した場合や，解答の言語を判定できるジャッジノードがない場合は，判定状況を SystemError とする．
+func enqueueJudge(ctx context.Context, db *sql.DB, solution models.Solution, problem *models.Problem, priority int) (string, error) {
+	requestID, err := newRequestID()
+	if err != nil {
+		return "", fmt.Errorf("Failed to generate request ID: %w", err)
+	}
+	if err := database.QueueSubmission(db, solution.SolutionID, requestID); err != nil {
+		return "", fmt.Errorf("Failed to queue solution: %w", err)
+	}
+
+	job := models.JudgeJob{RequestID: requestID, Request: models.JudgeRequest{Solution: solution, Problem: *problem, Priority: priority}}
+	node, err := selectJudgeNode(ctx, solution.LanguageID)
+	switch {
+	case err != nil:
+	case node != nil:
+		err = jobs.EnqueueToNode(ctx, node.NodeID, job)
+	default:
+		err = jobs.Enqueue(ctx, job)
+	}
+	if err != nil {
+		err = fmt.Errorf("Failed to queue solution: %w", err)
+		newStatusTracker(db, solution.SolutionID, requestID).update(models.SubmissionStatus{Status: models.SubmissionStatusSystemError, Message: err.Error()})
+		return "", err
+	}
+	return requestID, nil
+}
+
+// waitForJudgementは，判定の依頼に対する判定結果がデータベースに保存されるまで待機する．
+// 待機中は，ジャッジサーバーが判定キューを通じて通知する判定の進捗のイベントから判定状況（Compiling，Running）を更新し，イベントごとにonEventを呼び出す．
+// 判定が開始される（Acceptedのイベントを受け取る）までは，ジャッジサーバーのキューの状態から判定状況（Queued）を更新し，待機順が変わるたびにonQueueを呼び出す．
+func waitForJudgement(ctx context.Context, db *sql.DB, solutionID int, requestID string, onQueue func(models.QueueStatus), onEvent func(judgeproto.JudgeEvent)) (*models.ResultDetail, error) {
+	lastEventID := "0"
+	lastPosition := 0
+	started := false
+	for {
+		// 新しいイベントが追加されるまで，最大でキューの状態を確認する間隔だけ待機
+		messages, err := jobs.ReadEvents(ctx, requestID, lastEventID, queuePollInterval)
+		if ctx.Err() != nil {
+			return nil, errors.New("Judging is taking longer than expected; it will continue in the background and the result can be checked with the status API")
+		}
+		if err != nil {
+			log.Printf("Failed to read judge events of solution %d: %v", solutionID, err)
+			sleepContext(ctx, queuePollInterval)
+			continue
+		}
+
+		status, err := database.SelectSubmissionStatus(db, solutionID)
+		if err != nil {
+			// 状態の取得に失敗した場合は，取り出したイベントを次の確認で再び取り出す
+			continue
+		}
+		if status.RequestID != requestID {
+			return nil, errors.New("Judging was superseded by a newer request")
+		}
+
+		// 判定が終了した場合は，判定結果より先に追加された残りのイベントも待機せずに取り出す
+		if status.Status == models.SubmissionStatusJudged || status.Status == models.SubmissionStatusSystemError {
+			afterID := lastEventID
+			if len(messages) > 0 {
+				afterID = messages[len(messages)-1].ID
+			}
+			if rest, err := jobs.ReadEvents(ctx, requestID, afterID, -1); err == nil {
+				messages = append(messages, rest...)
+			}
+		}
+
+		// 判定の進捗のイベントを通知し，判定状況を更新
+		tracker := &statusTracker{db: db, current: *status}
+		for _, message := range messages {
+			lastEventID = message.ID
+			var event judgeproto.JudgeEvent
+			if err := message.Decode(&event); err != nil {
+				log.Printf("Ignoring judge event %s of solution %d: %v", message.ID, solutionID, err)
+				continue
+			}
+			// 判定に失敗して再試行される場合は，再び判定が開始されるまでキューの状態を確認
+			started = event.Done == nil || !event.Done.Retry
+			tracker.updateFromEvent(event)
+			if onEvent != nil {
+				onEvent(event)
+			}
+		}
+
+		switch status.Status {
+		case models.SubmissionStatusJudged:
+			return database.SelectResultDetailByRequestID(db, solutionID, requestID)
+		case models.SubmissionStatusSystemError:
+			return nil, errors.New(status.Message)
+		}
+		if started {
+			continue
+		}
+
+		// ジャッジサーバーのキューでの待機状況を取得
+		queueStatus, err := fetchQueueStatus(ctx, solutionID)
+		if err != nil {
+			continue
+		}
+		tracker.updateFromQueue(queueStatus)
+		if queueStatus.Position == lastPosition {
+			// 順番が変わっていない場合は通知しない
+			continue
+		}
+		lastPosition = queueStatus.Position
+		if queueStatus.Queued && onQueue != nil {
+			onQueue(queueStatus)
+		}
+	}
+}
+
+// newRequestIDは，判定の依頼の一意識別子（128ビットの乱数の16進表記）を生成する．
+func newRequestID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sleepContextは，指定された時間待機する．待機中にctxが終了した場合はfalseを返す．
+func sleepContext(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
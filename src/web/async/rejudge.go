@@ -0,0 +1,208 @@
+package async
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/web/database"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	rejudgePriority    = -1               // 再判定の優先度．新しい提出の判定を妨げないよう，通常の提出より後に処理される．
+	rejudgeConcurrency = 4                // 1回の再判定で同時にジャッジサーバーへ送信する解答の数
+	rejudgeTimeout     = 10 * time.Minute // 1つの解答の再判定（キューでの待機を含む）を打ち切るまでの時間
+	maxRejudgeJobs     = 100              // 状態を保持する再判定の最大数．超えた場合は終了した古い再判定から破棄する．
+)
+
+// rejudgeJobsは，再判定の状態を再判定IDごとに保持する．
+// 再判定の状態はwebサーバーのメモリ上にのみ保持され，再起動すると失われる（判定結果はデータベースに保存される）．
+var (
+	rejudgeJobsMu sync.Mutex
+	rejudgeJobs   = make(map[int]*models.RejudgeJob)
+	lastRejudgeID int
+)
+
+// StartRejudgeは，指定された解答の再判定をバックグラウンドで開始し，開始時点の再判定の状態を返す関数である．
+// 各解答は対象の問題の現在の設定とテストケースで判定され，判定結果は以前の判定結果を残したまま新しい試行番号で保存される．
+// 再判定は通常の提出より低い優先度でジャッジサーバーのキューに追加され，再判定前の最新の判定結果と比較して変化が記録される．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+// - job models.RejudgeJob: 要求したユーザーと対象の条件を設定した再判定．再判定IDと進捗はこの関数で設定される．
+// - solutions []models.Solution: 再判定する解答のリスト．
+//
+// 戻り値:
+// - models.RejudgeJob: 開始時点の再判定の状態．
+func StartRejudge(db *sql.DB, job models.RejudgeJob, solutions []models.Solution) models.RejudgeJob {
+	rejudgeJobsMu.Lock()
+	lastRejudgeID++
+	job.RejudgeID = lastRejudgeID
+	job.Status = models.RejudgeStatusRunning
+	job.Total = len(solutions)
+	job.Results = []models.RejudgeResult{}
+	job.StartedAt = time.Now()
+	rejudgeJobs[job.RejudgeID] = &job
+	pruneRejudgeJobs()
+	snapshot := copyRejudgeJob(&job)
+	rejudgeJobsMu.Unlock()
+
+	go runRejudge(db, job.RejudgeID, solutions)
+
+	return snapshot
+}
+
+// GetRejudgeJobは，指定された再判定IDの再判定の現在の状態を取得する関数である．
+//
+// パラメータ:
+// - rejudgeID int: 状態を取得する再判定のID．
+//
+// 戻り値:
+// - models.RejudgeJob: 再判定の現在の状態．
+// - error: 再判定が存在しない（または破棄された）場合のNotFoundError，またはnil．
+func GetRejudgeJob(rejudgeID int) (models.RejudgeJob, error) {
+	rejudgeJobsMu.Lock()
+	defer rejudgeJobsMu.Unlock()
+
+	job, ok := rejudgeJobs[rejudgeID]
+	if !ok {
+		return models.RejudgeJob{}, commonerrors.NewNotFoundError("Rejudge", "RejudgeID", fmt.Sprint(rejudgeID))
+	}
+	return copyRejudgeJob(job), nil
+}
+
+// runRejudgeは，再判定の対象の解答を並行数を制限してジャッジサーバーへ送信し，終了した解答の結果を再判定の状態に記録する．
+func runRejudge(db *sql.DB, rejudgeID int, solutions []models.Solution) {
+	// 同じ問題の解答が多いため，問題の設定は問題ごとに1度だけ取得
+	problems := make(map[int]*models.Problem)
+	problemErrors := make(map[int]error)
+	for _, solution := range solutions {
+		if _, ok := problems[solution.ProblemID]; ok {
+			continue
+		}
+		if _, ok := problemErrors[solution.ProblemID]; ok {
+			continue
+		}
+		problem, err := database.SelectProblemByProblemID(db, solution.ProblemID)
+		if err != nil {
+			problemErrors[solution.ProblemID] = err
+			continue
+		}
+		problems[solution.ProblemID] = problem
+	}
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, rejudgeConcurrency)
+	for _, solution := range solutions {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(solution models.Solution) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			var result models.RejudgeResult
+			if err, ok := problemErrors[solution.ProblemID]; ok {
+				result = models.RejudgeResult{SolutionID: solution.SolutionID, Error: "Failed to get problem: " + err.Error()}
+			} else {
+				result = rejudgeSolution(db, solution, problems[solution.ProblemID])
+			}
+			recordRejudgeResult(rejudgeID, result)
+		}(solution)
+	}
+	wg.Wait()
+
+	rejudgeJobsMu.Lock()
+	if job, ok := rejudgeJobs[rejudgeID]; ok {
+		finishedAt := time.Now()
+		job.Status = models.RejudgeStatusCompleted
+		job.FinishedAt = &finishedAt
+		log.Printf("Rejudge %d completed: %d solutions, %d changed, %d failed", rejudgeID, job.Total, job.Changed, job.Failed)
+	}
+	rejudgeJobsMu.Unlock()
+}
+
+// rejudgeSolutionは，1つの解答をジャッジサーバーで判定して新しい試行番号で保存し，再判定前の最新の判定結果と比較する．
+// 解答の判定状況は，通常の判定と同様に Queued から遷移する．
+func rejudgeSolution(db *sql.DB, solution models.Solution, problem *models.Problem) models.RejudgeResult {
+	result := models.RejudgeResult{SolutionID: solution.SolutionID}
+
+	// 再判定前の最新の判定結果(まだ判定されていない解答の場合は存在しない)
+	previous, err := database.SelectResultDetailBySolutionID(db, solution.SolutionID)
+	var notFound *commonerrors.NotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		result.Error = "Failed to get previous result: " + err.Error()
+		return result
+	}
+	if previous != nil {
+		result.PreviousVerdict = previous.Verdict
+		result.PreviousScore = previous.Score
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rejudgeTimeout)
+	defer cancel()
+	// 判定に失敗した場合は保存されないため，以前の判定結果が最新のまま残る
+	resultDetail, err := judgeSolution(ctx, db, solution, problem, rejudgePriority, nil, nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Attempt = resultDetail.Attempt
+	result.Verdict = resultDetail.Verdict
+	result.Score = resultDetail.Score
+	result.Changed = previous == nil || previous.Verdict != resultDetail.Verdict || previous.Score != resultDetail.Score
+	return result
+}
+
+// recordRejudgeResultは，終了した解答の結果を再判定の状態に追加し，進捗を更新する．
+func recordRejudgeResult(rejudgeID int, result models.RejudgeResult) {
+	rejudgeJobsMu.Lock()
+	defer rejudgeJobsMu.Unlock()
+
+	job, ok := rejudgeJobs[rejudgeID]
+	if !ok {
+		return
+	}
+	job.Results = append(job.Results, result)
+	job.Completed++
+	if result.Error != "" {
+		job.Failed++
+	}
+	if result.Changed {
+		job.Changed++
+	}
+}
+
+// pruneRejudgeJobsは，保持する再判定の数が上限を超えた場合に，終了した古い再判定から破棄する．
+// 呼び出し元は rejudgeJobsMu をロックしている必要がある．
+func pruneRejudgeJobs() {
+	if len(rejudgeJobs) <= maxRejudgeJobs {
+		return
+	}
+	rejudgeIDs := make([]int, 0, len(rejudgeJobs))
+	for rejudgeID := range rejudgeJobs {
+		rejudgeIDs = append(rejudgeIDs, rejudgeID)
+	}
+	sort.Ints(rejudgeIDs)
+	for _, rejudgeID := range rejudgeIDs {
+		if len(rejudgeJobs) <= maxRejudgeJobs {
+			return
+		}
+		if rejudgeJobs[rejudgeID].Status == models.RejudgeStatusCompleted {
+			delete(rejudgeJobs, rejudgeID)
+		}
+	}
+}
+
+// copyRejudgeJobは，並行して更新される再判定の状態を返すため，結果のリストを含めて複製する．
+func copyRejudgeJob(job *models.RejudgeJob) models.RejudgeJob {
+	snapshot := *job
+	snapshot.Results = append([]models.RejudgeResult{}, job.Results...)
+	return snapshot
+}
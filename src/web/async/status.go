@@ -0,0 +1,80 @@
+package async
+
+import (
+	"database/sql"
+	"log"
+	"procon_web_service/src/common/judgeproto"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/web/database"
+)
+
+// statusTrackerは，1回の判定の依頼における解答の判定状況を保持し，変化した場合にのみデータベースに保存する構造体である．
+// 判定状況の遷移はmodels.CanTransitionSubmissionStatusに従い，許可されない遷移は保存せずにログに記録する．
+// データベースでも判定の依頼の一意識別子と遷移元の判定状況を確認して更新するため，新しい判定の依頼が行われた後や判定の終了後の更新は反映されない．
+type statusTracker struct {
+	db      *sql.DB
+	current models.SubmissionStatus
+}
+
+// newStatusTrackerは，データベースに保存されている現在の判定状況から，指定された判定の依頼の判定状況の記録を開始する．
+// 現在の判定状況を取得できない場合は Pending から開始する．
+func newStatusTracker(db *sql.DB, solutionID int, requestID string) *statusTracker {
+	tracker := &statusTracker{db: db, current: models.SubmissionStatus{SolutionID: solutionID, Status: models.SubmissionStatusPending}}
+	if status, err := database.SelectSubmissionStatus(db, solutionID); err == nil {
+		tracker.current = *status
+	}
+	tracker.current.RequestID = requestID
+	return tracker
+}
+
+// updateは，判定状況を遷移させてデータベースに保存する．
+// 判定状況の保存に失敗しても判定は続行するため，エラーはログに記録するのみとする．
+func (t *statusTracker) update(next models.SubmissionStatus) {
+	next.SolutionID = t.current.SolutionID
+	next.RequestID = t.current.RequestID
+	if next.Status == t.current.Status && next.CurrentCase == t.current.CurrentCase && next.TotalCases == t.current.TotalCases &&
+		next.QueuePosition == t.current.QueuePosition && next.Message == t.current.Message {
+		return
+	}
+	if !models.CanTransitionSubmissionStatus(t.current.Status, next.Status) {
+		log.Printf("Ignoring invalid status transition of solution %d: %s -> %s", next.SolutionID, t.current.Status, next.Status)
+		return
+	}
+
+	if err := database.TransitionSubmissionStatus(t.db, next); err != nil {
+		log.Printf("Failed to update status of solution %d: %v", next.SolutionID, err)
+		return
+	}
+	t.current = next
+}
+
+// updateFromQueueは，ジャッジサーバーのキューの状態から判定状況（Queued，Compiling，Running）を更新する．
+// 判定中の段階が含まれない（判定が終了した直後など）場合は更新しない．
+func (t *statusTracker) updateFromQueue(queueStatus models.QueueStatus) {
+	switch {
+	case queueStatus.Queued:
+		t.update(models.SubmissionStatus{Status: models.SubmissionStatusQueued, QueuePosition: queueStatus.Position})
+	case queueStatus.Stage == models.JudgeStageCompiling:
+		t.update(models.SubmissionStatus{Status: models.SubmissionStatusCompiling})
+	case queueStatus.Stage == models.JudgeStageRunning:
+		t.update(models.SubmissionStatus{Status: models.SubmissionStatusRunning, CurrentCase: queueStatus.CurrentCase, TotalCases: queueStatus.TotalCases})
+	}
+}
+
+// updateFromEventは，ジャッジサーバーが通知した判定の進捗のイベントから判定状況（Compiling，Running）を更新する．
+// 判定が終了した後や，判定状況を遷移できないイベント（再試行での判定の開始など）では更新しない．
+func (t *statusTracker) updateFromEvent(event judgeproto.JudgeEvent) {
+	if t.current.Status == models.SubmissionStatusJudged || t.current.Status == models.SubmissionStatusSystemError {
+		return
+	}
+	switch {
+	case event.Accepted != nil:
+		if t.current.Status == models.SubmissionStatusQueued {
+			t.update(models.SubmissionStatus{Status: models.SubmissionStatusCompiling})
+		}
+	case event.Compiled != nil && event.Compiled.Success:
+		t.update(models.SubmissionStatus{Status: models.SubmissionStatusRunning, TotalCases: event.Compiled.TotalCases})
+	case event.CaseFinished != nil:
+		t.update(models.SubmissionStatus{Status: models.SubmissionStatusRunning, CurrentCase: event.CaseFinished.Done, TotalCases: event.CaseFinished.TotalCases})
+	}
+}
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// AdminConfigは，サービス全体を管理する管理者の設定を保持する構造体である．
+// 管理者は，問題の所有者でなくても再判定などの管理操作を行うことができる．
+//
+// フィールド:
+// - UserIDs map[int]bool: 管理者のユーザーIDの集合．
+type AdminConfig struct {
+	UserIDs map[int]bool
+}
+
+// NewAdminConfigは，環境変数 ADMIN_USER_IDS（カンマ区切りのユーザーID）から管理者の設定を読み込み，AdminConfigインスタンスを生成する関数である．
+// 環境変数が設定されていない場合，管理者は存在しない．ユーザーIDとして解釈できない値はログを出力して無視する．
+// 戻り値として，初期化されたAdminConfigのポインタを返す．
+func NewAdminConfig() *AdminConfig {
+	userIDs := make(map[int]bool)
+	for _, value := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		userID, err := strconv.Atoi(value)
+		if err != nil {
+			log.Printf("Ignoring invalid admin user ID: %s", value)
+			continue
+		}
+		userIDs[userID] = true
+	}
+	return &AdminConfig{UserIDs: userIDs}
+}
@@ -2,6 +2,7 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	commonerrors "procon_web_service/src/common/errors"
 	"procon_web_service/src/common/models"
@@ -27,8 +28,25 @@ import (
 func CreateProblemWithTx(tx *sql.Tx, problem models.Problem) (int, error) {
 	var lastInsertId int64
 
-	query := `INSERT INTO Problems (UserID, Title, Description, Difficulty) VALUES (?, ?, ?, ?)`
-	result, execErr := tx.Exec(query, problem.UserID, problem.Title, problem.Description, problem.Difficulty)
+	languageMultipliers, execErr := marshalLanguageMultipliers(problem.LanguageMultipliers)
+	if execErr != nil {
+		return 0, execErr
+	}
+	subtasks, execErr := marshalSubtasks(problem.Subtasks)
+	if execErr != nil {
+		return 0, execErr
+	}
+	sampleCases, execErr := marshalCaseNames(problem.SampleCases)
+	if execErr != nil {
+		return 0, execErr
+	}
+	revealedCases, execErr := marshalCaseNames(problem.RevealedCases)
+	if execErr != nil {
+		return 0, execErr
+	}
+
+	query := `INSERT INTO Problems (UserID, Title, Description, Difficulty, ProblemType, TimeLimit, MemoryLimit, LanguageMultipliers, CheckerLanguageID, CompareMode, FloatEpsilon, InteractorLanguageID, JudgeMode, Subtasks, SampleCases, RevealedCases, StderrVisibility, TestSetVersion) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, execErr := tx.Exec(query, problem.UserID, problem.Title, problem.Description, problem.Difficulty, problem.ProblemType, problem.TimeLimit, problem.MemoryLimit, languageMultipliers, problem.CheckerLanguageID, problem.CompareMode, problem.FloatEpsilon, problem.InteractorLanguageID, problem.JudgeMode, subtasks, sampleCases, revealedCases, problem.StderrVisibility, problem.TestSetVersion)
 	if execErr != nil {
 		return 0, execErr // 直接エラーを返す
 	}
@@ -42,7 +60,7 @@ func CreateProblemWithTx(tx *sql.Tx, problem models.Problem) (int, error) {
 
 // UpdateProblemは，指定されたIDの問題を更新する．
 //
-// この関数はデータベーストランザクションを用いて，問題の基本情報（Title, Description, Difficulty）の更新をアトミックに行うことを保証する．
+// この関数はデータベーストランザクションを用いて，問題の基本情報（Title, Description, Difficulty）と実行制限（TimeLimit, MemoryLimit, LanguageMultipliers），問題の種類と出力の判定方法（ProblemType, CheckerLanguageID, CompareMode, FloatEpsilon, InteractorLanguageID, JudgeMode），サブタスク（Subtasks），サンプルケースと判定結果で公開する詳細の設定（SampleCases, RevealedCases, StderrVisibility），テストデータの版（TestSetVersion）の更新をアトミックに行うことを保証する．
 //
 // パラメータ:
 // - db *sql.DB: データベース接続へのポインタである．
@@ -55,8 +73,25 @@ func CreateProblemWithTx(tx *sql.Tx, problem models.Problem) (int, error) {
 // トランザクションを用いることで，更新プロセス中にエラーが発生した場合には，変更がロールバックされ，データベースの整合性を保つ．
 func UpdateProblem(db *sql.DB, problemID int, problem models.Problem) error {
 	err := WithTransaction(db, func(tx *sql.Tx) error {
-		query := `UPDATE Problems SET Title = ?, Description = ?, Difficulty = ? WHERE ProblemID = ?`
-		if _, err := tx.Exec(query, problem.Title, problem.Description, problem.Difficulty, problemID); err != nil {
+		languageMultipliers, err := marshalLanguageMultipliers(problem.LanguageMultipliers)
+		if err != nil {
+			return err
+		}
+		subtasks, err := marshalSubtasks(problem.Subtasks)
+		if err != nil {
+			return err
+		}
+		sampleCases, err := marshalCaseNames(problem.SampleCases)
+		if err != nil {
+			return err
+		}
+		revealedCases, err := marshalCaseNames(problem.RevealedCases)
+		if err != nil {
+			return err
+		}
+
+		query := `UPDATE Problems SET Title = ?, Description = ?, Difficulty = ?, ProblemType = ?, TimeLimit = ?, MemoryLimit = ?, LanguageMultipliers = ?, CheckerLanguageID = ?, CompareMode = ?, FloatEpsilon = ?, InteractorLanguageID = ?, JudgeMode = ?, Subtasks = ?, SampleCases = ?, RevealedCases = ?, StderrVisibility = ?, TestSetVersion = ? WHERE ProblemID = ?`
+		if _, err := tx.Exec(query, problem.Title, problem.Description, problem.Difficulty, problem.ProblemType, problem.TimeLimit, problem.MemoryLimit, languageMultipliers, problem.CheckerLanguageID, problem.CompareMode, problem.FloatEpsilon, problem.InteractorLanguageID, problem.JudgeMode, subtasks, sampleCases, revealedCases, problem.StderrVisibility, problem.TestSetVersion, problemID); err != nil {
 			return err
 		}
 		return nil
@@ -70,6 +105,56 @@ func UpdateProblem(db *sql.DB, problemID int, problem models.Problem) error {
 	return nil
 }
 
+// SelectProblemIDsByTestSetVersionは，テストデータの版が指定された値である問題のIDの一覧をデータベースから取得する．
+// テストデータの版を導入する前に作成された問題（版が0の問題）を移行するために用いる．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタである．
+// - version int: テストデータの版である．
+//
+// 戻り値:
+// - []int: 問題IDのスライス．
+// - error: データベース操作中にエラーが発生した場合の詳細．成功時はnil．
+func SelectProblemIDsByTestSetVersion(db *sql.DB, version int) ([]int, error) {
+	rows, err := db.Query("SELECT ProblemID FROM Problems WHERE TestSetVersion = ? ORDER BY ProblemID", version)
+	if err != nil {
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+	defer rows.Close()
+
+	var problemIDs []int
+	for rows.Next() {
+		var problemID int
+		if err := rows.Scan(&problemID); err != nil {
+			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
+		}
+		problemIDs = append(problemIDs, problemID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
+	}
+
+	return problemIDs, nil
+}
+
+// UpdateProblemTestSetVersionは，指定された問題のテストデータの版が from である場合に限り，版を to に更新する．
+// 移行中に問題が更新され，新しい版がアップロードされた場合に，その版を移行した版で上書きしないために版を条件とする．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタである．
+// - problemID int: 更新対象の問題IDである．
+// - from int: 更新前のテストデータの版である．
+// - to int: 更新後のテストデータの版である．
+//
+// 戻り値:
+// - error: 更新操作に失敗した場合のエラー，または操作が成功した場合（版が from でなく更新しなかった場合を含む）はnil．
+func UpdateProblemTestSetVersion(db *sql.DB, problemID, from, to int) error {
+	if _, err := db.Exec("UPDATE Problems SET TestSetVersion = ? WHERE ProblemID = ? AND TestSetVersion = ?", to, problemID, from); err != nil {
+		return commonerrors.WrapDBError("UPDATE", err)
+	}
+	return nil
+}
+
 // DeleteProblemは，指定された問題IDに関連する問題およびそれに紐付く全てのデータをデータベースから削除する．
 // この処理には，問題自身のレコードの削除の他に，解答，テストケース結果など，問題に関連するデータの削除も含まれる．
 // データベーストランザクションを使用して，削除操作がアトミックに行われることを保証する．
@@ -90,10 +175,18 @@ func DeleteProblem(db *sql.DB, problemID int) error {
 			return err
 		}
 
+		if _, err := tx.Exec("DELETE FROM SubtaskResults WHERE SolutionID IN (SELECT SolutionID FROM Solutions WHERE ProblemID = ?)", problemID); err != nil {
+			return err
+		}
+
 		if _, err := tx.Exec("DELETE FROM ResultDetails WHERE SolutionID IN (SELECT SolutionID FROM Solutions WHERE ProblemID = ?)", problemID); err != nil {
 			return err
 		}
 
+		if _, err := tx.Exec("DELETE FROM SubmissionStatuses WHERE SolutionID IN (SELECT SolutionID FROM Solutions WHERE ProblemID = ?)", problemID); err != nil {
+			return err
+		}
+
 		if _, err := tx.Exec("DELETE FROM Solutions WHERE ProblemID = ?", problemID); err != nil {
 			return err
 		}
@@ -113,7 +206,7 @@ func DeleteProblem(db *sql.DB, problemID int) error {
 }
 
 // SelectProblemは，登録されている全問題のリストをデータベースから取得する．
-// 各問題には，問題ID，ユーザーID，タイトル，説明文，難易度，作成日時，更新日時が含まれる．
+// 各問題には，問題ID，ユーザーID，タイトル，説明文，難易度，実行制限，作成日時，更新日時が含まれる．
 //
 // パラメータ:
 // - db *sql.DB: データベース接続へのポインタである．
@@ -125,7 +218,7 @@ func SelectProblem(db *sql.DB) ([]models.Problem, error) {
 	problems := []models.Problem{}
 
 	// 問題の取得
-	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, CreatedAt, UpdatedAt FROM Problems`
+	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, ProblemType, TimeLimit, MemoryLimit, LanguageMultipliers, CheckerLanguageID, CompareMode, FloatEpsilon, InteractorLanguageID, JudgeMode, Subtasks, SampleCases, RevealedCases, StderrVisibility, TestSetVersion, CreatedAt, UpdatedAt FROM Problems`
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, commonerrors.WrapDBError("SELECT", err)
@@ -135,7 +228,7 @@ func SelectProblem(db *sql.DB) ([]models.Problem, error) {
 	problemMap := make(map[int]*models.Problem)
 	for rows.Next() {
 		var problem models.Problem
-		if err := rows.Scan(&problem.ProblemID, &problem.UserID, &problem.Title, &problem.Description, &problem.Difficulty, &problem.CreatedAt, &problem.UpdatedAt); err != nil {
+		if err := scanProblem(rows, &problem); err != nil {
 			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
 		}
 		problemMap[problem.ProblemID] = &problem
@@ -159,7 +252,7 @@ func SelectProblemByUserID(db *sql.DB, userID int) ([]*models.Problem, error) {
 	problems := []*models.Problem{}
 
 	// 問題の取得
-	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, CreatedAt, UpdatedAt FROM Problems WHERE UserID = ?`
+	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, ProblemType, TimeLimit, MemoryLimit, LanguageMultipliers, CheckerLanguageID, CompareMode, FloatEpsilon, InteractorLanguageID, JudgeMode, Subtasks, SampleCases, RevealedCases, StderrVisibility, TestSetVersion, CreatedAt, UpdatedAt FROM Problems WHERE UserID = ?`
 	rows, err := db.Query(query, userID)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -172,7 +265,7 @@ func SelectProblemByUserID(db *sql.DB, userID int) ([]*models.Problem, error) {
 	problemMap := make(map[int]*models.Problem)
 	for rows.Next() {
 		var problem models.Problem
-		if err := rows.Scan(&problem.ProblemID, &problem.UserID, &problem.Title, &problem.Description, &problem.Difficulty, &problem.CreatedAt, &problem.UpdatedAt); err != nil {
+		if err := scanProblem(rows, &problem); err != nil {
 			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
 		}
 		problemPtr := &problem
@@ -184,7 +277,7 @@ func SelectProblemByUserID(db *sql.DB, userID int) ([]*models.Problem, error) {
 }
 
 // SelectProblemByProblemIDは，指定された問題IDに基づき，特定の問題の詳細情報をデータベースから取得する．
-// 問題IDを指定して，その問題のID，ユーザーID，タイトル，説明，難易度，作成日時，更新日時を取得する．
+// 問題IDを指定して，その問題のID，ユーザーID，タイトル，説明，難易度，実行制限，作成日時，更新日時を取得する．
 //
 // パラメータ:
 // - db *sql.DB: データベース接続へのポインタである．
@@ -197,8 +290,8 @@ func SelectProblemByProblemID(db *sql.DB, problemID int) (*models.Problem, error
 	var problem models.Problem
 
 	// 問題の取得
-	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, CreatedAt, UpdatedAt FROM Problems WHERE ProblemID = ?`
-	if err := db.QueryRow(query, problemID).Scan(&problem.ProblemID, &problem.UserID, &problem.Title, &problem.Description, &problem.Difficulty, &problem.CreatedAt, &problem.UpdatedAt); err != nil {
+	query := `SELECT ProblemID, UserID, Title, Description, Difficulty, ProblemType, TimeLimit, MemoryLimit, LanguageMultipliers, CheckerLanguageID, CompareMode, FloatEpsilon, InteractorLanguageID, JudgeMode, Subtasks, SampleCases, RevealedCases, StderrVisibility, TestSetVersion, CreatedAt, UpdatedAt FROM Problems WHERE ProblemID = ?`
+	if err := scanProblem(db.QueryRow(query, problemID), &problem); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// 問題が見つからないエラーを生成
 			return nil, commonerrors.NewNotFoundError("Problem", "ProblemID", strconv.Itoa(problemID))
@@ -231,6 +324,84 @@ func IsProblemOwner(db *sql.DB, userID int, problemID int) error {
 		}
 		return commonerrors.WrapDBError("SELECT", err)
 	}
+	if !exists {
+		return commonerrors.NewAccessDeniedError("You do not have permission to access this resource")
+	}
+
+	return nil
+}
+
+// rowScannerは，*sql.Rowと*sql.Rowsに共通するScanメソッドを表すインターフェースである．
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanProblemは，Problemsテーブルの1行をmodels.Problem構造体に読み込む．
+// JSON形式で保存されている言語ごとの実行時間倍率，サブタスク，サンプルケースと詳細を公開するテストケースはデコードして格納する．
+func scanProblem(scanner rowScanner, problem *models.Problem) error {
+	var languageMultipliers, subtasks, sampleCases, revealedCases sql.NullString
+	if err := scanner.Scan(&problem.ProblemID, &problem.UserID, &problem.Title, &problem.Description, &problem.Difficulty, &problem.ProblemType, &problem.TimeLimit, &problem.MemoryLimit, &languageMultipliers, &problem.CheckerLanguageID, &problem.CompareMode, &problem.FloatEpsilon, &problem.InteractorLanguageID, &problem.JudgeMode, &subtasks,
+		&sampleCases, &revealedCases, &problem.StderrVisibility, &problem.TestSetVersion, &problem.CreatedAt, &problem.UpdatedAt); err != nil {
+		return err
+	}
+	if languageMultipliers.Valid && languageMultipliers.String != "" {
+		if err := json.Unmarshal([]byte(languageMultipliers.String), &problem.LanguageMultipliers); err != nil {
+			return err
+		}
+	}
+	if subtasks.Valid && subtasks.String != "" {
+		if err := json.Unmarshal([]byte(subtasks.String), &problem.Subtasks); err != nil {
+			return err
+		}
+	}
+	if sampleCases.Valid && sampleCases.String != "" {
+		if err := json.Unmarshal([]byte(sampleCases.String), &problem.SampleCases); err != nil {
+			return err
+		}
+	}
+	if revealedCases.Valid && revealedCases.String != "" {
+		if err := json.Unmarshal([]byte(revealedCases.String), &problem.RevealedCases); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// marshalLanguageMultipliersは，言語ごとの実行時間倍率をデータベースに保存するためのJSON文字列に変換する．
+// 倍率が設定されていない場合はNULLとして保存する．
+func marshalLanguageMultipliers(languageMultipliers map[int]float64) (interface{}, error) {
+	if len(languageMultipliers) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(languageMultipliers)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// marshalSubtasksは，サブタスクの設定をデータベースに保存するためのJSON文字列に変換する．
+// サブタスクが設定されていない場合はNULLとして保存する．
+func marshalSubtasks(subtasks []models.Subtask) (interface{}, error) {
+	if len(subtasks) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(subtasks)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// marshalCaseNamesは，サンプルケースや詳細を公開するテストケースの名前をデータベースに保存するためのJSON文字列に変換する．
+// テストケースが設定されていない場合はNULLとして保存する．
+func marshalCaseNames(caseNames []string) (interface{}, error) {
+	if len(caseNames) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(caseNames)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
@@ -10,14 +10,18 @@ import (
 
 // CreateResultDetailは，ジャッジ結果をデータベースに保存する関数である．
 // この関数は，解答IDとジャッジ結果の詳細を含むmodels.ResultDetail構造体を引数に取り，データベースに保存する．
-// ジャッジ結果の詳細には，総テストケース数，正解数，不正解数，タイムリミット超過数，エラーメッセージが含まれる．
-// また，各テストケースの結果もCaseResultsテーブルに保存される．
+// ジャッジ結果の詳細には，解答全体の判定結果，総テストケース数，判定結果ごとのテストケース数，コンパイラの出力，エラーメッセージ，判定に用いたテストデータの版が含まれる．
+// また，各テストケースの結果(実行時間，CPU時間，ピークメモリ使用量，終了コード，シグナル，チェッカーのメッセージと部分点，公開されている場合は標準エラー出力と出力を含む)もCaseResultsテーブルに，
+// 問題にサブタスクが設定されている場合は各サブタスクの採点結果もSubtaskResultsテーブルに保存される．
+// 判定結果は試行番号とともに保存され，再判定の場合は以前の判定結果を残したまま新しい試行番号（直前の試行番号+1）で保存される．
+// 保存した試行番号と判定日時は resultDetail.Attempt と resultDetail.JudgedAt に設定される．
+// resultDetail.RequestID が設定されており，同じ判定の依頼の判定結果が既に保存されている場合（判定キューから判定結果が再配信された場合）は保存せず，保存済みの試行番号と判定日時を設定する．
 // この操作はデータベーストランザクション内で行われ，トランザクションが正常に完了しなかった場合はエラーが返される．
 //
 // パラメータ:
 // - db *sql.DB: データベース接続へのポインタである．
 // - solutionID int: ジャッジ結果が関連する解答のIDである．
-// - resultDetail *models.ResultDetail: 保存するジャッジ結果の詳細である．
+// - resultDetail *models.ResultDetail: 保存するジャッジ結果の詳細である．試行番号と判定日時が設定される．
 //
 // 戻り値:
 // - error: ジャッジ結果の保存に失敗した場合のエラー，または操作が成功した場合はnilである．
@@ -25,20 +29,49 @@ import (
 // トランザクションを用いることで，更新プロセス中にエラーが発生した場合には，変更がロールバックされ，データベースの整合性を保つ．
 func CreateResultDetail(db *sql.DB, solutionID int, resultDetail *models.ResultDetail) error {
 	err := WithTransaction(db, func(tx *sql.Tx) error {
-		query := `INSERT INTO ResultDetails (SolutionID, TotalCases, CorrectCases, IncorrectCases, TimeLimitExceeded, ErrorMessage) VALUES (?, ?, ?, ?, ?, ?)`
-		_, err := tx.Exec(query, solutionID, resultDetail.TotalCases, resultDetail.CorrectCases, resultDetail.IncorrectCases, resultDetail.TimeLimitExceeded, resultDetail.ErrorMessage)
+		// 同じ解答の判定結果の保存が並行しても試行番号が重複しないよう，既存の判定結果をロックして次の試行番号を決定
+		var attempt int
+		if err := tx.QueryRow("SELECT COALESCE(MAX(Attempt), 0) + 1 FROM ResultDetails WHERE SolutionID = ? FOR UPDATE", solutionID).Scan(&attempt); err != nil {
+			return err
+		}
+
+		// 同じ判定の依頼の判定結果が保存済みの場合は，保存済みの判定結果を用いる
+		if resultDetail.RequestID != "" {
+			err := tx.QueryRow("SELECT Attempt, JudgedAt FROM ResultDetails WHERE SolutionID = ? AND RequestID = ?", solutionID, resultDetail.RequestID).Scan(&resultDetail.Attempt, &resultDetail.JudgedAt)
+			if err == nil {
+				return nil
+			}
+			if !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		query := `INSERT INTO ResultDetails (SolutionID, Attempt, RequestID, Verdict, TotalCases, CorrectCases, IncorrectCases, TimeLimitExceeded, MemoryLimitExceeded, OutputLimitExceeded, RuntimeError, CompileError, InternalError, SkippedCases, Score, MaxScore, CompileOutput, ErrorMessage, TestSetVersion) VALUES (?, ?, NULLIF(?, ''), ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		_, err := tx.Exec(query, solutionID, attempt, resultDetail.RequestID, resultDetail.Verdict, resultDetail.TotalCases, resultDetail.CorrectCases, resultDetail.IncorrectCases, resultDetail.TimeLimitExceeded,
+			resultDetail.MemoryLimitExceeded, resultDetail.OutputLimitExceeded, resultDetail.RuntimeError, resultDetail.CompileError, resultDetail.InternalError, resultDetail.SkippedCases, resultDetail.Score, resultDetail.MaxScore, resultDetail.CompileOutput, resultDetail.ErrorMessage, resultDetail.TestSetVersion)
 		if err != nil {
 			return err
 		}
 
-		for _, caseResult := range resultDetail.CaseResults {
-			query = `INSERT INTO CaseResults (SolutionID, CaseName, Result, ExecutionTime) VALUES (?, ?, ?, ?)`
-			_, err = tx.Exec(query, solutionID, caseResult.CaseName, caseResult.Result, caseResult.ExecutionTime)
+		for index, caseResult := range resultDetail.CaseResults {
+			query = `INSERT INTO CaseResults (SolutionID, Attempt, CaseIndex, CaseName, Result, ExecutionTime, CPUTime, PeakMemory, ExitCode, SignalName, CheckerMessage, Score, Stderr, Stdout, ExpectedOutput) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+			_, err = tx.Exec(query, solutionID, attempt, index, caseResult.CaseName, caseResult.Result, caseResult.ExecutionTime, caseResult.CPUTime, caseResult.PeakMemory, caseResult.ExitCode, caseResult.Signal, caseResult.CheckerMessage, caseResult.Score,
+				caseResult.Stderr, caseResult.Stdout, caseResult.ExpectedOutput)
 			if err != nil {
 				return err
 			}
 		}
-		return nil
+
+		for index, subtaskResult := range resultDetail.SubtaskResults {
+			query = `INSERT INTO SubtaskResults (SolutionID, Attempt, SubtaskIndex, Name, Scoring, Verdict, Score, MaxScore) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			_, err = tx.Exec(query, solutionID, attempt, index, subtaskResult.Name, subtaskResult.Scoring, subtaskResult.Verdict, subtaskResult.Score, subtaskResult.MaxScore)
+			if err != nil {
+				return err
+			}
+		}
+
+		resultDetail.Attempt = attempt
+		return tx.QueryRow("SELECT JudgedAt FROM ResultDetails WHERE SolutionID = ? AND Attempt = ?", solutionID, attempt).Scan(&resultDetail.JudgedAt)
 	})
 
 	// トランザクションエラー
@@ -49,10 +82,10 @@ func CreateResultDetail(db *sql.DB, solutionID int, resultDetail *models.ResultD
 	return nil
 }
 
-// SelectResultDetailBySolutionIDは，特定の解答IDに対する判定結果を取得する関数である．
-// この関数は，指定された解答IDに対応するResultDetailsテーブルからジャッジ結果の詳細を取得する．
-// 取得したジャッジ結果には，総テストケース数，正解数，不正解数，タイムリミット超過数，エラーメッセージが含まれる．
-// さらに，関連する各テストケースの結果もCaseResultsテーブルから取得され，models.ResultDetail構造体に格納される．
+// SelectResultDetailBySolutionIDは，特定の解答IDに対する最新の判定結果を取得する関数である．
+// この関数は，指定された解答IDに対応するResultDetailsテーブルから，試行番号が最大のジャッジ結果の詳細を取得する．
+// 取得したジャッジ結果には，解答全体の判定結果，総テストケース数，判定結果ごとのテストケース数，得点，エラーメッセージ，試行番号と判定日時が含まれる．
+// さらに，関連する各テストケースの結果(実行順，公開されている標準エラー出力と出力を含む)とサブタスクの採点結果もCaseResultsテーブルとSubtaskResultsテーブルから取得され，models.ResultDetail構造体に格納される．
 // 解答の詳細がデータベースに存在しない場合，NotFoundErrorが返される．
 // この関数はデータベースからの情報の取得に失敗した場合にエラーを返す．
 //
@@ -61,13 +94,92 @@ func CreateResultDetail(db *sql.DB, solutionID int, resultDetail *models.ResultD
 // - solutionID int: 判定結果を取得したい解答のIDである．
 //
 // 戻り値:
-// - *models.ResultDetail: 特定の解答IDに対する判定結果の詳細である．
+// - *models.ResultDetail: 特定の解答IDに対する最新の判定結果の詳細である．
 // - error: 判定結果の取得に失敗した場合のエラー，または操作が成功した場合はnilである．
 func SelectResultDetailBySolutionID(db *sql.DB, solutionID int) (*models.ResultDetail, error) {
+	var attempt int
+	if err := db.QueryRow("SELECT COALESCE(MAX(Attempt), 0) FROM ResultDetails WHERE SolutionID = ?", solutionID).Scan(&attempt); err != nil {
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+	if attempt == 0 {
+		// 解答の詳細が見つからないエラーを生成
+		return nil, commonerrors.NewNotFoundError("ResultDetails", "SolutionID", strconv.Itoa(solutionID))
+	}
+
+	return selectResultDetail(db, solutionID, attempt)
+}
+
+// SelectResultDetailByRequestIDは，特定の解答IDと判定の依頼の一意識別子に対する判定結果を取得する関数である．
+// 判定キューを通じて依頼した判定の結果を，他の依頼（再判定など）の判定結果と区別して取得するために用いる．
+// 判定結果が存在しない場合，NotFoundErrorが返される．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタである．
+// - solutionID int: 判定結果を取得したい解答のIDである．
+// - requestID string: 判定の依頼の一意識別子である．
+//
+// 戻り値:
+// - *models.ResultDetail: 判定の依頼に対する判定結果の詳細である．
+// - error: 判定結果の取得に失敗した場合のエラー，または操作が成功した場合はnilである．
+func SelectResultDetailByRequestID(db *sql.DB, solutionID int, requestID string) (*models.ResultDetail, error) {
+	var attempt int
+	err := db.QueryRow("SELECT Attempt FROM ResultDetails WHERE SolutionID = ? AND RequestID = ?", solutionID, requestID).Scan(&attempt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// 判定結果が見つからないエラーを生成
+			return nil, commonerrors.NewNotFoundError("ResultDetails", "RequestID", requestID)
+		}
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+
+	return selectResultDetail(db, solutionID, attempt)
+}
+
+// SelectResultHistoryBySolutionIDは，特定の解答IDに対する全ての試行の判定結果を，試行番号の昇順で取得する関数である．
+// 再判定が行われた解答では，以前の判定結果も含めて取得される．判定結果が存在しない場合は空のリストを返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタである．
+// - solutionID int: 判定結果を取得したい解答のIDである．
+//
+// 戻り値:
+// - []models.ResultDetail: 試行番号の昇順に並んだ判定結果の詳細のリストである．
+// - error: 判定結果の取得に失敗した場合のエラー，または操作が成功した場合はnilである．
+func SelectResultHistoryBySolutionID(db *sql.DB, solutionID int) ([]models.ResultDetail, error) {
+	rows, err := db.Query("SELECT Attempt FROM ResultDetails WHERE SolutionID = ? ORDER BY Attempt", solutionID)
+	if err != nil {
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+	var attempts []int
+	for rows.Next() {
+		var attempt int
+		if err := rows.Scan(&attempt); err != nil {
+			rows.Close()
+			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
+		}
+		attempts = append(attempts, attempt)
+	}
+	rows.Close()
+
+	history := []models.ResultDetail{}
+	for _, attempt := range attempts {
+		resultDetail, err := selectResultDetail(db, solutionID, attempt)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, *resultDetail)
+	}
+	return history, nil
+}
+
+// selectResultDetailは，解答IDと試行番号を指定して1つの判定結果を，テストケースとサブタスクの結果とともに取得する．
+func selectResultDetail(db *sql.DB, solutionID, attempt int) (*models.ResultDetail, error) {
 	var resultDetail models.ResultDetail
 	var caseResults []models.CaseResult
 
-	err := db.QueryRow("SELECT TotalCases, CorrectCases, IncorrectCases, TimeLimitExceeded, ErrorMessage FROM ResultDetails WHERE SolutionID = ?", solutionID).Scan(&resultDetail.TotalCases, &resultDetail.CorrectCases, &resultDetail.IncorrectCases, &resultDetail.TimeLimitExceeded, &resultDetail.ErrorMessage)
+	err := db.QueryRow("SELECT Attempt, COALESCE(RequestID, ''), Verdict, TotalCases, CorrectCases, IncorrectCases, TimeLimitExceeded, MemoryLimitExceeded, OutputLimitExceeded, RuntimeError, CompileError, InternalError, SkippedCases, Score, MaxScore, COALESCE(CompileOutput, ''), ErrorMessage, TestSetVersion, JudgedAt FROM ResultDetails WHERE SolutionID = ? AND Attempt = ?", solutionID, attempt).Scan(
+		&resultDetail.Attempt, &resultDetail.RequestID, &resultDetail.Verdict, &resultDetail.TotalCases, &resultDetail.CorrectCases, &resultDetail.IncorrectCases, &resultDetail.TimeLimitExceeded,
+		&resultDetail.MemoryLimitExceeded, &resultDetail.OutputLimitExceeded, &resultDetail.RuntimeError, &resultDetail.CompileError, &resultDetail.InternalError, &resultDetail.SkippedCases, &resultDetail.Score, &resultDetail.MaxScore, &resultDetail.CompileOutput, &resultDetail.ErrorMessage, &resultDetail.TestSetVersion, &resultDetail.JudgedAt)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			// 解答の詳細が見つからないエラーを生成
@@ -76,7 +188,7 @@ func SelectResultDetailBySolutionID(db *sql.DB, solutionID int) (*models.ResultD
 		return nil, commonerrors.WrapDBError("SELECT", err)
 	}
 
-	rows, err := db.Query("SELECT CaseName, Result, ExecutionTime FROM CaseResults WHERE SolutionID = ?", solutionID)
+	rows, err := db.Query("SELECT CaseName, Result, ExecutionTime, CPUTime, PeakMemory, ExitCode, COALESCE(SignalName, ''), COALESCE(CheckerMessage, ''), Score, COALESCE(Stderr, ''), COALESCE(Stdout, ''), COALESCE(ExpectedOutput, '') FROM CaseResults WHERE SolutionID = ? AND Attempt = ? ORDER BY CaseIndex", solutionID, attempt)
 	if errors.Is(err, sql.ErrNoRows) {
 		return &resultDetail, nil
 	} else if err != nil {
@@ -86,12 +198,27 @@ func SelectResultDetailBySolutionID(db *sql.DB, solutionID int) (*models.ResultD
 
 	for rows.Next() {
 		var caseResult models.CaseResult
-		if err := rows.Scan(&caseResult.CaseName, &caseResult.Result, &caseResult.ExecutionTime); err != nil {
+		if err := rows.Scan(&caseResult.CaseName, &caseResult.Result, &caseResult.ExecutionTime, &caseResult.CPUTime, &caseResult.PeakMemory, &caseResult.ExitCode, &caseResult.Signal, &caseResult.CheckerMessage, &caseResult.Score,
+			&caseResult.Stderr, &caseResult.Stdout, &caseResult.ExpectedOutput); err != nil {
 			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
 		}
 		caseResults = append(caseResults, caseResult)
 	}
 	resultDetail.CaseResults = caseResults
 
+	subtaskRows, err := db.Query("SELECT Name, Scoring, Verdict, Score, MaxScore FROM SubtaskResults WHERE SolutionID = ? AND Attempt = ? ORDER BY SubtaskIndex", solutionID, attempt)
+	if err != nil {
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+	defer subtaskRows.Close()
+
+	for subtaskRows.Next() {
+		var subtaskResult models.SubtaskResult
+		if err := subtaskRows.Scan(&subtaskResult.Name, &subtaskResult.Scoring, &subtaskResult.Verdict, &subtaskResult.Score, &subtaskResult.MaxScore); err != nil {
+			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
+		}
+		resultDetail.SubtaskResults = append(resultDetail.SubtaskResults, subtaskResult)
+	}
+
 	return &resultDetail, nil
 }
@@ -6,10 +6,12 @@ import (
 	commonerrors "procon_web_service/src/common/errors"
 	"procon_web_service/src/common/models"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // CreateSolutionは，新しい解答をデータベースに保存する関数である．
-// 引数として提供されたmodels.Solution構造体に基づき，解答情報をSolutionsテーブルに挿入する．
+// 引数として提供されたmodels.Solution構造体に基づき，解答情報をSolutionsテーブルに挿入し，判定状況を Pending としてSubmissionStatusesテーブルに挿入する．
 // 挿入操作はデータベーストランザクション内で行われ，挿入が成功すれば新しく生成された解答のIDを返し，失敗した場合はエラーを返す．
 //
 // パラメータ:
@@ -31,6 +33,12 @@ func CreateSolution(db *sql.DB, solution models.Solution) (int, error) {
 			return execErr
 		}
 		lastInsertId, execErr = result.LastInsertId() // 挿入された行のIDを取得
+		if execErr != nil {
+			return execErr
+		}
+
+		// 判定状況は判定の依頼を受けるまで Pending とする
+		_, execErr = tx.Exec(`INSERT INTO SubmissionStatuses (SolutionID, Status) VALUES (?, ?)`, lastInsertId, models.SubmissionStatusPending)
 		return execErr
 	})
 
@@ -134,8 +142,143 @@ func SelectSolutionBySolutionID(db *sql.DB, solutionID int) (*models.Solution, e
 			// 解答が見つからないエラーを生成
 			return nil, commonerrors.NewNotFoundError("Solution", "SolutionID", strconv.Itoa(solutionID))
 		}
-		commonerrors.WrapDBError("SELECT", err)
+		return nil, commonerrors.WrapDBError("SELECT", err)
 	}
 
 	return &solution, nil
 }
+
+// SelectSolutionsForRejudgeは，再判定の対象となる解答を提出日時の順に取得する関数である．
+// 問題IDが0でない場合はその問題の解答に，提出日時の範囲が指定されている場合はその範囲（from以上to未満）に提出された解答に限定する．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+// - problemID int: 対象とする問題のID．0の場合は全ての問題の解答が対象となる．
+// - from *time.Time: 対象とする提出日時の開始（この日時を含む）．nilの場合は制限しない．
+// - to *time.Time: 対象とする提出日時の終了（この日時を含まない）．nilの場合は制限しない．
+//
+// 戻り値:
+// - []models.Solution: 再判定の対象となる解答のリスト．
+// - error: 操作が失敗した場合のエラー，またはnil．
+func SelectSolutionsForRejudge(db *sql.DB, problemID int, from, to *time.Time) ([]models.Solution, error) {
+	solutions := []models.Solution{}
+
+	var conditions []string
+	var args []interface{}
+	if problemID != 0 {
+		conditions = append(conditions, "ProblemID = ?")
+		args = append(args, problemID)
+	}
+	if from != nil {
+		conditions = append(conditions, "SubmittedAt >= ?")
+		args = append(args, *from)
+	}
+	if to != nil {
+		conditions = append(conditions, "SubmittedAt < ?")
+		args = append(args, *to)
+	}
+
+	query := `SELECT SolutionID, UserID, ProblemID, LanguageID, Code, SubmittedAt FROM Solutions`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY SubmittedAt, SolutionID"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var solution models.Solution
+		if err := rows.Scan(&solution.SolutionID, &solution.UserID, &solution.ProblemID, &solution.LanguageID, &solution.Code, &solution.SubmittedAt); err != nil {
+			return nil, commonerrors.WrapDBError("ITERATING SELECTED SQL ROWS", err)
+		}
+		solutions = append(solutions, solution)
+	}
+
+	return solutions, nil
+}
+
+// QueueSubmissionは，解答の新しい判定の依頼を記録し，判定状況を Queued とする関数である．
+// 判定の依頼ごとに判定状況は Queued から始まり，テストケースの数やメッセージはリセットされる．
+// 以降は同じ一意識別子を指定したTransitionSubmissionStatusによる更新のみが反映される．
+// 判定状況の行が存在しない解答（判定状況の導入前に提出された解答）の場合は，新しく行を作成する．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+// - solutionID int: 判定を依頼する解答のID．
+// - requestID string: 判定の依頼の一意識別子．
+//
+// 戻り値:
+// - error: 操作が失敗した場合のエラー，またはnil．
+func QueueSubmission(db *sql.DB, solutionID int, requestID string) error {
+	query := `INSERT INTO SubmissionStatuses (SolutionID, Status, RequestID) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE Status = VALUES(Status), CurrentCase = 0, TotalCases = 0, QueuePosition = 0, Message = NULL, RequestID = VALUES(RequestID)`
+	if _, err := db.Exec(query, solutionID, models.SubmissionStatusQueued, requestID); err != nil {
+		return commonerrors.WrapDBError("INSERT", err)
+	}
+	return nil
+}
+
+// TransitionSubmissionStatusは，解答の判定状況を遷移させてデータベースに保存する関数である．
+// status.RequestID が現在の判定の依頼と一致し，現在の判定状況から status.Status へ遷移できる場合にのみ更新する．
+// 条件を満たさない場合（新しい判定の依頼が既に行われた場合や，判定が既に終了した場合）は何もしない．
+// 条件の確認と更新は1つのクエリで行うため，複数のサーバーが同じ解答の判定状況を並行して更新しても判定状況が逆戻りすることはない．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+// - status models.SubmissionStatus: 保存する判定状況．UpdatedAtとResultは無視される．
+//
+// 戻り値:
+// - error: 操作が失敗した場合のエラー，またはnil．
+func TransitionSubmissionStatus(db *sql.DB, status models.SubmissionStatus) error {
+	sources := models.SubmissionStatusSources(status.Status)
+	if len(sources) == 0 {
+		return nil
+	}
+
+	query := `UPDATE SubmissionStatuses SET Status = ?, CurrentCase = ?, TotalCases = ?, QueuePosition = ?, Message = ?
+		WHERE SolutionID = ? AND COALESCE(RequestID, '') = ? AND Status IN (?` + strings.Repeat(", ?", len(sources)-1) + `)`
+	args := []interface{}{status.Status, status.CurrentCase, status.TotalCases, status.QueuePosition, status.Message, status.SolutionID, status.RequestID}
+	for _, source := range sources {
+		args = append(args, source)
+	}
+	if _, err := db.Exec(query, args...); err != nil {
+		return commonerrors.WrapDBError("UPDATE", err)
+	}
+	return nil
+}
+
+// SelectSubmissionStatusは，特定の解答IDに対する判定状況をデータベースから取得する関数である．
+// 判定状況の行が存在しない解答（判定状況の導入前に提出された解答）は，判定結果が存在する場合は Judged，存在しない場合は Pending とする．
+// 解答が見つからない場合は，NotFoundErrorを返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+// - solutionID int: 判定状況を取得したい解答のID．
+//
+// 戻り値:
+// - *models.SubmissionStatus: 解答の判定状況（判定結果は含まない）．
+// - error: 操作が失敗した場合のエラー，またはnil．
+func SelectSubmissionStatus(db *sql.DB, solutionID int) (*models.SubmissionStatus, error) {
+	status := models.SubmissionStatus{SolutionID: solutionID}
+
+	query := `SELECT
+			COALESCE(st.Status, IF(EXISTS(SELECT 1 FROM ResultDetails r WHERE r.SolutionID = s.SolutionID), ?, ?)),
+			COALESCE(st.CurrentCase, 0), COALESCE(st.TotalCases, 0), COALESCE(st.QueuePosition, 0), COALESCE(st.Message, ''), COALESCE(st.UpdatedAt, s.SubmittedAt), COALESCE(st.RequestID, '')
+		FROM Solutions s LEFT JOIN SubmissionStatuses st ON st.SolutionID = s.SolutionID
+		WHERE s.SolutionID = ?`
+	err := db.QueryRow(query, models.SubmissionStatusJudged, models.SubmissionStatusPending, solutionID).Scan(
+		&status.Status, &status.CurrentCase, &status.TotalCases, &status.QueuePosition, &status.Message, &status.UpdatedAt, &status.RequestID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// 解答が見つからないエラーを生成
+			return nil, commonerrors.NewNotFoundError("Solution", "SolutionID", strconv.Itoa(solutionID))
+		}
+		return nil, commonerrors.WrapDBError("SELECT", err)
+	}
+
+	return &status, nil
+}
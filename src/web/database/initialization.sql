@@ -18,6 +18,20 @@ CREATE TABLE IF NOT EXISTS Problems (
     Title VARCHAR(255) NOT NULL,
     Description TEXT,
     Difficulty INT CHECK(Difficulty >= 1 AND Difficulty <= 5),
+    ProblemType VARCHAR(16) NOT NULL DEFAULT 'standard', -- 問題の種類(standard または interactive)
+    TimeLimit INT NOT NULL DEFAULT 2000, -- 実行時間制限(ミリ秒)
+    MemoryLimit INT NOT NULL DEFAULT 256, -- メモリ制限(MB)
+    LanguageMultipliers JSON, -- 言語IDをキーとした実行時間制限の倍率
+    CheckerLanguageID INT NOT NULL DEFAULT 0, -- チェッカーの言語ID(0の場合はチェッカーなし)
+    CompareMode VARCHAR(32) NOT NULL DEFAULT 'exact', -- 出力の比較モード
+    FloatEpsilon DOUBLE NOT NULL DEFAULT 0, -- 比較モードが float の場合の許容誤差
+    InteractorLanguageID INT NOT NULL DEFAULT 0, -- インタラクタの言語ID(インタラクティブ問題のみ)
+    JudgeMode VARCHAR(16) NOT NULL DEFAULT 'all', -- テストケースの判定方式(all, fail_fast)
+    Subtasks JSON, -- 部分点のためのサブタスクの設定
+    SampleCases JSON, -- 問題の詳細とともに入出力を公開するサンプルケースの名前
+    RevealedCases JSON, -- 提出プログラムの出力と期待される出力を判定結果で公開するテストケースの名前
+    StderrVisibility VARCHAR(16) NOT NULL DEFAULT 'revealed', -- 標準エラー出力の公開範囲(none, revealed, all)
+    TestSetVersion INT NOT NULL DEFAULT 0, -- テストデータの最新の版(作成時は1，更新のたびに1ずつ増える)
     CreatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
     UpdatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
     FOREIGN KEY (UserID) REFERENCES Users(UserID),
@@ -40,23 +54,105 @@ CREATE TABLE IF NOT EXISTS Solutions (
     INDEX language_id_index (LanguageID)
 );
 
+-- 解答の判定状況テーブル (SubmissionStatuses)
+-- 状態は Pending → Queued → Compiling → Running → Judged / SystemError の順に遷移し，再判定では Queued から再び遷移する．
+CREATE TABLE IF NOT EXISTS SubmissionStatuses (
+    SolutionID INT PRIMARY KEY,
+    Status VARCHAR(16) NOT NULL DEFAULT 'Pending',
+    CurrentCase INT NOT NULL DEFAULT 0, -- 判定が終了したテストケースの数 (Running の場合)
+    TotalCases INT NOT NULL DEFAULT 0, -- テストケースの総数 (Running 以降)
+    QueuePosition INT NOT NULL DEFAULT 0, -- ジャッジサーバーのキューでの順番 (Queued の場合)
+    Message TEXT, -- 判定に失敗した理由 (SystemError の場合)
+    RequestID VARCHAR(64), -- 判定状況が対象とする判定の依頼の一意識別子 (以前の依頼に対する更新を無視するために用いる)
+    UpdatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
+);
+
 -- 判定結果テーブル (ResultDetails)
 CREATE TABLE IF NOT EXISTS ResultDetails (
-    SolutionID INT PRIMARY KEY,
+    SolutionID INT NOT NULL,
+    Attempt INT NOT NULL DEFAULT 1, -- 判定の試行番号(再判定のたびに1ずつ増え，最大のものが最新の判定結果)
+    Verdict VARCHAR(8) NOT NULL,
     TotalCases INT NOT NULL,
     CorrectCases INT NOT NULL,
     IncorrectCases INT NOT NULL,
     TimeLimitExceeded INT NOT NULL,
+    MemoryLimitExceeded INT NOT NULL DEFAULT 0,
+    OutputLimitExceeded INT NOT NULL DEFAULT 0,
+    RuntimeError INT NOT NULL DEFAULT 0,
+    CompileError INT NOT NULL DEFAULT 0,
+    InternalError INT NOT NULL DEFAULT 0,
+    SkippedCases INT NOT NULL DEFAULT 0, -- 判定を打ち切ったため実行しなかったテストケースの数
+    Score DOUBLE NOT NULL DEFAULT 0,
+    MaxScore DOUBLE NOT NULL DEFAULT 0,
+    CompileOutput MEDIUMTEXT, -- コンパイラの出力 (サイズを制限して切り詰めたもの)
     ErrorMessage TEXT,
+    JudgedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    RequestID VARCHAR(64), -- 判定の依頼の一意識別子 (判定結果が再配信されても重複して保存しないために用いる)
+    TestSetVersion INT NOT NULL DEFAULT 0, -- 判定に用いたテストデータの版
+    PRIMARY KEY (SolutionID, Attempt),
+    UNIQUE KEY (SolutionID, RequestID),
     FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
 );
 
 -- ケースごとの結果テーブル (CaseResults)
 CREATE TABLE IF NOT EXISTS CaseResults (
     SolutionID INT,
+    Attempt INT NOT NULL DEFAULT 1, -- 判定の試行番号
+    CaseIndex INT NOT NULL DEFAULT 0, -- テストケースの実行順
     CaseName VARCHAR(255) NOT NULL,
     Result VARCHAR(255) NOT NULL,
-    ExecutionTime INT NOT NULL,
-    PRIMARY KEY (SolutionID, CaseName),
+    ExecutionTime BIGINT NOT NULL, -- 実行時間 (ウォールタイム，ナノ秒)
+    CPUTime BIGINT NOT NULL DEFAULT 0, -- CPU時間 (ナノ秒)
+    PeakMemory BIGINT NOT NULL DEFAULT 0, -- ピークメモリ使用量 (バイト)
+    ExitCode INT NOT NULL DEFAULT 0,
+    SignalName VARCHAR(16),
+    CheckerMessage TEXT,
+    Score DOUBLE NOT NULL DEFAULT 0,
+    Stderr TEXT, -- 標準エラー出力 (公開されている場合，サイズを制限して切り詰めたもの)
+    Stdout TEXT, -- 提出プログラムの出力 (公開されている場合)
+    ExpectedOutput TEXT, -- 期待される出力 (公開されている場合)
+    PRIMARY KEY (SolutionID, Attempt, CaseName),
     FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
 );
+
+-- サブタスクごとの結果テーブル (SubtaskResults)
+CREATE TABLE IF NOT EXISTS SubtaskResults (
+    SolutionID INT,
+    Attempt INT NOT NULL DEFAULT 1, -- 判定の試行番号
+    SubtaskIndex INT NOT NULL, -- 問題に設定されたサブタスクの順番
+    Name VARCHAR(255) NOT NULL,
+    Scoring VARCHAR(32) NOT NULL,
+    Verdict VARCHAR(8) NOT NULL,
+    Score DOUBLE NOT NULL,
+    MaxScore DOUBLE NOT NULL,
+    PRIMARY KEY (SolutionID, Attempt, SubtaskIndex),
+    FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
+);
+
+-- スキーマの版の管理テーブル (SchemaMigrations)
+-- 既存のデータベースは webサーバーの起動時に未適用のマイグレーション(database/migrations.go)が適用される．
+-- このスクリプトで作成したデータベースは最新のスキーマであるため，全ての版を適用済みとして登録する．
+CREATE TABLE IF NOT EXISTS SchemaMigrations (
+    Version INT PRIMARY KEY,
+    Name VARCHAR(255) NOT NULL,
+    AppliedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT IGNORE INTO SchemaMigrations (Version, Name) VALUES
+    (1, 'record verdicts, runtime errors and limit violations'),
+    (2, 'store time and memory limits per problem'),
+    (3, 'store compiler output'),
+    (4, 'add custom checkers'),
+    (5, 'select output comparators per problem'),
+    (6, 'add interactive problems'),
+    (7, 'add subtasks'),
+    (8, 'record CPU time and peak memory per case'),
+    (9, 'reveal stderr and outputs per problem settings'),
+    (10, 'flag sample cases'),
+    (11, 'add fail-fast judge mode and case order'),
+    (12, 'keep result history per attempt'),
+    (13, 'persist submission statuses'),
+    (14, 'identify judge requests on the durable queue'),
+    (15, 'version problem test sets'),
+    (16, 'widen compiler output');
@@ -0,0 +1,230 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	commonerrors "procon_web_service/src/common/errors"
+	"time"
+)
+
+// migrationLockName - 複数のwebサーバーが同時にマイグレーションを適用しないために取得するロックの名前
+const migrationLockName = "procon_web_service.schema_migrations"
+
+// migrationLockTimeout - マイグレーションのロックの取得を待つ最大の秒数
+const migrationLockTimeout = 300
+
+// migration - スキーマの変更を1つ適用するマイグレーション
+// MySQLではALTER TABLEなどのDDLがトランザクションに含まれないため，statements は1つずつ実行され，途中で失敗した場合は手動での修復が必要になる．
+type migration struct {
+	version    int      // 適用順を表す版(1から連番)
+	name       string   // 変更内容の説明
+	statements []string // 順に実行するSQL文
+}
+
+// migrations - 初期のスキーマ(Users，Problems，Solutions，ResultDetails，CaseResultsの作成)に対して適用するマイグレーションの一覧
+// 新しいデータベースは initialization.sql で最新のスキーマとして作成され，ここに含まれる全ての版が適用済みとして SchemaMigrations に登録される．
+// スキーマを変更する場合は，末尾に新しい版を追加し，initialization.sql のテーブル定義と SchemaMigrations への登録も更新する．
+var migrations = []migration{
+	{1, "record verdicts, runtime errors and limit violations", []string{
+		`ALTER TABLE ResultDetails
+			ADD COLUMN Verdict VARCHAR(8) NOT NULL DEFAULT '' AFTER SolutionID,
+			ADD COLUMN MemoryLimitExceeded INT NOT NULL DEFAULT 0 AFTER TimeLimitExceeded,
+			ADD COLUMN OutputLimitExceeded INT NOT NULL DEFAULT 0 AFTER MemoryLimitExceeded,
+			ADD COLUMN RuntimeError INT NOT NULL DEFAULT 0 AFTER OutputLimitExceeded,
+			ADD COLUMN CompileError INT NOT NULL DEFAULT 0 AFTER RuntimeError,
+			ADD COLUMN InternalError INT NOT NULL DEFAULT 0 AFTER CompileError`,
+		// 既存の判定結果は，当時記録されていたテストケースの数から判定結果の優先順位に従って求める
+		`UPDATE ResultDetails SET Verdict = CASE
+			WHEN TimeLimitExceeded > 0 THEN 'TLE'
+			WHEN IncorrectCases > 0 THEN 'WA'
+			WHEN TotalCases > 0 AND CorrectCases = TotalCases THEN 'AC'
+			ELSE 'IE' END`,
+		`ALTER TABLE ResultDetails ALTER COLUMN Verdict DROP DEFAULT`,
+		// 以前のジャッジが記録したテストケースの結果を，判定結果の略称に置き換える
+		`UPDATE CaseResults SET Result = CASE Result
+			WHEN 'PASSED' THEN 'AC'
+			WHEN 'FAILED' THEN 'WA'
+			WHEN 'TIME LIMITED EXCEEDED' THEN 'TLE'
+			WHEN 'INTERNAL ERROR' THEN 'IE'
+			ELSE Result END`,
+		`ALTER TABLE CaseResults
+			ADD COLUMN ExitCode INT NOT NULL DEFAULT 0 AFTER ExecutionTime,
+			ADD COLUMN SignalName VARCHAR(16) AFTER ExitCode`,
+	}},
+	{2, "store time and memory limits per problem", []string{
+		`ALTER TABLE Problems
+			ADD COLUMN TimeLimit INT NOT NULL DEFAULT 2000 AFTER Difficulty,
+			ADD COLUMN MemoryLimit INT NOT NULL DEFAULT 256 AFTER TimeLimit,
+			ADD COLUMN LanguageMultipliers JSON AFTER MemoryLimit`,
+	}},
+	{3, "store compiler output", []string{
+		`ALTER TABLE ResultDetails ADD COLUMN CompileOutput TEXT AFTER InternalError`,
+	}},
+	{4, "add custom checkers", []string{
+		`ALTER TABLE Problems ADD COLUMN CheckerLanguageID INT NOT NULL DEFAULT 0 AFTER LanguageMultipliers`,
+		`ALTER TABLE CaseResults
+			ADD COLUMN CheckerMessage TEXT AFTER SignalName,
+			ADD COLUMN Score DOUBLE NOT NULL DEFAULT 0 AFTER CheckerMessage`,
+	}},
+	{5, "select output comparators per problem", []string{
+		`ALTER TABLE Problems
+			ADD COLUMN CompareMode VARCHAR(32) NOT NULL DEFAULT 'exact' AFTER CheckerLanguageID,
+			ADD COLUMN FloatEpsilon DOUBLE NOT NULL DEFAULT 0 AFTER CompareMode`,
+	}},
+	{6, "add interactive problems", []string{
+		`ALTER TABLE Problems
+			ADD COLUMN ProblemType VARCHAR(16) NOT NULL DEFAULT 'standard' AFTER Difficulty,
+			ADD COLUMN InteractorLanguageID INT NOT NULL DEFAULT 0 AFTER FloatEpsilon`,
+	}},
+	{7, "add subtasks", []string{
+		`ALTER TABLE Problems ADD COLUMN Subtasks JSON AFTER InteractorLanguageID`,
+		`ALTER TABLE ResultDetails
+			ADD COLUMN Score DOUBLE NOT NULL DEFAULT 0 AFTER InternalError,
+			ADD COLUMN MaxScore DOUBLE NOT NULL DEFAULT 0 AFTER Score`,
+		`CREATE TABLE IF NOT EXISTS SubtaskResults (
+			SolutionID INT,
+			SubtaskIndex INT NOT NULL,
+			Name VARCHAR(255) NOT NULL,
+			Scoring VARCHAR(32) NOT NULL,
+			Verdict VARCHAR(8) NOT NULL,
+			Score DOUBLE NOT NULL,
+			MaxScore DOUBLE NOT NULL,
+			PRIMARY KEY (SolutionID, SubtaskIndex),
+			FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
+		)`,
+	}},
+	{8, "record CPU time and peak memory per case", []string{
+		// ExecutionTime は導入時からナノ秒で保存されているため，値の変換は不要
+		`ALTER TABLE CaseResults
+			MODIFY COLUMN ExecutionTime BIGINT NOT NULL,
+			ADD COLUMN CPUTime BIGINT NOT NULL DEFAULT 0 AFTER ExecutionTime,
+			ADD COLUMN PeakMemory BIGINT NOT NULL DEFAULT 0 AFTER CPUTime`,
+	}},
+	{9, "reveal stderr and outputs per problem settings", []string{
+		`ALTER TABLE Problems
+			ADD COLUMN RevealedCases JSON AFTER Subtasks,
+			ADD COLUMN StderrVisibility VARCHAR(16) NOT NULL DEFAULT 'revealed' AFTER RevealedCases`,
+		`ALTER TABLE CaseResults
+			ADD COLUMN Stderr TEXT AFTER Score,
+			ADD COLUMN Stdout TEXT AFTER Stderr,
+			ADD COLUMN ExpectedOutput TEXT AFTER Stdout`,
+	}},
+	{10, "flag sample cases", []string{
+		`ALTER TABLE Problems ADD COLUMN SampleCases JSON AFTER Subtasks`,
+	}},
+	{11, "add fail-fast judge mode and case order", []string{
+		`ALTER TABLE Problems ADD COLUMN JudgeMode VARCHAR(16) NOT NULL DEFAULT 'all' AFTER InteractorLanguageID`,
+		`ALTER TABLE ResultDetails ADD COLUMN SkippedCases INT NOT NULL DEFAULT 0 AFTER InternalError`,
+		`ALTER TABLE CaseResults ADD COLUMN CaseIndex INT NOT NULL DEFAULT 0 AFTER SolutionID`,
+	}},
+	{12, "keep result history per attempt", []string{
+		// 主キーの先頭を SolutionID のままにするため，外部キーのインデックスを失わずに1つの文で主キーを置き換えられる
+		`ALTER TABLE ResultDetails
+			ADD COLUMN Attempt INT NOT NULL DEFAULT 1 AFTER SolutionID,
+			ADD COLUMN JudgedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP AFTER ErrorMessage,
+			DROP PRIMARY KEY,
+			ADD PRIMARY KEY (SolutionID, Attempt)`,
+		// 既存の判定結果の判定日時は，正確な日時が記録されていないため提出日時とする
+		`UPDATE ResultDetails r JOIN Solutions s ON s.SolutionID = r.SolutionID SET r.JudgedAt = s.SubmittedAt`,
+		`ALTER TABLE CaseResults
+			ADD COLUMN Attempt INT NOT NULL DEFAULT 1 AFTER SolutionID,
+			DROP PRIMARY KEY,
+			ADD PRIMARY KEY (SolutionID, Attempt, CaseName)`,
+		`ALTER TABLE SubtaskResults
+			ADD COLUMN Attempt INT NOT NULL DEFAULT 1 AFTER SolutionID,
+			DROP PRIMARY KEY,
+			ADD PRIMARY KEY (SolutionID, Attempt, SubtaskIndex)`,
+	}},
+	{13, "persist submission statuses", []string{
+		// 既存の解答の判定状況は行が存在しない場合の扱い(SelectSubmissionStatus)で補われるため，行は追加しない
+		`CREATE TABLE IF NOT EXISTS SubmissionStatuses (
+			SolutionID INT PRIMARY KEY,
+			Status VARCHAR(16) NOT NULL DEFAULT 'Pending',
+			CurrentCase INT NOT NULL DEFAULT 0,
+			TotalCases INT NOT NULL DEFAULT 0,
+			QueuePosition INT NOT NULL DEFAULT 0,
+			Message TEXT,
+			UpdatedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			FOREIGN KEY (SolutionID) REFERENCES Solutions(SolutionID)
+		)`,
+	}},
+	{14, "identify judge requests on the durable queue", []string{
+		`ALTER TABLE SubmissionStatuses ADD COLUMN RequestID VARCHAR(64) AFTER Message`,
+		`ALTER TABLE ResultDetails
+			ADD COLUMN RequestID VARCHAR(64) AFTER JudgedAt,
+			ADD UNIQUE KEY (SolutionID, RequestID)`,
+	}},
+	{15, "version problem test sets", []string{
+		`ALTER TABLE Problems ADD COLUMN TestSetVersion INT NOT NULL DEFAULT 0 AFTER StderrVisibility`,
+		`ALTER TABLE ResultDetails ADD COLUMN TestSetVersion INT NOT NULL DEFAULT 0 AFTER RequestID`,
+	}},
+	{16, "widen compiler output", []string{
+		// 制限したコンパイラの出力に省略の表示や置換文字が加わるとTEXTの上限(65535バイト)を超えるため
+		`ALTER TABLE ResultDetails MODIFY COLUMN CompileOutput MEDIUMTEXT`,
+	}},
+}
+
+// Migrateは，データベースのスキーマを最新の版に更新する関数である．
+// この関数は，SchemaMigrationsテーブルに記録された適用済みの版を確認し，未適用のマイグレーションを版の順に適用して，適用した版を記録する．
+// 複数のwebサーバーが同時に起動した場合でも同じマイグレーションを重複して適用しないよう，適用中はMySQLの名前付きロック(GET_LOCK)を保持する．
+// マイグレーションの適用に失敗した場合，それ以降の版は適用せずにエラーを返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - error: マイグレーションの適用に失敗した場合のエラー，またはnil．
+func Migrate(db *sql.DB) error {
+	ctx := context.Background()
+
+	// 名前付きロックは接続ごとに保持されるため，ロックの取得から解放まで同じ接続を用いる
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return commonerrors.WrapDBError("connect", err)
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked); err != nil {
+		return commonerrors.WrapDBError("GET_LOCK", err)
+	}
+	if locked.Int64 != 1 {
+		return commonerrors.WrapDBError("GET_LOCK", fmt.Errorf("timed out waiting for lock %s", migrationLockName))
+	}
+	defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS SchemaMigrations (
+		Version INT PRIMARY KEY,
+		Name VARCHAR(255) NOT NULL,
+		AppliedAt TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return commonerrors.WrapDBError("CREATE", err)
+	}
+
+	var current int
+	if err := conn.QueryRowContext(ctx, "SELECT COALESCE(MAX(Version), 0) FROM SchemaMigrations").Scan(&current); err != nil {
+		return commonerrors.WrapDBError("SELECT", err)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		log.Printf("Applying schema migration %d: %s", m.version, m.name)
+		start := time.Now()
+		for _, statement := range m.statements {
+			if _, err := conn.ExecContext(ctx, statement); err != nil {
+				return commonerrors.WrapDBError(fmt.Sprintf("migration %d", m.version), err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, "INSERT INTO SchemaMigrations (Version, Name) VALUES (?, ?)", m.version, m.name); err != nil {
+			return commonerrors.WrapDBError("INSERT", err)
+		}
+		log.Printf("Applied schema migration %d in %v", m.version, time.Since(start))
+	}
+
+	return nil
+}
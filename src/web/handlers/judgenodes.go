@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/utils"
+	"procon_web_service/src/web/async"
+	webutils "procon_web_service/src/web/utils"
+)
+
+// ListJudgeNodesHandlerは，判定キューに登録されている全てのジャッジノードの情報を取得するHTTPハンドラ関数である．
+// 各ジャッジノードの情報には，判定できる言語，同時に判定する提出の最大数，バージョン，負荷（処理中と割り当て済みの判定リクエストの数），状態，ハートビートの有無が含まれる．
+// ジャッジノードの情報を取得できるのは管理者のみである．
+// 成功した場合，HTTPステータスコード200(OK)と共にジャッジノードの情報のリストを返す．
+//
+// 戻り値:
+// - http.HandlerFunc: ジャッジノードの情報を取得する関数．
+func ListJudgeNodesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		if !webutils.IsAdmin(claims.UserID) {
+			utils.SendErrorResponse(w, commonerrors.NewAccessDeniedError("You do not have permission to access this resource"))
+			return
+		}
+
+		nodes, err := async.ListJudgeNodes(r.Context())
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, nodes)
+	}
+}
+
+// UpdateJudgeNodeStateHandlerは，指定されたジャッジノードの状態（active，draining，disabled）を変更するHTTPハンドラ関数である．
+// リクエストボディから`models.JudgeNodeStateRequest`構造体にデコードする．
+// draining にしたジャッジノードには新しい判定リクエストが割り当てられず，disabled にしたジャッジノードの未処理の判定リクエストは他のジャッジノードに再割り当てされる．
+// ジャッジノードの状態を変更できるのは管理者のみである．
+// 成功した場合，HTTPステータスコード200(OK)と共に変更後のジャッジノードの情報を返す．
+//
+// 戻り値:
+// - http.HandlerFunc: ジャッジノードの状態を変更する関数．
+func UpdateJudgeNodeStateHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		if !webutils.IsAdmin(claims.UserID) {
+			utils.SendErrorResponse(w, commonerrors.NewAccessDeniedError("You do not have permission to access this resource"))
+			return
+		}
+
+		// URLからNodeIDを取得
+		nodeID, err := utils.GetStrVarFromRequest(r, "node_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		var request models.JudgeNodeStateRequest
+		if err := utils.DecodeRequestBody(r, &request); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if err := webutils.ValidateJudgeNodeStateRequest(&request); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		node, err := async.SetJudgeNodeState(r.Context(), nodeID, request.State)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, node)
+	}
+}
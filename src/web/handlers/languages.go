@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"net/http"
+	"procon_web_service/src/common/config"
+	"procon_web_service/src/common/utils"
+)
+
+// GetLanguagesHandlerは，提出に利用できる言語の一覧を取得するHTTPハンドラ関数である．
+// 言語の一覧は言語設定ファイルから読み込まれ，無効化された言語は含まれない．
+// 言語設定ファイルが更新された場合は，再起動せずに更新後の一覧が返される．
+// 成功した場合，HTTPステータスコード200(OK)と共に言語IDの昇順に並んだ言語の一覧をレスポンスとして返す．
+//
+// 戻り値:
+// - http.HandlerFunc: 言語の一覧を取得する処理を行う関数．
+func GetLanguagesHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		utils.SendJSONResponse(w, http.StatusOK, config.GetEnabledLanguages())
+	}
+}
@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"database/sql"
+	"log"
 	"net/http"
 	commonerrors "procon_web_service/src/common/errors"
 	"procon_web_service/src/common/minio"
 	"procon_web_service/src/common/models"
 	"procon_web_service/src/common/utils"
 	"procon_web_service/src/web/database"
+	"procon_web_service/src/web/testarchive"
 	webutils "procon_web_service/src/web/utils"
 )
 
@@ -18,6 +20,10 @@ const (
 // UploadProblemHandlerは，新しい問題の投稿を処理するHTTPハンドラ関数である．
 // この関数はHTTPリクエストから問題のメタデータと関連する入出力ファイルを解析し，それらをデータベースおよびMinIOに保存する．
 // 問題のメタデータはリクエストボディから`models.Problem`構造体にデコードされ，入出力ファイルはマルチパートフォームデータとして処理される．
+// 入出力ファイルは個別に添付する代わりに，ZIPまたはtar.gzのアーカイブ(test_archive)としてまとめてアップロードすることもでき，アーカイブ内のファイルはパターンに基づいて入力ファイルと出力ファイルの組にされる．
+// メタデータには実行時間制限，メモリ制限，言語ごとの実行時間倍率を含めることができ，未指定の場合は既定値が設定される．
+// チェッカー(スペシャルジャッジ)のファイルがアップロードされた場合は，出力の完全一致の代わりにチェッカーで判定される．
+// インタラクティブ問題の場合は，アップロードされたインタラクタと提出プログラムを対話させて判定される．
 // この関数は認証情報の確認，マルチパートフォームデータのパース，ファイルの妥当性検証，問題メタデータとファイルの保存をトランザクション内で行う．
 // 各ステップでエラーが発生した場合，適切なHTTPステータスコードとエラーメッセージで応答する．
 // 問題が正常に保存された場合，HTTPステータスコード201(Created)と保存された問題データをレスポンスとして返す．
@@ -50,12 +56,82 @@ func UploadProblemHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// テストケースのアーカイブの読み込みと入出力ファイルの組の確認(アーカイブと個別の入出力ファイルは同時に指定できない)
+		archive, err := testarchive.Parse(r.MultipartForm.File["test_archive"], r.FormValue("archive_input_pattern"), r.FormValue("archive_output_pattern"))
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		caseFileNames := webutils.FileNames(r.MultipartForm.File["input_file"])
+		if archive != nil {
+			if len(r.MultipartForm.File["input_file"]) > 0 || len(r.MultipartForm.File["output_file"]) > 0 {
+				utils.SendErrorResponse(w, commonerrors.NewFileValidationError("test_archive と input_file，output_file は同時に指定できません"))
+				return
+			}
+			caseFileNames = archive.CaseFileNames()
+		}
+
+		// チェッカーおよびインタラクタのファイルのフォーマットの確認
+		if err := webutils.ValidateProgramFiles("checker_file", r.MultipartForm.File["checker_file"]); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if err := webutils.ValidateProgramFiles("interactor_file", r.MultipartForm.File["interactor_file"]); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
 		// JSON形式の問題メタデータを取得
 		if err := utils.ParseProblemMetadata(r, &newProblem); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
+		// 実行制限の検証(未指定の場合は既定値を設定)
+		if err := webutils.ValidateProblemLimits(&newProblem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// チェッカーの設定の検証(ファイルのみ指定された場合は既定の言語を設定)
+		if err := webutils.ValidateProblemChecker(&newProblem, len(r.MultipartForm.File["checker_file"]) > 0); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 出力の比較モードの検証(未指定の場合は完全一致を設定)
+		if err := webutils.ValidateProblemCompareMode(&newProblem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 問題の種類の検証(インタラクティブ問題の場合はインタラクタが必須)
+		if err := webutils.ValidateProblemType(&newProblem, len(r.MultipartForm.File["interactor_file"]) > 0); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// テストケースの判定方式の検証(未指定の場合は全てのテストケースを実行)
+		if err := webutils.ValidateProblemJudgeMode(&newProblem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// サブタスクの検証(テストケースの名前は入力ファイル名と対応している必要がある)
+		if err := webutils.ValidateProblemSubtasks(&newProblem, caseFileNames); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 判定結果で公開する詳細の設定の検証(公開するテストケースは入力ファイル名と対応している必要がある)
+		if err := webutils.ValidateProblemReveal(&newProblem, caseFileNames); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// テストデータは最初の版として保存
+		newProblem.TestSetVersion = 1
+
 		// トランザクションの開始
 		tx, txErr := database.BeginTransaction(db)
 		if txErr != nil {
@@ -72,21 +148,14 @@ func UploadProblemHandler(db *sql.DB) http.HandlerFunc {
 			newProblem.ProblemID = problemID // 割り振られた問題IDをProblem構造体にセット
 		}
 
-		// [2] 入力ファイルの保存
-		if err := minio.UploadFileToMinIO(newProblem.ProblemID, r.MultipartForm.File["input_file"], "in"); err != nil {
-			tx.Rollback()
-			utils.SendErrorResponse(w, err)
-			return
-		}
-
-		// [3] 出力ファイルの保存
-		if err := minio.UploadFileToMinIO(newProblem.ProblemID, r.MultipartForm.File["output_file"], "out"); err != nil {
+		// [2] テストデータ(入出力ファイル，チェッカー，インタラクタ)を最初の版として保存
+		if err := uploadTestSet(r, newProblem.ProblemID, newProblem.TestSetVersion, archive); err != nil {
 			tx.Rollback()
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
-		// トランザクションのコミット( [1][2][3] が全て成功した時のみ)
+		// トランザクションのコミット( [1][2] が全て成功した時のみ)
 		if err := tx.Commit(); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
@@ -99,8 +168,10 @@ func UploadProblemHandler(db *sql.DB) http.HandlerFunc {
 // UpdateProblemHandlerは，指定されたIDの問題を更新するHTTPハンドラ関数である．
 // この関数はHTTPリクエストから問題の新しいメタデータと関連する入出力ファイルを解析し，それらをデータベースおよびMinIOに更新する．
 // 問題のメタデータはリクエストボディから`models.Problem`構造体にデコードされ，入出力ファイルはマルチパートフォームデータとして処理される．
+// 入出力ファイルは個別に添付する代わりに，ZIPまたはtar.gzのアーカイブ(test_archive)としてまとめてアップロードすることもでき，アーカイブ内のファイルはパターンに基づいて入力ファイルと出力ファイルの組にされる．
 // この関数は認証情報の確認，マルチパートフォームデータのパース，ファイルの妥当性検証，既存の問題メタデータとファイルの更新を行う．
-// まず，MinIOから古いファイルを削除し，新しいファイルを保存する．その後，データベースの問題メタデータを更新する．
+// まず，新しいファイルをテストデータの次の版としてMinIOに保存し，その後，データベースの問題メタデータとテストデータの版を更新する．
+// 直前の版より古いテストデータは更新の完了後に削除される．
 // 各ステップでエラーが発生した場合，適切なHTTPステータスコードとエラーメッセージで応答する．
 // 問題が正常に更新された場合，HTTPステータスコード200(OK)と更新された問題データをレスポンスとして返す．
 //
@@ -142,36 +213,105 @@ func UpdateProblemHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// テストケースのアーカイブの読み込みと入出力ファイルの組の確認(アーカイブと個別の入出力ファイルは同時に指定できない)
+		archive, err := testarchive.Parse(r.MultipartForm.File["test_archive"], r.FormValue("archive_input_pattern"), r.FormValue("archive_output_pattern"))
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		caseFileNames := webutils.FileNames(r.MultipartForm.File["input_file"])
+		if archive != nil {
+			if len(r.MultipartForm.File["input_file"]) > 0 || len(r.MultipartForm.File["output_file"]) > 0 {
+				utils.SendErrorResponse(w, commonerrors.NewFileValidationError("test_archive と input_file，output_file は同時に指定できません"))
+				return
+			}
+			caseFileNames = archive.CaseFileNames()
+		}
+
+		// チェッカーおよびインタラクタのファイルのフォーマットの確認
+		if err := webutils.ValidateProgramFiles("checker_file", r.MultipartForm.File["checker_file"]); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if err := webutils.ValidateProgramFiles("interactor_file", r.MultipartForm.File["interactor_file"]); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
 		// JSON形式の問題メタデータを取得
 		if err := utils.ParseProblemMetadata(r, &problem); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
-		// minIOの特定のバケットから古い問題の入出力データを削除(input/*, output/* まとめて)
-		if err := minio.DeleteFileFromMinIO(minio.GetFileSaveName("", problem.ProblemID, "", "")); err != nil {
+		// 実行制限の検証(未指定の場合は既定値を設定)
+		if err := webutils.ValidateProblemLimits(&problem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// チェッカーの設定の検証(ファイルのみ指定された場合は既定の言語を設定)
+		if err := webutils.ValidateProblemChecker(&problem, len(r.MultipartForm.File["checker_file"]) > 0); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
-		// 入力ファイルの保存
-		if err := minio.UploadFileToMinIO(problem.ProblemID, r.MultipartForm.File["input_file"], "input"); err != nil {
+		// 出力の比較モードの検証(未指定の場合は完全一致を設定)
+		if err := webutils.ValidateProblemCompareMode(&problem); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
-		// 出力ファイルの保存
-		if err := minio.UploadFileToMinIO(problem.ProblemID, r.MultipartForm.File["output_file"], "output"); err != nil {
+		// 問題の種類の検証(インタラクティブ問題の場合はインタラクタが必須)
+		if err := webutils.ValidateProblemType(&problem, len(r.MultipartForm.File["interactor_file"]) > 0); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
-		// データベースに問題のメタデータを保存
+		// テストケースの判定方式の検証(未指定の場合は全てのテストケースを実行)
+		if err := webutils.ValidateProblemJudgeMode(&problem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// サブタスクの検証(テストケースの名前は入力ファイル名と対応している必要がある)
+		if err := webutils.ValidateProblemSubtasks(&problem, caseFileNames); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 判定結果で公開する詳細の設定の検証(公開するテストケースは入力ファイル名と対応している必要がある)
+		if err := webutils.ValidateProblemReveal(&problem, caseFileNames); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 現在のテストデータの版を取得し，新しいテストデータは次の版として保存
+		current, err := database.SelectProblemByProblemID(db, problem.ProblemID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		problem.TestSetVersion = current.TestSetVersion + 1
+
+		// テストデータ(入出力ファイル，チェッカー，インタラクタ)の保存(古い版は判定中の解答が参照している可能性があるため，この時点では削除しない)
+		if err := uploadTestSet(r, problem.ProblemID, problem.TestSetVersion, archive); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// データベースに問題のメタデータとテストデータの版を保存
 		if err := database.UpdateProblem(db, problem.ProblemID, problem); err != nil {
+			minio.DeleteFileFromMinIO(minio.GetTestSetSaveName("", problem.ProblemID, problem.TestSetVersion, "", "") + "/")
 			utils.SendErrorResponse(w, err)
 			return
 		}
 
+		// 直前の版より古いテストデータを削除(判定キューで待機している判定リクエストのために直前の版は残す)
+		if err := minio.DeleteOldTestSets(problem.ProblemID, problem.TestSetVersion-1); err != nil {
+			log.Printf("Failed to delete old test sets of problem %d: %v", problem.ProblemID, err)
+		}
+
 		utils.SendJSONResponse(w, http.StatusOK, problem)
 	}
 }
@@ -279,7 +419,7 @@ func GetProblemByUserIDHandler(db *sql.DB) http.HandlerFunc {
 
 // GetProblemByProblemIDHandlerは，指定された問題IDの詳細情報を取得するHTTPハンドラ関数である．
 // この関数はURLパラメータから問題IDを取得し，そのIDに紐付く問題のメタデータと関連するカテゴリーIDをデータベースから取得する．
-// 取得される問題のデータには，問題ID，作成者ID，タイトル，説明，難易度，作成日時，更新日時，カテゴリーIDのリストが含まれる．
+// 取得される問題のデータには，問題ID，作成者ID，タイトル，説明，難易度，作成日時，更新日時，カテゴリーIDのリストに加えて，サンプルケースの入出力が含まれる．
 // 問題データの取得に成功した場合，HTTPステータスコード200(OK)とともに問題データをJSON形式で返す．
 // 指定された問題IDの問題が見つからない場合やデータベース操作中にエラーが発生した場合，適切なHTTPステータスコードとエラーメッセージで応答する．
 //
@@ -314,6 +454,43 @@ func GetProblemByProblemIDHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// サンプルケースの入出力を取得(非公開のテストケースは含めない)
+		if err := webutils.LoadProblemSamples(r.Context(), problem); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
 		utils.SendJSONResponse(w, http.StatusOK, problem)
 	}
 }
+
+// uploadTestSetは，テストデータ(入出力ファイル，チェッカー，インタラクタ)をMinIOに新しい版として保存する．
+// 入出力ファイルは，アーカイブが指定された場合はアーカイブから展開しながら，それ以外の場合は個別に添付されたファイルから保存する．
+// 保存に失敗した場合は，既に保存されたファイルを削除する．
+func uploadTestSet(r *http.Request, problemID, version int, archive *testarchive.Archive) error {
+	upload := minio.NewTestSetUpload(problemID, version)
+	err := func() error {
+		if archive != nil {
+			if err := archive.Upload(upload); err != nil {
+				return err
+			}
+		} else {
+			if err := upload.AddFileHeaders(models.TestSetFileInput, r.MultipartForm.File["input_file"]); err != nil {
+				return err
+			}
+			if err := upload.AddFileHeaders(models.TestSetFileOutput, r.MultipartForm.File["output_file"]); err != nil {
+				return err
+			}
+		}
+		if err := upload.AddFileHeaders(models.TestSetFileChecker, r.MultipartForm.File["checker_file"]); err != nil {
+			return err
+		}
+		return upload.AddFileHeaders(models.TestSetFileInteractor, r.MultipartForm.File["interactor_file"])
+	}()
+	if err != nil {
+		upload.Abort()
+		return err
+	}
+	_, err = upload.Commit()
+	return err
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/utils"
+	"procon_web_service/src/web/async"
+	"procon_web_service/src/web/database"
+	webutils "procon_web_service/src/web/utils"
+)
+
+// RejudgeSolutionHandlerは，指定された解答を再判定するHTTPハンドラ関数である．
+// 再判定を要求できるのは，解答の対象の問題の所有者と管理者のみである．
+// 再判定はバックグラウンドで行われ，HTTPステータスコード202(Accepted)と共に開始時点の再判定の状態を返す．
+// 進捗と判定結果の変化は，GetRejudgeHandlerで取得できる．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: 解答の再判定を開始する関数．
+func RejudgeSolutionHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		// URLからSolutionIDを取得
+		solutionID, err := utils.GetIntVarFromRequest(r, "solution_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		solution, err := database.SelectSolutionBySolutionID(db, solutionID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		if err := authorizeRejudge(db, claims.UserID, solution.ProblemID); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		job := async.StartRejudge(db, models.RejudgeJob{UserID: claims.UserID, ProblemID: solution.ProblemID, SolutionID: solution.SolutionID}, []models.Solution{*solution})
+		utils.SendJSONResponse(w, http.StatusAccepted, job)
+	}
+}
+
+// RejudgeProblemHandlerは，指定された問題の全ての解答，または指定された提出日時の範囲の解答を再判定するHTTPハンドラ関数である．
+// リクエストボディは省略でき，`models.RejudgeRequest`構造体の`from`と`to`で提出日時の範囲を指定できる．
+// 再判定を要求できるのは，問題の所有者と管理者のみである．
+// 再判定はバックグラウンドで行われ，HTTPステータスコード202(Accepted)と共に開始時点の再判定の状態を返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: 問題の解答の再判定を開始する関数．
+func RejudgeProblemHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		// URLからProblemIDを取得
+		problemID, err := utils.GetIntVarFromRequest(r, "problem_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		var request models.RejudgeRequest
+		if r.ContentLength != 0 {
+			if err := utils.DecodeRequestBody(r, &request); err != nil {
+				utils.SendErrorResponse(w, err)
+				return
+			}
+		}
+		request.ProblemID = problemID
+		if err := webutils.ValidateRejudgeRequest(&request, false); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		if _, err := database.SelectProblemByProblemID(db, problemID); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if err := authorizeRejudge(db, claims.UserID, problemID); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		solutions, err := database.SelectSolutionsForRejudge(db, problemID, request.From, request.To)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		job := async.StartRejudge(db, models.RejudgeJob{UserID: claims.UserID, ProblemID: problemID, From: request.From, To: request.To}, solutions)
+		utils.SendJSONResponse(w, http.StatusAccepted, job)
+	}
+}
+
+// RejudgeRangeHandlerは，指定された提出日時の範囲に提出された解答を再判定するHTTPハンドラ関数である．
+// リクエストボディから`models.RejudgeRequest`構造体にデコードし，`from`と`to`は必須である．`problem_id`を指定した場合はその問題の解答に限定する．
+// 全ての問題の解答を対象にできるため，再判定を要求できるのは管理者のみである．
+// 再判定はバックグラウンドで行われ，HTTPステータスコード202(Accepted)と共に開始時点の再判定の状態を返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: 提出日時の範囲の解答の再判定を開始する関数．
+func RejudgeRangeHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		if !webutils.IsAdmin(claims.UserID) {
+			utils.SendErrorResponse(w, commonerrors.NewAccessDeniedError("You do not have permission to access this resource"))
+			return
+		}
+
+		var request models.RejudgeRequest
+		if err := utils.DecodeRequestBody(r, &request); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if err := webutils.ValidateRejudgeRequest(&request, true); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		solutions, err := database.SelectSolutionsForRejudge(db, request.ProblemID, request.From, request.To)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		job := async.StartRejudge(db, models.RejudgeJob{UserID: claims.UserID, ProblemID: request.ProblemID, From: request.From, To: request.To}, solutions)
+		utils.SendJSONResponse(w, http.StatusAccepted, job)
+	}
+}
+
+// GetRejudgeHandlerは，指定された再判定IDの再判定の進捗と，各解答の判定結果の変化を取得するHTTPハンドラ関数である．
+// 再判定の状態を取得できるのは，再判定を要求したユーザーと管理者のみである．
+// 成功した場合，HTTPステータスコード200(OK)と共に再判定の状態を返す．
+//
+// 戻り値:
+// - http.HandlerFunc: 再判定の状態を取得する関数．
+func GetRejudgeHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value("userClaims").(*models.Claims)
+		if !ok {
+			// 認証情報が見つからない場合の処理
+			return
+		}
+
+		// URLからRejudgeIDを取得
+		rejudgeID, err := utils.GetIntVarFromRequest(r, "rejudge_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		job, err := async.GetRejudgeJob(rejudgeID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		if job.UserID != claims.UserID && !webutils.IsAdmin(claims.UserID) {
+			utils.SendErrorResponse(w, commonerrors.NewAccessDeniedError("You do not have permission to access this resource"))
+			return
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, job)
+	}
+}
+
+// authorizeRejudgeは，ユーザーが指定された問題の解答の再判定を要求できるか（管理者，または問題の所有者であるか）を確認する．
+func authorizeRejudge(db *sql.DB, userID, problemID int) error {
+	if webutils.IsAdmin(userID) {
+		return nil
+	}
+	return database.IsProblemOwner(db, userID, problemID)
+}
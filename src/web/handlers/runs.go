@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/common/utils"
+	"procon_web_service/src/web/async"
+	webutils "procon_web_service/src/web/utils"
+	"time"
+)
+
+// CustomRunHandlerは，提出を作成せずに任意の入力でコードを実行する（カスタム実行）HTTPハンドラ関数である．
+// リクエストボディから`models.CustomRun`構造体にデコードし，言語，コード，標準入力を検証した上でジャッジサーバーで実行する．
+// 問題IDが指定されている場合はその問題の実行制限を，指定されていない場合は既定の実行制限を適用する．
+// 実行結果はデータベースに保存されず，HTTPステータスコード200(OK)と共に標準出力，標準エラー出力，終了状態，実行時間，メモリ使用量を返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: カスタム実行を行う関数．
+func CustomRunHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var run models.CustomRun
+
+		if err := utils.DecodeRequestBody(r, &run); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 実行を受け付けている言語であること，および標準入力のサイズを検証
+		if err := webutils.ValidateCustomRun(&run); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		// 判定と同じく，キューでの待機とコンパイルを含めて2分で打ち切る
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Minute)
+		defer cancel()
+
+		result, err := async.RunCustom(ctx, db, run)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, result)
+	}
+}
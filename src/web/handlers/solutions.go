@@ -6,12 +6,14 @@ import (
 	"procon_web_service/src/common/models"
 	"procon_web_service/src/common/utils"
 	"procon_web_service/src/web/database"
+	webutils "procon_web_service/src/web/utils"
 )
 
 // SubmitSolutionHandlerは，ユーザーからの解答提出を処理するHTTPハンドラ関数である．
 // この関数はHTTPリクエストから解答データをデコードし，デコードされた解答をデータベースに保存する．
 // 解答データは，リクエストボディから`models.Solution`構造体にデコードされ，URLパラメータから問題IDを取得して構造体にセットする．
 // ユーザーの認証情報は，HTTPリクエストのコンテキストから取得し，解答にユーザーIDをセットする．
+// 解答の言語は，言語設定ファイルに定義され提出を受け付けている言語である必要がある．
 // 解答のデータベースへの保存が成功した場合，HTTPステータスコード201(Created)と保存された解答データをレスポンスとして返す．
 // 各ステップでエラーが発生した場合，適切なHTTPステータスコードとエラーメッセージで応答する．
 // 非同期処理のトリガーはWebSocketHandler内で行われるため，この関数ではデータベースへの保存と初期応答のみを担当する．
@@ -46,6 +48,12 @@ func SubmitSolutionHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// 提出を受け付けている言語であることを検証
+		if err := webutils.ValidateSolutionLanguage(&solution); err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
 		if solutionID, err := database.CreateSolution(db, solution); err != nil {
 			utils.SendErrorResponse(w, err)
 			return
@@ -150,6 +158,7 @@ func GetSolutionsByProblemIDHandler(db *sql.DB) http.HandlerFunc {
 // GetSolutionResultHandlerは，特定の解答IDに対する判定結果を取得するHTTPハンドラ関数である．
 // この関数は，リクエストから解答IDを取得し，その解答IDに紐づく判定結果をデータベースから検索する．
 // 判定結果は，`models.ResultDetail`構造体でクライアントに返される．
+// サンプルケースと詳細を公開するテストケース以外のテストケースは，名前とチェッカーのメッセージを伏せて返す．
 // データベースからの検索に失敗した場合や，該当する判定結果が存在しない場合には，適切なエラーメッセージと共にエラーレスポンスを返す．
 // 検索が成功した場合は，HTTPステータスコード200(OK)と共に，判定結果を含むレスポンスを返す．
 //
@@ -173,6 +182,112 @@ func GetSolutionResultHandler(db *sql.DB) http.HandlerFunc {
 			return
 		}
 
+		// 非公開のテストケースの名前と内容を伏せるため，解答の対象の問題の設定を取得
+		solution, err := database.SelectSolutionBySolutionID(db, solutionID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		problem, err := database.SelectProblemByProblemID(db, solution.ProblemID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		resultDetail.HideCases(problem)
+
 		utils.SendJSONResponse(w, http.StatusOK, resultDetail)
 	}
 }
+
+// GetSolutionResultHistoryHandlerは，特定の解答IDに対する全ての試行の判定結果を取得するHTTPハンドラ関数である．
+// 再判定が行われた解答では，以前の判定結果も含めて試行番号の昇順で返す．
+// 非公開のテストケースは，GetSolutionResultHandlerと同様に名前とチェッカーのメッセージを伏せて返す．
+// 成功した場合は，HTTPステータスコード200(OK)と共に，判定結果のリストを含むレスポンスを返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: 特定の解答の判定結果の履歴を取得する処理を行う関数．
+func GetSolutionResultHistoryHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// URLからSolutionIDを取得
+		solutionID, err := utils.GetIntVarFromRequest(r, "solution_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		solution, err := database.SelectSolutionBySolutionID(db, solutionID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		problem, err := database.SelectProblemByProblemID(db, solution.ProblemID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		history, err := database.SelectResultHistoryBySolutionID(db, solutionID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+		for i := range history {
+			history[i].HideCases(problem)
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, history)
+	}
+}
+
+// GetSolutionStatusHandlerは，特定の解答IDに対する判定状況を取得するHTTPハンドラ関数である．
+// 判定状況は Pending，Queued，Compiling，Running（判定が終了したテストケースの数と総数を含む），Judged，SystemError のいずれかである．
+// WebSocket接続が切断された場合も，クライアントはこのハンドラを定期的に呼び出して判定の終了を確認できる．
+// 判定状況が Judged の場合は，GetSolutionResultHandlerと同様に非公開のテストケースを伏せた最新の判定結果を含めて返す．
+// 成功した場合は，HTTPステータスコード200(OK)と共に，判定状況を含むレスポンスを返す．
+//
+// パラメータ:
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - http.HandlerFunc: 特定の解答の判定状況を取得する処理を行う関数．
+func GetSolutionStatusHandler(db *sql.DB) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// URLからSolutionIDを取得
+		solutionID, err := utils.GetIntVarFromRequest(r, "solution_id")
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		status, err := database.SelectSubmissionStatus(db, solutionID)
+		if err != nil {
+			utils.SendErrorResponse(w, err)
+			return
+		}
+
+		if status.Status == models.SubmissionStatusJudged {
+			solution, err := database.SelectSolutionBySolutionID(db, solutionID)
+			if err != nil {
+				utils.SendErrorResponse(w, err)
+				return
+			}
+			problem, err := database.SelectProblemByProblemID(db, solution.ProblemID)
+			if err != nil {
+				utils.SendErrorResponse(w, err)
+				return
+			}
+			resultDetail, err := database.SelectResultDetailBySolutionID(db, solutionID)
+			if err != nil {
+				utils.SendErrorResponse(w, err)
+				return
+			}
+			resultDetail.HideCases(problem)
+			status.Result = resultDetail
+		}
+
+		utils.SendJSONResponse(w, http.StatusOK, status)
+	}
+}
@@ -15,6 +15,9 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// judgeTimeoutは，WebSocketを介して依頼された解答の判定（キューでの待機を含む）を打ち切るまでの時間である．
+const judgeTimeout = 2 * time.Minute
+
 // upgraderは，HTTPリクエストをWebSocketプロトコルにアップグレードするための設定を保持するwebsocket.Upgrader構造体のインスタンスである．
 // このインスタンスは，WebSocket通信の際に使用され，ReadBufferSizeおよびWriteBufferSizeプロパティによって，読み取りと書き込みのバッファサイズが指定される．
 // CheckOrigin関数は，WebSocket接続を試みるオリジンの検証を行う．ここでは，すべてのオリジンからの接続を許可するためにtrueを返している．
@@ -32,8 +35,9 @@ var upgrader = websocket.Upgrader{
 
 // WebSocketHandlerは，WebSocket通信を介して解答の提出と判定結果の非同期通知を処理するHTTPハンドラ関数である．
 // クライアントからWebSocket接続が確立されると，この関数は接続をアップグレードし，クライアントとの間でメッセージを非同期にやり取りする準備をする．
-// クライアントから送信された解答データを受け取り，非同期に判定処理を行う．解答データの判定は，JudgeSolutionAsync関数によって実行され，判定結果はWebSocketを通じてクライアントに通知される．
+// クライアントから送信された解答データを受け取り，非同期に判定処理を行う．`type` が "custom_run" のメッセージはカスタム実行として，解答を作成せずに実行結果を通知する．解答データの判定は，JudgeSolutionAsync関数によって実行され，判定結果はWebSocketを通じてクライアントに通知される．
 // WebSocket接続は，通信が完了するまで，またはエラーが発生するまで維持される．エラーが発生した場合，適切なエラーメッセージがクライアントに送信される．
+// 判定はWebSocket接続が切断されても続行され，判定状況と判定結果はGetSolutionStatusHandlerで取得できる．
 // このハンドラは，Webサーバーとjudge-server間で別の通信メカニズム（例えばHTTPリクエスト）を使用して，解答の判定を非同期に行う設計になっている．
 //
 // パラメータ:
@@ -73,15 +77,44 @@ func WebSocketHandler(db *sql.DB) func(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 
-			// 解答提出リクエストの処理
-			var solution models.Solution
-			if err := json.Unmarshal(message, &solution); err != nil {
-				async.SendError(conn, "Failed to unmarshal solution: "+err.Error())
+			// メッセージの種類を取得(指定されていない場合は解答提出リクエスト)
+			var envelope struct {
+				Type string `json:"type"`
+			}
+			if err := json.Unmarshal(message, &envelope); err != nil {
+				async.SendError(conn, "Failed to unmarshal message: "+err.Error())
 				continue
 			}
 
-			// 解答に基づいて非同期処理をトリガー
-			go async.JudgeSolutionAsync(ctx, db, solution, conn)
+			switch envelope.Type {
+			case "custom_run":
+				// カスタム実行リクエストの処理(解答は作成しない)
+				var run models.CustomRun
+				if err := json.Unmarshal(message, &run); err != nil {
+					async.SendError(conn, "Failed to unmarshal custom run: "+err.Error())
+					continue
+				}
+				if err := webutils.ValidateCustomRun(&run); err != nil {
+					async.SendError(conn, err.Error())
+					continue
+				}
+				go async.RunCustomAsync(ctx, db, run, conn)
+			default:
+				// 解答提出リクエストの処理
+				var solution models.Solution
+				if err := json.Unmarshal(message, &solution); err != nil {
+					async.SendError(conn, "Failed to unmarshal solution: "+err.Error())
+					continue
+				}
+
+				// 解答に基づいて非同期処理をトリガー
+				// WebSocket接続が切断されても判定を続行して結果を保存するよう，接続とは独立したコンテキストで判定する
+				go func() {
+					judgeCtx, judgeCancel := context.WithTimeout(context.Background(), judgeTimeout)
+					defer judgeCancel()
+					async.JudgeSolutionAsync(judgeCtx, db, solution, conn)
+				}()
+			}
 		}
 	}
 }
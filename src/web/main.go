@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"procon_web_service/src/common/config"
 	"procon_web_service/src/common/middleware"
+	"procon_web_service/src/common/signature"
+	"procon_web_service/src/web/async"
+	"procon_web_service/src/web/database"
 	"procon_web_service/src/web/routes"
+	"procon_web_service/src/web/utils"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
@@ -35,9 +41,34 @@ func initDB() *sql.DB {
 }
 
 func main() {
+	// 言語設定ファイルの読み込みと検証(以降は変更を監視して再読み込み)
+	if err := config.InitLanguages(); err != nil {
+		log.Fatal(err)
+	}
+
 	db = initDB()
 	defer db.Close()
 
+	// 既存のデータベースのスキーマを最新の版に更新
+	if err := database.Migrate(db); err != nil {
+		log.Fatal(err)
+	}
+
+	// テストデータの版を導入する前に作成された問題のテストデータを最初の版に移行(失敗した問題は次回の起動時に再び移行)
+	if err := utils.MigrateLegacyTestSets(context.Background(), db); err != nil {
+		log.Printf("Legacy test sets are not fully migrated and the affected problems cannot be judged: %v", err)
+	}
+
+	// ジャッジサーバーとの通信の署名に用いる共有鍵の読み込みと判定キューへの接続
+	signer, err := signature.New()
+	if err != nil {
+		log.Fatal(err)
+	}
+	async.Configure(signer)
+
+	// 判定キューから判定結果を取り出してデータベースに保存
+	async.StartResultConsumer(context.Background(), db)
+
 	// マルチプレクサーの作成
 	router := mux.NewRouter()
 
@@ -29,13 +29,18 @@ func RegisterApiRoutes(router *mux.Router, db *sql.DB) {
 	publicRoutes.HandleFunc("/users", handlers.GetUserByUsernameHandler(db)).Methods(http.MethodGet)         // ユーザー名に基づくユーザープロファイルの取得(クエリパラメータ?usernameで取得)
 
 	// 解答に関するAPI
-	publicRoutes.HandleFunc("/solutions/{solution_id}", handlers.GetSolutionDetailsHandler(db)).Methods(http.MethodGet)              // 指定された解答IDの解答を取得
-	publicRoutes.HandleFunc("/solutions/{solution_id}/result", handlers.GetSolutionResultHandler(db)).Methods(http.MethodGet)        // 解答の結果の取得
-	publicRoutes.HandleFunc("/problems/{problem_id}/solutions", handlers.GetSolutionsByProblemIDHandler(db)).Methods(http.MethodGet) // 指定された問題IDの解答を取得
+	publicRoutes.HandleFunc("/solutions/{solution_id}", handlers.GetSolutionDetailsHandler(db)).Methods(http.MethodGet)               // 指定された解答IDの解答を取得
+	publicRoutes.HandleFunc("/solutions/{solution_id}/result", handlers.GetSolutionResultHandler(db)).Methods(http.MethodGet)         // 解答の結果の取得
+	publicRoutes.HandleFunc("/solutions/{solution_id}/status", handlers.GetSolutionStatusHandler(db)).Methods(http.MethodGet)         // 解答の判定状況の取得
+	publicRoutes.HandleFunc("/solutions/{solution_id}/results", handlers.GetSolutionResultHistoryHandler(db)).Methods(http.MethodGet) // 解答の再判定を含む全ての判定結果の取得
+	publicRoutes.HandleFunc("/problems/{problem_id}/solutions", handlers.GetSolutionsByProblemIDHandler(db)).Methods(http.MethodGet)  // 指定された問題IDの解答を取得
 
 	// ユーザーに対する解答の取得
 	publicRoutes.HandleFunc("/users/{user_id}/solutions", handlers.GetSolutionsByUserIDHandler(db)).Methods(http.MethodGet) // ユーザーIDに基づく解答の取得
 
+	// 言語に関するAPI
+	publicRoutes.HandleFunc("/languages", handlers.GetLanguagesHandler()).Methods(http.MethodGet) // 提出に利用できる言語の一覧の取得
+
 	// ルートURLのハンドラーを設定
 	publicRoutes.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "Welcome to the ProCon Web Service :)")
@@ -49,6 +54,15 @@ func RegisterApiRoutes(router *mux.Router, db *sql.DB) {
 	authRoutes.HandleFunc("/problems", handlers.UploadProblemHandler(db)).Methods(http.MethodPost) // 問題の投稿(認証が必要)
 	// 解答に関するAPI
 	authRoutes.HandleFunc("/problems/{problem_id}/solutions", handlers.SubmitSolutionHandler(db)).Methods(http.MethodPost) // 解答の提出(認証が必要)
+	authRoutes.HandleFunc("/runs", handlers.CustomRunHandler(db)).Methods(http.MethodPost)                                 // 任意の入力でのコードの実行(認証が必要)
+	// 再判定に関するAPI
+	authRoutes.HandleFunc("/solutions/{solution_id}/rejudge", handlers.RejudgeSolutionHandler(db)).Methods(http.MethodPost) // 解答の再判定(問題の所有者または管理者のみ)
+	authRoutes.HandleFunc("/problems/{problem_id}/rejudge", handlers.RejudgeProblemHandler(db)).Methods(http.MethodPost)    // 問題の解答の再判定(問題の所有者または管理者のみ)
+	authRoutes.HandleFunc("/rejudge", handlers.RejudgeRangeHandler(db)).Methods(http.MethodPost)                            // 提出日時の範囲の解答の再判定(管理者のみ)
+	authRoutes.HandleFunc("/rejudges/{rejudge_id}", handlers.GetRejudgeHandler()).Methods(http.MethodGet)                   // 再判定の進捗と判定結果の変化の取得(要求したユーザーまたは管理者のみ)
+	// ジャッジノードに関するAPI
+	authRoutes.HandleFunc("/judge-nodes", handlers.ListJudgeNodesHandler()).Methods(http.MethodGet)                       // ジャッジノードの一覧と負荷の取得(管理者のみ)
+	authRoutes.HandleFunc("/judge-nodes/{node_id}/state", handlers.UpdateJudgeNodeStateHandler()).Methods(http.MethodPut) // ジャッジノードの状態の変更(管理者のみ)
 	// ユーザーに関するAPI
 	authRoutes.HandleFunc("/users/logout", handlers.LogoutUserHandler(db)).Methods(http.MethodPost) // ログアウト(認証が必要)
 
@@ -0,0 +1,384 @@
+package testarchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"sort"
+	"strings"
+)
+
+const (
+	maxArchiveFiles = 10000   // アーカイブに含めることのできるファイルの最大数
+	maxArchiveSize  = 1 << 30 // アーカイブに含まれるファイルの展開後の合計サイズの上限(バイト)
+
+	casePatternName = "{name}" // ファイルのパターンのうちテストケースの名前に対応する部分
+)
+
+// defaultCasePatternsは，パターンが指定されなかった場合に順に試す入力ファイルと出力ファイルのパターンの組である．
+// 最初に1つ以上のテストケースが見つかったパターンが用いられる．
+var defaultCasePatterns = [][2]string{
+	{"{name}.in", "{name}.out"},
+	{"in/{name}", "out/{name}"},
+}
+
+// FileAdderは，テストデータのアップロードにファイルを追加するインターフェースである．minio.TestSetUploadがこれを実装する．
+type FileAdder interface {
+	AddFile(fileType, fileName string, reader io.Reader, size int64) error
+}
+
+// Archiveは，アップロードされたテストケースのアーカイブ（ZIPまたはtar.gz）に含まれる入力ファイルと出力ファイルの組を表す構造体である．
+// アーカイブの内容はメモリに展開せず，UploadでMinIOに転送する際に改めて読み込む．
+type Archive struct {
+	fileHeader *multipart.FileHeader
+	entries    map[string]archiveCaseFile // アーカイブ内のパスをキーとした，テストケースのファイル
+	caseNames  []string                   // テストケースのファイル名（昇順）
+}
+
+// archiveCaseFileは，アーカイブに含まれるテストケースの1つのファイルを表す．
+type archiveCaseFile struct {
+	fileType string // ファイルのタイプ（'in' または 'out'）
+	fileName string // MinIOに保存する際のファイル名
+}
+
+// archiveEntryは，アーカイブに含まれる1つの通常のファイルを表す．
+type archiveEntry struct {
+	name string
+	size int64 // アーカイブのヘッダーに記録された展開後のサイズ
+	open func() (*entryReader, error)
+}
+
+// entryReaderは，アーカイブから展開したファイルの内容を，ヘッダーに記録された展開後のサイズまでに制限して読み込むReaderである．
+// ヘッダーのサイズを偽ったアーカイブでも，展開後の合計サイズがParseで確認した上限を超えないようにする．
+type entryReader struct {
+	reader    io.Reader
+	name      string
+	remaining int64 // ヘッダーに記録されたサイズのうち，まだ読み込んでいないバイト数
+	err       error // サイズを超えた場合のエラー
+}
+
+// Parseは，マルチパートフォームデータに含まれるテストケースのアーカイブを読み込み，入力ファイルと出力ファイルを組にする関数である．
+//
+// アーカイブの形式はファイル名の拡張子（.zip，.tar.gz，.tgz）で判定する．アーカイブ内のファイルのパスは入力ファイルと出力ファイルのパターンと照合され，
+// パターンの "{name}" に対応する部分（"/" を含まない）が同じ入力ファイルと出力ファイルが1つのテストケースとなる．
+// 全てのファイルが1つのディレクトリに含まれている場合は，そのディレクトリを基準にパターンと照合する．いずれのパターンにも一致しないファイルは無視する．
+// パターンが指定されない場合は "{name}.in" と "{name}.out"，"in/{name}" と "out/{name}" を順に試す．
+// テストケースのファイル名は "{name}" に対応する部分に拡張子 .txt を付加したもの（既に .txt で終わる場合はそのまま）となる．
+// アーカイブが含まれない場合はnilを返す．
+//
+// パラメータ:
+// - archiveFiles []*multipart.FileHeader: アップロードされたアーカイブのリスト（1つのみ指定できる）．
+// - inputPattern string: 入力ファイルのパスのパターン（例："{name}.in"）．
+// - outputPattern string: 出力ファイルのパスのパターン（例："{name}.out"）．
+//
+// 戻り値:
+// - *Archive: 入力ファイルと出力ファイルを組にしたアーカイブ，またはnil．
+// - error: パターンが不正な場合，アーカイブを読み込めない場合，または対応する出力ファイル（入力ファイル）が存在しない場合のエラー，またはnil．
+func Parse(archiveFiles []*multipart.FileHeader, inputPattern, outputPattern string) (*Archive, error) {
+	if len(archiveFiles) == 0 {
+		return nil, nil
+	}
+	if len(archiveFiles) > 1 {
+		return nil, commonerrors.NewFileValidationError("test_archive にはアーカイブを1つだけ指定してください")
+	}
+
+	patterns := defaultCasePatterns
+	if inputPattern != "" || outputPattern != "" {
+		if err := validateCasePatterns(inputPattern, outputPattern); err != nil {
+			return nil, err
+		}
+		patterns = [][2]string{{inputPattern, outputPattern}}
+	}
+
+	archive := &Archive{fileHeader: archiveFiles[0]}
+	var names []string
+	var totalSize int64
+	err := archive.walk(func(entry archiveEntry) error {
+		if len(names) >= maxArchiveFiles {
+			return commonerrors.NewFileValidationError(fmt.Sprintf("アーカイブに含まれるファイルが多すぎます（上限は%d個です）", maxArchiveFiles))
+		}
+		// 展開時にはヘッダーに記録されたサイズを超えて読み込まないため，その合計で展開後のサイズを制限できる
+		if totalSize += entry.size; entry.size < 0 || totalSize > maxArchiveSize {
+			return commonerrors.NewFileValidationError(fmt.Sprintf("アーカイブの展開後のサイズが大きすぎます（上限は%dMBです）", maxArchiveSize>>20))
+		}
+		names = append(names, entry.name)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	trimmedNames := trimCommonDir(names)
+	// 正規化すると同じになるパスが含まれる場合は，アップロード時にどちらのファイルを用いるか定まらないため拒否する
+	seen := make(map[string]string, len(names))
+	for i, name := range trimmedNames {
+		if other, exists := seen[name]; exists {
+			return nil, commonerrors.NewFileValidationError(fmt.Sprintf("アーカイブ内のファイルのパスが重複しています: %s，%s", other, names[i]))
+		}
+		seen[name] = names[i]
+	}
+	for _, pattern := range patterns {
+		entries, caseNames, err := pairCaseFiles(trimmedNames, pattern[0], pattern[1])
+		if err != nil {
+			return nil, err
+		}
+		if len(caseNames) > 0 {
+			// アップロード時にアーカイブ内のパスで照合できるよう，共通のディレクトリを除く前のパスをキーとする
+			archive.entries = make(map[string]archiveCaseFile, len(entries))
+			for i, name := range names {
+				if caseFile, ok := entries[trimmedNames[i]]; ok {
+					archive.entries[name] = caseFile
+				}
+			}
+			archive.caseNames = caseNames
+			return archive, nil
+		}
+	}
+	return nil, commonerrors.NewFileValidationError("アーカイブにパターンと一致するテストケースが存在しません")
+}
+
+// CaseFileNamesは，アーカイブに含まれるテストケースのファイル名（MinIOに保存する際のファイル名）のリストを昇順で返す．
+func (a *Archive) CaseFileNames() []string {
+	return a.caseNames
+}
+
+// Uploadは，アーカイブに含まれるテストケースの入力ファイルと出力ファイルを，テストデータのアップロードに追加する．
+// ファイルはアーカイブから展開しながら1つずつMinIOに転送されるため，アーカイブ全体をメモリに展開することはない．
+//
+// パラメータ:
+// - upload FileAdder: ファイルを追加するテストデータのアップロード．
+//
+// 戻り値:
+// - error: アーカイブの読み込み，またはアップロード中に発生したエラー，またはnil．
+func (a *Archive) Upload(upload FileAdder) error {
+	return a.walk(func(entry archiveEntry) error {
+		caseFile, ok := a.entries[entry.name]
+		if !ok {
+			return nil
+		}
+		reader, err := entry.open()
+		if err != nil {
+			return commonerrors.NewFileValidationError(fmt.Sprintf("アーカイブの %s を読み込めません: %v", entry.name, err))
+		}
+		err = upload.AddFile(caseFile.fileType, caseFile.fileName, reader, entry.size)
+		if reader.err != nil {
+			// アップロードのエラーではなく，アーカイブが不正であることを返す
+			return reader.err
+		}
+		return err
+	})
+}
+
+// walkは，アーカイブに含まれる通常のファイルを順に読み込み，それぞれについて関数を呼び出す内部関数である．
+// tar.gz形式のアーカイブは先頭から順にしか読み込めないため，関数の呼び出し中にのみファイルの内容を読み込むことができる．
+func (a *Archive) walk(fn func(entry archiveEntry) error) error {
+	file, err := a.fileHeader.Open()
+	if err != nil {
+		return commonerrors.NewFileValidationError("アーカイブを開けません")
+	}
+	defer file.Close()
+
+	fileName := strings.ToLower(a.fileHeader.Filename)
+	switch {
+	case strings.HasSuffix(fileName, ".zip"):
+		return walkZip(file, a.fileHeader.Size, fn)
+	case strings.HasSuffix(fileName, ".tar.gz"), strings.HasSuffix(fileName, ".tgz"):
+		return walkTarGz(file, fn)
+	default:
+		return commonerrors.NewFileValidationError("test_archive には .zip，.tar.gz，.tgz のいずれかのアーカイブを指定してください")
+	}
+}
+
+// walkZipは，ZIP形式のアーカイブに含まれる通常のファイルを順に読み込む内部関数である．
+func walkZip(file multipart.File, size int64, fn func(entry archiveEntry) error) error {
+	reader, err := zip.NewReader(file, size)
+	if err != nil {
+		return commonerrors.NewFileValidationError(fmt.Sprintf("ZIPファイルを読み込めません: %v", err))
+	}
+	for _, f := range reader.File {
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		f := f
+		size := int64(f.UncompressedSize64)
+		var opened io.ReadCloser
+		err := fn(archiveEntry{
+			name: f.Name,
+			size: size,
+			open: func() (*entryReader, error) {
+				var err error
+				if opened, err = f.Open(); err != nil {
+					return nil, err
+				}
+				return newEntryReader(opened, f.Name, size), nil
+			},
+		})
+		if opened != nil {
+			opened.Close()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkTarGzは，tar.gz形式のアーカイブに含まれる通常のファイルを先頭から順に読み込む内部関数である．
+func walkTarGz(file multipart.File, fn func(entry archiveEntry) error) error {
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return commonerrors.NewFileValidationError(fmt.Sprintf("tar.gzファイルを読み込めません: %v", err))
+	}
+	defer gzipReader.Close()
+
+	reader := tar.NewReader(gzipReader)
+	for {
+		header, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return commonerrors.NewFileValidationError(fmt.Sprintf("tar.gzファイルを読み込めません: %v", err))
+		}
+		if !header.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		name, size := header.Name, header.Size
+		err = fn(archiveEntry{
+			name: name,
+			size: size,
+			open: func() (*entryReader, error) { return newEntryReader(reader, name, size), nil },
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// newEntryReaderは，展開したファイルの内容をヘッダーに記録されたサイズまでに制限して読み込むReaderを生成する内部関数である．
+func newEntryReader(reader io.Reader, name string, size int64) *entryReader {
+	// サイズを超えたことを検出するため，1バイト余分に読み込めるようにする
+	return &entryReader{reader: io.LimitReader(reader, size+1), name: name, remaining: size}
+}
+
+// Readは，ファイルの内容を読み込み，ヘッダーに記録されたサイズを超えた場合はエラーを返す．
+func (r *entryReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.reader.Read(p)
+	if r.remaining -= int64(n); r.remaining < 0 {
+		r.err = commonerrors.NewFileValidationError(fmt.Sprintf("アーカイブの %s の展開後のサイズがヘッダーに記録されたサイズを超えています", r.name))
+		return n + int(r.remaining), r.err
+	}
+	return n, err
+}
+
+// validateCasePatternsは，入力ファイルと出力ファイルのパターンがそれぞれ "{name}" を1つだけ含み，互いに異なることを検証する内部関数である．
+func validateCasePatterns(inputPattern, outputPattern string) error {
+	for field, pattern := range map[string]string{"archive_input_pattern": inputPattern, "archive_output_pattern": outputPattern} {
+		if strings.Count(pattern, casePatternName) != 1 {
+			return commonerrors.NewValidationError(field, fmt.Sprintf("The pattern must contain %s exactly once.", casePatternName))
+		}
+	}
+	if inputPattern == outputPattern {
+		return commonerrors.NewValidationError("archive_output_pattern", "The output pattern must differ from the input pattern.")
+	}
+	return nil
+}
+
+// pairCaseFilesは，アーカイブ内のパスを入力ファイルと出力ファイルのパターンと照合し，名前が同じ入力ファイルと出力ファイルを組にする内部関数である．
+// 入力ファイルに対応する出力ファイル（出力ファイルに対応する入力ファイル）が存在しない場合や，ファイル名が重複する場合はエラーを返す．
+func pairCaseFiles(names []string, inputPattern, outputPattern string) (map[string]archiveCaseFile, []string, error) {
+	entries := make(map[string]archiveCaseFile)
+	inputs := make(map[string]string)  // テストケースのファイル名から入力ファイルのパスへの対応
+	outputs := make(map[string]string) // テストケースのファイル名から出力ファイルのパスへの対応
+
+	for _, name := range names {
+		for _, candidate := range []struct {
+			pattern  string
+			fileType string
+			files    map[string]string
+		}{
+			{inputPattern, models.TestSetFileInput, inputs},
+			{outputPattern, models.TestSetFileOutput, outputs},
+		} {
+			caseName, ok := matchCasePattern(candidate.pattern, name)
+			if !ok {
+				continue
+			}
+			fileName := caseFileName(caseName)
+			if other, exists := candidate.files[fileName]; exists {
+				return nil, nil, commonerrors.NewFileValidationError(fmt.Sprintf("テストケース %s のファイルが重複しています: %s，%s", fileName, other, name))
+			}
+			candidate.files[fileName] = name
+			entries[name] = archiveCaseFile{fileType: candidate.fileType, fileName: fileName}
+		}
+	}
+
+	caseNames := make([]string, 0, len(inputs))
+	for fileName, name := range inputs {
+		if _, ok := outputs[fileName]; !ok {
+			return nil, nil, commonerrors.NewFileValidationError(fmt.Sprintf("入力ファイル %s に対応する出力ファイルが存在しません", name))
+		}
+		caseNames = append(caseNames, fileName)
+	}
+	for fileName, name := range outputs {
+		if _, ok := inputs[fileName]; !ok {
+			return nil, nil, commonerrors.NewFileValidationError(fmt.Sprintf("出力ファイル %s に対応する入力ファイルが存在しません", name))
+		}
+	}
+	sort.Strings(caseNames)
+	return entries, caseNames, nil
+}
+
+// matchCasePatternは，パスがパターンと一致する場合に "{name}" に対応する部分を返す内部関数である．
+// 対応する部分が空の場合，"/" を含む場合，または "." で始まる（隠しファイル）場合は一致しないものとする．
+func matchCasePattern(pattern, name string) (string, bool) {
+	prefix, suffix, _ := strings.Cut(pattern, casePatternName)
+	if len(name) <= len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return "", false
+	}
+	caseName := name[len(prefix) : len(name)-len(suffix)]
+	if strings.Contains(caseName, "/") || strings.HasPrefix(caseName, ".") {
+		return "", false
+	}
+	return caseName, true
+}
+
+// caseFileNameは，テストケースの名前から，MinIOに保存する際のファイル名（拡張子 .txt を付加したもの）を返す内部関数である．
+func caseFileName(caseName string) string {
+	if path.Ext(caseName) == ".txt" {
+		return caseName
+	}
+	return caseName + ".txt"
+}
+
+// trimCommonDirは，全てのパスが同じ1つのディレクトリに含まれている場合に，そのディレクトリを除いたパスのリストを返す内部関数である．
+// アーカイブの作成時にテストケースのディレクトリごと圧縮した場合でも，パターンをディレクトリからの相対パスで指定できるようにする．
+func trimCommonDir(names []string) []string {
+	cleaned := make([]string, len(names))
+	for i, name := range names {
+		cleaned[i] = strings.TrimPrefix(path.Clean("/"+name), "/")
+	}
+
+	var common string
+	for i, name := range cleaned {
+		dir, _, ok := strings.Cut(name, "/")
+		if !ok || (i > 0 && dir != common) {
+			return cleaned
+		}
+		common = dir
+	}
+	for i := range cleaned {
+		cleaned[i] = strings.TrimPrefix(cleaned[i], common+"/")
+	}
+	return cleaned
+}
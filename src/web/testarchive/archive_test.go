@@ -0,0 +1,403 @@
+package testarchive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"hash/crc32"
+	"io"
+	"mime/multipart"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMatchCasePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    string
+		ok      bool
+	}{
+		{"{name}.in", "01.in", "01", true},
+		{"{name}.in", "sample_1.in", "sample_1", true},
+		{"{name}.in", "01.out", "", false},
+		{"{name}.in", ".in", "", false},
+		{"{name}.in", "dir/01.in", "", false},
+		{"{name}.in", ".hidden.in", "", false},
+		{"in/{name}", "in/01.txt", "01.txt", true},
+		{"in/{name}", "in/", "", false},
+		{"in/{name}", "out/01.txt", "", false},
+		{"in/{name}", "in/sub/01.txt", "", false},
+		{"input_{name}.txt", "input_a.txt", "a", true},
+		{"input_{name}.txt", "input_.txt", "", false},
+	}
+
+	for _, test := range tests {
+		got, ok := matchCasePattern(test.pattern, test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("matchCasePattern(%q, %q) = (%q, %v), want (%q, %v)", test.pattern, test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestTrimCommonDir(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		want  []string
+	}{
+		{"common directory", []string{"tests/01.in", "tests/01.out"}, []string{"01.in", "01.out"}},
+		{"common directory with subdirectories", []string{"tests/in/01", "tests/out/01"}, []string{"in/01", "out/01"}},
+		{"only one level is trimmed", []string{"a/b/01.in", "a/b/01.out"}, []string{"b/01.in", "b/01.out"}},
+		{"different directories", []string{"in/01", "out/01"}, []string{"in/01", "out/01"}},
+		{"file at the root", []string{"tests/01.in", "01.out"}, []string{"tests/01.in", "01.out"}},
+		{"no directory", []string{"01.in", "01.out"}, []string{"01.in", "01.out"}},
+		{"cleaned paths", []string{"./tests/01.in", "/tests//01.out", "tests/x/../02.in"}, []string{"01.in", "01.out", "02.in"}},
+		{"parent directory", []string{"../tests/01.in"}, []string{"01.in"}},
+		{"empty", []string{}, []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := trimCommonDir(test.names); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("trimCommonDir(%q) = %q, want %q", test.names, got, test.want)
+			}
+		})
+	}
+}
+
+func TestPairCaseFiles(t *testing.T) {
+	names := []string{"02.in", "01.in", "01.out", "02.out", "README.md", "01.in.bak"}
+	entries, caseNames, err := pairCaseFiles(names, "{name}.in", "{name}.out")
+	if err != nil {
+		t.Fatalf("pairCaseFiles returned error: %v", err)
+	}
+	if want := []string{"01.txt", "02.txt"}; !reflect.DeepEqual(caseNames, want) {
+		t.Errorf("case names = %q, want %q", caseNames, want)
+	}
+	wantEntries := map[string]archiveCaseFile{
+		"01.in":  {fileType: models.TestSetFileInput, fileName: "01.txt"},
+		"02.in":  {fileType: models.TestSetFileInput, fileName: "02.txt"},
+		"01.out": {fileType: models.TestSetFileOutput, fileName: "01.txt"},
+		"02.out": {fileType: models.TestSetFileOutput, fileName: "02.txt"},
+	}
+	if !reflect.DeepEqual(entries, wantEntries) {
+		t.Errorf("entries = %v, want %v", entries, wantEntries)
+	}
+}
+
+func TestPairCaseFilesKeepsTxtExtension(t *testing.T) {
+	_, caseNames, err := pairCaseFiles([]string{"in/a.txt", "out/a.txt", "in/b", "out/b"}, "in/{name}", "out/{name}")
+	if err != nil {
+		t.Fatalf("pairCaseFiles returned error: %v", err)
+	}
+	if want := []string{"a.txt", "b.txt"}; !reflect.DeepEqual(caseNames, want) {
+		t.Errorf("case names = %q, want %q", caseNames, want)
+	}
+}
+
+func TestPairCaseFilesErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		names         []string
+		inputPattern  string
+		outputPattern string
+	}{
+		{"missing output", []string{"01.in", "01.out", "02.in"}, "{name}.in", "{name}.out"},
+		{"missing input", []string{"01.in", "01.out", "02.out"}, "{name}.in", "{name}.out"},
+		{"duplicate file name", []string{"in/a", "in/a.txt", "out/a"}, "in/{name}", "out/{name}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, _, err := pairCaseFiles(test.names, test.inputPattern, test.outputPattern); err == nil {
+				t.Errorf("pairCaseFiles(%q) returned no error", test.names)
+			}
+		})
+	}
+}
+
+func TestPairCaseFilesNoMatch(t *testing.T) {
+	entries, caseNames, err := pairCaseFiles([]string{"a.txt", "b.txt"}, "{name}.in", "{name}.out")
+	if err != nil || len(entries) != 0 || len(caseNames) != 0 {
+		t.Errorf("pairCaseFiles = (%v, %q, %v), want no cases", entries, caseNames, err)
+	}
+}
+
+func TestValidateCasePatterns(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+		valid  bool
+	}{
+		{"{name}.in", "{name}.out", true},
+		{"in/{name}", "out/{name}", true},
+		{"input.txt", "{name}.out", false},
+		{"{name}.in", "{name}{name}.out", false},
+		{"{name}.txt", "{name}.txt", false},
+	}
+
+	for _, test := range tests {
+		if err := validateCasePatterns(test.input, test.output); (err == nil) != test.valid {
+			t.Errorf("validateCasePatterns(%q, %q) = %v, want valid %v", test.input, test.output, err, test.valid)
+		}
+	}
+}
+
+func TestParseAndUpload(t *testing.T) {
+	files := map[string]string{
+		"tests/in/01":      "1 2\n",
+		"tests/out/01":     "3\n",
+		"tests/in/02.txt":  "4 5\n",
+		"tests/out/02.txt": "9\n",
+		"tests/README":     "ignored\n",
+	}
+	want := map[string]string{
+		"in/01.txt":  "1 2\n",
+		"out/01.txt": "3\n",
+		"in/02.txt":  "4 5\n",
+		"out/02.txt": "9\n",
+	}
+
+	for _, format := range []struct {
+		fileName string
+		build    func(t *testing.T, files map[string]string) []byte
+	}{
+		{"cases.zip", buildZip},
+		{"cases.tar.gz", buildTarGz},
+		{"CASES.TGZ", buildTarGz},
+	} {
+		t.Run(format.fileName, func(t *testing.T) {
+			archive, err := Parse([]*multipart.FileHeader{newFileHeader(t, format.fileName, format.build(t, files))}, "", "")
+			if err != nil {
+				t.Fatalf("Parse returned error: %v", err)
+			}
+			if got := archive.CaseFileNames(); !reflect.DeepEqual(got, []string{"01.txt", "02.txt"}) {
+				t.Errorf("CaseFileNames() = %q", got)
+			}
+
+			upload := fileRecorder{}
+			if err := archive.Upload(upload); err != nil {
+				t.Fatalf("Upload returned error: %v", err)
+			}
+			if !reflect.DeepEqual(map[string]string(upload), want) {
+				t.Errorf("uploaded files = %v, want %v", upload, want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := buildZip(t, map[string]string{"01.in": "1\n", "01.out": "1\n"})
+	tests := []struct {
+		name          string
+		fileName      string
+		content       []byte
+		inputPattern  string
+		outputPattern string
+	}{
+		{"unsupported format", "cases.rar", valid, "", ""},
+		{"broken zip", "cases.zip", []byte("not a zip"), "", ""},
+		{"broken tar.gz", "cases.tar.gz", []byte("not a tar.gz"), "", ""},
+		{"no matching cases", "cases.zip", buildZip(t, map[string]string{"a.txt": "1\n"}), "", ""},
+		{"invalid pattern", "cases.zip", valid, "input", "{name}.out"},
+		{"unpaired case", "cases.zip", buildZip(t, map[string]string{"01.in": "1\n", "01.out": "1\n", "02.in": "2\n"}), "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Parse([]*multipart.FileHeader{newFileHeader(t, test.fileName, test.content)}, test.inputPattern, test.outputPattern); err == nil {
+				t.Error("Parse returned no error")
+			}
+		})
+	}
+
+	header := newFileHeader(t, "cases.zip", valid)
+	if _, err := Parse([]*multipart.FileHeader{header, header}, "", ""); err == nil {
+		t.Error("Parse returned no error for multiple archives")
+	}
+	if archive, err := Parse(nil, "", ""); archive != nil || err != nil {
+		t.Errorf("Parse(nil) = (%v, %v), want (nil, nil)", archive, err)
+	}
+}
+
+func TestParseRejectsDuplicatePaths(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+	}{
+		{"same name", []string{"01.in", "01.out", "01.in"}},
+		{"same name after normalization", []string{"tests/01.in", "tests/01.out", "tests//01.in"}},
+		{"dot segment", []string{"01.in", "01.out", "./01.out"}},
+		{"ignored file", []string{"01.in", "01.out", "README", "x/../README"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			writer := zip.NewWriter(&buf)
+			for _, name := range test.files {
+				w, err := writer.Create(name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				io.WriteString(w, "1\n")
+			}
+			if err := writer.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := Parse([]*multipart.FileHeader{newFileHeader(t, "cases.zip", buf.Bytes())}, "", "")
+			var validationError *commonerrors.FileValidationError
+			if !errors.As(err, &validationError) {
+				t.Errorf("Parse returned %v, want FileValidationError", err)
+			}
+		})
+	}
+}
+
+func TestEntryReader(t *testing.T) {
+	// ヘッダーに記録されたサイズちょうどの場合は全て読み込める
+	content, err := io.ReadAll(newEntryReader(bytes.NewReader([]byte("abc")), "01.in", 3))
+	if err != nil || string(content) != "abc" {
+		t.Errorf("ReadAll = (%q, %v), want (%q, nil)", content, err, "abc")
+	}
+
+	// ヘッダーに記録されたサイズを超える部分は読み込まずにエラーを返す
+	reader := newEntryReader(bytes.NewReader([]byte("abcdef")), "01.in", 3)
+	content, err = io.ReadAll(reader)
+	var validationError *commonerrors.FileValidationError
+	if !errors.As(err, &validationError) || string(content) != "abc" {
+		t.Errorf("ReadAll = (%q, %v), want (%q, FileValidationError)", content, err, "abc")
+	}
+	if n, err := reader.Read(make([]byte, 8)); n != 0 || err != reader.err {
+		t.Errorf("Read after exceeding = (%d, %v), want (0, %v)", n, err, reader.err)
+	}
+}
+
+func TestUploadRejectsEntryLargerThanHeader(t *testing.T) {
+	// 展開後のサイズを実際より小さく記録したZIPファイル
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"01.in": strings.Repeat("1", 1<<16), "01.out": "1\n"} {
+		w, err := writer.CreateRaw(&zip.FileHeader{
+			Name:               name,
+			Method:             zip.Store,
+			CRC32:              crc32.ChecksumIEEE([]byte(content)),
+			CompressedSize64:   uint64(len(content)),
+			UncompressedSize64: 2,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(w, content)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := Parse([]*multipart.FileHeader{newFileHeader(t, "cases.zip", buf.Bytes())}, "", "")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	upload := fileRecorder{}
+	if err := archive.Upload(upload); err == nil {
+		t.Error("Upload returned no error for an entry larger than its header")
+	}
+	if content, ok := upload["in/01.txt"]; ok && len(content) > 2 {
+		t.Errorf("uploaded %d bytes of an entry recorded as 2 bytes", len(content))
+	}
+}
+
+// fileRecorder - アップロードされたファイルの内容を "<タイプ>/<ファイル名>" をキーとして記録する FileAdder
+type fileRecorder map[string]string
+
+func (f fileRecorder) AddFile(fileType, fileName string, reader io.Reader, size int64) error {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	f[fileType+"/"+fileName] = string(content)
+	return nil
+}
+
+// buildZip - ファイルの内容からZIP形式のアーカイブを生成
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, name := range sortedNames(files) {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildTarGz - ファイルの内容からtar.gz形式のアーカイブを生成
+func buildTarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	writer := tar.NewWriter(gzipWriter)
+	for _, name := range sortedNames(files) {
+		if err := writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(files[name])), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(writer, files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// newFileHeader - アーカイブの内容をマルチパートフォームデータとして読み込み，test_archive のファイルヘッダーを返す
+func newFileHeader(t *testing.T, fileName string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("test_archive", fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["test_archive"][0]
+}
+
+func sortedNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/web/config"
+)
+
+var adminUserIDs = config.NewAdminConfig().UserIDs // 管理者のユーザーIDの集合
+
+// IsAdminは，指定されたユーザーが管理者であるかどうかを判定する関数である．
+// 管理者は環境変数 ADMIN_USER_IDS で指定されたユーザーである．
+//
+// パラメータ:
+// - userID int: 判定するユーザーのID．
+//
+// 戻り値:
+// - bool: 管理者である場合はtrue，それ以外の場合はfalse．
+func IsAdmin(userID int) bool {
+	return adminUserIDs[userID]
+}
+
+// ValidateJudgeNodeStateRequestは，ジャッジノードの状態の変更リクエストの妥当性を検証する関数である．
+// 状態は active，draining，disabled のいずれかである必要がある．
+//
+// パラメータ:
+// - request *models.JudgeNodeStateRequest: 検証する状態の変更リクエスト．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateJudgeNodeStateRequest(request *models.JudgeNodeStateRequest) error {
+	switch request.State {
+	case models.JudgeNodeStateActive, models.JudgeNodeStateDraining, models.JudgeNodeStateDisabled:
+		return nil
+	}
+	return commonerrors.NewValidationError("state", "Unsupported judge node state: "+request.State+".")
+}
@@ -57,3 +57,56 @@ func ValidateFiles(inputFiles, outputFiles []*multipart.FileHeader) error {
 
 	return nil
 }
+
+// ValidateProgramFilesは，マルチパートフォームデータに含まれるチェッカーやインタラクタのファイルの妥当性を検証する．
+// これらのファイルは1つのソースファイルと，testlib.hなどの任意の数のヘッダファイル(.h，.hpp)から構成される．
+// ファイルが含まれない場合は何も検証しない．
+//
+// パラメータ:
+// - fieldName string: 検証するファイルのフォームフィールド名（例："checker_file"）．
+// - programFiles []*multipart.FileHeader: 検証するファイルのリスト．
+//
+// 戻り値:
+// - error: ファイル検証に失敗した場合のエラー．エラーはソースファイルが1つではない場合，または重複するファイル名が存在する場合に発生する．
+func ValidateProgramFiles(fieldName string, programFiles []*multipart.FileHeader) error {
+	if len(programFiles) == 0 {
+		return nil
+	}
+
+	fileNames := make(map[string]bool)
+	sourceCount := 0
+
+	for _, file := range programFiles {
+		if _, fileExists := fileNames[file.Filename]; fileExists {
+			return commonerrors.NewFileValidationError("重複するファイル名が存在します")
+		}
+		fileNames[file.Filename] = true
+
+		switch filepath.Ext(file.Filename) {
+		case ".h", ".hpp":
+		default:
+			sourceCount++
+		}
+	}
+
+	if sourceCount != 1 {
+		return commonerrors.NewFileValidationError(fmt.Sprintf("%s にはソースファイルを1つだけ含めてください", fieldName))
+	}
+
+	return nil
+}
+
+// FileNamesは，マルチパートフォームデータに含まれるファイルのファイル名のリストを返す．
+//
+// パラメータ:
+// - files []*multipart.FileHeader: ファイルのリスト．
+//
+// 戻り値:
+// - []string: ファイル名のリスト．
+func FileNames(files []*multipart.FileHeader) []string {
+	fileNames := make([]string, 0, len(files))
+	for _, file := range files {
+		fileNames = append(fileNames, file.Filename)
+	}
+	return fileNames
+}
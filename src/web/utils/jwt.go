@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 	"net/http"
+	commonconfig "procon_web_service/src/common/config"
 	commonerrors "procon_web_service/src/common/errors"
 	"procon_web_service/src/common/models"
 	"procon_web_service/src/web/config"
@@ -18,8 +19,8 @@ import (
 
 var (
 	redisClient = redis.NewClient(&redis.Options{
-		Addr:     "redis:6379",
-		Password: "",
+		Addr:     commonconfig.NewRedisConfig().Addr,
+		Password: commonconfig.NewRedisConfig().Password,
 		DB:       0,
 	})
 
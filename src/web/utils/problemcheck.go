@@ -0,0 +1,256 @@
+package utils
+
+import (
+	"fmt"
+	"path/filepath"
+	"procon_web_service/src/common/config"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+)
+
+// ValidateProblemLimitsは，問題メタデータに含まれる実行制限の妥当性を検証する．
+// 実行時間制限およびメモリ制限が指定されていない場合は既定値を設定した上で，
+// それぞれの値が許容範囲内であること，言語ごとの倍率がサポートされている言語に対する正の値であることを確認する．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の制限には既定値が設定される．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemLimits(problem *models.Problem) error {
+	if problem.TimeLimit == 0 {
+		problem.TimeLimit = models.DefaultTimeLimit
+	}
+	if problem.MemoryLimit == 0 {
+		problem.MemoryLimit = models.DefaultMemoryLimit
+	}
+
+	if problem.TimeLimit < 0 || problem.TimeLimit > models.MaxTimeLimit {
+		return commonerrors.NewValidationError("time_limit", fmt.Sprintf("The time limit must be between 1 and %d milliseconds.", models.MaxTimeLimit))
+	}
+	if problem.MemoryLimit < 0 || problem.MemoryLimit > models.MaxMemoryLimit {
+		return commonerrors.NewValidationError("memory_limit", fmt.Sprintf("The memory limit must be between 1 and %d MB.", models.MaxMemoryLimit))
+	}
+
+	for languageID, multiplier := range problem.LanguageMultipliers {
+		if _, ok := config.GetLanguageConfigByID(languageID); !ok {
+			return commonerrors.NewValidationError("language_multipliers", fmt.Sprintf("Unsupported language ID: %d.", languageID))
+		}
+		if multiplier <= 0 || multiplier > models.MaxTimeMultiplier {
+			return commonerrors.NewValidationError("language_multipliers", fmt.Sprintf("The time multiplier must be greater than 0 and at most %g.", models.MaxTimeMultiplier))
+		}
+	}
+
+	return nil
+}
+
+// ValidateProblemCheckerは，問題メタデータに含まれるチェッカーの設定の妥当性を検証する．
+// チェッカーのファイルがアップロードされ，言語が指定されていない場合は既定の言語（C++）を設定する．
+// チェッカーのファイルがアップロードされていない場合に言語が指定されているとエラーとなる．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定のチェッカーの言語には既定値が設定される．
+// - hasCheckerFile bool: チェッカーのファイルがアップロードされている場合はtrue．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemChecker(problem *models.Problem, hasCheckerFile bool) error {
+	if !hasCheckerFile {
+		if problem.CheckerLanguageID != 0 {
+			return commonerrors.NewValidationError("checker_language_id", "A checker file is required when the checker language is specified.")
+		}
+		return nil
+	}
+
+	if problem.CheckerLanguageID == 0 {
+		problem.CheckerLanguageID = models.DefaultCheckerLanguageID
+	}
+	if _, ok := config.GetLanguageConfigByID(problem.CheckerLanguageID); !ok {
+		return commonerrors.NewValidationError("checker_language_id", fmt.Sprintf("Unsupported language ID: %d.", problem.CheckerLanguageID))
+	}
+
+	return nil
+}
+
+// ValidateProblemCompareModeは，問題メタデータに含まれる出力の比較モードの妥当性を検証する．
+// 比較モードが指定されていない場合は完全一致を，比較モードが"float"で許容誤差が指定されていない場合は既定の許容誤差を設定する．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の比較モードおよび許容誤差には既定値が設定される．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemCompareMode(problem *models.Problem) error {
+	switch problem.CompareMode {
+	case "":
+		problem.CompareMode = models.CompareModeExact
+	case models.CompareModeExact, models.CompareModeLines, models.CompareModeTokens, models.CompareModeFloat, models.CompareModeCaseInsensitive:
+	default:
+		return commonerrors.NewValidationError("compare_mode", fmt.Sprintf("Unsupported compare mode: %s.", problem.CompareMode))
+	}
+
+	if problem.FloatEpsilon < 0 || problem.FloatEpsilon >= 1 {
+		return commonerrors.NewValidationError("float_epsilon", "The float epsilon must be greater than 0 and less than 1.")
+	}
+	if problem.CompareMode == models.CompareModeFloat && problem.FloatEpsilon == 0 {
+		problem.FloatEpsilon = models.DefaultFloatEpsilon
+	}
+
+	return nil
+}
+
+// ValidateProblemTypeは，問題メタデータに含まれる問題の種類とインタラクタの設定の妥当性を検証する．
+// 問題の種類が指定されていない場合は通常の問題とする．
+// インタラクティブ問題ではインタラクタのファイルが必須であり，言語が指定されていない場合は既定の言語（C++）を設定する．
+// インタラクティブ問題の判定はインタラクタが行うため，チェッカーを併用することはできない．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の問題の種類およびインタラクタの言語には既定値が設定される．
+// - hasInteractorFile bool: インタラクタのファイルがアップロードされている場合はtrue．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemType(problem *models.Problem, hasInteractorFile bool) error {
+	switch problem.ProblemType {
+	case "", models.ProblemTypeStandard:
+		problem.ProblemType = models.ProblemTypeStandard
+		if hasInteractorFile || problem.InteractorLanguageID != 0 {
+			return commonerrors.NewValidationError("problem_type", "An interactor can only be set for interactive problems.")
+		}
+		return nil
+	case models.ProblemTypeInteractive:
+	default:
+		return commonerrors.NewValidationError("problem_type", fmt.Sprintf("Unsupported problem type: %s.", problem.ProblemType))
+	}
+
+	if !hasInteractorFile {
+		return commonerrors.NewValidationError("interactor_file", "An interactor file is required for interactive problems.")
+	}
+	if problem.HasChecker() {
+		return commonerrors.NewValidationError("checker_file", "A checker cannot be used for interactive problems.")
+	}
+
+	if problem.InteractorLanguageID == 0 {
+		problem.InteractorLanguageID = models.DefaultCheckerLanguageID
+	}
+	if _, ok := config.GetLanguageConfigByID(problem.InteractorLanguageID); !ok {
+		return commonerrors.NewValidationError("interactor_language_id", fmt.Sprintf("Unsupported language ID: %d.", problem.InteractorLanguageID))
+	}
+
+	return nil
+}
+
+// ValidateProblemJudgeModeは，問題メタデータに含まれるテストケースの判定方式の妥当性を検証する．
+// 判定方式が指定されていない場合は全てのテストケースを実行する "all" を設定する．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の判定方式には既定値が設定される．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemJudgeMode(problem *models.Problem) error {
+	switch problem.JudgeMode {
+	case "":
+		problem.JudgeMode = models.JudgeModeAll
+	case models.JudgeModeAll, models.JudgeModeFailFast:
+	default:
+		return commonerrors.NewValidationError("judge_mode", fmt.Sprintf("Unsupported judge mode: %s.", problem.JudgeMode))
+	}
+	return nil
+}
+
+// ValidateProblemSubtasksは，問題メタデータに含まれるサブタスクの設定の妥当性を検証する．
+// 各サブタスクの名前が空でなく重複しないこと，配点が正の値であること，採点方式がサポートされていること，
+// およびテストケースの名前がアップロードされた入力ファイル名（拡張子の省略も可）と対応していることを確認する．
+// 採点方式が指定されていない場合は "all_or_nothing" を設定する．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の採点方式には既定値が設定される．
+// - inputFileNames []string: アップロードされた入力ファイル名のリスト．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemSubtasks(problem *models.Problem, inputFileNames []string) error {
+	caseNames := caseNameSet(inputFileNames)
+
+	subtaskNames := make(map[string]bool)
+	for i := range problem.Subtasks {
+		subtask := &problem.Subtasks[i]
+
+		if subtask.Name == "" {
+			return commonerrors.NewValidationError("subtasks", "The subtask name must not be empty.")
+		}
+		if subtaskNames[subtask.Name] {
+			return commonerrors.NewValidationError("subtasks", fmt.Sprintf("Duplicate subtask name: %s.", subtask.Name))
+		}
+		subtaskNames[subtask.Name] = true
+
+		if subtask.Points <= 0 {
+			return commonerrors.NewValidationError("subtasks", fmt.Sprintf("The points of subtask %s must be greater than 0.", subtask.Name))
+		}
+
+		switch subtask.Scoring {
+		case "":
+			subtask.Scoring = models.ScoringAllOrNothing
+		case models.ScoringAllOrNothing, models.ScoringMin, models.ScoringSum:
+		default:
+			return commonerrors.NewValidationError("subtasks", fmt.Sprintf("Unsupported scoring policy: %s.", subtask.Scoring))
+		}
+
+		if len(subtask.CaseNames) == 0 {
+			return commonerrors.NewValidationError("subtasks", fmt.Sprintf("Subtask %s must contain at least one test case.", subtask.Name))
+		}
+		for _, caseName := range subtask.CaseNames {
+			if !caseNames[caseName] {
+				return commonerrors.NewValidationError("subtasks", fmt.Sprintf("Test case %s in subtask %s does not exist.", caseName, subtask.Name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateProblemRevealは，問題メタデータに含まれるサンプルケースと判定結果で公開する詳細の設定の妥当性を検証する．
+// サンプルケースと詳細を公開するテストケースの名前がアップロードされた入力ファイル名（拡張子の省略も可）と対応していること，
+// および標準エラー出力の公開範囲がサポートされていることを確認する．
+// 公開範囲が指定されていない場合は "revealed" を設定する．
+//
+// パラメータ:
+// - problem *models.Problem: 検証する問題．未指定の公開範囲には既定値が設定される．
+// - inputFileNames []string: アップロードされた入力ファイル名のリスト．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateProblemReveal(problem *models.Problem, inputFileNames []string) error {
+	switch problem.StderrVisibility {
+	case "":
+		problem.StderrVisibility = models.StderrVisibilityRevealed
+	case models.StderrVisibilityNone, models.StderrVisibilityRevealed, models.StderrVisibilityAll:
+	default:
+		return commonerrors.NewValidationError("stderr_visibility", fmt.Sprintf("Unsupported stderr visibility: %s.", problem.StderrVisibility))
+	}
+
+	caseNames := caseNameSet(inputFileNames)
+	for _, caseName := range problem.SampleCases {
+		if !caseNames[caseName] {
+			return commonerrors.NewValidationError("sample_cases", fmt.Sprintf("Test case %s does not exist.", caseName))
+		}
+	}
+	for _, caseName := range problem.RevealedCases {
+		if !caseNames[caseName] {
+			return commonerrors.NewValidationError("revealed_cases", fmt.Sprintf("Test case %s does not exist.", caseName))
+		}
+	}
+
+	return nil
+}
+
+// caseNameSetは，アップロードされた入力ファイル名から，テストケースの指定に用いることのできる名前（ファイル名と拡張子を除いた名前）の集合を作成する．
+func caseNameSet(inputFileNames []string) map[string]bool {
+	caseNames := make(map[string]bool)
+	for _, fileName := range inputFileNames {
+		caseNames[fileName] = true
+		caseNames[fileName[:len(fileName)-len(filepath.Ext(fileName))]] = true
+	}
+	return caseNames
+}
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"context"
+	"procon_web_service/src/common/minio"
+	"procon_web_service/src/common/models"
+	"sort"
+)
+
+const maxSampleFileSize = 64 << 10 // 問題の詳細に含めるサンプルケースの入出力の最大サイズ(バイト)
+
+// LoadProblemSamplesは，問題に設定されたサンプルケースの入出力をMinIOから読み込み，問題のSamplesに設定する．
+// サンプルケースは入力ファイル名の順に並べられ，入出力がそれぞれ64KBを超える場合は途中までの内容となる．
+//
+// パラメータ:
+// - ctx context.Context: 操作のコンテキスト．
+// - problem *models.Problem: サンプルケースを設定する問題．
+//
+// 戻り値:
+// - error: 読み込み中に発生したエラー．成功時はnil．
+func LoadProblemSamples(ctx context.Context, problem *models.Problem) error {
+	if len(problem.SampleCases) == 0 {
+		return nil
+	}
+
+	fileNames, err := minio.ListFileNames(ctx, problem.ProblemID, problem.TestSetVersion, "in")
+	if err != nil {
+		return err
+	}
+	sort.Strings(fileNames)
+
+	for _, fileName := range fileNames {
+		if !problem.IsSample(fileName) {
+			continue
+		}
+		input, inputTruncated, err := minio.ReadFileContent(ctx, problem.ProblemID, problem.TestSetVersion, "in", fileName, maxSampleFileSize)
+		if err != nil {
+			return err
+		}
+		output, outputTruncated, err := minio.ReadFileContent(ctx, problem.ProblemID, problem.TestSetVersion, "out", fileName, maxSampleFileSize)
+		if err != nil {
+			return err
+		}
+		problem.Samples = append(problem.Samples, models.SampleCase{
+			Name:      fileName,
+			Input:     string(input),
+			Output:    string(output),
+			Truncated: inputTruncated || outputTruncated,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"procon_web_service/src/common/config"
+	commonerrors "procon_web_service/src/common/errors"
+	"procon_web_service/src/common/models"
+	"strings"
+)
+
+const maxCustomRunStdinSize = 1 << 20 // カスタム実行で標準入力に与える内容の最大サイズ(バイト)
+
+// ValidateSolutionLanguageは，提出された解答の言語が提出を受け付けている言語であることを検証する．
+// 言語設定ファイルに定義されていない言語や，無効化された言語での提出は受け付けない．
+//
+// パラメータ:
+// - solution *models.Solution: 検証する解答．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateSolutionLanguage(solution *models.Solution) error {
+	langConfig, ok := config.GetLanguageConfigByID(solution.LanguageID)
+	if !ok || !langConfig.Enabled {
+		return commonerrors.NewValidationError("language_id", fmt.Sprintf("Unsupported language ID: %d.", solution.LanguageID))
+	}
+	return nil
+}
+
+// ValidateCustomRunは，カスタム実行の内容が実行可能であることを検証する．
+// コードの言語が提出を受け付けている言語であること，コードが空でないこと，および標準入力が1MB以下であることを確認する．
+//
+// パラメータ:
+// - run *models.CustomRun: 検証するカスタム実行の内容．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateCustomRun(run *models.CustomRun) error {
+	langConfig, ok := config.GetLanguageConfigByID(run.LanguageID)
+	if !ok || !langConfig.Enabled {
+		return commonerrors.NewValidationError("language_id", fmt.Sprintf("Unsupported language ID: %d.", run.LanguageID))
+	}
+	if strings.TrimSpace(run.Code) == "" {
+		return commonerrors.NewValidationError("code", "Code must not be empty.")
+	}
+	if len(run.Stdin) > maxCustomRunStdinSize {
+		return commonerrors.NewValidationError("stdin", fmt.Sprintf("Stdin must not exceed %d bytes.", maxCustomRunStdinSize))
+	}
+	return nil
+}
+
+// ValidateRejudgeRequestは，再判定の対象の条件の妥当性を検証する．
+// 提出日時の範囲は，開始と終了の両方が指定されている場合に開始が終了より前である必要がある．
+//
+// パラメータ:
+// - request *models.RejudgeRequest: 検証する再判定の対象の条件．
+// - requireRange bool: 提出日時の範囲（開始と終了の両方）の指定を必須とする場合はtrue．
+//
+// 戻り値:
+// - error: 検証に失敗した場合のエラー．成功時はnil．
+func ValidateRejudgeRequest(request *models.RejudgeRequest, requireRange bool) error {
+	if requireRange && (request.From == nil || request.To == nil) {
+		return commonerrors.NewValidationError("from/to", "Both from and to must be specified.")
+	}
+	if request.From != nil && request.To != nil && !request.From.Before(*request.To) {
+		return commonerrors.NewValidationError("from/to", "From must be before to.")
+	}
+	if request.ProblemID < 0 {
+		return commonerrors.NewValidationError("problem_id", fmt.Sprintf("Invalid problem ID: %d.", request.ProblemID))
+	}
+	return nil
+}
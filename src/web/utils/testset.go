@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"procon_web_service/src/common/minio"
+	"procon_web_service/src/common/models"
+	"procon_web_service/src/web/database"
+)
+
+// MigrateLegacyTestSetsは，テストデータの版を導入する前に作成された問題（版が0の問題）のテストデータを最初の版に移行する．
+// 問題ごとに，移行前の形式で保存されたファイルから最初の版のマニフェストを作成し，問題のテストデータの版を更新する．
+// 移行に失敗した問題は版が0のまま残り，次回の起動時に再び移行される．移行前の形式のファイルは削除しない．
+//
+// パラメータ:
+// - ctx context.Context: 操作のコンテキスト．
+// - db *sql.DB: データベース接続へのポインタ．
+//
+// 戻り値:
+// - error: 問題の一覧の取得に失敗した場合，またはいずれかの問題の移行に失敗した場合のエラー．成功時はnil．
+func MigrateLegacyTestSets(ctx context.Context, db *sql.DB) error {
+	problemIDs, err := database.SelectProblemIDsByTestSetVersion(db, 0)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, problemID := range problemIDs {
+		manifest, err := minio.MigrateLegacyTestSet(ctx, problemID)
+		if err == nil {
+			err = database.UpdateProblemTestSetVersion(db, problemID, 0, models.LegacyTestSetVersion)
+		}
+		if err != nil {
+			log.Printf("Failed to migrate legacy test set of problem %d: %v", problemID, err)
+			failed++
+			continue
+		}
+		log.Printf("Migrated legacy test set of problem %d to version %d (%d files)", problemID, models.LegacyTestSetVersion, len(manifest.Files))
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to migrate legacy test sets of %d of %d problems", failed, len(problemIDs))
+	}
+	return nil
+}